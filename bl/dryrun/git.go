@@ -0,0 +1,183 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/ejoffe/spr/bl/internal"
+	"github.com/ejoffe/spr/git"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/uuid"
+)
+
+// readOnlyCommands are the git commands that are run during a dry run, everything else is recorded
+var readOnlyCommands = []string{
+	"cat-file", "diff", "for-each-ref", "log", "ls-remote", "merge-base", "rev-list", "rev-parse", "show", "status",
+}
+
+// Git records the changes made to the local repository and the remote instead of making them.
+// Fetches are still made so the plan is computed against the current remote branches.
+type Git struct {
+	git.GitInterface
+	plan *Plan
+
+	// pushed maps the branches that would have been pushed to their commit hash
+	pushed map[string]string
+	// commitIds maps the hashes of the commits without a commit-id to the commit-id that would be added
+	commitIds map[string]string
+}
+
+// NewGit returns a git.GitInterface which records the mutations to the plan and passes reads to wrapped
+func NewGit(wrapped git.GitInterface, plan *Plan) *Git {
+	return &Git{
+		GitInterface: wrapped,
+		plan:         plan,
+		pushed:       map[string]string{},
+		commitIds:    map[string]string{},
+	}
+}
+
+// AppendCommitId records the commit-ids that would be added to the local commits.
+// The commits returned by UnMergedCommits have these commit-ids so the rest of the plan can refer to them.
+func (g *Git) AppendCommitId() error {
+	commits, err := g.GitInterface.UnMergedCommits(context.Background())
+	if err != nil {
+		return err
+	}
+
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+	// Oldest commit first
+	commits = internal.HeadFirst(commits)
+	for i := len(commits) - 1; i >= 0; i-- {
+		commit := commits[i]
+		hash := commit.Hash.String()
+		if internal.CommitId(commit.Message) != "" || g.commitIds[hash] != "" {
+			continue
+		}
+		g.commitIds[hash] = uuid.New().String()[:8]
+		g.plan.commitIds = append(g.plan.commitIds, commitId{
+			hash:    hash,
+			subject: internal.Subject(commit.Message),
+			id:      g.commitIds[hash],
+		})
+	}
+	return nil
+}
+
+func (g *Git) UnMergedCommits(ctx context.Context) ([]*object.Commit, error) {
+	commits, err := g.GitInterface.UnMergedCommits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+	for i, commit := range commits {
+		id, found := g.commitIds[commit.Hash.String()]
+		if !found {
+			continue
+		}
+		withId := *commit
+		withId.Message = strings.TrimRight(withId.Message, "\n") + "\n\ncommit-id:" + id + "\n"
+		commits[i] = &withId
+	}
+	return commits, nil
+}
+
+func (g *Git) Git(args string, output *string) error {
+	command := strings.SplitN(args, " ", 2)[0]
+	for _, readOnly := range readOnlyCommands {
+		if command == readOnly {
+			return g.GitInterface.Git(args, output)
+		}
+	}
+
+	if command == "push" {
+		var refspecs []string
+		for _, arg := range strings.Split(args, " ")[1:] {
+			if strings.Contains(arg, ":") {
+				refspecs = append(refspecs, arg)
+			}
+		}
+		if len(refspecs) > 0 {
+			return g.recordPush(refspecs)
+		}
+	}
+
+	g.plan.addOther("run git %s", args)
+	return nil
+}
+
+func (g *Git) MustGit(args string, output *string) {
+	err := g.Git(args, output)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (g *Git) GitWithEditor(args string, output *string, editorCmd string) error {
+	g.plan.addOther("run git %s", args)
+	return nil
+}
+
+func (g *Git) Push(remoteName string, refspecs []string) error {
+	return g.recordPush(refspecs)
+}
+
+// RecordCherryPick records the push of branchName with sha cherry-picked on destBranchRef. The cherry pick isn't made
+// so the branch is recorded as pointing to sha.
+func (g *Git) RecordCherryPick(branchName string, destBranchRef string, sha string) error {
+	return g.recordPush([]string{sha + ":refs/heads/" + branchName})
+}
+
+// recordPush records the branches that would be pushed and the commit they would point to
+func (g *Git) recordPush(refspecs []string) error {
+	for _, refspec := range refspecs {
+		src, dst, _ := strings.Cut(strings.TrimPrefix(refspec, "+"), ":")
+		// The PR set refs mirror the PR set state, which isn't part of the plan
+		if strings.HasPrefix(dst, "refs/") && !strings.HasPrefix(dst, "refs/heads/") {
+			continue
+		}
+		branch := strings.TrimPrefix(dst, "refs/heads/")
+
+		var out string
+		err := g.GitInterface.Git("log -1 --format=%H%x09%s "+src, &out)
+		if err != nil {
+			return fmt.Errorf("resolving %s %w", src, err)
+		}
+		hash, subject, _ := strings.Cut(out, "\t")
+
+		g.plan.lock.Lock()
+		g.pushed[branch] = hash
+		g.plan.pushes = append(g.plan.pushes, push{branch: branch, hash: hash, subject: subject})
+		g.plan.lock.Unlock()
+	}
+	return nil
+}
+
+func (g *Git) DeleteRemoteBranch(ctx context.Context, branch string) error {
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+
+	delete(g.pushed, branch)
+	g.plan.deletes = append(g.plan.deletes, branch)
+	return nil
+}
+
+// OriginBranchRef returns the commit a branch would point to if it was pushed earlier in the dry run
+func (g *Git) OriginBranchRef(ctx context.Context, branch string) (string, error) {
+	g.plan.lock.Lock()
+	hash, found := g.pushed[branch]
+	g.plan.lock.Unlock()
+	if found {
+		return hash, nil
+	}
+	return g.GitInterface.OriginBranchRef(ctx, branch)
+}
+
+func (g *Git) Rebase(ctx context.Context, remoteName, branchName string) error {
+	g.plan.addOther("rebase on %s/%s", remoteName, branchName)
+	return nil
+}
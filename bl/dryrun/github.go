@@ -0,0 +1,142 @@
+package dryrun
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/hosting"
+)
+
+// GitHub records the changes made to pull requests instead of making them.
+// Pull requests that would be created are given the numbers following the highest existing pull request number.
+type GitHub struct {
+	hosting.Client
+	plan *Plan
+}
+
+// NewGitHub returns a hosting.Client which records the mutations to the plan and passes reads to wrapped
+func NewGitHub(wrapped hosting.Client, plan *Plan) *GitHub {
+	return &GitHub{
+		Client: wrapped,
+		plan:   plan,
+	}
+}
+
+func (g *GitHub) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
+	info, err := g.Client.GetInfo(ctx, gitcmd)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, pr := range info.PullRequests {
+		g.plan.addPullRequest(pr.Number, pullRequest{
+			Title:       pr.Title,
+			Body:        pr.Body,
+			BaseRefName: pr.ToBranch,
+			HeadRefName: pr.FromBranch,
+		})
+	}
+	return info, nil
+}
+
+func (g *GitHub) PullRequestsAndStatus(ctx context.Context, repoOwner string, repoName string) (*hosting.Repository, error) {
+	repo, err := g.Client.PullRequestsAndStatus(ctx, repoOwner, repoName)
+	if err != nil || repo == nil {
+		return repo, err
+	}
+
+	for _, node := range repo.PullRequests {
+		g.plan.addPullRequest(node.Number, pullRequest{
+			Title:       node.Title,
+			Body:        node.Body,
+			BaseRefName: node.BaseRefName,
+			HeadRefName: node.HeadRefName,
+		})
+	}
+	return repo, nil
+}
+
+func (g *GitHub) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface, info *hosting.Info,
+	commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
+	g.plan.lock.Lock()
+	number := g.plan.nextNumber
+	g.plan.nextNumber++
+	g.plan.lock.Unlock()
+
+	g.plan.addOther("create pull request #%d for %s : %s", number, commit.CommitID, commit.Subject)
+	return &hosting.PullRequest{
+		Id:     fmt.Sprintf("dry-run-%d", number),
+		Number: number,
+		Commit: commit,
+		Title:  commit.Subject,
+	}, nil
+}
+
+func (g *GitHub) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+
+	number := g.plan.nextNumber
+	g.plan.nextNumber++
+	g.plan.planned[number] = &pullRequest{
+		Title:       pull.Title,
+		Body:        pull.Body,
+		BaseRefName: pull.BaseRefName,
+		HeadRefName: pull.HeadRefName,
+	}
+	g.plan.created = append(g.plan.created, number)
+	return fmt.Sprintf("dry-run-%d", number), number, nil
+}
+
+func (g *GitHub) EditPullRequest2(ctx context.Context, owner string, repo string, number int, edit hosting.PullRequestEdit) error {
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+
+	pr, found := g.plan.planned[number]
+	if !found {
+		return fmt.Errorf("pull request #%d not found", number)
+	}
+	if edit.Title != nil {
+		pr.Title = *edit.Title
+	}
+	if edit.Body != nil {
+		pr.Body = *edit.Body
+	}
+	if edit.BaseRefName != nil {
+		pr.BaseRefName = *edit.BaseRefName
+	}
+	if edit.State != nil && *edit.State == hosting.PullRequestStateClosed {
+		g.plan.closed = append(g.plan.closed, number)
+	}
+	return nil
+}
+
+func (g *GitHub) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest,
+	pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
+	g.plan.addOther("update pull request #%d for %s : %s", pr.Number, commit.CommitID, commit.Subject)
+	return nil
+}
+
+func (g *GitHub) AddReviewers(ctx context.Context, pr *hosting.PullRequest, userIDs []string) error {
+	g.plan.addOther("add reviewers %v to pull request #%d", userIDs, pr.Number)
+	return nil
+}
+
+func (g *GitHub) CommentPullRequest(ctx context.Context, pr *hosting.PullRequest, comment string) error {
+	g.plan.addOther("comment on pull request #%d : %s", pr.Number, comment)
+	return nil
+}
+
+func (g *GitHub) MergePullRequest(ctx context.Context, pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
+	g.plan.addOther("merge pull request #%d with %s", pr.Number, mergeMethod)
+	return nil
+}
+
+func (g *GitHub) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
+	g.plan.lock.Lock()
+	defer g.plan.lock.Unlock()
+
+	g.plan.closed = append(g.plan.closed, pr.Number)
+	return nil
+}
@@ -0,0 +1,155 @@
+// Package dryrun records the changes a command would make instead of making them.
+// The Git and GitHub types wrap a git.GitInterface and hosting.Client, reads are passed through while all of
+// the mutations are added to a Plan which can be printed for review.
+package dryrun
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/ejoffe/spr/output"
+)
+
+// Plan is the list of changes recorded during a dry run
+type Plan struct {
+	lock sync.Mutex
+
+	commitIds []commitId
+	pushes    []push
+	deletes   []string
+
+	// original is the state of the existing pull requests and planned is their state after the recorded edits
+	original   map[int]pullRequest
+	planned    map[int]*pullRequest
+	created    []int
+	closed     []int
+	nextNumber int
+
+	// other holds the descriptions of the remaining changes in the order they were recorded
+	other []string
+}
+
+type commitId struct {
+	hash    string
+	subject string
+	id      string
+}
+
+type push struct {
+	branch  string
+	hash    string
+	subject string
+}
+
+type pullRequest struct {
+	Title       string
+	Body        string
+	BaseRefName string
+	HeadRefName string
+}
+
+// NewPlan returns an empty plan
+func NewPlan() *Plan {
+	return &Plan{
+		original:   map[int]pullRequest{},
+		planned:    map[int]*pullRequest{},
+		nextNumber: 1,
+	}
+}
+
+// Empty returns true if nothing would be changed
+func (p *Plan) Empty() bool {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	return len(p.commitIds) == 0 && len(p.pushes) == 0 && len(p.deletes) == 0 && len(p.created) == 0 &&
+		len(p.closed) == 0 && len(p.other) == 0 && len(p.edited()) == 0
+}
+
+// Print outputs the plan, each change is printed separately
+func (p *Plan) Print(printer output.Printer) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for _, c := range p.commitIds {
+		printer.Printf("add commit-id:%s to %s : %s\n", c.id, c.hash[:8], c.subject)
+	}
+	for _, push := range p.pushes {
+		printer.Printf("push %s (%s : %s)\n", push.branch, push.hash[:8], push.subject)
+	}
+	for _, number := range p.created {
+		pr := p.planned[number]
+		printer.Printf("create pull request #%d %s -> %s : %s\n%s",
+			number, pr.HeadRefName, pr.BaseRefName, pr.Title, indent(pr.Body))
+	}
+	for _, number := range p.edited() {
+		original, pr := p.original[number], p.planned[number]
+		if original.BaseRefName != pr.BaseRefName {
+			printer.Printf("retarget pull request #%d from %s to %s\n", number, original.BaseRefName, pr.BaseRefName)
+		}
+		if original.Title != pr.Title {
+			printer.Printf("retitle pull request #%d from %q to %q\n", number, original.Title, pr.Title)
+		}
+		if original.Body != pr.Body {
+			printer.Printf("rewrite body of pull request #%d\n%s", number, indent(pr.Body))
+		}
+	}
+	slices.Sort(p.closed)
+	for _, number := range p.closed {
+		printer.Printf("close pull request #%d : %s\n", number, p.original[number].Title)
+	}
+	slices.Sort(p.deletes)
+	for _, branch := range p.deletes {
+		printer.Printf("delete branch %s\n", branch)
+	}
+	for _, other := range p.other {
+		printer.Printf("%s\n", other)
+	}
+}
+
+// edited returns the numbers of the existing pull requests that have recorded changes
+func (p *Plan) edited() []int {
+	var numbers []int
+	for number, original := range p.original {
+		pr := p.planned[number]
+		if pr != nil && *pr != original && !slices.Contains(p.closed, number) {
+			numbers = append(numbers, number)
+		}
+	}
+	slices.Sort(numbers)
+	return numbers
+}
+
+// addPullRequest records the current state of an existing pull request
+func (p *Plan) addPullRequest(number int, pr pullRequest) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if _, found := p.original[number]; found {
+		return
+	}
+	p.original[number] = pr
+	p.planned[number] = &pr
+	p.nextNumber = max(p.nextNumber, number+1)
+}
+
+func (p *Plan) addOther(format string, a ...any) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.other = append(p.other, fmt.Sprintf(format, a...))
+}
+
+func indent(body string) string {
+	if body == "" {
+		return ""
+	}
+
+	var b strings.Builder
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		b.WriteString("    " + line + "\n")
+	}
+	return b.String()
+}
@@ -6,31 +6,28 @@ import (
 	"io"
 	"os"
 	"path"
-	"strconv"
 	"strings"
 
 	"github.com/ejoffe/spr/bl/ptrutils"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
 	"github.com/ejoffe/spr/git/realgit"
-	"github.com/ejoffe/spr/github"
 	"github.com/ejoffe/spr/github/githubclient"
-	"github.com/ejoffe/spr/github/githubclient/genqlient"
-	gogithub "github.com/google/go-github/v69/github"
+	"github.com/ejoffe/spr/hosting"
 )
 
 type GitApi struct {
 	config *config.Config
 	gitcmd git.GitInterface
-	github github.GitHubInterface
+	github hosting.Client
 }
 
-func New(config *config.Config, gitcmd git.GitInterface, github github.GitHubInterface) GitApi {
+func New(config *config.Config, gitcmd git.GitInterface, github hosting.Client) GitApi {
 	return GitApi{config: config, gitcmd: gitcmd, github: github}
 }
 
 // DeletePullRequest deletes the pull request and the associated branch
-func (gapi GitApi) DeletePullRequest(ctx context.Context, pr *github.PullRequest) error {
+func (gapi GitApi) DeletePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
 	err := gapi.github.ClosePullRequest(ctx, pr)
 	if err != nil {
 		return fmt.Errorf("deleting pr %d %w", pr.Number, err)
@@ -39,23 +36,33 @@ func (gapi GitApi) DeletePullRequest(ctx context.Context, pr *github.PullRequest
 	return gapi.gitcmd.DeleteRemoteBranch(ctx, pr.FromBranch)
 }
 
+// cherryPickRecorder is implemented by a git.GitInterface that records the branch a cherry pick would push instead of
+// making it, i.e. a dry run
+type cherryPickRecorder interface {
+	RecordCherryPick(branchName string, destBranchRef string, sha string) error
+}
+
 // CreateRemoteBranchWithCherryPick creates the remote branch `branchname` on `destBranchRef` and cherry-picks the sha
 // on it. Returns a reference to the new branch.
 func (gapi GitApi) CreateRemoteBranchWithCherryPick(ctx context.Context, branchName string, destBranchName string, sha string) error {
-	// The "github.com/go-git/go-git/" doesn't support cherry picks so we
-	//have to do this by shelling out to the command line
-	gitshell := realgit.NewGitCmd(gapi.config)
-
 	destBranchRefName, err := gapi.gitcmd.OriginBranchRef(ctx, destBranchName)
 	if err != nil {
 		return fmt.Errorf("getting the ref for %s %w", destBranchName, err)
 	}
 
+	// A dry run doesn't create the worktree or cherry pick
+	if recorder, ok := gapi.gitcmd.(cherryPickRecorder); ok {
+		return recorder.RecordCherryPick(branchName, destBranchRefName, sha)
+	}
+
+	// The "github.com/go-git/go-git/" doesn't support cherry picks so we
+	//have to do this by shelling out to the command line
+	gitshell := realgit.NewGitCmd(gapi.config)
+
 	// cleanup code
 	cleanup := struct {
 		dir      string
 		worktree string
-		branch   string
 	}{}
 	defer func() {
 		if cleanup.worktree != "" {
@@ -63,10 +70,6 @@ func (gapi GitApi) CreateRemoteBranchWithCherryPick(ctx context.Context, branchN
 			gitshell.Git(fmt.Sprintf("worktree prune"), nil)
 		}
 
-		if cleanup.branch != "" {
-			gitshell.Git(fmt.Sprintf("branch -D %s", branchName), nil)
-		}
-
 		if cleanup.dir != "" {
 			os.RemoveAll(cleanup.dir)
 		}
@@ -79,8 +82,8 @@ func (gapi GitApi) CreateRemoteBranchWithCherryPick(ctx context.Context, branchN
 	}
 	cleanup.dir = tempDir
 
-	// Create the worktree
-	err = gitshell.Git(fmt.Sprintf("worktree add %s %s", tempDir, destBranchRefName), nil)
+	// Create the worktree, it is detached at destBranchRefName so no local branch is created (or removed)
+	err = gitshell.Git(fmt.Sprintf("worktree add --detach %s %s", tempDir, destBranchRefName), nil)
 	if err != nil {
 		return fmt.Errorf("creating the worktree in %s %w", tempDir, err)
 	}
@@ -90,15 +93,6 @@ func (gapi GitApi) CreateRemoteBranchWithCherryPick(ctx context.Context, branchN
 	gitworktreeshell := realgit.NewGitCmd(gapi.config)
 	gitworktreeshell.SetRootDir(tempDir)
 
-	// Create the local branch if it doesn't already exist
-	if branchExists, _ := gapi.gitcmd.BranchExists(branchName); !branchExists {
-		err = gitworktreeshell.Git(fmt.Sprintf("checkout -b %s %s", branchName, destBranchRefName), nil)
-		if err != nil {
-			return fmt.Errorf("creating the branch %s in worktree %s %w", branchName, tempDir, err)
-		}
-	}
-	cleanup.branch = branchName
-
 	// Cherry pick commit over to this branch.
 	// Output a meaningful error message if we can't apply the cherry-pick
 	output := ""
@@ -111,10 +105,18 @@ func (gapi GitApi) CreateRemoteBranchWithCherryPick(ctx context.Context, branchN
 		return fmt.Errorf("cherry picking %s into %s in worktree %s %w", sha, branchName, tempDir, err)
 	}
 
-	// Push the branch up to the remote
-	err = gitworktreeshell.Git(fmt.Sprintf("push --force %s %s:%s", gapi.config.Repo.GitHubRemote, branchName, branchName), nil)
+	var cherryPicked string
+	err = gitworktreeshell.Git("rev-parse HEAD", &cherryPicked)
 	if err != nil {
-		return fmt.Errorf("pushing %s to %s %w", branchName, gapi.config.Repo.GitHubRemote, err)
+		return fmt.Errorf("resolving the cherry pick of %s in worktree %s %w", sha, tempDir, err)
+	}
+
+	// Push the cherry pick up to the remote branch. The worktree shares its objects with the repository so the push
+	// goes through gitcmd.
+	remote := gapi.config.Repo.PushRemoteName()
+	err = gapi.gitcmd.Git(fmt.Sprintf("push --force %s %s:refs/heads/%s", remote, strings.TrimSpace(cherryPicked), branchName), nil)
+	if err != nil {
+		return fmt.Errorf("pushing %s to %s %w", branchName, remote, err)
 	}
 
 	return nil
@@ -126,7 +128,7 @@ func (gapi GitApi) CreatePullRequest(
 	repositoryId string,
 	commit git.Commit,
 	prevCommit *git.Commit,
-) (*github.PullRequest, error) {
+) (*hosting.PullRequest, error) {
 
 	headRefName, baseRefName := gapi.getBranches(commit, prevCommit)
 
@@ -138,7 +140,7 @@ func (gapi GitApi) CreatePullRequest(
 	owner := gapi.config.Repo.GitHubRepoOwner
 	repoName := gapi.config.Repo.GitHubRepoName
 
-	prInput := genqlient.CreatePullRequestInput{
+	prInput := hosting.NewPullRequest{
 		HeadRepositoryId: headRepositoryId,
 		RepositoryId:     repositoryId,
 		Title:            commit.Subject,
@@ -147,21 +149,25 @@ func (gapi GitApi) CreatePullRequest(
 		Body:             body,
 		Draft:            false, // We always create draft PRs then we do an update (to link them together) with an update.
 	}
+	if gapi.config.Repo.ForkWorkflow() {
+		prInput.HeadOwner = gapi.config.Repo.PushRepoOwner
+		prInput.HeadName = gapi.config.Repo.PushRepoName
+	}
 
 	prId, prNumber, err := gapi.github.CreatePullRequest2(ctx, owner, repoName, prInput)
 	if err != nil {
 		return nil, fmt.Errorf("creating PR for commit %s: %w", commit.CommitHash, err)
 	}
 
-	pr := &github.PullRequest{
+	pr := &hosting.PullRequest{
 		Id:         prId,
 		Number:     prNumber,
 		FromBranch: headRefName,
 		ToBranch:   baseRefName,
 		Commit:     commit,
 		Title:      commit.Subject,
-		MergeStatus: github.PullRequestMergeStatus{
-			ChecksPass:     github.CheckStatusUnknown,
+		MergeStatus: hosting.PullRequestMergeStatus{
+			ChecksPass:     hosting.CheckStatusUnknown,
 			ReviewApproved: false,
 			NoConflicts:    false,
 			Stacked:        false,
@@ -176,8 +182,8 @@ func (gapi GitApi) CreatePullRequest(
 // prevCommit is used to compute the destination branch name.
 func (gapi GitApi) UpdatePullRequest(
 	ctx context.Context,
-	pullRequests []*github.PullRequest,
-	pr *github.PullRequest,
+	pullRequests []*hosting.PullRequest,
+	pr *hosting.PullRequest,
 	commit git.Commit,
 	prevCommit *git.Commit,
 ) error {
@@ -188,8 +194,8 @@ func (gapi GitApi) UpdatePullRequest(
 // pullRequests is used to create links to related pull requests
 func (gapi GitApi) UpdatePullRequestToMain(
 	ctx context.Context,
-	pullRequests []*github.PullRequest,
-	pr *github.PullRequest,
+	pullRequests []*hosting.PullRequest,
+	pr *hosting.PullRequest,
 	commit git.Commit,
 ) error {
 	return gapi.updatePullRequest(ctx, pullRequests, pr, commit, nil)
@@ -200,8 +206,8 @@ func (gapi GitApi) UpdatePullRequestToMain(
 // prevCommit is used to compute the destination branch name. If it is nil main/master will be used
 func (gapi GitApi) updatePullRequest(
 	ctx context.Context,
-	pullRequests []*github.PullRequest,
-	pr *github.PullRequest,
+	pullRequests []*hosting.PullRequest,
+	pr *hosting.PullRequest,
 	commit git.Commit,
 	prevCommit *git.Commit,
 ) error {
@@ -214,28 +220,21 @@ func (gapi GitApi) updatePullRequest(
 		return fmt.Errorf("getting body %w", err)
 	}
 
-	id, err := strconv.ParseInt(pr.DatabaseId, 10, 64)
-	if err != nil {
-		return fmt.Errorf("converting ID %s to integer %w", pr.Id, err)
-	}
-
 	title := &commit.Subject
 	owner := gapi.config.Repo.GitHubRepoOwner
 	repoName := gapi.config.Repo.GitHubRepoName
-
-	head := gogithub.PullRequestBranch{
-		Ref: ptrutils.Ptr(headRefName),
-	}
-	base := gogithub.PullRequestBranch{
-		Ref: ptrutils.Ptr(baseRefName),
+	// A draft pull request stays a draft, the hosting provider only makes it one if it isn't yet
+	var draft *bool
+	if gapi.config.User.CreateDraftPRs {
+		draft = ptrutils.Ptr(true)
 	}
-	err = gapi.github.EditPullRequest2(ctx, owner, repoName, pr.Number, &gogithub.PullRequest{
-		ID:    &id,
-		Title: title,
-		Body:  &body,
-		Draft: &gapi.config.User.CreateDraftPRs,
-		Head:  &head,
-		Base:  &base,
+
+	err = gapi.github.EditPullRequest2(ctx, owner, repoName, pr.Number, hosting.PullRequestEdit{
+		Title:       title,
+		Body:        &body,
+		Draft:       draft,
+		HeadRefName: ptrutils.Ptr(headRefName),
+		BaseRefName: ptrutils.Ptr(baseRefName),
 	})
 	if err != nil {
 		return fmt.Errorf("updating PR for commit %s: %w", commit.CommitHash, err)
@@ -246,12 +245,12 @@ func (gapi GitApi) updatePullRequest(
 
 func (gapi GitApi) MergePullRequest(
 	ctx context.Context,
-	pr *github.PullRequest,
+	pr *hosting.PullRequest,
 ) error {
 	// Get the merge method
 	mergeMethod := gapi.config.Repo.MergeMethod
 
-	err := gapi.github.MergePullRequest(ctx, pr, genqlient.PullRequestMergeMethod(strings.ToUpper(mergeMethod)))
+	err := gapi.github.MergePullRequest(ctx, pr, hosting.MergeMethod(strings.ToUpper(mergeMethod)))
 	if err != nil {
 		return fmt.Errorf("unable to merge %d %w", pr.Number, err)
 	}
@@ -259,10 +258,12 @@ func (gapi GitApi) MergePullRequest(
 	return nil
 }
 
-// getBranches returns the head and base branch ref names
+// getBranches returns the head and base branch ref names.
+// The branches of a fork can't be the base of a pull request on the upstream repository, so with a fork every pull
+// request is based on the default branch and includes the commits of the pull requests below it.
 func (gapi GitApi) getBranches(commit git.Commit, prevCommit *git.Commit) (string, string) {
 	baseRefName := gapi.config.Repo.GitHubBranch
-	if prevCommit != nil {
+	if prevCommit != nil && !gapi.config.Repo.ForkWorkflow() {
 		baseRefName = git.BranchNameFromCommit(gapi.config, *prevCommit)
 	}
 	headRefName := git.BranchNameFromCommit(gapi.config, commit)
@@ -270,7 +271,7 @@ func (gapi GitApi) getBranches(commit git.Commit, prevCommit *git.Commit) (strin
 	return headRefName, baseRefName
 }
 
-func (gapi GitApi) getBody(commit git.Commit, pullRequests []*github.PullRequest) (string, error) {
+func (gapi GitApi) getBody(commit git.Commit, pullRequests []*hosting.PullRequest) (string, error) {
 	body := githubclient.FormatBody(commit, pullRequests, gapi.config.Repo.ShowPrTitlesInStack)
 	if gapi.config.Repo.PRTemplatePath == "" {
 		return body, nil
@@ -0,0 +1,146 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+)
+
+// PRSetRefPrefix is the prefix of the refs on the remote that record the PR set of each commit.
+// A commit in PR set N has the ref refs/spr/prsets/<stack>/sN/<commit-id> pointing at it, so the PR sets survive moving
+// to another machine or losing the state file. The stack is the escaped Config.PRSetKey, the stacks on other branches
+// have their own refs.
+const PRSetRefPrefix = "refs/spr/prsets/"
+
+var prSetRefRegex = regexp.MustCompile(`^s(\d+)/([a-f0-9]{8})$`)
+
+// prSetRefStack returns the prefix of the PR set refs of the stack
+func prSetRefStack(config *config.Config) string {
+	return PRSetRefPrefix + url.QueryEscape(config.PRSetKey()) + "/"
+}
+
+// PRSetRef returns the ref that records the commit is in the PR set of the stack
+func PRSetRef(config *config.Config, commitId string, prIndex int) string {
+	return fmt.Sprintf("%ss%d/%s", prSetRefStack(config), prIndex, commitId)
+}
+
+// ParsePRSetRef returns the commit-id and PR set index of a PR set ref of the stack
+func ParsePRSetRef(config *config.Config, ref string) (string, int, bool) {
+	ref, found := strings.CutPrefix(ref, prSetRefStack(config))
+	if !found {
+		return "", 0, false
+	}
+	matches := prSetRefRegex.FindStringSubmatch(ref)
+	if matches == nil {
+		return "", 0, false
+	}
+	prIndex, err := strconv.Atoi(matches[1])
+	if err != nil {
+		return "", 0, false
+	}
+	return matches[2], prIndex, true
+}
+
+// ReadPRSetRefs returns the mapping of commit-id to PR set index recorded on the push remote for the stack. The refs
+// saved in the state are used while it knows all the local commits, they are only listed on the remote when the state
+// doesn't have them or a commit was made or pulled since they were saved.
+func ReadPRSetRefs(config *config.Config, gitcmd git.GitInterface, gitCommits []*LocalCommit) (map[string]int, error) {
+	prSets := config.PRSetRefs()
+	if prSets == nil {
+		return listPRSetRefs(config, gitcmd)
+	}
+	known := config.StackCommitIds()
+	for _, gitCommit := range gitCommits {
+		if !known[gitCommit.CommitID] {
+			return listPRSetRefs(config, gitcmd)
+		}
+	}
+	return prSets, nil
+}
+
+// listPRSetRefs lists the PR set refs of the stack on the push remote and saves them in the state
+func listPRSetRefs(config *config.Config, gitcmd git.GitInterface) (map[string]int, error) {
+	var output string
+	err := gitcmd.Git(fmt.Sprintf("ls-remote %s %s*", config.Repo.PushRemoteName(), prSetRefStack(config)), &output)
+	if err != nil {
+		return nil, fmt.Errorf("listing the PR set refs %w", err)
+	}
+
+	prSets := map[string]int{}
+	for _, line := range strings.Split(output, "\n") {
+		_, ref, _ := strings.Cut(line, "\t")
+		if commitId, prIndex, ok := ParsePRSetRef(config, ref); ok {
+			prSets[commitId] = prIndex
+		}
+	}
+	config.SetPRSetRefs(prSets)
+	return prSets, nil
+}
+
+// PRSetRefspecs returns the refspecs that bring the PR set refs on the remote in line with the PR sets of the local
+// commits. Refs of commits that are no longer local are deleted if the commit still has a pull request, as it was
+// orphaned and its pull request will be closed.
+func (s *State) PRSetRefspecs(config *config.Config) []string {
+	var refspecs []string
+	local := map[string]bool{}
+	for _, commit := range s.LocalCommits {
+		local[commit.CommitID] = true
+		stored, found := s.PRSetRefs[commit.CommitID]
+		switch {
+		case commit.PRIndex == nil && found:
+			refspecs = append(refspecs, ":"+PRSetRef(config, commit.CommitID, stored))
+		case commit.PRIndex != nil && !found:
+			refspecs = append(refspecs, commit.CommitHash+":"+PRSetRef(config, commit.CommitID, *commit.PRIndex))
+		case commit.PRIndex != nil && *commit.PRIndex != stored:
+			refspecs = append(refspecs, ":"+PRSetRef(config, commit.CommitID, stored))
+			refspecs = append(refspecs, commit.CommitHash+":"+PRSetRef(config, commit.CommitID, *commit.PRIndex))
+		}
+	}
+	for commitId, stored := range s.PRSetRefs {
+		if !local[commitId] {
+			refspecs = append(refspecs, ":"+PRSetRef(config, commitId, stored))
+		}
+	}
+	slices.Sort(refspecs)
+	return refspecs
+}
+
+// PushPRSetRefs updates the PR set refs on the remote to match the PR sets of the local commits and saves them in the
+// state
+func (s *State) PushPRSetRefs(config *config.Config, gitcmd git.GitInterface) error {
+	refspecs := s.PRSetRefspecs(config)
+	if len(refspecs) == 0 {
+		return nil
+	}
+
+	remote := config.Repo.PushRemoteName()
+	err := gitcmd.Git(fmt.Sprintf("push --force %s %s", remote, strings.Join(refspecs, " ")), nil)
+	if err != nil {
+		return fmt.Errorf("pushing the PR set refs to %s %w", remote, err)
+	}
+
+	// The refs of the commits the stack doesn't know are left as they were
+	saved := maps.Clone(config.PRSetRefs())
+	if saved == nil {
+		saved = map[string]int{}
+	}
+	for commitId := range s.PRSetRefs {
+		delete(saved, commitId)
+	}
+	s.PRSetRefs = map[string]int{}
+	for _, commit := range s.LocalCommits {
+		if commit.PRIndex != nil {
+			s.PRSetRefs[commit.CommitID] = *commit.PRIndex
+		}
+	}
+	maps.Copy(saved, s.PRSetRefs)
+	config.SetPRSetRefs(saved)
+	return nil
+}
@@ -0,0 +1,116 @@
+package internal_test
+
+import (
+	"testing"
+
+	"github.com/ejoffe/spr/bl/internal"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/git/mockgit"
+	"github.com/ejoffe/spr/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// prSetRefsConfig is the config of the stack of the feature branch on main
+func prSetRefsConfig() *config.Config {
+	cfg := config.EmptyConfig()
+	cfg.Repo.GitHubHost = "github.com"
+	cfg.Repo.GitHubRepoOwner = "owner"
+	cfg.Repo.GitHubRepoName = "repo"
+	cfg.Repo.GitHubRemote = "origin"
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.LocalBranch = "feature/x"
+	return cfg
+}
+
+const prSetRefStack = "refs/spr/prsets/github.com%2Fowner%2Frepo%3Amain%3Afeature%2Fx/"
+
+func TestParsePRSetRef(t *testing.T) {
+	cfg := prSetRefsConfig()
+	require.Equal(t, prSetRefStack+"s12/0123abcd", internal.PRSetRef(cfg, "0123abcd", 12))
+	commitId, prIndex, ok := internal.ParsePRSetRef(cfg, internal.PRSetRef(cfg, "0123abcd", 12))
+	require.True(t, ok)
+	require.Equal(t, "0123abcd", commitId)
+	require.Equal(t, 12, prIndex)
+
+	other := prSetRefsConfig()
+	other.Repo.LocalBranch = "other"
+	for _, ref := range []string{
+		"refs/heads/spr/main/0123abcd",
+		"refs/spr/prsets/s1/0123abcd",
+		prSetRefStack + "0123abcd",
+		prSetRefStack + "s1/0123",
+		prSetRefStack + "sx/0123abcd",
+		// The refs of other stacks
+		internal.PRSetRef(other, "0123abcd", 1),
+		prSetRefStack + "s1/s1/0123abcd",
+	} {
+		_, _, ok := internal.ParsePRSetRef(cfg, ref)
+		require.False(t, ok, ref)
+	}
+}
+
+func TestReadPRSetRefs(t *testing.T) {
+	cfg := prSetRefsConfig()
+	expectations := mock.New(t, true)
+	gitmock := mockgit.NewMockGit(expectations)
+	gitCommits := []*internal.LocalCommit{{Commit: git.Commit{CommitID: "11111111"}}}
+
+	// The refs are listed when the state doesn't have them
+	expectations.ExpectGit("git ls-remote origin "+prSetRefStack+"*", mock.StringOutputter(
+		"hash11111111\t"+prSetRefStack+"s1/11111111\n"+
+			"hash22222222\trefs/spr/prsets/other/s0/22222222\n"))
+	prSetRefs, err := internal.ReadPRSetRefs(cfg, gitmock, gitCommits)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"11111111": 1}, prSetRefs)
+	require.Equal(t, map[string]int{"11111111": 1}, cfg.PRSetRefs())
+	gitmock.ExpectationsMet()
+
+	// The saved refs are used while the state knows the local commits
+	cfg.SetStackCommitIds(map[string]bool{"11111111": true})
+	prSetRefs, err = internal.ReadPRSetRefs(cfg, gitmock, gitCommits)
+	require.NoError(t, err)
+	require.Equal(t, map[string]int{"11111111": 1}, prSetRefs)
+	gitmock.ExpectationsMet()
+
+	// A commit the state doesn't know lists them again
+	gitCommits = append(gitCommits, &internal.LocalCommit{Commit: git.Commit{CommitID: "33333333"}})
+	expectations.ExpectGit("git ls-remote origin "+prSetRefStack+"*", mock.StringOutputter(""))
+	prSetRefs, err = internal.ReadPRSetRefs(cfg, gitmock, gitCommits)
+	require.NoError(t, err)
+	require.Empty(t, prSetRefs)
+	require.NotNil(t, cfg.PRSetRefs())
+	gitmock.ExpectationsMet()
+}
+
+func TestPRSetRefspecs(t *testing.T) {
+	commit := func(id string, prIndex *int) *internal.LocalCommit {
+		return &internal.LocalCommit{
+			Commit:  git.Commit{CommitID: id, CommitHash: "hash" + id},
+			PRIndex: prIndex,
+		}
+	}
+	state := internal.State{
+		LocalCommits: []*internal.LocalCommit{
+			commit("11111111", ptrutils.Ptr(0)),
+			commit("22222222", ptrutils.Ptr(1)),
+			commit("33333333", nil),
+			commit("44444444", ptrutils.Ptr(1)),
+		},
+		PRSetRefs: map[string]int{
+			"11111111": 0,
+			"22222222": 0,
+			"33333333": 2,
+			"99999999": 3,
+		},
+	}
+
+	require.Equal(t, []string{
+		":" + prSetRefStack + "s0/22222222",
+		":" + prSetRefStack + "s2/33333333",
+		":" + prSetRefStack + "s3/99999999",
+		"hash22222222:" + prSetRefStack + "s1/22222222",
+		"hash44444444:" + prSetRefStack + "s1/44444444",
+	}, state.PRSetRefspecs(prSetRefsConfig()))
+}
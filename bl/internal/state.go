@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"iter"
+	"maps"
 	"regexp"
 	"slices"
 	"strings"
@@ -14,19 +15,18 @@ import (
 	"github.com/ejoffe/spr/bl/ptrutils"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github"
-	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/go-git/go-git/v5/plumbing/object"
 )
 
 // A LocalCommit is a commit its associated Pull Request, and metadata.
-// Note that the github.PullRequest also might have a copy of the commit and that commit will have different hash as it
+// Note that the hosting.PullRequest also might have a copy of the commit and that commit will have different hash as it
 // when is pushed to the remote repo.
 type LocalCommit struct {
 	git.Commit
 
 	// The pull request that has this commit at the top
-	PullRequest *github.PullRequest
+	PullRequest *hosting.PullRequest
 
 	// The index is a simple way of referring to a commit. Child commits have larger indices.
 	Index int
@@ -39,6 +39,7 @@ type LocalCommit struct {
 // Indices is a list of commit indices and the destination pull request set index
 type Indices struct {
 	DestinationPRIndex *int            // Matches LocalCommit.PRIndex
+	DestinationName    string          // Names the destination PR set if it isn't empty
 	CommitIndexes      mapset.Set[int] // Matches LocalCommit.Index
 }
 
@@ -48,25 +49,29 @@ type State struct {
 	RepositoryId       string
 	// The 0th commit in this slice is the HEAD commit
 	LocalCommits  []*LocalCommit
-	OrphanedPRs   mapset.Set[*github.PullRequest]
+	OrphanedPRs   mapset.Set[*hosting.PullRequest]
 	MutatedPRSets mapset.Set[int]
+	// PRSetRefs maps the commit-ids of the local commits and their pull requests to the PR set recorded on the remote
+	PRSetRefs map[string]int
+	// PRSetNames maps the names of the named PR sets to their index
+	PRSetNames map[string]int
 }
 
 func indexColor(i *int) string {
 	if i == nil {
-		return github.ColorBlue
+		return hosting.ColorBlue
 	}
 	switch *i % 4 {
 	case 0:
-		return github.ColorRed
+		return hosting.ColorRed
 	case 1:
-		return github.ColorGreen
+		return hosting.ColorGreen
 	case 2:
-		return github.ColorBlue
+		return hosting.ColorBlue
 	case 3:
-		return github.ColorLightBlue
+		return hosting.ColorLightBlue
 	}
-	return github.ColorReset
+	return hosting.ColorReset
 }
 
 func padNumber(pad int) func(string) string {
@@ -103,7 +108,7 @@ func FormatSubject(subject string) string {
 
 func (prc LocalCommit) PRSetString(config *config.Config) string {
 	noPrMessage := "No Pull Request Created"
-	empty := github.StatusBitIcons(config)["empty"]
+	empty := hosting.StatusBitIcons(config)["empty"]
 
 	prString := fmt.Sprintf("[%s%s%s%s] %s : %s",
 		empty,
@@ -116,36 +121,39 @@ func (prc LocalCommit) PRSetString(config *config.Config) string {
 
 	if prc.PullRequest != nil {
 		padding := padNumber(5)
-		prInfo := fmt.Sprintf("%s %s : https://%s/%s/%s/pull/%s",
+		prInfo := fmt.Sprintf("%s %s : %s%s",
 			prc.PullRequest.StatusString(config),
 			FormatSubject(prc.Commit.Subject),
-			config.Repo.GitHubHost, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName, padding(fmt.Sprintf("%d", prc.PullRequest.Number)))
+			config.Repo.PullRequestURLPrefix(), padding(fmt.Sprintf("%d", prc.PullRequest.Number)))
 		prString = prInfo
 	}
 
 	prIndex := "--"
 	if prc.PRIndex != nil {
 		prIndex = fmt.Sprintf("s%d", *prc.PRIndex)
+		if name := PRSetName(config.PRSetNames(), *prc.PRIndex); name != "" {
+			prIndex += "(" + name + ")"
+		}
 	}
 
 	line := fmt.Sprintf("%s%2d%s %s%s%s %s",
-		github.ColorLightBlue,
+		hosting.ColorLightBlue,
 		prc.Index,
-		github.ColorReset,
+		hosting.ColorReset,
 		indexColor(prc.PRIndex),
 		prIndex,
-		github.ColorReset,
+		hosting.ColorReset,
 		//FormatSubject(prc.Commit.Subject),
 		prString,
 	)
 
-	return github.TrimToTerminal(config, line)
+	return hosting.TrimToTerminal(config, line)
 }
 
 // NewReadState pulls git and github information and constructs the state of the local unmerged commits.
 // The resulting State contains the ordered and linked commits along with their associated PRs
-func NewReadState(ctx context.Context, config *config.Config, gitcmd git.GitInterface, github github.GitHubInterface) (*State, error) {
-	prAndStatus, err := github.PullRequestsAndStatus(ctx, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName)
+func NewReadState(ctx context.Context, config *config.Config, gitcmd git.GitInterface, github hosting.Client) (*State, error) {
+	repo, err := github.PullRequestsAndStatus(ctx, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get pull requests and status: %w", err)
 	}
@@ -155,44 +163,104 @@ func NewReadState(ctx context.Context, config *config.Config, gitcmd git.GitInte
 		return nil, fmt.Errorf("failed to get unmerged commits: %w", err)
 	}
 
-	return NewState(ctx, config, prAndStatus, commits)
-}
+	prSetRefs, err := ReadPRSetRefs(config, gitcmd, GenerateCommits(commits))
+	if err != nil {
+		return nil, err
+	}
 
-// NewState composes git and github information and constructs the state of the local unmerged commits.
-// The resulting State contains the ordered and linked commits along with their associated PRs
-func NewState(ctx context.Context, config *config.Config, prAndStatus *genqlient.PullRequestsAndStatusResponse, commits []*object.Commit) (*State, error) {
-	prMap := GeneratePullRequestMap(prAndStatus)
+	return NewState(ctx, config, repo, commits, prSetRefs)
+}
 
+// NewState composes git and hosting information and constructs the state of the local unmerged commits.
+// The resulting State contains the ordered and linked commits along with their associated PRs.
+// The PR sets recorded in prSetRefs take precedence over the ones in the state file.
+func NewState(
+	ctx context.Context,
+	config *config.Config,
+	repo *hosting.Repository,
+	commits []*object.Commit,
+	prSetRefs map[string]int,
+) (*State, error) {
 	gitCommits := GenerateCommits(commits)
+	prMap := StackPullRequestMap(config, GeneratePullRequestMap(config, repo), gitCommits)
+	MigratePRSets(config, gitCommits, prMap)
 	for _, gitCommit := range gitCommits {
 		gitCommit.PullRequest = prMap[gitCommit.CommitID]
 	}
 
 	orphanedPRs := GetOrphanedPRs(gitCommits, prMap)
-	UpdateRepoToCommitIdToPrSet(config, gitCommits, prMap)
+	UpdateRepoToCommitIdToPrSet(config, prSetRefs, gitCommits, prMap)
 	AssignPullRequests(config, gitCommits, prMap)
+	UpdatePRSetNames(config, gitCommits)
 
 	SetStackedCheck(config, gitCommits)
 
-	parentId := prAndStatus.Repository.Parent.Id
+	var repositoryId, parentId string
+	if repo != nil {
+		repositoryId = repo.Id
+		parentId = repo.ParentId
+	}
 	if parentId == "" {
-		parentId = prAndStatus.Repository.Id
+		parentId = repositoryId
 	}
 
 	return &State{
 		ParentRepositoryId: parentId,
-		RepositoryId:       prAndStatus.Repository.Id,
+		RepositoryId:       repositoryId,
 		LocalCommits:       gitCommits,
 		OrphanedPRs:        orphanedPRs,
 		MutatedPRSets:      mapset.NewSet[int](),
+		PRSetRefs:          ownedPRSetRefs(prSetRefs, gitCommits, prMap),
+		PRSetNames:         maps.Clone(config.PRSetNames()),
 	}, nil
 }
 
+// StackPullRequestMap returns the pull requests of the local branch's stack: the pull requests of the local commits and
+// of the commits that were in the stack before. Other local branches stacked on the same target branch have their own
+// pull requests which are left alone. The commitIds of the stack are saved to find its orphaned pull requests next time.
+func StackPullRequestMap(
+	config *config.Config,
+	prMap map[string]*hosting.PullRequest,
+	gitCommits []*LocalCommit,
+) map[string]*hosting.PullRequest {
+	stack := config.StackCommitIds()
+	stackPRMap := map[string]*hosting.PullRequest{}
+	commitIds := map[string]bool{}
+	// The commits that are no longer local are kept until their pull request is closed
+	for commitId, pr := range prMap {
+		if stack[commitId] {
+			stackPRMap[commitId] = pr
+			commitIds[commitId] = true
+		}
+	}
+	for _, gitCommit := range gitCommits {
+		if pr, ok := prMap[gitCommit.CommitID]; ok {
+			stackPRMap[gitCommit.CommitID] = pr
+		}
+		commitIds[gitCommit.CommitID] = true
+	}
+	config.SetStackCommitIds(commitIds)
+	return stackPRMap
+}
+
+// MigratePRSets moves the PR sets of the local commits and pull requests saved by earlier versions to the keys of this
+// repository, see Config.MigratePRSets
+func MigratePRSets(config *config.Config, gitCommits []*LocalCommit, prMap map[string]*hosting.PullRequest) {
+	commitIds := map[string]bool{}
+	for commitId := range prMap {
+		commitIds[commitId] = true
+	}
+	for _, gitCommit := range gitCommits {
+		commitIds[gitCommit.CommitID] = true
+	}
+	config.MigratePRSets(commitIds)
+}
+
 // GetOrphanedPRs gets all PRs that reference commits that aren't in the unmerged-commits
 func GetOrphanedPRs(
 	gitCommits []*LocalCommit,
-	prMap map[string]*github.PullRequest,
-) mapset.Set[*github.PullRequest] {
+	prMap map[string]*hosting.PullRequest,
+) mapset.Set[*hosting.PullRequest] {
 	// Add PRs that reference commits that are not part of the unmerged-commits to the orphans list
 	prGCMap := maputils.NewGC(prMap)
 
@@ -200,7 +268,7 @@ func GetOrphanedPRs(
 		prGCMap.Lookup(gitCommit.CommitID)
 	}
 
-	orphanedPrs := mapset.NewSet[*github.PullRequest]()
+	orphanedPrs := mapset.NewSet[*hosting.PullRequest]()
 	for _, v := range prGCMap.GetUnaccessed() {
 		orphanedPrs.Add(v)
 	}
@@ -208,16 +276,25 @@ func GetOrphanedPRs(
 	return orphanedPrs
 }
 
+// UpdateRepoToCommitIdToPrSet updates the PR set state with the PR sets recorded on the remote and purges the commits
+// that no longer have a pull request
 func UpdateRepoToCommitIdToPrSet(
 	config *config.Config,
+	prSetRefs map[string]int,
 	gitCommits []*LocalCommit,
-	prMap map[string]*github.PullRequest,
+	prMap map[string]*hosting.PullRequest,
 ) {
 	// Get the mapping of commitIds to PR Sets
-	prSetMap, ok := config.State.RepoToCommitIdToPRSet[config.Repo.GitHubRepoName]
-	if !ok {
+	prSetMap := maps.Clone(config.PRSets())
+	if prSetMap == nil {
 		prSetMap = map[string]int{}
 	}
+	// The PR sets recorded on the remote are shared between machines so they win over the state file
+	for _, gitCommit := range gitCommits {
+		if prIndex, ok := prSetRefs[gitCommit.CommitID]; ok {
+			prSetMap[gitCommit.CommitID] = prIndex
+		}
+	}
 	// Purge any mappings that aren't used
 	purgeMap := maputils.NewGC(prSetMap)
 
@@ -227,19 +304,33 @@ func UpdateRepoToCommitIdToPrSet(
 		}
 	}
 
-	config.State.RepoToCommitIdToPRSet[config.Repo.GitHubRepoName] = purgeMap.PurgeUnaccessed()
+	config.SetPRSets(purgeMap.PurgeUnaccessed())
+}
+
+// ownedPRSetRefs returns the PR set refs of the local commits and of the pull requests of the stack, the refs of the
+// commits the stack doesn't know, which may have been pushed from another machine, are left alone
+func ownedPRSetRefs(prSetRefs map[string]int, gitCommits []*LocalCommit, prMap map[string]*hosting.PullRequest) map[string]int {
+	owned := map[string]int{}
+	for commitId, prIndex := range prSetRefs {
+		if _, ok := prMap[commitId]; ok {
+			owned[commitId] = prIndex
+		}
+	}
+	for _, gitCommit := range gitCommits {
+		if prIndex, ok := prSetRefs[gitCommit.CommitID]; ok {
+			owned[gitCommit.CommitID] = prIndex
+		}
+	}
+	return owned
 }
 
 func AssignPullRequests(
 	config *config.Config,
 	gitCommits []*LocalCommit,
-	prMap map[string]*github.PullRequest,
+	prMap map[string]*hosting.PullRequest,
 ) {
 	// Get the mapping of commitIds to PR Set
-	prSetMap, ok := config.State.RepoToCommitIdToPRSet[config.Repo.GitHubRepoName]
-	if !ok {
-		prSetMap = map[string]int{}
-	}
+	prSetMap := config.PRSets()
 	for _, gitCommit := range gitCommits {
 		if pr, ok := prMap[gitCommit.CommitID]; ok {
 			if prIndex, ok := prSetMap[gitCommit.CommitID]; ok {
@@ -250,6 +341,38 @@ func AssignPullRequests(
 	}
 }
 
+// UpdatePRSetNames purges the names of the PR sets that no longer have any commits
+func UpdatePRSetNames(config *config.Config, gitCommits []*LocalCommit) {
+	config.SetPRSetNames(existingPRSetNames(config.PRSetNames(), gitCommits))
+}
+
+// existingPRSetNames returns the names of the PR sets that have commits
+func existingPRSetNames(names map[string]int, gitCommits []*LocalCommit) map[string]int {
+	if len(names) == 0 {
+		return names
+	}
+	existing := map[string]int{}
+	for name, prIndex := range names {
+		for _, gitCommit := range gitCommits {
+			if gitCommit.PRIndex != nil && *gitCommit.PRIndex == prIndex {
+				existing[name] = prIndex
+				break
+			}
+		}
+	}
+	return existing
+}
+
+// PRSetName returns the name of the PR set or "" if it isn't named
+func PRSetName(names map[string]int, prIndex int) string {
+	for name, index := range names {
+		if index == prIndex {
+			return name
+		}
+	}
+	return ""
+}
+
 func SetStackedCheck(config *config.Config, gitCommits []*LocalCommit) {
 	for i := len(gitCommits) - 1; i >= 0; i-- {
 		cm := gitCommits[i]
@@ -263,7 +386,7 @@ func SetStackedCheck(config *config.Config, gitCommits []*LocalCommit) {
 			return
 		}
 		if config.Repo.RequireChecks {
-			if cm.PullRequest.MergeStatus.ChecksPass != github.CheckStatusPass {
+			if cm.PullRequest.MergeStatus.ChecksPass != hosting.CheckStatusPass {
 				return
 			}
 		}
@@ -349,6 +472,39 @@ func (s *State) ApplyIndices(indices *Indices) {
 		existingPRSets.Add(*cm.PRIndex)
 	}
 	s.MutatedPRSets = s.MutatedPRSets.Intersect(existingPRSets)
+
+	// A PR set has a single name, naming a named PR set renames it
+	if indices.DestinationName != "" {
+		if s.PRSetNames == nil {
+			s.PRSetNames = map[string]int{}
+		}
+		maps.DeleteFunc(s.PRSetNames, func(name string, prIndex int) bool {
+			return prIndex == *indices.DestinationPRIndex
+		})
+		s.PRSetNames[indices.DestinationName] = *indices.DestinationPRIndex
+	}
+	s.PRSetNames = existingPRSetNames(s.PRSetNames, s.LocalCommits)
+}
+
+// SplitPRSet moves each part, a set of commit indices in the PR set, to a new PR set. Commits that aren't in a part
+// stay in the PR set, if every commit is in a part the first part stays in the PR set. The moved commits keep their
+// pull requests.
+func (s *State) SplitPRSet(prIndex int, parts []mapset.Set[int]) {
+	inPRSet := mapset.NewSet[int]()
+	for _, commit := range s.CommitsByPRSet(prIndex) {
+		inPRSet.Add(commit.Index)
+	}
+	split := mapset.NewSet[int]()
+	for _, part := range parts {
+		split = split.Union(part)
+	}
+	if len(parts) != 0 && split.IsSuperset(inPRSet) {
+		parts = parts[1:]
+	}
+
+	for _, part := range parts {
+		s.ApplyIndices(&Indices{CommitIndexes: part})
+	}
 }
 
 // CommitsByPRSet returns all of the commits for the given PR set with the newest commits first.
@@ -403,8 +559,8 @@ func (s *State) MutatedPRSetsWithOutOfOrderCommits() mapset.Set[int] {
 }
 
 // PullRequest gets all pull request from the LocalCommits.
-func PullRequests(commits []*LocalCommit) []*github.PullRequest {
-	pullRequests := make([]*github.PullRequest, 0, len(commits))
+func PullRequests(commits []*LocalCommit) []*hosting.PullRequest {
+	pullRequests := make([]*hosting.PullRequest, 0, len(commits))
 	for _, ci := range commits {
 		if ci.PullRequest != nil {
 			pullRequests = append(pullRequests, ci.PullRequest)
@@ -414,25 +570,36 @@ func PullRequests(commits []*LocalCommit) []*github.PullRequest {
 }
 
 // GeneratePullRequestMap creates a mapping of commit-id:####### to the github.PullRequst for that commit
-func GeneratePullRequestMap(prAndStatus *genqlient.PullRequestsAndStatusResponse) map[string]*github.PullRequest {
-	if prAndStatus == nil || prAndStatus.Viewer.PullRequests.Nodes == nil {
-		return map[string]*github.PullRequest{}
+// Only PRs authored by the viewer and based in the repository (or its parent) are included.
+func GeneratePullRequestMap(config *config.Config, repo *hosting.Repository) map[string]*hosting.PullRequest {
+	if repo == nil || repo.PullRequests == nil {
+		return map[string]*hosting.PullRequest{}
 	}
 
-	prMap := map[string]*github.PullRequest{}
+	prMap := map[string]*hosting.PullRequest{}
 
-	for _, prNode := range prAndStatus.Viewer.PullRequests.Nodes {
-		commitID := CommitIdFromBranch(prNode.HeadRefName)
-		if commitID == "" {
+	for _, prNode := range repo.PullRequests {
+		if prNode.Author == "" || prNode.Author != repo.Viewer {
+			continue
+		}
+		if !IsRepository(prNode.BaseRepositoryId, repo.Id, repo.ParentId) {
 			continue
 		}
 
-		var commit genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit
-		if len(prNode.Commits.Nodes) > 0 {
-			commit = prNode.Commits.Nodes[0].Commit
+		// The pull requests of stacks on other target branches are left alone
+		commitID := git.CommitIdFromBranchName(config, prNode.HeadRefName)
+		if commitID == "" || !git.IsBranchOfTarget(config, prNode.HeadRefName) {
+			continue
 		}
 
-		ghpr := &github.PullRequest{
+		// The head commit is the last one, a pull request from a fork is based on the target branch so it contains
+		// the commits of the pull requests below it as well
+		var commit hosting.Commit
+		if len(prNode.Commits) > 0 {
+			commit = prNode.Commits[len(prNode.Commits)-1]
+		}
+
+		ghpr := &hosting.PullRequest{
 			Id:          prNode.Id,
 			DatabaseId:  fmt.Sprintf("%d", prNode.DatabaseId),
 			Number:      prNode.Number,
@@ -455,40 +622,29 @@ func GeneratePullRequestMap(prAndStatus *genqlient.PullRequestsAndStatusResponse
 	return prMap
 }
 
-func CommitIdFromBranch(branchName string) string {
-	segments := strings.Split(branchName, "/")
-	if len(segments) != 3 {
-		return ""
-	}
-	if segments[0] != "spr" {
-		return ""
+// IsRepository returns true if the repositoryId matches the repository or the parent repository
+func IsRepository(repositoryId string, repoId string, parentRepoId string) bool {
+	if repositoryId == "" {
+		return false
 	}
-	commitId := segments[2]
-	if len(commitId) != 8 {
-		return ""
-	}
-	return commitId
+	return repositoryId == repoId || repositoryId == parentRepoId
 }
 
-func ComputeMergeStatus(pr genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest) github.PullRequestMergeStatus {
-	prms := github.PullRequestMergeStatus{}
-	switch pr.StatusCheckRollup.State {
-	case genqlient.StatusStateError:
-		fallthrough
-	case genqlient.StatusStateFailure:
-		prms.ChecksPass = github.CheckStatusFail
-	case genqlient.StatusStateExpected:
-		fallthrough
-	case genqlient.StatusStatePending:
-		prms.ChecksPass = github.CheckStatusPending
-	case "":
+func ComputeMergeStatus(pr hosting.OpenPullRequest) hosting.PullRequestMergeStatus {
+	prms := hosting.PullRequestMergeStatus{}
+	switch pr.CheckState {
+	case hosting.CheckStateFailure:
+		prms.ChecksPass = hosting.CheckStatusFail
+	case hosting.CheckStatePending:
+		prms.ChecksPass = hosting.CheckStatusPending
+	case hosting.CheckStateNone:
 		fallthrough
-	case genqlient.StatusStateSuccess:
-		prms.ChecksPass = github.CheckStatusPass
+	case hosting.CheckStateSuccess:
+		prms.ChecksPass = hosting.CheckStatusPass
 	}
 
-	prms.NoConflicts = pr.Mergeable == genqlient.MergeableStateMergeable
-	prms.ReviewApproved = pr.ReviewDecision == genqlient.PullRequestReviewDecisionApproved
+	prms.NoConflicts = pr.Mergeable == hosting.MergeableStateMergeable
+	prms.ReviewApproved = pr.ReviewDecision == hosting.ReviewDecisionApproved
 
 	return prms
 }
@@ -533,7 +689,8 @@ func (s *State) UpdatePRSetState(config *config.Config) {
 		prSetMap[commit.CommitID] = *commit.PRIndex
 
 	}
-	config.State.RepoToCommitIdToPRSet[config.Repo.GitHubRepoName] = prSetMap
+	config.SetPRSets(prSetMap)
+	config.SetPRSetNames(existingPRSetNames(s.PRSetNames, s.LocalCommits))
 }
 
 func HeadFirst(commits []*object.Commit) []*object.Commit {
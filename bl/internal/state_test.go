@@ -10,8 +10,7 @@ import (
 	"github.com/ejoffe/spr/bl/ptrutils"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github"
-	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/require"
@@ -20,37 +19,31 @@ import (
 func TestNewState(t *testing.T) {
 	ctx := context.Background()
 	config := config.EmptyConfig()
-	prAndStatus := &genqlient.PullRequestsAndStatusResponse{}
+	repo := &hosting.Repository{}
 	commits := []*object.Commit{}
 
 	t.Run("smoke test", func(t *testing.T) {
-		state, err := internal.NewState(ctx, config, prAndStatus, commits)
+		state, err := internal.NewState(ctx, config, repo, commits, nil)
 		require.NoError(t, err)
 		require.NotNil(t, state)
 	})
 
 	t.Run("uses parent id if set", func(t *testing.T) {
-		prAndStatus := &genqlient.PullRequestsAndStatusResponse{
-			Repository: genqlient.PullRequestsAndStatusRepository{
-				Id: "id",
-				Parent: genqlient.PullRequestsAndStatusRepositoryParentRepository{
-					Id: "parent",
-				},
-			},
+		repo := &hosting.Repository{
+			Id:       "id",
+			ParentId: "parent",
 		}
-		state, err := internal.NewState(ctx, config, prAndStatus, commits)
+		state, err := internal.NewState(ctx, config, repo, commits, nil)
 		require.NoError(t, err)
 		require.NotNil(t, state)
 		require.Equal(t, state.ParentRepositoryId, "parent")
 	})
 
 	t.Run("uses id as parent id if parent id not set", func(t *testing.T) {
-		prAndStatus := &genqlient.PullRequestsAndStatusResponse{
-			Repository: genqlient.PullRequestsAndStatusRepository{
-				Id: "id",
-			},
+		repo := &hosting.Repository{
+			Id: "id",
 		}
-		state, err := internal.NewState(ctx, config, prAndStatus, commits)
+		state, err := internal.NewState(ctx, config, repo, commits, nil)
 		require.NoError(t, err)
 		require.NotNil(t, state)
 		require.Equal(t, state.ParentRepositoryId, "id")
@@ -67,13 +60,13 @@ func TestGetOrphanedPRs(t *testing.T) {
 	type testCase struct {
 		name            string
 		gitCommits      []*internal.LocalCommit
-		prMap           map[string]*github.PullRequest
-		expectedOrphans mapset.Set[*github.PullRequest]
+		prMap           map[string]*hosting.PullRequest
+		expectedOrphans mapset.Set[*hosting.PullRequest]
 	}
 
-	pr1 := &github.PullRequest{DatabaseId: "1", Id: "10"}
-	pr2 := &github.PullRequest{DatabaseId: "2", Id: "20"}
-	pr3 := &github.PullRequest{DatabaseId: "3", Id: "30"}
+	pr1 := &hosting.PullRequest{DatabaseId: "1", Id: "10"}
+	pr2 := &hosting.PullRequest{DatabaseId: "2", Id: "20"}
+	pr3 := &hosting.PullRequest{DatabaseId: "3", Id: "30"}
 
 	testCases := []testCase{
 		{
@@ -82,49 +75,49 @@ func TestGetOrphanedPRs(t *testing.T) {
 				{Commit: git.Commit{CommitID: "11111111"}},
 				{Commit: git.Commit{CommitID: "22222222"}},
 			},
-			prMap: map[string]*github.PullRequest{
+			prMap: map[string]*hosting.PullRequest{
 				"11111111": pr1,
 				"22222222": pr2,
 			},
-			expectedOrphans: mapset.NewSet[*github.PullRequest](),
+			expectedOrphans: mapset.NewSet[*hosting.PullRequest](),
 		},
 		{
 			name: "one orphaned PR",
 			gitCommits: []*internal.LocalCommit{
 				{Commit: git.Commit{CommitID: "11111111"}},
 			},
-			prMap: map[string]*github.PullRequest{
+			prMap: map[string]*hosting.PullRequest{
 				"11111111": pr1,
 				"22222222": pr2,
 			},
-			expectedOrphans: mapset.NewSet[*github.PullRequest](pr2),
+			expectedOrphans: mapset.NewSet[*hosting.PullRequest](pr2),
 		},
 		{
 			name: "multiple orphaned PRs",
 			gitCommits: []*internal.LocalCommit{
 				{Commit: git.Commit{CommitID: "11111111"}},
 			},
-			prMap: map[string]*github.PullRequest{
+			prMap: map[string]*hosting.PullRequest{
 				"11111111": pr1,
 				"22222222": pr2,
 				"33333333": pr3,
 			},
-			expectedOrphans: mapset.NewSet[*github.PullRequest](pr2, pr3),
+			expectedOrphans: mapset.NewSet[*hosting.PullRequest](pr2, pr3),
 		},
 		{
 			name:            "no PRs",
 			gitCommits:      []*internal.LocalCommit{},
-			prMap:           map[string]*github.PullRequest{},
-			expectedOrphans: mapset.NewSet[*github.PullRequest](),
+			prMap:           map[string]*hosting.PullRequest{},
+			expectedOrphans: mapset.NewSet[*hosting.PullRequest](),
 		},
 		{
 			name:       "all PRs orphaned",
 			gitCommits: []*internal.LocalCommit{},
-			prMap: map[string]*github.PullRequest{
+			prMap: map[string]*hosting.PullRequest{
 				"11111111": pr1,
 				"22222222": pr2,
 			},
-			expectedOrphans: mapset.NewSet[*github.PullRequest](pr1, pr2),
+			expectedOrphans: mapset.NewSet[*hosting.PullRequest](pr1, pr2),
 		},
 	}
 
@@ -136,14 +129,54 @@ func TestGetOrphanedPRs(t *testing.T) {
 	}
 }
 
+func TestStackPullRequestMap(t *testing.T) {
+	pr1 := &hosting.PullRequest{Number: 1}
+	pr2 := &hosting.PullRequest{Number: 2}
+	pr3 := &hosting.PullRequest{Number: 3}
+	prMap := map[string]*hosting.PullRequest{"11111111": pr1, "22222222": pr2, "33333333": pr3}
+
+	config := config.EmptyConfig()
+	config.Repo.LocalBranch = "feature"
+	config.SetStackCommitIds(map[string]bool{"22222222": true, "44444444": true})
+
+	gitCommits := []*internal.LocalCommit{
+		{Commit: git.Commit{CommitID: "11111111"}},
+		{Commit: git.Commit{CommitID: "55555555"}},
+	}
+	// 22222222 was in the stack so its pull request is orphaned, 33333333 is in the stack of another local branch and
+	// 44444444 no longer has a pull request
+	stackPRMap := internal.StackPullRequestMap(config, prMap, gitCommits)
+	require.Equal(t, map[string]*hosting.PullRequest{"11111111": pr1, "22222222": pr2}, stackPRMap)
+	require.Equal(t, map[string]bool{"11111111": true, "22222222": true, "55555555": true}, config.StackCommitIds())
+	require.Equal(t, mapset.NewSet(pr2), internal.GetOrphanedPRs(gitCommits, stackPRMap))
+
+	// The stacks of other local branches are kept apart
+	config.Repo.LocalBranch = "other"
+	require.Nil(t, config.StackCommitIds())
+}
+
+func TestMigratePRSets(t *testing.T) {
+	config := config.EmptyConfig()
+	config.Repo.GitHubRepoName = "repo"
+	config.Repo.LocalBranch = "feature"
+	config.State.RepoToCommitIdToPRSet["repo"] = map[string]int{"11111111": 0, "22222222": 1, "33333333": 2}
+
+	// 11111111 is a local commit and 22222222 has a pull request, 33333333 was saved for another repository named repo
+	gitCommits := []*internal.LocalCommit{{Commit: git.Commit{CommitID: "11111111"}}}
+	prMap := map[string]*hosting.PullRequest{"22222222": {Number: 2}}
+	internal.MigratePRSets(config, gitCommits, prMap)
+	require.Equal(t, map[string]int{"11111111": 0, "22222222": 1}, config.PRSets())
+	require.Equal(t, map[string]int{"33333333": 2}, config.State.RepoToCommitIdToPRSet["repo"])
+}
+
 func TestUpdateRepoToCommitIdToPrSet(t *testing.T) {
 	config := config.EmptyConfig()
 	config.Repo.GitHubRepoName = t.Name()
-	config.State.RepoToCommitIdToPRSet[t.Name()] = map[string]int{
+	config.SetPRSets(map[string]int{
 		"11111111": 1,
 		"22222222": 0,
 		"99999999": 9,
-	}
+	})
 	gitCommits := []*internal.LocalCommit{
 		{
 			Commit: git.Commit{
@@ -165,7 +198,7 @@ func TestUpdateRepoToCommitIdToPrSet(t *testing.T) {
 		},
 	}
 
-	prMap := map[string]*github.PullRequest{
+	prMap := map[string]*hosting.PullRequest{
 		"11111111": {
 			Id:         "10",
 			DatabaseId: "1",
@@ -180,21 +213,29 @@ func TestUpdateRepoToCommitIdToPrSet(t *testing.T) {
 		},
 	}
 
-	internal.UpdateRepoToCommitIdToPrSet(config, gitCommits, prMap)
+	prSetRefs := map[string]int{
+		"22222222": 2,
+		"88888888": 8,
+	}
+
+	internal.UpdateRepoToCommitIdToPrSet(config, prSetRefs, gitCommits, prMap)
 
 	// Since 99999999 isn't used it should be removed from the mapping
-	_, ok := config.State.RepoToCommitIdToPRSet[t.Name()]["99999999"]
+	_, ok := config.PRSets()["99999999"]
 	require.False(t, ok)
+
+	// The PR sets recorded on the remote win over the state file
+	require.Equal(t, map[string]int{"11111111": 1, "22222222": 2}, config.PRSets())
 }
 
 func TestAssignPullRequests(t *testing.T) {
 	config := config.EmptyConfig()
 	config.Repo.GitHubRepoName = t.Name()
-	config.State.RepoToCommitIdToPRSet[t.Name()] = map[string]int{
+	config.SetPRSets(map[string]int{
 		"11111111": 1,
 		"22222222": 0,
 		"99999999": 9,
-	}
+	})
 	gitCommits := []*internal.LocalCommit{
 		{
 			Commit: git.Commit{
@@ -216,7 +257,7 @@ func TestAssignPullRequests(t *testing.T) {
 		},
 	}
 
-	prMap := map[string]*github.PullRequest{
+	prMap := map[string]*hosting.PullRequest{
 		"11111111": {
 			Id:         "10",
 			DatabaseId: "1",
@@ -274,9 +315,9 @@ func TestSetStackedCheck(t *testing.T) {
 			Commit: git.Commit{
 				WIP: false,
 			},
-			PullRequest: &github.PullRequest{
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+			PullRequest: &hosting.PullRequest{
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 				},
@@ -299,11 +340,11 @@ func TestSetStackedCheck(t *testing.T) {
 
 func TestApplyIndicies(t *testing.T) {
 	// Define the PRs here so the pointer value will be consistent between calls of testingState
-	// this allow us to compare sets containing &github.PullRequest
-	pr0 := &github.PullRequest{DatabaseId: "0", Id: "00"}
-	pr1 := &github.PullRequest{DatabaseId: "1", Id: "10"}
-	pr2 := &github.PullRequest{DatabaseId: "2", Id: "20"}
-	pr3 := &github.PullRequest{DatabaseId: "3", Id: "30"}
+	// this allow us to compare sets containing &hosting.PullRequest
+	pr0 := &hosting.PullRequest{DatabaseId: "0", Id: "00"}
+	pr1 := &hosting.PullRequest{DatabaseId: "1", Id: "10"}
+	pr2 := &hosting.PullRequest{DatabaseId: "2", Id: "20"}
+	pr3 := &hosting.PullRequest{DatabaseId: "3", Id: "30"}
 	testingState := func() *internal.State {
 		gitCommits := []*internal.LocalCommit{
 			{
@@ -332,7 +373,7 @@ func TestApplyIndicies(t *testing.T) {
 		}
 		return &internal.State{
 			LocalCommits:  gitCommits,
-			OrphanedPRs:   mapset.NewSet[*github.PullRequest](),
+			OrphanedPRs:   mapset.NewSet[*hosting.PullRequest](),
 			MutatedPRSets: mapset.NewSet[int](),
 		}
 	}
@@ -427,12 +468,80 @@ func TestApplyIndicies(t *testing.T) {
 	}
 }
 
+func TestApplyIndiciesNames(t *testing.T) {
+	testingState := func() *internal.State {
+		return &internal.State{
+			LocalCommits: []*internal.LocalCommit{
+				{Index: 0, PRIndex: ptrutils.Ptr(0)},
+				{Index: 1, PRIndex: ptrutils.Ptr(1)},
+				{Index: 2},
+			},
+			OrphanedPRs:   mapset.NewSet[*hosting.PullRequest](),
+			MutatedPRSets: mapset.NewSet[int](),
+			PRSetNames:    map[string]int{"auth": 0, "docs": 1},
+		}
+	}
+
+	// A new name names the new PR set
+	state := testingState()
+	state.ApplyIndices(&internal.Indices{DestinationName: "login", CommitIndexes: mapset.NewSet[int](2)})
+	require.Equal(t, map[string]int{"auth": 0, "docs": 1, "login": 2}, state.PRSetNames)
+
+	// Naming a named PR set renames it
+	state = testingState()
+	state.ApplyIndices(&internal.Indices{
+		DestinationPRIndex: ptrutils.Ptr(0), DestinationName: "oauth", CommitIndexes: mapset.NewSet[int](0),
+	})
+	require.Equal(t, map[string]int{"oauth": 0, "docs": 1}, state.PRSetNames)
+
+	// The name of a PR set that no longer has commits is dropped
+	state = testingState()
+	state.ApplyIndices(&internal.Indices{DestinationPRIndex: ptrutils.Ptr(0), CommitIndexes: mapset.NewSet[int](0, 1)})
+	require.Equal(t, map[string]int{"auth": 0}, state.PRSetNames)
+}
+
+func TestSplitPRSet(t *testing.T) {
+	pr := func(i int) *hosting.PullRequest { return &hosting.PullRequest{Number: i} }
+	testingState := func() *internal.State {
+		commits := []*internal.LocalCommit{}
+		for i := 3; i >= 0; i-- {
+			commits = append(commits, &internal.LocalCommit{Index: i, PRIndex: ptrutils.Ptr(0), PullRequest: pr(i)})
+		}
+		return &internal.State{
+			LocalCommits:  commits,
+			OrphanedPRs:   mapset.NewSet[*hosting.PullRequest](),
+			MutatedPRSets: mapset.NewSet[int](),
+		}
+	}
+	prIndexes := func(state *internal.State) []int {
+		result := make([]int, len(state.LocalCommits))
+		for _, commit := range state.LocalCommits {
+			result[commit.Index] = *commit.PRIndex
+		}
+		return result
+	}
+
+	// The unselected commits stay in the PR set
+	state := testingState()
+	state.SplitPRSet(0, []mapset.Set[int]{mapset.NewSet(1), mapset.NewSet(3)})
+	require.Equal(t, []int{0, 1, 0, 2}, prIndexes(state))
+	require.Equal(t, mapset.NewSet(0, 1, 2), state.MutatedPRSets)
+	require.Equal(t, 0, state.OrphanedPRs.Cardinality())
+
+	// If every commit is selected the first part stays in the PR set
+	state = testingState()
+	state.SplitPRSet(0, []mapset.Set[int]{mapset.NewSet(0, 1), mapset.NewSet(2, 3)})
+	require.Equal(t, []int{0, 0, 1, 1}, prIndexes(state))
+	require.Equal(t, mapset.NewSet(0, 1), state.MutatedPRSets)
+	require.Equal(t, 0, state.OrphanedPRs.Cardinality())
+}
+
 func TestCommitsByPRSet(t *testing.T) {
 	// Define the PRs here so the pointer value will be consistent between calls of testingState
-	// this allow us to compare sets containing &github.PullRequest
-	pr0 := &github.PullRequest{DatabaseId: "0", Id: "00"}
-	pr1 := &github.PullRequest{DatabaseId: "1", Id: "10"}
-	pr2 := &github.PullRequest{DatabaseId: "2", Id: "20"}
+	// this allow us to compare sets containing &hosting.PullRequest
+	pr0 := &hosting.PullRequest{DatabaseId: "0", Id: "00"}
+	pr1 := &hosting.PullRequest{DatabaseId: "1", Id: "10"}
+	pr2 := &hosting.PullRequest{DatabaseId: "2", Id: "20"}
 	testingState := internal.State{
 		LocalCommits: []*internal.LocalCommit{
 			{
@@ -473,13 +582,13 @@ func TestMutatedPRSetsWithOutOfOrderCommits(t *testing.T) {
 		LocalCommits: []*internal.LocalCommit{
 			// Start PR set 1
 			{
-				PullRequest: &github.PullRequest{
+				PullRequest: &hosting.PullRequest{
 					ToBranch: "0",
 				},
 				PRIndex: ptrutils.Ptr(0),
 			},
 			{
-				PullRequest: &github.PullRequest{
+				PullRequest: &hosting.PullRequest{
 					FromBranch: "0",
 					ToBranch:   "1",
 				},
@@ -490,20 +599,20 @@ func TestMutatedPRSetsWithOutOfOrderCommits(t *testing.T) {
 			},
 			// Start PR set 1 which is out of order
 			{
-				PullRequest: &github.PullRequest{
+				PullRequest: &hosting.PullRequest{
 					ToBranch: "0",
 				},
 				PRIndex: ptrutils.Ptr(1),
 			},
 			{
-				PullRequest: &github.PullRequest{
+				PullRequest: &hosting.PullRequest{
 					FromBranch: "1",
 				},
 				PRIndex: ptrutils.Ptr(1),
 			},
 			// Resume PR set 0
 			{
-				PullRequest: &github.PullRequest{
+				PullRequest: &hosting.PullRequest{
 					FromBranch: "1",
 					ToBranch:   "2",
 				},
@@ -519,10 +628,10 @@ func TestMutatedPRSetsWithOutOfOrderCommits(t *testing.T) {
 }
 
 func TestPullRequests(t *testing.T) {
-	pr0 := &github.PullRequest{DatabaseId: "0", Id: "00"}
-	pr1 := &github.PullRequest{DatabaseId: "1", Id: "10"}
-	pr2 := &github.PullRequest{DatabaseId: "2", Id: "20"}
-	pr3 := &github.PullRequest{DatabaseId: "3", Id: "30"}
+	pr0 := &hosting.PullRequest{DatabaseId: "0", Id: "00"}
+	pr1 := &hosting.PullRequest{DatabaseId: "1", Id: "10"}
+	pr2 := &hosting.PullRequest{DatabaseId: "2", Id: "20"}
+	pr3 := &hosting.PullRequest{DatabaseId: "3", Id: "30"}
 	testingCommits := []*internal.LocalCommit{
 		{
 			Index:       0,
@@ -549,53 +658,54 @@ func TestPullRequests(t *testing.T) {
 		},
 	}
 
-	expectedPullRequests := []*github.PullRequest{
+	expectedPullRequests := []*hosting.PullRequest{
 		pr0, pr1, pr2, pr3,
 	}
 	pullRequests := internal.PullRequests(testingCommits)
 	require.Equal(t, expectedPullRequests, pullRequests)
 }
 
+// mainConfig returns a config for stacks targeting main
+func mainConfig() *config.Config {
+	cfg := config.EmptyConfig()
+	cfg.Repo.GitHubBranch = "main"
+	return cfg
+}
+
 func TestGeneratePullRequestMap(t *testing.T) {
 	t.Run("handles no PRs", func(t *testing.T) {
-		prMap := internal.GeneratePullRequestMap(&genqlient.PullRequestsAndStatusResponse{})
-		require.Equal(t, map[string]*github.PullRequest{}, prMap)
+		prMap := internal.GeneratePullRequestMap(config.EmptyConfig(), &hosting.Repository{})
+		require.Equal(t, map[string]*hosting.PullRequest{}, prMap)
 	})
 
 	t.Run("computes key based on head branch", func(t *testing.T) {
-		prMap := internal.GeneratePullRequestMap(&genqlient.PullRequestsAndStatusResponse{
-			Viewer: genqlient.PullRequestsAndStatusViewerUser{
-				PullRequests: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnection{
-					Nodes: []genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
+		prMap := internal.GeneratePullRequestMap(mainConfig(), &hosting.Repository{
+			Id:     "repo",
+			Viewer: "me",
+			PullRequests: []hosting.OpenPullRequest{
+				{
+					Id:               "30",
+					DatabaseId:       3,
+					Number:           3,
+					HeadRefName:      "spr/main/0f47588b",
+					BaseRefName:      "main",
+					Title:            "Test PR",
+					Body:             "Test Body",
+					Mergeable:        hosting.MergeableStateMergeable,
+					Author:           "me",
+					BaseRepositoryId: "repo",
+					Commits: []hosting.Commit{
 						{
-							Id:          "30",
-							DatabaseId:  3,
-							Number:      3,
-							HeadRefName: "spr/main/0f47588b",
-							BaseRefName: "main",
-							Title:       "Test PR",
-							Body:        "Test Body",
-							Mergeable:   genqlient.MergeableStateMergeable,
-							Commits: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-								Nodes: []genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-									{
-										Commit: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{
-											Oid:             "012345",
-											MessageHeadline: "WIP Headline",
-											MessageBody:     "some message",
-											StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommitStatusCheckRollup{
-												State: genqlient.StatusStateSuccess,
-											},
-										},
-									},
-								},
-							},
+							Oid:             "012345",
+							MessageHeadline: "WIP Headline",
+							MessageBody:     "some message",
+							CheckState:      hosting.CheckStateSuccess,
 						},
 					},
 				},
 			},
 		})
-		expected := map[string]*github.PullRequest{
+		expected := map[string]*hosting.PullRequest{
 			"0f47588b": {
 				Id:         "30",
 				DatabaseId: "3",
@@ -604,8 +714,8 @@ func TestGeneratePullRequestMap(t *testing.T) {
 				ToBranch:   "main",
 				Title:      "Test PR",
 				Body:       "Test Body",
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					NoConflicts:    true,
 					ReviewApproved: false,
 				},
@@ -622,116 +732,113 @@ func TestGeneratePullRequestMap(t *testing.T) {
 	})
 }
 
-func TestCommitIdFromBranch(t *testing.T) {
-	require.Equal(t, "", bl.CommitIdFromBranch(""))
-	require.Equal(t, "", bl.CommitIdFromBranch("spr/"))
-	require.Equal(t, "", bl.CommitIdFromBranch("spr/main"))
-	require.Equal(t, "", bl.CommitIdFromBranch("spr/main/1234444"))
-	require.Equal(t, "", bl.CommitIdFromBranch("other/main/12344448"))
-	require.Equal(t, "12344448", bl.CommitIdFromBranch("spr/main/12344448"))
+func TestGeneratePullRequestMapRejectsForeignPRs(t *testing.T) {
+	node := func(author string, baseRepoId string, commitId string) hosting.OpenPullRequest {
+		return hosting.OpenPullRequest{
+			HeadRefName:      "spr/main/" + commitId,
+			BaseRefName:      "main",
+			Author:           author,
+			BaseRepositoryId: baseRepoId,
+		}
+	}
+
+	prMap := internal.GeneratePullRequestMap(mainConfig(), &hosting.Repository{
+		Id:       "repo",
+		ParentId: "parent",
+		Viewer:   "me",
+		PullRequests: []hosting.OpenPullRequest{
+			node("me", "repo", "11111111"),
+			node("me", "parent", "22222222"),
+			node("someone", "repo", "33333333"),
+			node("me", "other", "44444444"),
+			node("me", "", "55555555"),
+			{HeadRefName: "spr/main/66666666", BaseRepositoryId: "repo"},
+		},
+	})
+
+	require.Len(t, prMap, 2)
+	require.Contains(t, prMap, "11111111")
+	require.Contains(t, prMap, "22222222")
 }
 
 func TestComputeMergeStatus(t *testing.T) {
 	tests := []struct {
 		desc     string
-		pr       genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest
-		expected github.PullRequestMergeStatus
+		pr       hosting.OpenPullRequest
+		expected hosting.PullRequestMergeStatus
 	}{
 		{
 			desc: "all pass",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestStatusCheckRollup{
-					State: genqlient.StatusStateSuccess,
-				},
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
+			pr: hosting.OpenPullRequest{
+				CheckState:     hosting.CheckStateSuccess,
+				Mergeable:      hosting.MergeableStateMergeable,
+				ReviewDecision: hosting.ReviewDecisionApproved,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 			},
 		},
 		{
 			desc: "no state",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestStatusCheckRollup{
-					State: "",
-				},
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
+			pr: hosting.OpenPullRequest{
+				CheckState:     hosting.CheckStateNone,
+				Mergeable:      hosting.MergeableStateMergeable,
+				ReviewDecision: hosting.ReviewDecisionApproved,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
-				ReviewApproved: true,
-				NoConflicts:    true,
-			},
-		},
-		{
-			desc: "check error",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestStatusCheckRollup{
-					State: genqlient.StatusStateError,
-				},
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
-			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusFail,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 			},
 		},
 		{
 			desc: "check fail",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestStatusCheckRollup{
-					State: genqlient.StatusStateFailure,
-				},
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
+			pr: hosting.OpenPullRequest{
+				CheckState:     hosting.CheckStateFailure,
+				Mergeable:      hosting.MergeableStateMergeable,
+				ReviewDecision: hosting.ReviewDecisionApproved,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusFail,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusFail,
 				ReviewApproved: true,
 				NoConflicts:    true,
 			},
 		},
 		{
 			desc: "check pending",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				StatusCheckRollup: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequestStatusCheckRollup{
-					State: genqlient.StatusStatePending,
-				},
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
+			pr: hosting.OpenPullRequest{
+				CheckState:     hosting.CheckStatePending,
+				Mergeable:      hosting.MergeableStateMergeable,
+				ReviewDecision: hosting.ReviewDecisionApproved,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPending,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPending,
 				ReviewApproved: true,
 				NoConflicts:    true,
 			},
 		},
 		{
 			desc: "conflicts",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				Mergeable:      genqlient.MergeableStateConflicting,
-				ReviewDecision: genqlient.PullRequestReviewDecisionApproved,
+			pr: hosting.OpenPullRequest{
+				Mergeable:      hosting.MergeableStateConflicting,
+				ReviewDecision: hosting.ReviewDecisionApproved,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    false,
 			},
 		},
 		{
 			desc: "review required",
-			pr: genqlient.PullRequestsAndStatusViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-				Mergeable:      genqlient.MergeableStateMergeable,
-				ReviewDecision: genqlient.PullRequestReviewDecisionReviewRequired,
+			pr: hosting.OpenPullRequest{
+				Mergeable:      hosting.MergeableStateMergeable,
+				ReviewDecision: hosting.ReviewDecisionReviewRequired,
 			},
-			expected: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			expected: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: false,
 				NoConflicts:    true,
 			},
@@ -781,11 +888,11 @@ func TestUpdatePRSetState(t *testing.T) {
 	config.State.RepoToCommitIdToPRSet["other"] = map[string]int{
 		"44444444": 4,
 	}
-	config.State.RepoToCommitIdToPRSet[t.Name()] = map[string]int{
+	config.SetPRSets(map[string]int{
 		"11111111": 1,
 		"22222222": 0,
 		"99999999": 9,
-	}
+	})
 
 	testingCommits := []*internal.LocalCommit{
 		{
@@ -823,7 +930,7 @@ func TestUpdatePRSetState(t *testing.T) {
 		"other": {
 			"44444444": 4,
 		},
-		t.Name(): {
+		config.PRSetKey(): {
 			"11111111": 0,
 			"22222222": 0,
 			"33333333": 1,
@@ -916,3 +1023,29 @@ func TestBody(t *testing.T) {
 	require.Equal(t, "msg", bl.Body("\nmsg"))
 	require.Equal(t, "", bl.Body(""))
 }
+
+func TestGeneratePullRequestMapSkipsOtherTargetBranches(t *testing.T) {
+	node := func(head string, base string) hosting.OpenPullRequest {
+		return hosting.OpenPullRequest{
+			HeadRefName:      head,
+			BaseRefName:      base,
+			Author:           "me",
+			BaseRepositoryId: "repo",
+		}
+	}
+
+	prMap := internal.GeneratePullRequestMap(mainConfig(), &hosting.Repository{
+		Id:     "repo",
+		Viewer: "me",
+		PullRequests: []hosting.OpenPullRequest{
+			node("spr/main/11111111", "main"),
+			node("spr/main/22222222", "spr/main/11111111"),
+			node("spr/release/1.0/33333333", "release/1.0"),
+			node("spr/release/1.0/44444444", "spr/release/1.0/33333333"),
+		},
+	})
+
+	require.Len(t, prMap, 2)
+	require.Contains(t, prMap, "11111111")
+	require.Contains(t, prMap, "22222222")
+}
@@ -0,0 +1,67 @@
+package journal
+
+import (
+	"context"
+	"strings"
+
+	"github.com/ejoffe/spr/git"
+)
+
+// Git records the commit of each remote branch before it is pushed or deleted
+type Git struct {
+	git.GitInterface
+	entry *Entry
+}
+
+// NewGit returns a git.GitInterface which records the remote branches to the entry before changing them
+func NewGit(wrapped git.GitInterface, entry *Entry) *Git {
+	return &Git{
+		GitInterface: wrapped,
+		entry:        entry,
+	}
+}
+
+func (g *Git) Git(args string, output *string) error {
+	if strings.HasPrefix(args, "push ") {
+		err := g.entry.recordBranches(g.GitInterface, pushedBranches(strings.Split(args, " ")[1:]))
+		if err != nil {
+			return err
+		}
+	}
+	return g.GitInterface.Git(args, output)
+}
+
+func (g *Git) MustGit(args string, output *string) {
+	err := g.Git(args, output)
+	if err != nil {
+		panic(err)
+	}
+}
+
+func (g *Git) Push(remoteName string, refspecs []string) error {
+	err := g.entry.recordBranches(g.GitInterface, pushedBranches(refspecs))
+	if err != nil {
+		return err
+	}
+	return g.GitInterface.Push(remoteName, refspecs)
+}
+
+func (g *Git) DeleteRemoteBranch(ctx context.Context, branch string) error {
+	err := g.entry.recordBranches(g.GitInterface, []string{branch})
+	if err != nil {
+		return err
+	}
+	return g.GitInterface.DeleteRemoteBranch(ctx, branch)
+}
+
+// pushedBranches returns the destination branches of the refspecs, arguments that aren't refspecs are ignored
+func pushedBranches(args []string) []string {
+	var branches []string
+	for _, arg := range args {
+		_, dst, found := strings.Cut(strings.TrimPrefix(arg, "+"), ":")
+		if found && !strings.HasPrefix(arg, "-") {
+			branches = append(branches, strings.TrimPrefix(dst, "refs/heads/"))
+		}
+	}
+	return branches
+}
@@ -0,0 +1,150 @@
+package journal
+
+import (
+	"context"
+	"sync"
+
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/hosting"
+)
+
+// GitHub records the state of each pull request before it is changed.
+// The state comes from the pull requests read earlier in the command, pull requests that are created are recorded so
+// they can be closed.
+type GitHub struct {
+	hosting.Client
+	entry *Entry
+
+	lock sync.Mutex
+	// read is the state of the pull requests when they were read
+	read map[int]PullRequest
+}
+
+// NewGitHub returns a hosting.Client which records the pull requests to the entry before changing them
+func NewGitHub(wrapped hosting.Client, entry *Entry) *GitHub {
+	return &GitHub{
+		Client: wrapped,
+		entry:  entry,
+		read:   map[int]PullRequest{},
+	}
+}
+
+func (g *GitHub) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
+	info, err := g.Client.GetInfo(ctx, gitcmd)
+	if err != nil {
+		return nil, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, pr := range info.PullRequests {
+		g.read[pr.Number] = PullRequest{
+			Number:      pr.Number,
+			Title:       pr.Title,
+			Body:        pr.Body,
+			BaseRefName: pr.ToBranch,
+			HeadRefName: pr.FromBranch,
+			State:       "open",
+		}
+	}
+	return info, nil
+}
+
+func (g *GitHub) PullRequestsAndStatus(ctx context.Context, repoOwner string, repoName string) (*hosting.Repository, error) {
+	repo, err := g.Client.PullRequestsAndStatus(ctx, repoOwner, repoName)
+	if err != nil || repo == nil {
+		return repo, err
+	}
+
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	for _, node := range repo.PullRequests {
+		g.read[node.Number] = PullRequest{
+			Number:      node.Number,
+			Title:       node.Title,
+			Body:        node.Body,
+			BaseRefName: node.BaseRefName,
+			HeadRefName: node.HeadRefName,
+			State:       "open",
+		}
+	}
+	return repo, nil
+}
+
+func (g *GitHub) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface, info *hosting.Info,
+	commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
+	pr, err := g.Client.CreatePullRequest(ctx, gitcmd, info, commit, prevCommit)
+	if err != nil {
+		return nil, err
+	}
+	g.entry.recordPullRequest(PullRequest{Number: pr.Number, Created: true})
+	return pr, nil
+}
+
+func (g *GitHub) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
+	id, number, err := g.Client.CreatePullRequest2(ctx, owner, repoName, pull)
+	if err != nil {
+		return id, number, err
+	}
+	g.entry.recordPullRequest(PullRequest{Number: number, Created: true})
+	return id, number, nil
+}
+
+func (g *GitHub) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest,
+	pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
+	g.recordRead(pr.Number)
+	return g.Client.UpdatePullRequest(ctx, gitcmd, pullRequests, pr, commit, prevCommit)
+}
+
+func (g *GitHub) EditPullRequest2(ctx context.Context, owner string, repo string, number int, edit hosting.PullRequestEdit) error {
+	g.recordRead(number)
+	return g.Client.EditPullRequest2(ctx, owner, repo, number, edit)
+}
+
+func (g *GitHub) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
+	g.recordRead(pr.Number)
+	return g.Client.ClosePullRequest(ctx, pr)
+}
+
+func (g *GitHub) MergePullRequest(ctx context.Context, pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
+	g.recordRead(pr.Number)
+	err := g.Client.MergePullRequest(ctx, pr, mergeMethod)
+	if err != nil {
+		return err
+	}
+
+	g.entry.lock.Lock()
+	defer g.entry.lock.Unlock()
+	if recorded := g.entry.pullRequest(pr.Number); recorded != nil {
+		recorded.Merged = true
+	}
+	return nil
+}
+
+// recordRead records the state the pull request had when it was read. Pull requests that weren't read can't be
+// restored so they aren't recorded.
+func (g *GitHub) recordRead(number int) {
+	g.lock.Lock()
+	pr, found := g.read[number]
+	g.lock.Unlock()
+	if found {
+		g.entry.recordPullRequest(pr)
+	}
+}
+
+// toEdit returns the edit which restores the pull request
+func (pr PullRequest) toEdit() hosting.PullRequestEdit {
+	return hosting.PullRequestEdit{
+		Title:       ptrutils.Ptr(pr.Title),
+		Body:        ptrutils.Ptr(pr.Body),
+		BaseRefName: ptrutils.Ptr(pr.BaseRefName),
+		State:       ptrutils.Ptr(hosting.PullRequestState(pr.State)),
+	}
+}
+
+func closedPullRequest() hosting.PullRequestEdit {
+	return hosting.PullRequestEdit{
+		State: ptrutils.Ptr(hosting.PullRequestStateClosed),
+	}
+}
@@ -0,0 +1,428 @@
+// Package journal records the state that a command changes so that the command can be undone.
+// The Git and GitHub types wrap a git.GitInterface and hosting.Client and, before each change is made, record
+// the previous state of the remote branch or pull request in an Entry. Entries are saved under the repository's .git
+// directory and Undo restores the state they recorded.
+package journal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ejoffe/spr/bl/lockfile"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/ejoffe/spr/output"
+)
+
+// maxEntries is the number of commands that can be undone
+const maxEntries = 20
+
+// lockStale is the age at which the repository's lock is assumed to have been left behind by a spr that died.
+// It is generous as updating a large stack can take a while.
+const lockStale = 30 * time.Minute
+
+// Entry is the state changed by a single command
+type Entry struct {
+	lock sync.Mutex
+
+	Command string    `json:"command"`
+	Time    time.Time `json:"time"`
+
+	// Head is the local commit and Branch the local branch when the command started
+	Head   string `json:"head"`
+	Branch string `json:"branch"`
+
+	// Remote is the remote the branches were pushed to
+	Remote         string         `json:"remote"`
+	RemoteBranches []RemoteBranch `json:"remoteBranches,omitempty"`
+	PullRequests   []PullRequest  `json:"pullRequests,omitempty"`
+
+	// PRSets is the commit-id to PR set state of the repository when the command started
+	PRSets map[string]int `json:"prSets,omitempty"`
+	// PRSetNames is the names of the PR sets when the command started
+	PRSetNames map[string]int `json:"prSetNames,omitempty"`
+}
+
+// RemoteBranch is the commit a remote branch pointed to before it was changed.
+// The Hash is empty if the branch didn't exist.
+type RemoteBranch struct {
+	Name string `json:"name"`
+	Hash string `json:"hash,omitempty"`
+}
+
+// PullRequest is the state of a pull request before it was changed
+type PullRequest struct {
+	Number      int    `json:"number"`
+	Created     bool   `json:"created,omitempty"`
+	Merged      bool   `json:"merged,omitempty"`
+	Title       string `json:"title,omitempty"`
+	Body        string `json:"body,omitempty"`
+	BaseRefName string `json:"baseRefName,omitempty"`
+	HeadRefName string `json:"headRefName,omitempty"`
+	State       string `json:"state,omitempty"`
+}
+
+type journalFile struct {
+	Entries []*Entry `json:"entries"`
+}
+
+// Begin starts a new entry for the command recording the local HEAD and the PR set state
+func Begin(config *config.Config, gitcmd git.GitInterface, command string) (*Entry, error) {
+	var head string
+	err := gitcmd.Git("rev-parse HEAD", &head)
+	if err != nil {
+		return nil, fmt.Errorf("getting HEAD %w", err)
+	}
+	branch, err := gitcmd.GetLocalBranchShortName()
+	if err != nil {
+		return nil, fmt.Errorf("getting the local branch %w", err)
+	}
+
+	return &Entry{
+		Command:    command,
+		Time:       time.Now(),
+		Head:       head,
+		Branch:     branch,
+		Remote:     config.Repo.PushRemoteName(),
+		PRSets:     maps.Clone(config.PRSets()),
+		PRSetNames: maps.Clone(config.PRSetNames()),
+	}, nil
+}
+
+// Record adds the entry to the journal if the command changed anything
+func Record(gitcmd git.GitInterface, entry *Entry) error {
+	changed, err := entry.changed(gitcmd)
+	if err != nil || !changed {
+		return err
+	}
+
+	path, err := journalPath(gitcmd)
+	if err != nil {
+		return err
+	}
+	entries, err := load(path)
+	if err != nil {
+		return err
+	}
+	entries = append(entries, entry)
+	if len(entries) > maxEntries {
+		entries = entries[len(entries)-maxEntries:]
+	}
+	return save(path, entries)
+}
+
+// Last returns the most recent entry in the journal or nil if the journal is empty
+func Last(gitcmd git.GitInterface) (*Entry, error) {
+	path, err := journalPath(gitcmd)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := load(path)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return entries[len(entries)-1], nil
+}
+
+// RemoveLast removes the most recent entry from the journal
+func RemoveLast(gitcmd git.GitInterface) error {
+	path, err := journalPath(gitcmd)
+	if err != nil {
+		return err
+	}
+	entries, err := load(path)
+	if err != nil || len(entries) == 0 {
+		return err
+	}
+	return save(path, entries[:len(entries)-1])
+}
+
+// changed returns true if any remote changes were recorded or the local HEAD moved
+func (e *Entry) changed(gitcmd git.GitInterface) (bool, error) {
+	e.lock.Lock()
+	remoteChanges := len(e.RemoteBranches) > 0 || len(e.PullRequests) > 0
+	e.lock.Unlock()
+	if remoteChanges {
+		return true, nil
+	}
+
+	var head string
+	err := gitcmd.Git("rev-parse HEAD", &head)
+	if err != nil {
+		return false, fmt.Errorf("getting HEAD %w", err)
+	}
+	return head != e.Head, nil
+}
+
+// recordBranches records the current commit of each of the remote branches that hasn't been recorded yet
+func (e *Entry) recordBranches(gitcmd git.GitInterface, branches []string) error {
+	e.lock.Lock()
+	var unrecorded []string
+	for _, branch := range branches {
+		if !e.hasBranch(branch) {
+			unrecorded = append(unrecorded, branch)
+		}
+	}
+	e.lock.Unlock()
+	if len(unrecorded) == 0 {
+		return nil
+	}
+
+	hashes, err := remoteHashes(gitcmd, e.Remote, unrecorded)
+	if err != nil {
+		return err
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	for _, branch := range unrecorded {
+		if !e.hasBranch(branch) {
+			e.RemoteBranches = append(e.RemoteBranches, RemoteBranch{Name: branch, Hash: hashes[branch]})
+		}
+	}
+	return nil
+}
+
+func (e *Entry) hasBranch(branch string) bool {
+	for _, recorded := range e.RemoteBranches {
+		if recorded.Name == branch {
+			return true
+		}
+	}
+	return false
+}
+
+// recordPullRequest records the pull request if it hasn't been recorded yet
+func (e *Entry) recordPullRequest(pr PullRequest) {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	if e.pullRequest(pr.Number) == nil {
+		e.PullRequests = append(e.PullRequests, pr)
+	}
+}
+
+func (e *Entry) pullRequest(number int) *PullRequest {
+	for i := range e.PullRequests {
+		if e.PullRequests[i].Number == number {
+			return &e.PullRequests[i]
+		}
+	}
+	return nil
+}
+
+// remoteHashes returns the commit each of the branches points to on the remote, missing branches aren't included
+func remoteHashes(gitcmd git.GitInterface, remote string, branches []string) (map[string]string, error) {
+	hashes := map[string]string{}
+	if len(branches) == 0 {
+		return hashes, nil
+	}
+
+	args := []string{"ls-remote", remote}
+	names := map[string]string{}
+	for _, branch := range branches {
+		names[remoteRef(branch)] = branch
+		args = append(args, remoteRef(branch))
+	}
+	var output string
+	err := gitcmd.Git(strings.Join(args, " "), &output)
+	if err != nil {
+		return nil, fmt.Errorf("listing the remote branches %w", err)
+	}
+
+	for _, line := range strings.Split(output, "\n") {
+		hash, ref, found := strings.Cut(line, "\t")
+		if branch, ok := names[ref]; found && ok {
+			hashes[branch] = hash
+		}
+	}
+	return hashes, nil
+}
+
+// remoteRef returns the ref of a recorded branch. Refs outside of refs/heads, like the PR set refs, are recorded by
+// their full name.
+func remoteRef(branch string) string {
+	if strings.HasPrefix(branch, "refs/") {
+		return branch
+	}
+	return "refs/heads/" + branch
+}
+
+// Lock takes the repository's lock, which is held while a command changes the remote branches, pull requests and
+// journal so spr processes in other terminals don't interleave their changes
+func Lock(gitcmd git.GitInterface) (*lockfile.Lock, error) {
+	dir, err := sprDir(gitcmd)
+	if err != nil {
+		return nil, err
+	}
+	return lockfile.Acquire(filepath.Join(dir, "lock"), 0, lockStale)
+}
+
+// journalPath returns the path of the journal, which is kept in the repository's .git directory
+func journalPath(gitcmd git.GitInterface) (string, error) {
+	dir, err := sprDir(gitcmd)
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "journal.json"), nil
+}
+
+// sprDir returns the directory spr keeps its files in under the repository's .git directory
+func sprDir(gitcmd git.GitInterface) (string, error) {
+	var gitDir string
+	err := gitcmd.Git("rev-parse --absolute-git-dir", &gitDir)
+	if err != nil {
+		return "", fmt.Errorf("getting the .git directory %w", err)
+	}
+	return filepath.Join(gitDir, "spr"), nil
+}
+
+func load(path string) ([]*Entry, error) {
+	contents, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading the journal %w", err)
+	}
+
+	var journal journalFile
+	err = json.Unmarshal(contents, &journal)
+	if err != nil {
+		return nil, fmt.Errorf("parsing the journal %s %w", path, err)
+	}
+	return journal.Entries, nil
+}
+
+func save(path string, entries []*Entry) error {
+	contents, err := json.MarshalIndent(journalFile{Entries: entries}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("formatting the journal %w", err)
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("creating the journal directory %w", err)
+	}
+	err = os.WriteFile(path, contents, 0o644)
+	if err != nil {
+		return fmt.Errorf("writing the journal %w", err)
+	}
+	return nil
+}
+
+// Undo restores the remote branches, pull requests, PR set state and local HEAD recorded in the entry
+func (e *Entry) Undo(ctx context.Context, config *config.Config, gitcmd git.GitInterface, github hosting.Client, printer output.Printer) error {
+	branch, err := gitcmd.GetLocalBranchShortName()
+	if err != nil {
+		return fmt.Errorf("getting the local branch %w", err)
+	}
+	if branch != e.Branch {
+		return fmt.Errorf("spr %s was run on branch %s, checkout %s to undo it", e.Command, e.Branch, e.Branch)
+	}
+
+	err = e.Rollback(ctx, config, gitcmd, github, printer)
+	if err != nil {
+		return err
+	}
+
+	var head string
+	err = gitcmd.Git("rev-parse HEAD", &head)
+	if err != nil {
+		return fmt.Errorf("getting HEAD %w", err)
+	}
+	if head != e.Head {
+		printer.Printf("reset %s to %s\n", e.Branch, e.Head[:8])
+		// --keep refuses to overwrite local changes
+		err = gitcmd.Git("reset --keep "+e.Head, nil)
+		if err != nil {
+			return fmt.Errorf("resetting %s to %s %w", e.Branch, e.Head, err)
+		}
+	}
+	return nil
+}
+
+// Rollback restores the remote branches, pull requests and PR set state recorded in the entry, the local commits are
+// left as they are. Branches are restored first so the pull requests can be reopened against them. Merges can't be
+// rolled back. Branches and pull requests already in their recorded state are left alone so a failed rollback can be
+// retried.
+func (e *Entry) Rollback(ctx context.Context, config *config.Config, gitcmd git.GitInterface, github hosting.Client, printer output.Printer) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	var names []string
+	for _, b := range e.RemoteBranches {
+		names = append(names, b.Name)
+	}
+	current, err := remoteHashes(gitcmd, e.Remote, names)
+	if err != nil {
+		return err
+	}
+
+	var refspecs []string
+	for _, b := range e.RemoteBranches {
+		if b.Hash == "" || current[b.Name] == b.Hash {
+			continue
+		}
+		// The PR set refs are restored along with the PR set state without being listed
+		if !strings.HasPrefix(b.Name, "refs/") {
+			printer.Printf("restore branch %s to %s\n", b.Name, b.Hash[:8])
+		}
+		refspecs = append(refspecs, b.Hash+":"+remoteRef(b.Name))
+	}
+	if len(refspecs) > 0 {
+		err = gitcmd.Git(fmt.Sprintf("push --force --atomic %s %s", e.Remote, strings.Join(refspecs, " ")), nil)
+		if err != nil {
+			return fmt.Errorf("restoring branches %w", err)
+		}
+	}
+
+	// Newest changes first
+	for i := len(e.PullRequests) - 1; i >= 0; i-- {
+		pr := e.PullRequests[i]
+		switch {
+		case pr.Merged:
+			printer.Printf("pull request #%d was merged, the merge can't be undone\n", pr.Number)
+		case pr.Created:
+			printer.Printf("close pull request #%d\n", pr.Number)
+			err = github.EditPullRequest2(ctx, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName, pr.Number, closedPullRequest())
+		default:
+			printer.Printf("restore pull request #%d : %s\n", pr.Number, pr.Title)
+			err = github.EditPullRequest2(ctx, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName, pr.Number, pr.toEdit())
+		}
+		if err != nil {
+			return fmt.Errorf("restoring pull request #%d %w", pr.Number, err)
+		}
+	}
+
+	for _, b := range e.RemoteBranches {
+		if b.Hash != "" || current[b.Name] == "" {
+			continue
+		}
+		if strings.HasPrefix(b.Name, "refs/") {
+			err = gitcmd.Git(fmt.Sprintf("push %s :%s", e.Remote, b.Name), nil)
+			if err != nil {
+				return fmt.Errorf("deleting %s %w", b.Name, err)
+			}
+			continue
+		}
+		printer.Printf("delete branch %s\n", b.Name)
+		err = gitcmd.DeleteRemoteBranch(ctx, b.Name)
+		if err != nil {
+			return err
+		}
+	}
+
+	config.SetPRSets(e.PRSets)
+	config.SetPRSetNames(e.PRSetNames)
+	return nil
+}
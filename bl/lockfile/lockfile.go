@@ -0,0 +1,134 @@
+// Package lockfile provides advisory locks that are held by creating a lock file, so they behave the same on every
+// platform. A lock file older than the stale timeout is assumed to have been left behind by a process that died and is
+// taken over.
+package lockfile
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ErrLocked is returned when the lock is held by another process
+var ErrLocked = errors.New("another spr is running")
+
+// ErrLost is returned by Release when the lock was taken over as stale by another process
+var ErrLost = errors.New("the lock was taken over by another spr")
+
+// retryInterval is how often a held lock is retried while waiting for it
+const retryInterval = 50 * time.Millisecond
+
+// Lock is a held lock, holder is the contents of its lock file
+type Lock struct {
+	path   string
+	holder string
+}
+
+// Acquire takes the lock by creating the lock file at path. If another process holds the lock it is retried until wait
+// has passed, then an ErrLocked error saying which process holds it is returned.
+func Acquire(path string, wait time.Duration, stale time.Duration) (*Lock, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return nil, fmt.Errorf("creating the lock directory %w", err)
+	}
+
+	// The nonce tells this lock from an earlier one of the same process
+	host, _ := os.Hostname()
+	holder := fmt.Sprintf("pid %d on %s\nnonce %s\n", os.Getpid(), host, rand.Text())
+
+	deadline := time.Now().Add(wait)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_, err = file.WriteString(holder)
+			err = errors.Join(err, file.Close())
+			if err != nil {
+				os.Remove(path)
+				return nil, fmt.Errorf("writing the lock file %s %w", path, err)
+			}
+			return &Lock{path: path, holder: holder}, nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("creating the lock file %s %w", path, err)
+		}
+
+		info, err := os.Stat(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			// Released in the meantime
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading the lock file %s %w", path, err)
+		}
+
+		if time.Since(info.ModTime()) > stale {
+			err = takeOverStale(path, stale)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			holder, _ := os.ReadFile(path)
+			pid, _, _ := strings.Cut(string(holder), "\n")
+			return nil, fmt.Errorf("%w (%s since %s), remove %s if it isn't", ErrLocked,
+				strings.TrimSpace(pid), info.ModTime().Format(time.TimeOnly), path)
+		}
+		time.Sleep(retryInterval)
+	}
+}
+
+// takeOverStale removes the stale lock file at path. It is renamed aside first so only one process takes it over, and
+// is put back if it turns out another process replaced the stale lock file with its own in the meantime.
+func takeOverStale(path string, stale time.Duration) error {
+	aside := path + ".stale." + rand.Text()
+	err := os.Rename(path, aside)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Taken over or released in the meantime
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("removing the stale lock file %s %w", path, err)
+	}
+	defer os.Remove(aside)
+
+	info, err := os.Stat(aside)
+	if err != nil {
+		return fmt.Errorf("reading the stale lock file %s %w", aside, err)
+	}
+	if time.Since(info.ModTime()) <= stale {
+		// The lock file of a running process, it is put back unless yet another process holds the lock now
+		err = os.Link(aside, path)
+		if err != nil && !errors.Is(err, fs.ErrExist) {
+			return fmt.Errorf("restoring the lock file %s %w", path, err)
+		}
+	}
+	return nil
+}
+
+// Release releases the lock by removing the lock file. If the lock file was taken over by another process, as it was
+// held for longer than the stale timeout, it is left alone and ErrLost is returned.
+func (l *Lock) Release() error {
+	holder, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading the lock file %s %w", l.path, err)
+	}
+	if string(holder) != l.holder {
+		return fmt.Errorf("%w (%s)", ErrLost, l.path)
+	}
+
+	err = os.Remove(l.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("removing the lock file %s %w", l.path, err)
+	}
+	return nil
+}
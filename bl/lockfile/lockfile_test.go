@@ -0,0 +1,75 @@
+package lockfile_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ejoffe/spr/bl/lockfile"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcquireRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spr", "lock")
+
+	lock, err := lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+	require.FileExists(t, path)
+
+	_, err = lockfile.Acquire(path, 0, time.Minute)
+	require.ErrorIs(t, err, lockfile.ErrLocked)
+	require.ErrorContains(t, err, "another spr is running (pid ")
+	require.ErrorContains(t, err, "remove "+path+" if it isn't")
+
+	require.NoError(t, lock.Release())
+	require.NoFileExists(t, path)
+
+	lock, err = lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+}
+
+func TestAcquireWaitsForRelease(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+
+	first, err := lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+	time.AfterFunc(100*time.Millisecond, func() { first.Release() })
+
+	second, err := lockfile.Acquire(path, 10*time.Second, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, second.Release())
+}
+
+func TestAcquireRemovesStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	require.NoError(t, os.WriteFile(path, []byte("pid 1 on elsewhere\n"), 0o644))
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	lock, err := lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+	require.NoError(t, lock.Release())
+
+	// The stale lock file isn't left aside
+	entries, err := os.ReadDir(filepath.Dir(path))
+	require.NoError(t, err)
+	require.Empty(t, entries)
+}
+
+func TestReleaseLeavesLockTakenOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "lock")
+	lock, err := lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+
+	other, err := lockfile.Acquire(path, 0, time.Minute)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, lock.Release(), lockfile.ErrLost)
+	require.FileExists(t, path)
+	require.NoError(t, other.Release())
+	require.NoFileExists(t, path)
+}
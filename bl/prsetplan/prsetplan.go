@@ -0,0 +1,248 @@
+// Package prsetplan formats the PR sets of the local commits as an editable plan, like the todo list of git rebase -i,
+// and applies the edited plan to the state.
+//
+// Each commit has a line with its PR set, index, commit-id and subject, newest first like spr status. The PR set is
+// edited to move the commit:
+//
+//	sN         the commit is in PR set N, a PR set that doesn't exist yet is created
+//	name       the commit is in the named PR set, a new name creates a new PR set
+//	sN(name)   the commit is in PR set N which is named name
+//	--         the commit isn't in a PR set
+//
+// The N of a PR set that doesn't exist yet is a placeholder grouping its commits, new PR sets get the next free
+// indices in the order of their oldest commit.
+package prsetplan
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ejoffe/spr/bl/internal"
+	"github.com/ejoffe/spr/bl/selector"
+)
+
+// ErrInvalidPlan is returned by Parse if a line of the plan can't be parsed
+var ErrInvalidPlan = errors.New("invalid PR set plan")
+
+// noPRSet is the PR set of commits that aren't in a PR set
+const noPRSet = "--"
+
+const help = `
+# Edit the PR set of each commit and save to update the PR sets, the commits are listed newest first.
+#
+# sN         put the commit in PR set N, a PR set that doesn't exist yet is created
+# name       put the commit in the named PR set, a new name creates a new PR set
+# sN(name)   put the commit in PR set N and name it
+# --         take the commit out of its PR set
+#
+# The N of a new PR set only groups its commits, new PR sets are numbered after the existing ones.
+# The order of the lines doesn't matter and lines starting with # are ignored.
+# If you remove everything the update is aborted.
+`
+
+var labelRegex = regexp.MustCompile(`^s(\d+)(?:\((.*)\))?$`)
+
+// Format returns the plan of the PR sets of the local commits
+func Format(state *internal.State) string {
+	labels := []string{}
+	width := len(noPRSet)
+	for _, commit := range state.LocalCommits {
+		label := noPRSet
+		if commit.PRIndex != nil {
+			label = fmt.Sprintf("s%d", *commit.PRIndex)
+			if name := internal.PRSetName(state.PRSetNames, *commit.PRIndex); name != "" {
+				label += "(" + name + ")"
+			}
+		}
+		labels = append(labels, label)
+		width = max(width, len(label))
+	}
+
+	var b strings.Builder
+	for i, commit := range state.LocalCommits {
+		fmt.Fprintf(&b, "%-*s %2d %s %s\n", width, labels[i], commit.Index, commit.CommitID, commit.Subject)
+	}
+	b.WriteString(help)
+	return b.String()
+}
+
+// Plan is the PR sets of the commits parsed from an edited plan
+type Plan struct {
+	sets []*prSet
+}
+
+// prSet is a PR set of the plan, prIndex is nil for a PR set that doesn't exist yet
+type prSet struct {
+	prIndex *int
+	name    string
+	commits mapset.Set[int]
+}
+
+// Parse parses the edited plan. Every local commit must be listed once, unless the plan is empty in which case nil is
+// returned to abort the update.
+func Parse(state *internal.State, plan string) (*Plan, error) {
+	byCommitId := map[string]*internal.LocalCommit{}
+	existing := mapset.NewSet[int]()
+	for _, commit := range state.LocalCommits {
+		byCommitId[commit.CommitID] = commit
+		if commit.PRIndex != nil {
+			existing.Add(*commit.PRIndex)
+		}
+	}
+
+	sets := map[string]*prSet{}
+	order := []string{}
+	listed := mapset.NewSet[string]()
+	for lineNo, line := range strings.Split(plan, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		invalid := func(format string, a ...any) error {
+			return fmt.Errorf("%w: line %d %q: %s", ErrInvalidPlan, lineNo+1, line, fmt.Sprintf(format, a...))
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) < 3 {
+			return nil, invalid("expected a PR set, commit index and commit-id")
+		}
+		commit, ok := byCommitId[fields[2]]
+		if !ok {
+			return nil, invalid("unknown commit-id %s", fields[2])
+		}
+		if listed.Contains(commit.CommitID) {
+			return nil, invalid("commit %s is listed more than once", commit.CommitID)
+		}
+		listed.Add(commit.CommitID)
+
+		label := fields[0]
+		if label == noPRSet {
+			continue
+		}
+		key, set, err := parseLabel(label, existing, state.PRSetNames)
+		if err != nil {
+			return nil, invalid("%s", err)
+		}
+		if current, ok := sets[key]; ok {
+			if current.name != "" && set.name != "" && current.name != set.name {
+				return nil, invalid("PR set %s is named both %s and %s", key, current.name, set.name)
+			}
+			set.name = cmp.Or(set.name, current.name)
+			set.commits = current.commits
+		} else {
+			set.commits = mapset.NewSet[int]()
+			order = append(order, key)
+		}
+		set.commits.Add(commit.Index)
+		sets[key] = set
+	}
+
+	if listed.Cardinality() == 0 {
+		return nil, nil
+	}
+
+	named := map[string]string{}
+	result := &Plan{}
+	for _, key := range order {
+		set := sets[key]
+		if set.name != "" {
+			if other, ok := named[set.name]; ok {
+				return nil, fmt.Errorf("%w: %s names both %s and %s", ErrInvalidPlan, set.name, other, key)
+			}
+			named[set.name] = key
+		}
+		result.sets = append(result.sets, set)
+	}
+	for _, commit := range state.LocalCommits {
+		if !listed.Contains(commit.CommitID) {
+			return nil, fmt.Errorf("%w: commit %d %s is missing, use %s to take it out of its PR set",
+				ErrInvalidPlan, commit.Index, commit.CommitID, noPRSet)
+		}
+	}
+	// New PR sets are numbered in the order of their oldest commit
+	slices.SortStableFunc(result.sets, func(a, b *prSet) int {
+		return slices.Min(a.commits.ToSlice()) - slices.Min(b.commits.ToSlice())
+	})
+	return result, nil
+}
+
+// parseLabel parses the PR set of a line and returns the key identifying the PR set in the plan and the PR set
+func parseLabel(label string, existing mapset.Set[int], names map[string]int) (string, *prSet, error) {
+	if matches := labelRegex.FindStringSubmatch(label); matches != nil {
+		prIndex, err := strconv.Atoi(matches[1])
+		if err != nil {
+			return "", nil, fmt.Errorf("invalid PR set %s", label)
+		}
+		name := matches[2]
+		if strings.Contains(label, "(") && !selector.ValidPRSetName(name) {
+			return "", nil, fmt.Errorf("%q can't be used as a PR set name", name)
+		}
+		set := &prSet{name: name}
+		if existing.Contains(prIndex) {
+			set.prIndex = &prIndex
+		}
+		return "s" + matches[1], set, nil
+	}
+
+	if !selector.ValidPRSetName(label) {
+		return "", nil, fmt.Errorf("%q isn't a PR set, name or %s", label, noPRSet)
+	}
+	if prIndex, ok := names[label]; ok && existing.Contains(prIndex) {
+		return fmt.Sprintf("s%d", prIndex), &prSet{prIndex: &prIndex, name: label}, nil
+	}
+	return label, &prSet{name: label}, nil
+}
+
+// Apply applies the plan to the state with State.ApplyIndices. Commits are first added to their PR sets, which moves
+// them along with their pull requests, then the PR sets are trimmed to the planned commits so only the commits taken
+// out of a PR set have their pull requests closed. PR sets keep their names unless they are renamed.
+func Apply(state *internal.State, plan *Plan) {
+	if plan == nil {
+		return
+	}
+
+	claimed := mapset.NewSet[string]()
+	for _, set := range plan.sets {
+		claimed.Add(set.name)
+	}
+
+	names := maps.Clone(state.PRSetNames)
+	planned := map[int]mapset.Set[int]{}
+	for _, set := range plan.sets {
+		commits := set.commits.Clone()
+		name := set.name
+		if set.prIndex != nil {
+			for _, commit := range state.CommitsByPRSet(*set.prIndex) {
+				commits.Add(commit.Index)
+			}
+			// The PR set may have lost its commits, and its name, to the PR sets before it
+			if existing := internal.PRSetName(names, *set.prIndex); name == "" && !claimed.Contains(existing) {
+				name = existing
+			}
+		}
+		indices := internal.Indices{DestinationPRIndex: set.prIndex, DestinationName: name, CommitIndexes: commits}
+		state.ApplyIndices(&indices)
+		planned[*indices.DestinationPRIndex] = set.commits
+	}
+
+	prIndexes := mapset.NewSet[int]()
+	for _, commit := range state.LocalCommits {
+		if commit.PRIndex != nil {
+			prIndexes.Add(*commit.PRIndex)
+		}
+	}
+	for _, prIndex := range mapset.Sorted(prIndexes) {
+		commits, ok := planned[prIndex]
+		if !ok {
+			commits = mapset.NewSet[int]()
+		}
+		state.ApplyIndices(&internal.Indices{DestinationPRIndex: &prIndex, CommitIndexes: commits})
+	}
+}
@@ -0,0 +1,176 @@
+package prsetplan_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/ejoffe/spr/bl/internal"
+	"github.com/ejoffe/spr/bl/prsetplan"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+// testingState returns a state with the commits 3 (newest) to 0, commits 0 and 1 are in s0 named auth and commit 2 is
+// in s1. Commit i has the commit-id iiiiiiii.
+func testingState() *internal.State {
+	prIndexes := []*int{ptrutils.Ptr(0), ptrutils.Ptr(0), ptrutils.Ptr(1), nil}
+	commits := []*internal.LocalCommit{}
+	for i := 3; i >= 0; i-- {
+		commit := &internal.LocalCommit{
+			Commit: git.Commit{
+				CommitID: strings.Repeat(fmt.Sprintf("%d", i), 8),
+				Subject:  fmt.Sprintf("commit %d", i),
+			},
+			Index:   i,
+			PRIndex: prIndexes[i],
+		}
+		if prIndexes[i] != nil {
+			commit.PullRequest = &hosting.PullRequest{Number: i + 1}
+		}
+		commits = append(commits, commit)
+	}
+	return &internal.State{
+		LocalCommits:  commits,
+		OrphanedPRs:   mapset.NewSet[*hosting.PullRequest](),
+		MutatedPRSets: mapset.NewSet[int](),
+		PRSetNames:    map[string]int{"auth": 0},
+	}
+}
+
+// prIndexes returns the PR set of the commits ordered by index, -1 for commits that aren't in a PR set
+func prIndexes(state *internal.State) []int {
+	result := make([]int, len(state.LocalCommits))
+	for _, commit := range state.LocalCommits {
+		result[commit.Index] = -1
+		if commit.PRIndex != nil {
+			result[commit.Index] = *commit.PRIndex
+		}
+	}
+	return result
+}
+
+func TestFormat(t *testing.T) {
+	plan := prsetplan.Format(testingState())
+	lines := strings.Split(plan, "\n")
+	require.Equal(t, []string{
+		"--        3 33333333 commit 3",
+		"s1        2 22222222 commit 2",
+		"s0(auth)  1 11111111 commit 1",
+		"s0(auth)  0 00000000 commit 0",
+	}, lines[:4])
+	require.Contains(t, plan, "# sN(name)   put the commit in PR set N and name it")
+
+	// The unedited plan doesn't change anything
+	state := testingState()
+	parsed, err := prsetplan.Parse(state, plan)
+	require.NoError(t, err)
+	prsetplan.Apply(state, parsed)
+	require.Equal(t, testingState(), state)
+}
+
+func TestParseApply(t *testing.T) {
+	tests := []struct {
+		desc      string
+		plan      string
+		prIndexes []int
+		mutated   []int
+		orphaned  []int
+		names     map[string]int
+	}{
+		{
+			desc:      "create a new PR set",
+			plan:      "s7 3 33333333\ns1 2 22222222\nauth 1 11111111\ns0 0 00000000",
+			prIndexes: []int{0, 0, 1, 2},
+			mutated:   []int{2},
+			names:     map[string]int{"auth": 0},
+		},
+		{
+			desc:      "create a named PR set",
+			plan:      "docs 3 33333333\n-- 2 22222222\ns0 1 11111111\ns0 0 00000000",
+			prIndexes: []int{0, 0, -1, 2},
+			mutated:   []int{2},
+			orphaned:  []int{2},
+			names:     map[string]int{"auth": 0, "docs": 2},
+		},
+		{
+			desc:      "move a commit between PR sets keeps its pull request",
+			plan:      "-- 3 33333333\nauth 2 22222222\ns1 1 11111111\nauth 0 00000000",
+			prIndexes: []int{0, 1, 0, -1},
+			mutated:   []int{0, 1},
+			names:     map[string]int{"auth": 0},
+		},
+		{
+			desc:      "swap the commits of named PR sets",
+			plan:      "-- 3 33333333\ns0(auth) 2 22222222\ns1(docs) 1 11111111\ns1(docs) 0 00000000",
+			prIndexes: []int{1, 1, 0, -1},
+			mutated:   []int{0, 1},
+			names:     map[string]int{"auth": 0, "docs": 1},
+		},
+		{
+			desc:      "rename a PR set",
+			plan:      "-- 3 33333333\ns1 2 22222222\ns0(oauth) 1 11111111\ns0(oauth) 0 00000000",
+			prIndexes: []int{0, 0, 1, -1},
+			names:     map[string]int{"oauth": 0},
+		},
+		{
+			desc:      "remove all commits from a PR set",
+			plan:      "-- 3 33333333\n-- 2 22222222\ns0 1 11111111\n-- 0 00000000",
+			prIndexes: []int{-1, 0, -1, -1},
+			mutated:   []int{0},
+			orphaned:  []int{0, 2},
+			names:     map[string]int{"auth": 0},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			state := testingState()
+			plan, err := prsetplan.Parse(state, test.plan)
+			require.NoError(t, err)
+			prsetplan.Apply(state, plan)
+
+			require.Equal(t, test.prIndexes, prIndexes(state))
+			require.ElementsMatch(t, test.mutated, state.MutatedPRSets.ToSlice())
+			orphaned := []int{}
+			for pr := range state.OrphanedPRs.Iter() {
+				orphaned = append(orphaned, pr.Number-1)
+			}
+			require.ElementsMatch(t, test.orphaned, orphaned)
+			require.Equal(t, test.names, state.PRSetNames)
+		})
+	}
+}
+
+func TestParseEmptyPlanAborts(t *testing.T) {
+	plan, err := prsetplan.Parse(testingState(), "# nothing\n\n")
+	require.NoError(t, err)
+	require.Nil(t, plan)
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		plan string
+		msg  string
+	}{
+		{plan: "s0 3", msg: "line 1 \"s0 3\": expected a PR set"},
+		{plan: "s0 3 deadbeef", msg: "unknown commit-id deadbeef"},
+		{plan: "s0 3 33333333\ns1 3 33333333", msg: "line 2 \"s1 3 33333333\": commit 33333333 is listed more than once"},
+		{plan: "top 3 33333333", msg: `"top" isn't a PR set, name or --`},
+		{plan: "s0(s1) 3 33333333", msg: `"s1" can't be used as a PR set name`},
+		{plan: "s0(a) 3 33333333\ns0(b) 2 22222222", msg: "PR set s0 is named both a and b"},
+		{plan: "s0(a) 3 33333333\ns1(a) 2 22222222", msg: "a names both s0 and s1"},
+		{plan: "s0 3 33333333", msg: "commit 2 22222222 is missing, use -- to take it out of its PR set"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.plan, func(t *testing.T) {
+			_, err := prsetplan.Parse(testingState(), test.plan)
+			require.ErrorIs(t, err, prsetplan.ErrInvalidPlan)
+			require.ErrorContains(t, err, test.msg)
+		})
+	}
+}
@@ -1,169 +1,448 @@
+// Package selector evaluates the commit selectors given to spr update. A selector is an optional destination PR set
+// followed by a comma separated list of terms:
+//
+//	sN:            rewrite PR set N with the selected commits
+//	sN+            add the selected commits to PR set N
+//	name:, name+   the same for the PR set with the name, a new name names the PR set of the selected commits
+//	3              the commit with index 3
+//	1-3, 3-, -2    a range of indices, open ranges run to the newest or from the oldest commit
+//	sN, name       the commits in PR set N or the named PR set
+//	top N          the N newest commits (top alone is the newest)
+//	bottom N       the N oldest commits (bottom alone is the oldest)
+//	1a2b3c         the commit whose hash or commit-id starts with the hex prefix, which has 4 or more digits and
+//	               isn't a number
+//	/regex/        the commits whose subject matches the regex, a / in the regex is written \/
+//	unassigned     the commits that aren't in a PR set
+//	!term          excludes the commits of the term, a selector with only exclusions starts from all commits
 package selector
 
 import (
 	"errors"
 	"fmt"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"unicode"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/ejoffe/spr/bl/internal"
+	"github.com/ejoffe/spr/bl/ptrutils"
 )
 
 // ErrInvalidSelector is returned by Evaluate if the selector is invalid
 var ErrInvalidSelector = errors.New("invalid commit selector")
 
-func splitAndClean(s string, sep string) []string {
+// minHashPrefix is the shortest hex prefix that is matched against the commit hashes and commit-ids
+const minHashPrefix = 4
+
+// keywords are the terms that can't be used as PR set names
+var keywords = []string{"top", "bottom", "unassigned"}
+
+// Error is an invalid selector. Pos is the byte offset in the selector where the problem is.
+type Error struct {
+	Selector string
+	Pos      int
+	Msg      string
+}
+
+// Error returns the message followed by the selector with a caret under the problem
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s\n  %s\n  %s^", ErrInvalidSelector, e.Msg, e.Selector, strings.Repeat(" ", e.Pos))
+}
+
+func (e *Error) Unwrap() error {
+	return ErrInvalidSelector
+}
+
+func AsPRSet(s string) (int, bool) {
 	s = strings.TrimSpace(s)
 
-	var result []string
-	for _, str := range strings.Split(s, sep) {
-		if str != "" {
-			result = append(result, strings.TrimSpace(str))
+	if rest, found := strings.CutPrefix(s, "s"); found {
+		if n, err := strconv.Atoi(rest); err == nil && n >= 0 {
+			return n, true
 		}
 	}
-	return result
+
+	return 0, false
 }
 
-func asInteger(s string) (int, bool) {
-	s = strings.TrimSpace(s)
+// AsPRSetOrName returns the index of a PR set given as sN or by its name
+func AsPRSetOrName(s string, names map[string]int) (int, bool) {
+	if prIndex, ok := AsPRSet(s); ok {
+		return prIndex, true
+	}
+	prIndex, ok := names[strings.TrimSpace(s)]
+	return prIndex, ok
+}
+
+// ValidPRSetName returns true if s can be used as a PR set name, a letter followed by letters, digits, '-', '_' or '.'
+// that isn't a keyword or sN
+func ValidPRSetName(s string) bool {
+	p := parser{selector: s}
+	name, _ := p.name()
+	return name != "" && name == s && !reservedName(name)
+}
 
-	number, err := strconv.Atoi(s)
-	return number, err == nil
+// reservedName returns true if the name is a keyword or looks like sN
+func reservedName(name string) bool {
+	return slices.Contains(keywords, name) || (len(name) > 1 && name[0] == 's' && isDigits(name[1:]))
 }
 
-func asRange(r string) (int, int, bool) {
-	r = strings.TrimSpace(r)
+// Evaluate evaluates the selector string and existing pull request sets and returns an Indices.
+// names maps the names of the named PR sets to their index.
+func Evaluate(commits []*internal.LocalCommit, names map[string]int, selector string) (internal.Indices, error) {
+	p := parser{selector: selector, commits: commits, names: names}
 
-	parts := splitAndClean(r, "-")
-	if len(parts) != 2 {
-		return 0, 0, false
+	indices := internal.Indices{}
+	destination, additive, err := p.destination(&indices)
+	if err == nil {
+		indices.CommitIndexes, err = p.commitIndexes()
+	}
+	if err == nil && additive && indices.DestinationPRIndex != nil {
+		// treat the "s#+..." as "s#:s#,..."
+		err = p.addPRSet(indices.CommitIndexes, *indices.DestinationPRIndex, destination, 0)
+	}
+	if err != nil {
+		indices.CommitIndexes = mapset.NewSet[int]()
+		return indices, err
 	}
 
-	parts = []string{
-		strings.TrimSpace(parts[0]),
-		strings.TrimSpace(parts[1]),
+	// Naming the commits of an existing PR set names that PR set rather than moving its commits to a new one
+	if indices.DestinationName != "" && indices.DestinationPRIndex == nil {
+		indices.DestinationPRIndex = p.samePRSet(indices.CommitIndexes)
 	}
+	return indices, nil
+}
 
-	start, err := strconv.Atoi(parts[0])
-	if err != nil {
-		return 0, 0, false
+// parser is a recursive descent parser of a selector, pos is the offset of the next byte to parse
+type parser struct {
+	selector string
+	pos      int
+	commits  []*internal.LocalCommit
+	names    map[string]int
+}
+
+func (p *parser) errorf(pos int, format string, a ...any) error {
+	return &Error{Selector: p.selector, Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.selector) && p.selector[p.pos] == ' ' {
+		p.pos++
 	}
+}
 
-	end, err := strconv.Atoi(parts[1])
-	if err != nil {
-		return 0, 0, false
+// peek returns the next byte after any whitespace, or 0 at the end of the selector
+func (p *parser) peek() byte {
+	p.skipSpace()
+	if p.pos == len(p.selector) {
+		return 0
 	}
+	return p.selector[p.pos]
+}
 
-	if start > end {
-		return 0, 0, false
+// word consumes the next run of letters and digits and returns it with its offset
+func (p *parser) word() (string, int) {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.selector) && isWordByte(p.selector[p.pos]) {
+		p.pos++
 	}
+	return p.selector[start:p.pos], start
+}
 
-	return start, end, true
+func isWordByte(b byte) bool {
+	return b < unicode.MaxASCII && (unicode.IsLetter(rune(b)) || unicode.IsDigit(rune(b)))
 }
 
-func AsPRSet(s string) (int, bool) {
-	s = strings.TrimSpace(s)
+func isDigits(s string) bool {
+	return s != "" && strings.Trim(s, "0123456789") == ""
+}
 
-	if rest, found := strings.CutPrefix(s, "s"); found {
-		if n, ok := asInteger(rest); ok {
-			return n, true
-		}
+// name consumes the next PR set name, a letter followed by letters, digits, '-', '_' or '.', and returns it with its
+// offset. The name is empty if the next byte isn't a letter.
+func (p *parser) name() (string, int) {
+	p.skipSpace()
+	start := p.pos
+	if p.pos == len(p.selector) || !unicode.IsLetter(rune(p.selector[p.pos])) {
+		return "", start
+	}
+	for p.pos < len(p.selector) && (isWordByte(p.selector[p.pos]) || strings.IndexByte("-_.", p.selector[p.pos]) >= 0) {
+		p.pos++
+	}
+	return p.selector[start:p.pos], start
+}
+
+// destination parses the optional sN: sN+ name: or name+ at the start of the selector and returns the destination as
+// written and true if the selected commits are added to the destination
+func (p *parser) destination(indices *internal.Indices) (string, bool, error) {
+	name, start := p.name()
+	next := p.peek()
+	if name == "" || (next != ':' && next != '+') {
+		p.pos = 0
+		return "", false, nil
 	}
+	p.pos++
 
-	return 0, false
+	if prIndex, ok := AsPRSet(name); ok {
+		indices.DestinationPRIndex = &prIndex
+		return name, next == '+', nil
+	}
+	if reservedName(name) {
+		return "", false, p.errorf(start, "%s can't be used as a PR set name", name)
+	}
+	indices.DestinationName = name
+	if prIndex, ok := p.names[name]; ok {
+		indices.DestinationPRIndex = &prIndex
+	}
+	return name, next == '+', nil
 }
 
-func asDestination(s string) (int, string, bool) {
-	s = strings.TrimSpace(s)
+// samePRSet returns the index of the PR set if the commits are exactly the commits of a PR set
+func (p *parser) samePRSet(indexes mapset.Set[int]) *int {
+	var prIndex *int
+	for _, commit := range p.commits {
+		if !indexes.Contains(commit.Index) {
+			continue
+		}
+		if commit.PRIndex == nil || (prIndex != nil && *prIndex != *commit.PRIndex) {
+			return nil
+		}
+		prIndex = commit.PRIndex
+	}
+	if prIndex == nil {
+		return nil
+	}
+	for _, commit := range p.commits {
+		if commit.PRIndex != nil && *commit.PRIndex == *prIndex && !indexes.Contains(commit.Index) {
+			return nil
+		}
+	}
+	return ptrutils.Ptr(*prIndex)
+}
 
-	// Split by ":"
-	parts := strings.Split(s, ":")
-	if len(parts) == 2 {
+// commitIndexes parses the comma separated terms and returns the indices of the selected commits
+func (p *parser) commitIndexes() (mapset.Set[int], error) {
+	included := mapset.NewSet[int]()
+	excluded := mapset.NewSet[int]()
+	hasIncluded := false
 
-		parts = []string{
-			strings.TrimSpace(parts[0]),
-			strings.TrimSpace(parts[1]),
+	if p.peek() == 0 {
+		return included, nil
+	}
+	for {
+		termIndexes := included
+		if p.peek() == '!' {
+			p.pos++
+			termIndexes = excluded
+		} else {
+			hasIncluded = true
 		}
 
-		if prIndex, ok := AsPRSet(parts[0]); ok {
-			return prIndex, parts[1], true
+		err := p.term(termIndexes)
+		if err != nil {
+			return nil, err
+		}
+
+		switch p.peek() {
+		case 0:
+			if !hasIncluded {
+				for _, commit := range p.commits {
+					included.Add(commit.Index)
+				}
+			}
+			return included.Difference(excluded), nil
+		case ',':
+			p.pos++
+		default:
+			return nil, p.errorf(p.pos, "expected a comma")
 		}
 	}
+}
 
-	// Split by "+"
-	parts = strings.Split(s, "+")
-	if len(parts) != 2 {
-		return 0, "", false
+// term parses a single term and adds the indices of the commits it selects
+func (p *parser) term(indexes mapset.Set[int]) error {
+	if p.peek() == '/' {
+		return p.subject(indexes)
+	}
+	if p.peek() == '-' {
+		// Open range starting at the oldest commit
+		start := p.pos
+		p.pos++
+		to, err := p.index()
+		if err != nil {
+			return err
+		}
+		return p.addRange(indexes, 0, to, start)
 	}
 
-	parts = []string{
-		strings.TrimSpace(parts[0]),
-		strings.TrimSpace(parts[1]),
+	if name, start := p.name(); name != "" {
+		if prIndex, ok := p.names[name]; ok {
+			return p.addPRSet(indexes, prIndex, name, start)
+		}
+		p.pos = start
 	}
 
-	if prIndex, ok := AsPRSet(parts[0]); ok {
-		// treat the "s#+..." as "s#:s#,..."
-		return prIndex, parts[0] + "," + parts[1], true
+	word, start := p.word()
+	switch {
+	case word == "":
+		if p.pos == len(p.selector) {
+			return p.errorf(start, "expected a commit")
+		}
+		return p.errorf(start, "unexpected %q", p.selector[start])
+	case isDigits(word):
+		from, err := p.checkIndex(word, start)
+		if err != nil {
+			return err
+		}
+		if p.peek() != '-' {
+			indexes.Add(from)
+			return nil
+		}
+		p.pos++
+		// Open range ending at the newest commit
+		if !isWordByte(p.peek()) {
+			return p.addRange(indexes, from, len(p.commits)-1, start)
+		}
+		to, err := p.index()
+		if err != nil {
+			return err
+		}
+		return p.addRange(indexes, from, to, start)
+	case word == "top" || word == "bottom":
+		count := 1
+		if isDigits(string(p.peek())) {
+			countWord, countStart := p.word()
+			count, _ = strconv.Atoi(countWord)
+			if count < 1 || count > len(p.commits) {
+				return p.errorf(countStart, "%s %d is not valid with %d commits", word, count, len(p.commits))
+			}
+		} else if len(p.commits) == 0 {
+			return p.errorf(start, "there are no commits")
+		}
+		if word == "top" {
+			return p.addRange(indexes, len(p.commits)-count, len(p.commits)-1, start)
+		}
+		return p.addRange(indexes, 0, count-1, start)
+	case word == "unassigned":
+		for _, commit := range p.commits {
+			if commit.PRIndex == nil {
+				indexes.Add(commit.Index)
+			}
+		}
+		return nil
+	}
+
+	if prIndex, ok := AsPRSet(word); ok {
+		return p.addPRSet(indexes, prIndex, word, start)
+	}
+	if len(word) >= minHashPrefix && strings.Trim(strings.ToLower(word), "0123456789abcdef") == "" {
+		return p.hash(indexes, strings.ToLower(word), start)
+	}
+	return p.errorf(start, "unknown term %q", word)
+}
+
+// index parses a commit index
+func (p *parser) index() (int, error) {
+	word, start := p.word()
+	if !isDigits(word) {
+		return 0, p.errorf(start, "expected a commit index")
 	}
+	return p.checkIndex(word, start)
+}
 
-	return 0, "", false
+func (p *parser) checkIndex(word string, start int) (int, error) {
+	index, err := strconv.Atoi(word)
+	if err != nil || index >= len(p.commits) {
+		return 0, p.errorf(start, "commit index %s is not valid", word)
+	}
+	return index, nil
 }
 
-// Evaluate evaluates the selector string and existing pull request sets and returns an Indices
-func Evaluate(commits []*internal.LocalCommit, selector string) (internal.Indices, error) {
-	var destinationPRIndex *int
-	if destIndex, rest, ok := asDestination(selector); ok {
-		destinationPRIndex = &destIndex
-		selector = rest
+func (p *parser) addRange(indexes mapset.Set[int], from int, to int, start int) error {
+	if from > to {
+		return p.errorf(start, "range %d-%d is reversed", from, to)
 	}
+	for n := from; n <= to; n++ {
+		indexes.Add(n)
+	}
+	return nil
+}
 
-	indexes, err := evalateCommitIndexes(commits, selector)
-	return internal.Indices{
-		DestinationPRIndex: destinationPRIndex,
-		CommitIndexes:      indexes,
-	}, err
+// addPRSet adds the commits of the PR set, token is the PR set as written in the selector
+func (p *parser) addPRSet(indexes mapset.Set[int], prIndex int, token string, start int) error {
+	validPr := false
+	for _, commit := range p.commits {
+		if commit.PRIndex != nil && *commit.PRIndex == prIndex {
+			indexes.Add(commit.Index)
+			validPr = true
+		}
+	}
+	if !validPr {
+		return p.errorf(start, "invalid pull request set %s", token)
+	}
+	return nil
 }
 
-func evalateCommitIndexes(commits []*internal.LocalCommit, selector string) (mapset.Set[int], error) {
-	commitIndexes := mapset.NewSet[int]()
+// hash adds the commit whose hash or commit-id starts with the prefix
+func (p *parser) hash(indexes mapset.Set[int], prefix string, start int) error {
+	var matches []int
+	for _, commit := range p.commits {
+		if strings.HasPrefix(commit.CommitHash, prefix) || strings.HasPrefix(commit.CommitID, prefix) {
+			matches = append(matches, commit.Index)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return p.errorf(start, "no commit matches %s", prefix)
+	case 1:
+		indexes.Add(matches[0])
+		return nil
+	}
+	slices.Sort(matches)
+	var indices []string
+	for _, index := range matches {
+		indices = append(indices, strconv.Itoa(index))
+	}
+	return p.errorf(start, "%s matches commits %s", prefix, strings.Join(indices, ", "))
+}
 
-	selector = strings.TrimSpace(selector)
+// subject parses a /regex/ and adds the commits whose subject matches it
+func (p *parser) subject(indexes mapset.Set[int]) error {
+	start := p.pos
+	p.pos++
 
-	list := splitAndClean(selector, ",")
-	for _, l := range list {
-		if n, ok := asInteger(l); ok {
-			commitIndexes.Add(n)
-			continue
+	var expr strings.Builder
+	for {
+		if p.pos == len(p.selector) {
+			return p.errorf(start, "unterminated /regex/")
 		}
-		if from, to, ok := asRange(l); ok {
-			for n := from; n <= to; n++ {
-				commitIndexes.Add(n)
-			}
-			continue
+		b := p.selector[p.pos]
+		p.pos++
+		if b == '/' {
+			break
 		}
-		if prIndex, ok := AsPRSet(l); ok {
-			validPr := false
-			for _, commit := range commits {
-				if commit.PRIndex != nil && *commit.PRIndex == prIndex {
-					commitIndexes.Add(commit.Index)
-					validPr = true
-				}
-			}
-			if !validPr {
-				return commitIndexes, fmt.Errorf("invalid pull request set %s: %w", l, ErrInvalidSelector)
-			}
-			continue
+		if b == '\\' && p.pos < len(p.selector) && p.selector[p.pos] == '/' {
+			b = '/'
+			p.pos++
 		}
-
-		return commitIndexes, ErrInvalidSelector
+		expr.WriteByte(b)
 	}
 
-	for commitIndex := range commitIndexes.Iter() {
-		if commitIndex < 0 || commitIndex >= len(commits) {
-			return commitIndexes, fmt.Errorf("commit index %d is not valid: %w", commitIndex, ErrInvalidSelector)
+	regex, err := regexp.Compile(expr.String())
+	if err != nil {
+		return p.errorf(start, "%s", err)
+	}
+	matched := false
+	for _, commit := range p.commits {
+		if regex.MatchString(commit.Subject) {
+			indexes.Add(commit.Index)
+			matched = true
 		}
 	}
-
-	return commitIndexes, nil
+	if !matched {
+		return p.errorf(start, "no commit subject matches /%s/", expr.String())
+	}
+	return nil
 }
@@ -16,6 +16,8 @@ func ptr(i int) *int {
 	return &i
 }
 
+// testingCommits returns the commits with indices 0 to count-1, commit i has the subject "commit i", the commit-id
+// iiiiiiii and the hash hashi
 func testingCommits(count int, prMap map[int]int) []*internal.LocalCommit {
 	commits := []*internal.LocalCommit{}
 	for i := 0; i != count; i++ {
@@ -26,7 +28,9 @@ func testingCommits(count int, prMap map[int]int) []*internal.LocalCommit {
 
 		commit := internal.LocalCommit{
 			Commit: git.Commit{
-				CommitID: strings.Repeat(fmt.Sprintf("%d", i), 8),
+				CommitID:   strings.Repeat(fmt.Sprintf("%d", i), 8),
+				CommitHash: fmt.Sprintf("abcdef%d0123", i),
+				Subject:    fmt.Sprintf("commit %d", i),
 			},
 			Index:   i,
 			PRIndex: prIndex,
@@ -98,6 +102,54 @@ func TestEvaluate(t *testing.T) {
 			commits:  testingCommits(10, CommitToPr{1: 0, 2: 0, 3: 0}),
 			indicies: internal.Indices{DestinationPRIndex: ptr(0), CommitIndexes: mapset.NewSet[int](1, 2, 3, 4, 5, 6, 7, 8, 9)},
 		},
+		{
+			desc:     "open range to the newest commit",
+			input:    "6-",
+			commits:  testingCommits(9, CommitToPr{}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](6, 7, 8)},
+		},
+		{
+			desc:     "open range from the oldest commit",
+			input:    "-2, 7 -",
+			commits:  testingCommits(9, CommitToPr{}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](0, 1, 2, 7, 8)},
+		},
+		{
+			desc:     "top and bottom",
+			input:    "top 2,bottom,top",
+			commits:  testingCommits(9, CommitToPr{}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](0, 7, 8)},
+		},
+		{
+			desc:     "hash prefixes",
+			input:    "abcdef3,ABCDEF50",
+			commits:  testingCommits(9, CommitToPr{}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](3, 5)},
+		},
+		{
+			desc:     "subject regex",
+			input:    `/commit [2-4]$/,/^(commit 7|nope, \/)/`,
+			commits:  testingCommits(9, CommitToPr{}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](2, 3, 4, 7)},
+		},
+		{
+			desc:     "unassigned",
+			input:    "unassigned",
+			commits:  testingCommits(5, CommitToPr{1: 0, 3: 1}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](0, 2, 4)},
+		},
+		{
+			desc:     "exclusions",
+			input:    "!s0,0-8,!4,!/commit 6/",
+			commits:  testingCommits(9, CommitToPr{1: 0, 2: 0}),
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](0, 3, 5, 7, 8)},
+		},
+		{
+			desc:     "only exclusions start from all commits",
+			input:    "s1:!0,!top",
+			commits:  testingCommits(5, CommitToPr{}),
+			indicies: internal.Indices{DestinationPRIndex: ptr(1), CommitIndexes: mapset.NewSet[int](1, 2, 3)},
+		},
 		{
 			desc:    "error invalid pr set",
 			input:   "s9",
@@ -122,9 +174,10 @@ func TestEvaluate(t *testing.T) {
 			err:   selector.ErrInvalidSelector,
 		},
 		{
-			desc:  "error invalid syntax - bad range",
-			input: "1-",
-			err:   selector.ErrInvalidSelector,
+			desc:    "error invalid syntax - bad range",
+			input:   "1-2-3",
+			commits: testingCommits(9, CommitToPr{}),
+			err:     selector.ErrInvalidSelector,
 		},
 		{
 			desc:  "error invalid syntax - bad destination",
@@ -140,7 +193,7 @@ func TestEvaluate(t *testing.T) {
 
 	for _, test := range tests {
 		t.Run(test.desc, func(t *testing.T) {
-			indexes, err := selector.Evaluate(test.commits, test.input)
+			indexes, err := selector.Evaluate(test.commits, nil, test.input)
 			require.ErrorIs(t, err, test.err)
 			if err == nil {
 				require.Equal(t, test.indicies, indexes)
@@ -148,3 +201,140 @@ func TestEvaluate(t *testing.T) {
 		})
 	}
 }
+
+func TestEvaluateErrorPositions(t *testing.T) {
+	tests := []struct {
+		input string
+		pos   int
+		msg   string
+	}{
+		{input: "0-3,xyz", pos: 4, msg: `unknown term "xyz"`},
+		{input: "1-99", pos: 2, msg: "commit index 99 is not valid"},
+		{input: "0, 3-2", pos: 3, msg: "range 3-2 is reversed"},
+		{input: "1,s9", pos: 2, msg: "invalid pull request set s9"},
+		{input: "1,s09", pos: 2, msg: "invalid pull request set s09"},
+		{input: "s9+1", pos: 0, msg: "invalid pull request set s9"},
+		{input: "0 1", pos: 2, msg: "expected a comma"},
+		{input: "0,", pos: 2, msg: "expected a commit"},
+		{input: "top 10", pos: 4, msg: "top 10 is not valid with 9 commits"},
+		{input: "1,/commit (/", pos: 2, msg: "error parsing regexp"},
+		{input: "1,/commit", pos: 2, msg: "unterminated /regex/"},
+		{input: "/nothing/", pos: 0, msg: "no commit subject matches /nothing/"},
+		{input: "abcdef", pos: 0, msg: "abcdef matches commits 0, 1, 2, 3, 4, 5, 6, 7, 8"},
+		{input: "s0:,", pos: 3, msg: `unexpected ','`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			_, err := selector.Evaluate(testingCommits(9, CommitToPr{}), nil, test.input)
+			require.ErrorIs(t, err, selector.ErrInvalidSelector)
+
+			var selectorErr *selector.Error
+			require.ErrorAs(t, err, &selectorErr)
+			require.Equal(t, test.pos, selectorErr.Pos)
+			require.Contains(t, selectorErr.Msg, test.msg)
+		})
+	}
+
+	_, err := selector.Evaluate(testingCommits(9, CommitToPr{}), nil, "0-3,xyz")
+	require.EqualError(t, err, "invalid commit selector: unknown term \"xyz\"\n  0-3,xyz\n      ^")
+
+	// A named PR set is reported by its name
+	_, err = selector.Evaluate(testingCommits(9, CommitToPr{}), map[string]int{"feature": 3}, "1,feature")
+	require.ErrorContains(t, err, "invalid pull request set feature")
+}
+
+func TestEvaluateCommitIdPrefix(t *testing.T) {
+	commits := testingCommits(3, CommitToPr{})
+	commits[1].CommitID = "cafe1234"
+
+	indexes, err := selector.Evaluate(commits, nil, "CAFE")
+	require.NoError(t, err)
+	require.Equal(t, mapset.NewSet[int](1), indexes.CommitIndexes)
+}
+
+func TestEvaluateNames(t *testing.T) {
+	names := map[string]int{"auth": 0, "docs.v2": 1}
+	tests := []struct {
+		desc     string
+		input    string
+		indicies internal.Indices
+	}{
+		{
+			desc:     "new name",
+			input:    "auth-refactor:3-4",
+			indicies: internal.Indices{DestinationName: "auth-refactor", CommitIndexes: mapset.NewSet[int](3, 4)},
+		},
+		{
+			desc:  "existing name",
+			input: "auth:0,3",
+			indicies: internal.Indices{
+				DestinationPRIndex: ptr(0), DestinationName: "auth", CommitIndexes: mapset.NewSet[int](0, 3),
+			},
+		},
+		{
+			desc:  "additive existing name",
+			input: "auth+3",
+			indicies: internal.Indices{
+				DestinationPRIndex: ptr(0), DestinationName: "auth", CommitIndexes: mapset.NewSet[int](0, 1, 3),
+			},
+		},
+		{
+			desc:     "name as a term",
+			input:    "docs.v2,4",
+			indicies: internal.Indices{CommitIndexes: mapset.NewSet[int](2, 4)},
+		},
+		{
+			desc:  "renaming a PR set keeps its index",
+			input: "docs:s1",
+			indicies: internal.Indices{
+				DestinationPRIndex: ptr(1), DestinationName: "docs", CommitIndexes: mapset.NewSet[int](2),
+			},
+		},
+		{
+			desc:     "naming part of a PR set makes a new PR set",
+			input:    "login:1",
+			indicies: internal.Indices{DestinationName: "login", CommitIndexes: mapset.NewSet[int](1)},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.desc, func(t *testing.T) {
+			commits := testingCommits(5, CommitToPr{0: 0, 1: 0, 2: 1})
+			indexes, err := selector.Evaluate(commits, names, test.input)
+			require.NoError(t, err)
+			require.Equal(t, test.indicies, indexes)
+		})
+	}
+
+	for _, input := range []string{"top:1", "unassigned+1", "unknown", "s12:1,unknown"} {
+		t.Run(input, func(t *testing.T) {
+			_, err := selector.Evaluate(testingCommits(5, CommitToPr{}), names, input)
+			require.ErrorIs(t, err, selector.ErrInvalidSelector)
+		})
+	}
+}
+
+func TestAsPRSetOrName(t *testing.T) {
+	names := map[string]int{"auth": 2}
+
+	prIndex, ok := selector.AsPRSetOrName("s1", names)
+	require.True(t, ok)
+	require.Equal(t, 1, prIndex)
+
+	prIndex, ok = selector.AsPRSetOrName(" auth ", names)
+	require.True(t, ok)
+	require.Equal(t, 2, prIndex)
+
+	_, ok = selector.AsPRSetOrName("docs", names)
+	require.False(t, ok)
+}
+
+func TestValidPRSetName(t *testing.T) {
+	for _, name := range []string{"auth", "auth-refactor", "docs.v2", "s1x", "a_1"} {
+		require.True(t, selector.ValidPRSetName(name), name)
+	}
+	for _, name := range []string{"", "s1", "top", "unassigned", "1abc", "auth refactor", " auth", "auth:"} {
+		require.False(t, selector.ValidPRSetName(name), name)
+	}
+}
@@ -8,3 +8,5 @@ var NewReadState = internal.NewReadState
 var PullRequests = internal.PullRequests
 
 type LocalCommit = internal.LocalCommit
+type State = internal.State
+type Indices = internal.Indices
@@ -51,14 +51,17 @@ func main() {
 
 	ctx := context.Background()
 	cfg := config_parser.ParseConfig(gitcmd)
-	client := githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+	client, err := githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+	check(err)
 	gitcmd = realgit.NewGitCmd(cfg)
 
 	sd := spr.NewStackedPR(cfg, client, gitcmd)
-	sd.AmendCommit(ctx)
+	err = sd.AmendCommit(ctx)
+	check(err)
 
 	if opts.Update {
-		sd.UpdatePullRequests(ctx, nil, nil)
+		err = sd.UpdatePullRequests(ctx, nil, nil)
+		check(err)
 	}
 }
 
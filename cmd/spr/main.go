@@ -8,8 +8,12 @@ import (
 	"github.com/ejoffe/rake"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/config/config_parser"
+	"github.com/ejoffe/spr/git"
 	"github.com/ejoffe/spr/git/realgit"
+	"github.com/ejoffe/spr/gitea/giteaclient"
 	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/gitlab/gitlabclient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/ejoffe/spr/spr"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -28,6 +32,25 @@ func init() {
 	log.Logger = log.With().Caller().Logger().Output(zerolog.ConsoleWriter{Out: os.Stderr})
 }
 
+// hostingClient is the client of the service hosting the repository
+type hostingClient interface {
+	hosting.Client
+
+	// Record captures the API traffic to a cassette file
+	Record(path string)
+}
+
+// newClient returns the client for the configured hosting provider
+func newClient(ctx context.Context, gitcmd git.GitInterface, cfg *config.Config) (hostingClient, error) {
+	switch cfg.Repo.HostingProvider {
+	case config.HostingProviderGitLab:
+		return gitlabclient.NewGitLabClient(ctx, gitcmd, cfg)
+	case config.HostingProviderGitea:
+		return giteaclient.NewGiteaClient(ctx, gitcmd, cfg)
+	}
+	return githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+}
+
 func main() {
 	gitcmd := realgit.NewGitCmd(config.DefaultConfig())
 
@@ -41,7 +64,11 @@ func main() {
 	gitcmd = realgit.NewGitCmd(cfg)
 
 	ctx := context.Background()
-	client := githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+	client, err := newClient(ctx, gitcmd, cfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(3)
+	}
 	stackedpr := spr.NewStackedPR(cfg, client, gitcmd)
 
 	detailFlag := &cli.BoolFlag{
@@ -98,6 +125,10 @@ VERSION: fork of {{.Version}}
 				Value: false,
 				Usage: "Show runtime debug info",
 			},
+			&cli.StringFlag{
+				Name:  "record",
+				Usage: "Record all GitHub, GitLab or Gitea API traffic (with credentials redacted) to the given cassette file",
+			},
 		},
 		Before: func(c *cli.Context) error {
 			if c.IsSet("debug") {
@@ -111,7 +142,14 @@ VERSION: fork of {{.Version}}
 				cfg.User.LogGitCommands = true
 				cfg.User.LogGitHubCalls = true
 			}
-			client.MaybeStar(ctx, cfg)
+			if c.IsSet("record") {
+				client.Record(c.String("record"))
+			}
+			if stargazer, ok := client.(interface {
+				MaybeStar(ctx context.Context, cfg *config.Config) error
+			}); ok {
+				return stargazer.MaybeStar(ctx, cfg)
+			}
 			return nil
 		},
 		Commands: []*cli.Command{
@@ -120,8 +158,10 @@ VERSION: fork of {{.Version}}
 				Aliases: []string{"s", "st"},
 				Usage:   "Show status of open pull requests",
 				Action: func(c *cli.Context) error {
-					stackedpr.StatusCommitsAndPRSets(ctx)
-					return nil
+					if c.Args().Len() == 1 {
+						return stackedpr.StatusSelectedCommits(ctx, c.Args().First())
+					}
+					return stackedpr.StatusCommitsAndPRSets(ctx)
 				},
 				Flags: []cli.Flag{
 					detailFlag,
@@ -131,8 +171,7 @@ VERSION: fork of {{.Version}}
 				Name:  "sync",
 				Usage: "Synchronize local stack with remote",
 				Action: func(c *cli.Context) error {
-					stackedpr.SyncStack(ctx)
-					return nil
+					return stackedpr.SyncStack(ctx)
 				},
 			},
 			{
@@ -140,13 +179,22 @@ VERSION: fork of {{.Version}}
 				Aliases: []string{"u", "up"},
 				Usage:   "Update and create pull requests for updated commits in the stack",
 				Action: func(c *cli.Context) error {
+					if c.Bool("interactive") {
+						if c.Args().Len() != 0 || c.Bool("dry-run") {
+							fmt.Printf("Usage: update -i\n")
+							return nil
+						}
+						return stackedpr.EditPRSets(ctx)
+					}
 					if c.Args().Len() != 1 {
 						fmt.Printf("Usage: update <selector>\n")
 						return nil
 					}
 					selector := c.Args().First()
-					stackedpr.UpdatePRSets(ctx, selector)
-					return nil
+					if c.Bool("dry-run") {
+						return stackedpr.PlanPRSets(ctx, selector)
+					}
+					return stackedpr.UpdatePRSets(ctx, selector)
 				},
 				Flags: []cli.Flag{
 					detailFlag,
@@ -165,6 +213,15 @@ VERSION: fork of {{.Version}}
 						Aliases: []string{"nr"},
 						Usage:   "Disable rebasing",
 					},
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Show the branches and pull requests that would be changed without changing them",
+					},
+					&cli.BoolFlag{
+						Name:    "interactive",
+						Aliases: []string{"i"},
+						Usage:   "Edit the PR set of each commit in $EDITOR",
+					},
 				},
 			},
 			{
@@ -172,12 +229,11 @@ VERSION: fork of {{.Version}}
 				Usage: "Merge all mergeable pull requests",
 				Action: func(c *cli.Context) error {
 					if c.Args().Len() != 1 {
-						fmt.Printf("Usage: merge <PR set index>\n")
+						fmt.Printf("Usage: merge <PR set index or name>\n")
 						return nil
 					}
 					setIndex := c.Args().First()
-					stackedpr.MergePRSet(ctx, setIndex)
-					return nil
+					return stackedpr.MergePRSet(ctx, setIndex)
 				},
 				Flags: []cli.Flag{
 					detailFlag,
@@ -188,12 +244,46 @@ VERSION: fork of {{.Version}}
 					},
 				},
 			},
+			{
+				Name:  "split",
+				Usage: "Split a PR set into a new PR set for each selector, unselected commits stay in the PR set",
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() < 2 {
+						fmt.Printf("Usage: split <PR set index or name> <selector>...\n")
+						return nil
+					}
+					return stackedpr.SplitPRSet(ctx, c.Args().First(), c.Args().Tail())
+				},
+				Flags: []cli.Flag{
+					detailFlag,
+				},
+			},
+			{
+				Name:  "combine",
+				Usage: "Combine PR sets into the first one, keeping their pull requests",
+				Action: func(c *cli.Context) error {
+					if c.Args().Len() < 2 {
+						fmt.Printf("Usage: combine <PR set index or name> <PR set index or name>...\n")
+						return nil
+					}
+					return stackedpr.CombinePRSets(ctx, c.Args().Slice())
+				},
+				Flags: []cli.Flag{
+					detailFlag,
+				},
+			},
+			{
+				Name:  "undo",
+				Usage: "Undo the last update, merge or sync",
+				Action: func(c *cli.Context) error {
+					return stackedpr.Undo(ctx)
+				},
+			},
 			{
 				Name:  "check",
 				Usage: "Run pre merge checks (configured by MergeCheck in repository config)",
 				Action: func(c *cli.Context) error {
-					stackedpr.RunMergeCheck(ctx)
-					return nil
+					return stackedpr.RunMergeCheck(ctx)
 				},
 			},
 			{
@@ -205,14 +295,23 @@ VERSION: fork of {{.Version}}
 			},
 		},
 		After: func(c *cli.Context) error {
-			rake.LoadSources(cfg.State,
-				rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+			err := config_parser.WriteState(cfg)
+			if err != nil {
+				return err
+			}
 			if c.IsSet("profile") {
-				stackedpr.ProfilingSummary()
+				return stackedpr.ProfilingSummary()
 			}
 			return nil
 		},
 	}
 
-	app.Run(os.Args)
+	err = app.Run(os.Args)
+	if err != nil {
+		if os.Getenv("SPR_DEBUG") == "1" {
+			panic(err)
+		}
+		fmt.Printf("error: %s\n", err)
+		os.Exit(1)
+	}
 }
@@ -1,6 +1,12 @@
 package config
 
 import (
+	"fmt"
+	"maps"
+	"regexp"
+	"strings"
+	"sync"
+
 	"github.com/ejoffe/rake"
 )
 
@@ -8,6 +14,21 @@ type Config struct {
 	Repo  *RepoConfig
 	User  *UserConfig
 	State *InternalState
+
+	// loadedState is a copy of the state as it was in the state file when it was loaded
+	loadedState *InternalState
+
+	// branchNames is shared with the copies of the config, it is nil for a config not made by EmptyConfig
+	branchNames *branchNames
+}
+
+// branchNames has the viewer's login, which fills the {login} placeholder when the login isn't configured, and the
+// branch name regex compiled for the current template and login
+type branchNames struct {
+	lock        sync.Mutex
+	viewerLogin string
+	key         string
+	regex       *regexp.Regexp
 }
 
 // Config object to hold spr configuration
@@ -16,9 +37,27 @@ type RepoConfig struct {
 	GitHubRepoName  string `yaml:"githubRepoName"`
 	GitHubHost      string `default:"github.com" yaml:"githubHost"`
 
+	// HostingProvider selects the service hosting the repository, either github, gitlab or gitea (which also covers
+	// Forgejo). The GitHub settings (host, owner, name, api url, remote and branch) are used for the others as well.
+	HostingProvider string `default:"github" yaml:"hostingProvider"`
+
+	// GitHubApiUrl and GitHubGraphQLUrl override the API endpoints derived from GitHubHost.
+	// They only need to be set when a GitHub Enterprise Server doesn't use the standard /api/v3 and /api/graphql paths.
+	GitHubApiUrl     string `yaml:"githubApiUrl,omitempty"`
+	GitHubGraphQLUrl string `yaml:"githubGraphQLUrl,omitempty"`
+
 	GitHubRemote string `default:"origin" yaml:"githubRemote"`
 	GitHubBranch string `default:"main" yaml:"githubBranch"`
 
+	// UpstreamRemote and PushRemote split GitHubRemote for a fork based workflow. Pull requests are opened on the
+	// repository of UpstreamRemote and the spr branches are pushed to PushRemote, a fork of it. Both default to
+	// GitHubRemote.
+	UpstreamRemote string `yaml:"upstreamRemote,omitempty"`
+	PushRemote     string `yaml:"pushRemote,omitempty"`
+	// PushRepoOwner and PushRepoName are the repository of PushRemote, they are read from its url when not set.
+	PushRepoOwner string `yaml:"pushRepoOwner,omitempty"`
+	PushRepoName  string `yaml:"pushRepoName,omitempty"`
+
 	RequireChecks   bool `default:"true" yaml:"requireChecks"`
 	RequireApproval bool `default:"true" yaml:"requireApproval"`
 
@@ -35,6 +74,126 @@ type RepoConfig struct {
 
 	ShowPrTitlesInStack    bool `default:"false" yaml:"showPrTitlesInStack"`
 	BranchPushIndividually bool `default:"false" yaml:"branchPushIndividually"`
+
+	// BranchNameTemplate is the name of the branch pushed for each commit. The {login}, {target} and {commit-id}
+	// placeholders are replaced by the user's login, the target branch and the commit-id of the commit. The branches
+	// of existing pull requests are matched against the same template.
+	BranchNameTemplate string `default:"spr/{target}/{commit-id}" yaml:"branchNameTemplate"`
+
+	// LocalBranch is the checked out branch. It is found along with the GitHubRemote and GitHubBranch it tracks and
+	// isn't saved.
+	LocalBranch string `yaml:"-"`
+}
+
+// Placeholders of the branch name template
+const (
+	BranchNameLogin    = "{login}"
+	BranchNameTarget   = "{target}"
+	BranchNameCommitId = "{commit-id}"
+
+	DefaultBranchNameTemplate = "spr/" + BranchNameTarget + "/" + BranchNameCommitId
+)
+
+// Hosting providers
+const (
+	HostingProviderGitHub = "github"
+	HostingProviderGitLab = "gitlab"
+	HostingProviderGitea  = "gitea"
+)
+
+// PullRequestURLPrefix returns the web url of the pull requests (merge requests on GitLab), the number of a pull
+// request is appended to it to get its url.
+func (c *RepoConfig) PullRequestURLPrefix() string {
+	switch c.HostingProvider {
+	case HostingProviderGitLab:
+		return fmt.Sprintf("https://%s/%s/%s/-/merge_requests/", c.GitHubHost, c.GitHubRepoOwner, c.GitHubRepoName)
+	case HostingProviderGitea:
+		return fmt.Sprintf("https://%s/%s/%s/pulls/", c.GitHubHost, c.GitHubRepoOwner, c.GitHubRepoName)
+	}
+	return fmt.Sprintf("https://%s/%s/%s/pull/", c.GitHubHost, c.GitHubRepoOwner, c.GitHubRepoName)
+}
+
+// RepoKey returns host/owner/name, which identifies the repository
+func (c *RepoConfig) RepoKey() string {
+	return c.GitHubHost + "/" + c.GitHubRepoOwner + "/" + c.GitHubRepoName
+}
+
+// BranchNameTemplateOrDefault returns the branch name template, or the default template when it isn't set
+func (c *RepoConfig) BranchNameTemplateOrDefault() string {
+	if c.BranchNameTemplate != "" {
+		return c.BranchNameTemplate
+	}
+	return DefaultBranchNameTemplate
+}
+
+// Login returns the user's login on the hosting provider which fills the {login} placeholder of the branch name
+// template. It is the configured login, or else the viewer's login found when the pull requests are fetched.
+func (c *Config) Login() string {
+	if c.User.Login != "" || c.branchNames == nil {
+		return c.User.Login
+	}
+	c.branchNames.lock.Lock()
+	defer c.branchNames.lock.Unlock()
+	return c.branchNames.viewerLogin
+}
+
+// SetViewerLogin sets the login of the authenticated user, as returned by the hosting provider
+func (c *Config) SetViewerLogin(login string) {
+	if c.branchNames == nil {
+		return
+	}
+	c.branchNames.lock.Lock()
+	defer c.branchNames.lock.Unlock()
+	c.branchNames.viewerLogin = login
+}
+
+// BranchNameRegex returns a regex matching the branch names made from the branch name template (for any target
+// branch). The target branch and commit-id are captured by the "target" and "commitid" subexpressions. The regex is
+// compiled once for the template and login.
+func (c *Config) BranchNameRegex() *regexp.Regexp {
+	template := c.Repo.BranchNameTemplateOrDefault()
+	login := c.Login()
+	if c.branchNames == nil {
+		return compileBranchNameRegex(template, login)
+	}
+
+	c.branchNames.lock.Lock()
+	defer c.branchNames.lock.Unlock()
+	key := template + "\x00" + login
+	if c.branchNames.regex == nil || c.branchNames.key != key {
+		c.branchNames.key = key
+		c.branchNames.regex = compileBranchNameRegex(template, login)
+	}
+	return c.branchNames.regex
+}
+
+func compileBranchNameRegex(template string, login string) *regexp.Regexp {
+	return regexp.MustCompile("^" + strings.NewReplacer(
+		regexp.QuoteMeta(BranchNameLogin), regexp.QuoteMeta(login),
+		regexp.QuoteMeta(BranchNameTarget), `(?P<target>[a-zA-Z0-9_\-/\.]+)`,
+		regexp.QuoteMeta(BranchNameCommitId), `(?P<commitid>[a-f0-9]{8})`,
+	).Replace(regexp.QuoteMeta(template)) + "$")
+}
+
+// UpstreamRemoteName returns the remote of the repository the pull requests are opened on
+func (c *RepoConfig) UpstreamRemoteName() string {
+	if c.UpstreamRemote != "" {
+		return c.UpstreamRemote
+	}
+	return c.GitHubRemote
+}
+
+// PushRemoteName returns the remote the spr branches are pushed to
+func (c *RepoConfig) PushRemoteName() string {
+	if c.PushRemote != "" {
+		return c.PushRemote
+	}
+	return c.GitHubRemote
+}
+
+// ForkWorkflow returns true if the spr branches are pushed to a fork of the repository the pull requests are opened on
+func (c *RepoConfig) ForkWorkflow() bool {
+	return c.PushRemoteName() != c.UpstreamRemoteName()
 }
 
 type UserConfig struct {
@@ -44,6 +203,15 @@ type UserConfig struct {
 	CreateDraftPRs       bool `default:"false" yaml:"createDraftPRs"`
 	PreserveTitleAndBody bool `default:"false" yaml:"preserveTitleAndBody"`
 	NoRebase             bool `default:"false" yaml:"noRebase"`
+
+	// Login is the user's login on the hosting provider, it fills the {login} placeholder of the branch name template.
+	// It only needs to be set to override the login of the authenticated user.
+	Login string `yaml:"login,omitempty"`
+
+	// MaxRetries and RetryBudgetSeconds limit how many times and for how long a failed or rate limited GitHub request
+	// is retried.
+	MaxRetries         int `default:"5" yaml:"maxRetries"`
+	RetryBudgetSeconds int `default:"120" yaml:"retryBudgetSeconds"`
 }
 
 type InternalState struct {
@@ -51,17 +219,224 @@ type InternalState struct {
 
 	Stargazer bool `default:"false" yaml:"stargazer"`
 	RunCount  int  `default:"0" yaml:"runcount"`
-	// Maps the repo name to a map of commitIds to the PRSet index
+	// Maps the stack (see Config.PRSetKey) to a map of commitIds to the PRSet index
 	RepoToCommitIdToPRSet map[string]map[string]int
+	// Maps the stack (see Config.PRSetKey) to a map of PR set names to the PRSet index
+	RepoToPRSetNames map[string]map[string]int
+	// Maps the stack (see Config.PRSetKey) to the commitIds that were in the stack, the pull requests of the ones that
+	// are no longer local are orphaned
+	RepoToCommitIds map[string]map[string]bool
+	// Maps the stack (see Config.PRSetKey) to a map of commitIds to the PRSet index recorded on the remote when the PR
+	// set refs were last listed or pushed
+	RepoToPRSetRefs map[string]map[string]int
+}
+
+// PRSetKey returns the key of the stack's PR sets in RepoToCommitIdToPRSet. The PR sets are kept per repository
+// (host/owner/name), target branch and local branch so stacks on different branches don't interfere with each other.
+func (c *Config) PRSetKey() string {
+	return strings.Join([]string{c.Repo.RepoKey(), c.Repo.GitHubBranch, c.Repo.LocalBranch}, ":")
+}
+
+// PRSets returns the map of commitIds to the PRSet index of the stack, it is nil if nothing has been saved
+func (c *Config) PRSets() map[string]int {
+	return c.State.RepoToCommitIdToPRSet[c.PRSetKey()]
+}
+
+// SetPRSets saves the map of commitIds to the PRSet index of the stack, nil removes it
+func (c *Config) SetPRSets(prSets map[string]int) {
+	if prSets == nil {
+		delete(c.State.RepoToCommitIdToPRSet, c.PRSetKey())
+		return
+	}
+	c.State.RepoToCommitIdToPRSet[c.PRSetKey()] = prSets
+}
+
+// StackCommitIds returns the commitIds that were in the stack when it was last read, it is nil if nothing has been saved
+func (c *Config) StackCommitIds() map[string]bool {
+	return c.State.RepoToCommitIds[c.PRSetKey()]
+}
+
+// SetStackCommitIds saves the commitIds of the stack, nil or an empty map removes them
+func (c *Config) SetStackCommitIds(commitIds map[string]bool) {
+	if len(commitIds) == 0 {
+		delete(c.State.RepoToCommitIds, c.PRSetKey())
+		return
+	}
+	if c.State.RepoToCommitIds == nil {
+		c.State.RepoToCommitIds = map[string]map[string]bool{}
+	}
+	c.State.RepoToCommitIds[c.PRSetKey()] = commitIds
+}
+
+// PRSetRefs returns the map of commitIds to the PRSet index recorded on the remote for the stack, it is nil if the PR
+// set refs haven't been listed
+func (c *Config) PRSetRefs() map[string]int {
+	return c.State.RepoToPRSetRefs[c.PRSetKey()]
+}
+
+// SetPRSetRefs saves the map of commitIds to the PRSet index recorded on the remote for the stack, nil removes it
+func (c *Config) SetPRSetRefs(prSetRefs map[string]int) {
+	if prSetRefs == nil {
+		delete(c.State.RepoToPRSetRefs, c.PRSetKey())
+		return
+	}
+	if c.State.RepoToPRSetRefs == nil {
+		c.State.RepoToPRSetRefs = map[string]map[string]int{}
+	}
+	c.State.RepoToPRSetRefs[c.PRSetKey()] = prSetRefs
+}
+
+// PRSetNames returns the map of names to the PRSet index of the stack, it is nil if no PR set has been named
+func (c *Config) PRSetNames() map[string]int {
+	return c.State.RepoToPRSetNames[c.PRSetKey()]
+}
+
+// SetPRSetNames saves the map of names to the PRSet index of the stack, nil or an empty map removes it
+func (c *Config) SetPRSetNames(names map[string]int) {
+	if len(names) == 0 {
+		delete(c.State.RepoToPRSetNames, c.PRSetKey())
+		return
+	}
+	if c.State.RepoToPRSetNames == nil {
+		c.State.RepoToPRSetNames = map[string]map[string]int{}
+	}
+	c.State.RepoToPRSetNames[c.PRSetKey()] = names
+}
+
+// MigratePRSets moves the PR sets saved by earlier versions, which were keyed by the repository name rather than
+// host/owner/name, to the keys of this repository. PR sets keyed by the repository name alone become the PR sets of the
+// current stack. As the name doesn't say which repository they were saved for, only the PR sets of commitIds, the
+// commits and pull requests of this repository, are moved. The others are left for the repositories of the same name.
+func (c *Config) MigratePRSets(commitIds map[string]bool) {
+	for key, prSets := range c.State.RepoToCommitIdToPRSet {
+		name, branches, _ := strings.Cut(key, ":")
+		if name != c.Repo.GitHubRepoName {
+			continue
+		}
+
+		newKey := c.PRSetKey()
+		if branches != "" {
+			newKey = c.Repo.RepoKey() + ":" + branches
+		}
+		migrated, found := c.State.RepoToCommitIdToPRSet[newKey]
+		for commitId, prIndex := range prSets {
+			if !commitIds[commitId] {
+				continue
+			}
+			// The PR sets saved for this repository win over the ones it had before
+			if !found {
+				if migrated == nil {
+					migrated = map[string]int{}
+					c.State.RepoToCommitIdToPRSet[newKey] = migrated
+				}
+				migrated[commitId] = prIndex
+			}
+			delete(prSets, commitId)
+		}
+		if len(prSets) == 0 {
+			delete(c.State.RepoToCommitIdToPRSet, key)
+		}
+	}
+}
+
+// Clone returns a deep copy of the state
+func (s *InternalState) Clone() *InternalState {
+	clone := *s
+	clone.MergeCheckCommit = maps.Clone(s.MergeCheckCommit)
+	clone.RepoToCommitIdToPRSet = cloneStacks(s.RepoToCommitIdToPRSet)
+	clone.RepoToPRSetNames = cloneStacks(s.RepoToPRSetNames)
+	clone.RepoToCommitIds = cloneStacks(s.RepoToCommitIds)
+	clone.RepoToPRSetRefs = cloneStacks(s.RepoToPRSetRefs)
+	return &clone
+}
+
+func cloneStacks[V any](stacks map[string]map[string]V) map[string]map[string]V {
+	clone := map[string]map[string]V{}
+	for key, m := range stacks {
+		clone[key] = maps.Clone(m)
+	}
+	return clone
+}
+
+// StateLoaded records the state as it is in the state file, MergeState applies the changes made since then
+func (c *Config) StateLoaded() {
+	c.loadedState = c.State.Clone()
+}
+
+// MergeState returns the current state, read from the state file, with the changes made to this config's state since
+// it was loaded applied. Other spr processes may have saved the state in the meantime, merging keeps their changes to
+// other stacks and merge checks rather than overwriting them.
+func (c *Config) MergeState(current *InternalState) *InternalState {
+	loaded := c.loadedState
+	if loaded == nil {
+		loaded = &InternalState{}
+	}
+
+	merged := current.Clone()
+	if merged.MergeCheckCommit == nil {
+		merged.MergeCheckCommit = map[string]string{}
+	}
+	if c.State.Stargazer != loaded.Stargazer {
+		merged.Stargazer = c.State.Stargazer
+	}
+	merged.RunCount += c.State.RunCount - loaded.RunCount
+
+	for key := range keys(c.State.MergeCheckCommit, loaded.MergeCheckCommit) {
+		commit, ok := c.State.MergeCheckCommit[key]
+		loadedCommit, loadedOk := loaded.MergeCheckCommit[key]
+		switch {
+		case ok == loadedOk && commit == loadedCommit:
+		case ok:
+			merged.MergeCheckCommit[key] = commit
+		default:
+			delete(merged.MergeCheckCommit, key)
+		}
+	}
+	mergeStacks(merged.RepoToCommitIdToPRSet, c.State.RepoToCommitIdToPRSet, loaded.RepoToCommitIdToPRSet)
+	mergeStacks(merged.RepoToPRSetNames, c.State.RepoToPRSetNames, loaded.RepoToPRSetNames)
+	mergeStacks(merged.RepoToCommitIds, c.State.RepoToCommitIds, loaded.RepoToCommitIds)
+	mergeStacks(merged.RepoToPRSetRefs, c.State.RepoToPRSetRefs, loaded.RepoToPRSetRefs)
+	return merged
+}
+
+// mergeStacks applies the stacks changed between loaded and ours to merged
+func mergeStacks[V comparable](merged, ours, loaded map[string]map[string]V) {
+	for key := range keys(ours, loaded) {
+		m, ok := ours[key]
+		loadedM, loadedOk := loaded[key]
+		switch {
+		case ok == loadedOk && maps.Equal(m, loadedM):
+		case ok:
+			merged[key] = maps.Clone(m)
+		default:
+			delete(merged, key)
+		}
+	}
+}
+
+// keys returns the keys of both maps
+func keys[V any](a map[string]V, b map[string]V) map[string]bool {
+	all := map[string]bool{}
+	for key := range a {
+		all[key] = true
+	}
+	for key := range b {
+		all[key] = true
+	}
+	return all
 }
 
 func EmptyConfig() *Config {
 	return &Config{
-		Repo: &RepoConfig{},
-		User: &UserConfig{},
+		Repo:        &RepoConfig{},
+		User:        &UserConfig{},
+		branchNames: &branchNames{},
 		State: &InternalState{
 			MergeCheckCommit:      map[string]string{},
 			RepoToCommitIdToPRSet: map[string]map[string]int{},
+			RepoToPRSetNames:      map[string]map[string]int{},
+			RepoToCommitIds:       map[string]map[string]bool{},
+			RepoToPRSetRefs:       map[string]map[string]int{},
 		},
 	}
 }
@@ -7,12 +7,21 @@ import (
 	"path"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/ejoffe/rake"
+	"github.com/ejoffe/spr/bl/lockfile"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
+	"gopkg.in/yaml.v3"
 )
 
+// stateLockWait is how long to wait for another spr process to finish writing the state file
+var stateLockWait = 10 * time.Second
+
+// stateLockStale is the age at which a lock on the state file was left behind, writing it takes a moment
+const stateLockStale = time.Minute
+
 func ParseConfig(gitcmd git.GitInterface) *config.Config {
 	cfg := config.EmptyConfig()
 
@@ -21,6 +30,7 @@ func ParseConfig(gitcmd git.GitInterface) *config.Config {
 		NewGitHubRemoteSource(cfg, gitcmd),
 		rake.YamlFileSource(RepoConfigFilePath(gitcmd)),
 		NewRemoteBranchSource(gitcmd),
+		NewForkRemoteSource(gitcmd),
 	)
 	if cfg.Repo.GitHubHost == "" {
 		fmt.Println("unable to auto configure repository host - must be set manually in .spr.yml")
@@ -45,11 +55,15 @@ func ParseConfig(gitcmd git.GitInterface) *config.Config {
 		rake.DefaultSource(),
 		rake.YamlFileSource(InternalConfigFilePath()),
 	)
+	cfg.StateLoaded()
 
 	cfg.State.RunCount = cfg.State.RunCount + 1
 
-	rake.LoadSources(cfg.State,
-		rake.YamlFileWriter(InternalConfigFilePath()))
+	err := WriteState(cfg)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(5)
+	}
 
 	// init case : if yaml config files not found : create them
 	if _, err := os.Stat(RepoConfigFilePath(gitcmd)); errors.Is(err, os.ErrNotExist) {
@@ -64,9 +78,58 @@ func ParseConfig(gitcmd git.GitInterface) *config.Config {
 	return cfg
 }
 
+// WriteState saves the state to the state file. The state file is locked while it is read, merged and written so
+// the changes other spr processes made to it since it was loaded are kept. The state is written to a temporary file
+// which replaces the state file, so the state file is read whole without taking the lock.
+func WriteState(cfg *config.Config) error {
+	path := InternalConfigFilePath()
+	lock, err := lockfile.Acquire(path+".lock", stateLockWait, stateLockStale)
+	if err != nil {
+		return fmt.Errorf("saving the state %w", err)
+	}
+	defer lock.Release()
+
+	current := config.EmptyConfig().State
+	rake.LoadSources(current,
+		rake.DefaultSource(),
+		rake.YamlFileSource(path),
+	)
+	merged := cfg.MergeState(current)
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return fmt.Errorf("saving the state %w", err)
+	}
+	// Only the lock holder writes the temporary file, the state file is only replaced once it is written whole
+	err = os.WriteFile(path+".tmp", data, 0o644)
+	if err != nil {
+		os.Remove(path + ".tmp")
+		return fmt.Errorf("saving the state %w", err)
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return fmt.Errorf("saving the state %w", err)
+	}
+
+	// The merged state is what is in the state file now
+	*cfg.State = *merged
+	cfg.StateLoaded()
+	return nil
+}
+
 func CheckConfig(cfg *config.Config) error {
-	if strings.Contains(cfg.Repo.GitHubBranch, "/") {
-		return errors.New("Remote branch name must not contain backslashes '/'")
+	if cfg.Repo.ForkWorkflow() && (cfg.Repo.PushRepoOwner == "" || cfg.Repo.PushRepoName == "") {
+		return fmt.Errorf("unable to auto configure the repository of push remote %s - pushRepoOwner and pushRepoName "+
+			"must be set manually in .spr.yml", cfg.Repo.PushRemoteName())
+	}
+	template := cfg.Repo.BranchNameTemplateOrDefault()
+	if strings.Count(template, config.BranchNameCommitId) != 1 {
+		return fmt.Errorf("branchNameTemplate %q must contain %s once", template, config.BranchNameCommitId)
+	}
+	switch cfg.Repo.HostingProvider {
+	case config.HostingProviderGitHub, config.HostingProviderGitLab, config.HostingProviderGitea:
+	default:
+		return fmt.Errorf("unknown hosting provider %q, must be %s, %s or %s", cfg.Repo.HostingProvider,
+			config.HostingProviderGitHub, config.HostingProviderGitLab, config.HostingProviderGitea)
 	}
 	return nil
 }
@@ -1,12 +1,15 @@
 package config_parser
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git/mockgit"
 	"github.com/ejoffe/spr/mock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGetRepoDetailsFromRemote(t *testing.T) {
@@ -48,7 +51,7 @@ func TestGetRepoDetailsFromRemote(t *testing.T) {
 	}
 	for i, testCase := range testCases {
 		t.Logf("Testing %v %q", i, testCase.remote)
-		githubHost, repoOwner, repoName, match := getRepoDetailsFromRemote(testCase.remote)
+		githubHost, repoOwner, repoName, match := getRepoDetailsFromRemote("origin", testCase.remote)
 		if githubHost != testCase.githubHost {
 			t.Fatalf("Wrong \"githubHost\" returned for test case %v, expected %q, got %q", i, testCase.githubHost, githubHost)
 		}
@@ -93,3 +96,138 @@ func TestGitHubRemoteSource(t *testing.T) {
 	assert.Equal(t, expect, actual)
 	mock.ExpectationsMet()
 }
+
+func TestForkRemoteSource(t *testing.T) {
+	expectations := mock.New(t, true)
+	mock := mockgit.NewMockGit(expectations)
+	mock.ExpectRemotes(
+		[2]string{"fork", "git@github.com:c3/d2.git"},
+		[2]string{"upstream", "https://github.com/r2/d2.git"},
+	)
+
+	repoCfg := &config.RepoConfig{
+		GitHubRepoOwner: "c3",
+		GitHubRepoName:  "d2",
+		GitHubRemote:    "origin",
+		UpstreamRemote:  "upstream",
+		PushRemote:      "fork",
+	}
+	NewForkRemoteSource(mock).Load(repoCfg)
+	assert.Equal(t, "github.com", repoCfg.GitHubHost)
+	assert.Equal(t, "r2", repoCfg.GitHubRepoOwner)
+	assert.Equal(t, "d2", repoCfg.GitHubRepoName)
+	assert.Equal(t, "c3", repoCfg.PushRepoOwner)
+	assert.Equal(t, "d2", repoCfg.PushRepoName)
+	mock.ExpectationsMet()
+
+	// Without a fork the remotes aren't read
+	repoCfg = &config.RepoConfig{GitHubRemote: "origin", PushRemote: "origin"}
+	NewForkRemoteSource(mock).Load(repoCfg)
+	assert.Empty(t, repoCfg.PushRepoOwner)
+	mock.ExpectationsMet()
+}
+
+func TestCheckConfigFork(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Repo.PushRemote = "fork"
+	assert.ErrorContains(t, CheckConfig(cfg), "pushRepoOwner and pushRepoName must be set")
+
+	cfg.Repo.PushRepoOwner = "c3"
+	cfg.Repo.PushRepoName = "d2"
+	assert.NoError(t, CheckConfig(cfg))
+}
+
+func TestCheckConfigBranchNameTemplate(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Repo.BranchNameTemplate = "users/{target}"
+	assert.ErrorContains(t, CheckConfig(cfg), "must contain {commit-id} once")
+
+	// The login is the viewer's login unless it is set
+	cfg.Repo.BranchNameTemplate = "users/{login}/{commit-id}"
+	assert.NoError(t, CheckConfig(cfg))
+}
+
+func TestGetRemoteBranch(t *testing.T) {
+	testCases := []struct {
+		status string
+		local  string
+		remote string
+		branch string
+		match  bool
+	}{
+		{"## main...origin/main", "main", "origin", "main", true},
+		{"## main...origin/main [ahead 2]", "main", "origin", "main", true},
+		{"## feature...upstream/release/2026.10 [ahead 1]", "feature", "upstream", "release/2026.10", true},
+		{"## team/feature...origin/team/integration", "team/feature", "origin", "team/integration", true},
+		{"## main", "", "", "", false},
+		{"## HEAD (no branch)", "", "", "", false},
+	}
+	for _, tc := range testCases {
+		local, remote, branch, match := getRemoteBranch(tc.status)
+		assert.Equal(t, tc.local, local, tc.status)
+		assert.Equal(t, tc.remote, remote, tc.status)
+		assert.Equal(t, tc.branch, branch, tc.status)
+		assert.Equal(t, tc.match, match, tc.status)
+	}
+}
+
+func TestCheckConfigSlashedBranch(t *testing.T) {
+	cfg := config.DefaultConfig()
+	cfg.Repo.GitHubBranch = "release/2026.10"
+	assert.NoError(t, CheckConfig(cfg))
+}
+
+func TestWriteStateKeepsOtherChanges(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+
+	first := config.EmptyConfig()
+	first.StateLoaded()
+	second := config.EmptyConfig()
+	second.StateLoaded()
+
+	first.State.RepoToCommitIdToPRSet["stack1"] = map[string]int{"11111111": 0}
+	require.NoError(t, WriteState(first))
+	second.State.RepoToCommitIdToPRSet["stack2"] = map[string]int{"22222222": 1}
+	require.NoError(t, WriteState(second))
+
+	expected := map[string]map[string]int{
+		"stack1": {"11111111": 0},
+		"stack2": {"22222222": 1},
+	}
+	assert.Equal(t, expected, second.State.RepoToCommitIdToPRSet)
+	contents, err := os.ReadFile(InternalConfigFilePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "stack1")
+	assert.Contains(t, string(contents), "stack2")
+	assert.NoFileExists(t, filepath.Join(os.Getenv("HOME"), ".spr.state.lock"))
+	assert.NoFileExists(t, filepath.Join(os.Getenv("HOME"), ".spr.state.tmp"))
+}
+
+func TestWriteStateLocked(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	wait := stateLockWait
+	stateLockWait = 0
+	t.Cleanup(func() { stateLockWait = wait })
+	require.NoError(t, os.WriteFile(InternalConfigFilePath()+".lock", []byte("pid 1 on elsewhere\n"), 0o644))
+
+	err := WriteState(config.EmptyConfig())
+	assert.ErrorContains(t, err, "another spr is running (pid 1 on elsewhere since ")
+}
+
+func TestWriteStateFailureKeepsStateFile(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	cfg := config.EmptyConfig()
+	cfg.StateLoaded()
+	cfg.State.RepoToCommitIdToPRSet["stack1"] = map[string]int{"11111111": 0}
+	require.NoError(t, WriteState(cfg))
+
+	// The temporary file can't be written over a directory
+	require.NoError(t, os.Mkdir(InternalConfigFilePath()+".tmp", 0o755))
+	cfg.State.RepoToCommitIdToPRSet["stack2"] = map[string]int{"22222222": 1}
+	assert.ErrorContains(t, WriteState(cfg), "saving the state")
+
+	contents, err := os.ReadFile(InternalConfigFilePath())
+	require.NoError(t, err)
+	assert.Contains(t, string(contents), "stack1")
+	assert.NotContains(t, string(contents), "stack2")
+}
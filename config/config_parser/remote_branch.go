@@ -17,20 +17,33 @@ func NewRemoteBranchSource(gitcmd git.GitInterface) *remoteBranch {
 	}
 }
 
-var _remoteBranchRegex = regexp.MustCompile(`^## ([a-zA-Z0-9_\-/\.]+)\.\.\.([a-zA-Z0-9_\-/\.]+)/([a-zA-Z0-9_\-/\.]+)`)
+// The remote name can't contain a slash so the upstream is split on the first one, which leaves the slashes of a
+// branch like release/1.0 in the branch name
+var _remoteBranchRegex = regexp.MustCompile(`^## ([a-zA-Z0-9_\-/\.]+)\.\.\.([a-zA-Z0-9_\-\.]+)/([a-zA-Z0-9_\-/\.]+)`)
 
 func (s *remoteBranch) Load(cfg interface{}) {
 	var output string
 	err := s.gitcmd.Git("status -b --porcelain -u no", &output)
 	check(err)
 
-	matches := _remoteBranchRegex.FindStringSubmatch(output)
-	if matches == nil {
+	local, remote, branch, match := getRemoteBranch(output)
+	if !match {
 		return
 	}
 
 	repoCfg := cfg.(*config.RepoConfig)
 
-	repoCfg.GitHubRemote = matches[2]
-	repoCfg.GitHubBranch = matches[3]
+	repoCfg.LocalBranch = local
+	repoCfg.GitHubRemote = remote
+	repoCfg.GitHubBranch = branch
+}
+
+// getRemoteBranch returns the local branch along with the remote and branch it tracks from the output of
+// git status -b --porcelain
+func getRemoteBranch(status string) (local string, remote string, branch string, match bool) {
+	matches := _remoteBranchRegex.FindStringSubmatch(status)
+	if matches == nil {
+		return "", "", "", false
+	}
+	return matches[1], matches[2], matches[3], true
 }
@@ -29,7 +29,7 @@ func (s *remoteSource) Load(_ interface{}) {
 	lines := strings.Split(output, "\n")
 
 	for _, line := range lines {
-		githubHost, repoOwner, repoName, match := getRepoDetailsFromRemote(line)
+		githubHost, repoOwner, repoName, match := getRepoDetailsFromRemote("origin", line)
 		if match {
 			s.config.Repo.GitHubHost = githubHost
 			s.config.Repo.GitHubRepoOwner = repoOwner
@@ -39,7 +39,51 @@ func (s *remoteSource) Load(_ interface{}) {
 	}
 }
 
-func getRepoDetailsFromRemote(remote string) (string, string, string, bool) {
+type forkRemoteSource struct {
+	gitcmd git.GitInterface
+}
+
+// NewForkRemoteSource reads the repositories of the upstream and push remotes from their urls. It does nothing unless
+// the remotes are split for a fork based workflow, in which case the repository the pull requests are opened on is
+// always the one of the upstream remote.
+func NewForkRemoteSource(gitcmd git.GitInterface) *forkRemoteSource {
+	return &forkRemoteSource{
+		gitcmd: gitcmd,
+	}
+}
+
+func (s *forkRemoteSource) Load(cfg interface{}) {
+	repoCfg := cfg.(*config.RepoConfig)
+	if !repoCfg.ForkWorkflow() {
+		return
+	}
+
+	var output string
+	err := s.gitcmd.Git("remote -v", &output)
+	check(err)
+
+	for _, line := range strings.Split(output, "\n") {
+		githubHost, repoOwner, repoName, match := getRepoDetailsFromRemote(repoCfg.UpstreamRemoteName(), line)
+		if match {
+			repoCfg.GitHubHost = githubHost
+			repoCfg.GitHubRepoOwner = repoOwner
+			repoCfg.GitHubRepoName = repoName
+		}
+		_, repoOwner, repoName, match = getRepoDetailsFromRemote(repoCfg.PushRemoteName(), line)
+		if match {
+			if repoCfg.PushRepoOwner == "" {
+				repoCfg.PushRepoOwner = repoOwner
+			}
+			if repoCfg.PushRepoName == "" {
+				repoCfg.PushRepoName = repoName
+			}
+		}
+	}
+}
+
+// getRepoDetailsFromRemote returns the host, owner and name of the repository if the line of `git remote -v` is the
+// push url of the named remote
+func getRepoDetailsFromRemote(name string, remote string) (string, string, string, bool) {
 	// Allows "https://", "ssh://" or no protocol at all (this means ssh)
 	protocolFormat := `(?:(https://)|(ssh://))?`
 	// This may or may not be present in the address
@@ -49,8 +93,8 @@ func getRepoDetailsFromRemote(remote string) (string, string, string, bool) {
 	repoFormat := `(?P<githubHost>[a-z0-9._\-]+)(/|:)(?P<repoOwner>[\w-]+)/(?P<repoName>[\w-]+)`
 	// This is neither required in https access nor in ssh one
 	suffixFormat := `(.git)?`
-	regexFormat := fmt.Sprintf(`^origin\s+%s%s%s%s \(push\)`,
-		protocolFormat, userFormat, repoFormat, suffixFormat)
+	regexFormat := fmt.Sprintf(`^%s\s+%s%s%s%s \(push\)`,
+		regexp.QuoteMeta(name), protocolFormat, userFormat, repoFormat, suffixFormat)
 	regex := regexp.MustCompile(regexFormat)
 	matches := regex.FindStringSubmatch(remote)
 	if matches != nil {
@@ -13,7 +13,11 @@ func TestEmptyConfig(t *testing.T) {
 		State: &InternalState{
 			MergeCheckCommit:      map[string]string{},
 			RepoToCommitIdToPRSet: map[string]map[string]int{},
+			RepoToPRSetNames:      map[string]map[string]int{},
+			RepoToCommitIds:       map[string]map[string]bool{},
+			RepoToPRSetRefs:       map[string]map[string]int{},
 		},
+		branchNames: &branchNames{},
 	}
 	actual := EmptyConfig()
 	assert.Equal(t, expect, actual)
@@ -27,6 +31,7 @@ func TestDefaultConfig(t *testing.T) {
 			GitHubRemote:          "origin",
 			GitHubBranch:          "main",
 			GitHubHost:            "github.com",
+			HostingProvider:       "github",
 			RequireChecks:         true,
 			RequireApproval:       true,
 			MergeMethod:           "rebase",
@@ -34,16 +39,168 @@ func TestDefaultConfig(t *testing.T) {
 			PRTemplateInsertStart: "",
 			PRTemplateInsertEnd:   "",
 			ShowPrTitlesInStack:   false,
+			BranchNameTemplate:    "spr/{target}/{commit-id}",
 		},
 		User: &UserConfig{
-			LogGitCommands: false,
-			LogGitHubCalls: false,
+			LogGitCommands:     false,
+			LogGitHubCalls:     false,
+			MaxRetries:         5,
+			RetryBudgetSeconds: 120,
 		},
 		State: &InternalState{
 			MergeCheckCommit:      map[string]string{},
 			RepoToCommitIdToPRSet: map[string]map[string]int{},
+			RepoToPRSetNames:      map[string]map[string]int{},
+			RepoToCommitIds:       map[string]map[string]bool{},
+			RepoToPRSetRefs:       map[string]map[string]int{},
 		},
+		branchNames: &branchNames{},
 	}
 	actual := DefaultConfig()
 	assert.Equal(t, expect, actual)
 }
+
+func TestRemoteNames(t *testing.T) {
+	repo := &RepoConfig{GitHubRemote: "origin"}
+	assert.Equal(t, "origin", repo.UpstreamRemoteName())
+	assert.Equal(t, "origin", repo.PushRemoteName())
+	assert.False(t, repo.ForkWorkflow())
+
+	repo.PushRemote = "origin"
+	assert.False(t, repo.ForkWorkflow())
+
+	repo.UpstreamRemote = "upstream"
+	assert.Equal(t, "upstream", repo.UpstreamRemoteName())
+	assert.Equal(t, "origin", repo.PushRemoteName())
+	assert.True(t, repo.ForkWorkflow())
+}
+
+func TestPRSets(t *testing.T) {
+	cfg := EmptyConfig()
+	cfg.Repo.GitHubHost = "github.com"
+	cfg.Repo.GitHubRepoOwner = "owner"
+	cfg.Repo.GitHubRepoName = "repo"
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.LocalBranch = "feature"
+	assert.Equal(t, "github.com/owner/repo:main:feature", cfg.PRSetKey())
+	assert.Nil(t, cfg.PRSets())
+
+	cfg.SetPRSets(map[string]int{"22222222": 1})
+	assert.Equal(t, map[string]int{"22222222": 1}, cfg.PRSets())
+
+	cfg.Repo.GitHubBranch = "release/1.0"
+	cfg.SetPRSets(map[string]int{"33333333": 0})
+	assert.Equal(t, map[string]int{"33333333": 0}, cfg.PRSets())
+	assert.Equal(t, map[string]int{"22222222": 1}, cfg.State.RepoToCommitIdToPRSet["github.com/owner/repo:main:feature"])
+
+	// A repository of the same name from another owner has its own PR sets
+	cfg.Repo.GitHubRepoOwner = "other"
+	assert.Nil(t, cfg.PRSets())
+
+	cfg.Repo.GitHubRepoOwner = "owner"
+	cfg.SetPRSets(nil)
+	assert.NotContains(t, cfg.State.RepoToCommitIdToPRSet, "github.com/owner/repo:release/1.0:feature")
+}
+
+func TestPRSetNames(t *testing.T) {
+	cfg := EmptyConfig()
+	cfg.Repo.GitHubHost = "github.com"
+	cfg.Repo.GitHubRepoOwner = "owner"
+	cfg.Repo.GitHubRepoName = "repo"
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.LocalBranch = "main"
+	assert.Nil(t, cfg.PRSetNames())
+
+	cfg.SetPRSetNames(map[string]int{"auth": 1})
+	assert.Equal(t, map[string]map[string]int{
+		"github.com/owner/repo:main:main": {"auth": 1},
+	}, cfg.State.RepoToPRSetNames)
+
+	cfg.Repo.LocalBranch = "feature"
+	assert.Nil(t, cfg.PRSetNames())
+
+	cfg.Repo.LocalBranch = "main"
+	cfg.SetPRSetNames(map[string]int{})
+	assert.Empty(t, cfg.State.RepoToPRSetNames)
+}
+
+func TestMigratePRSets(t *testing.T) {
+	cfg := EmptyConfig()
+	cfg.Repo.GitHubHost = "github.com"
+	cfg.Repo.GitHubRepoOwner = "owner"
+	cfg.Repo.GitHubRepoName = "repo"
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.LocalBranch = "feature"
+	cfg.State.RepoToCommitIdToPRSet = map[string]map[string]int{
+		"repo":                          {"11111111": 0, "55555555": 0},
+		"repo:release/1.0:backport":     {"22222222": 1},
+		"other":                         {"33333333": 2},
+		"github.com/owner/other:main:x": {"44444444": 3},
+	}
+
+	// Only the PR sets of the repository's commits are moved, 55555555 belongs to another repository named repo
+	commitIds := map[string]bool{"11111111": true, "22222222": true}
+	cfg.MigratePRSets(commitIds)
+	assert.Equal(t, map[string]map[string]int{
+		"repo":                               {"55555555": 0},
+		"github.com/owner/repo:main:feature": {"11111111": 0},
+		"github.com/owner/repo:release/1.0:backport": {"22222222": 1},
+		"other":                         {"33333333": 2},
+		"github.com/owner/other:main:x": {"44444444": 3},
+	}, cfg.State.RepoToCommitIdToPRSet)
+
+	// Migrating again changes nothing
+	cfg.MigratePRSets(commitIds)
+	assert.Len(t, cfg.State.RepoToCommitIdToPRSet, 5)
+
+	// The PR sets saved for this repository win
+	cfg.MigratePRSets(map[string]bool{"55555555": true})
+	assert.NotContains(t, cfg.State.RepoToCommitIdToPRSet, "repo")
+	assert.Equal(t, map[string]int{"11111111": 0}, cfg.State.RepoToCommitIdToPRSet["github.com/owner/repo:main:feature"])
+}
+
+func TestMergeState(t *testing.T) {
+	cfg := EmptyConfig()
+	cfg.State.RunCount = 3
+	cfg.State.MergeCheckCommit = map[string]string{"a": "aaaa", "b": "bbbb"}
+	cfg.State.RepoToCommitIdToPRSet = map[string]map[string]int{
+		"stack1": {"11111111": 0},
+		"stack2": {"22222222": 0},
+	}
+	cfg.StateLoaded()
+
+	// This process
+	cfg.State.RunCount++
+	cfg.State.MergeCheckCommit["a"] = "aaa2"
+	cfg.State.RepoToCommitIdToPRSet["stack1"]["11111111"] = 1
+	delete(cfg.State.RepoToCommitIdToPRSet, "stack2")
+
+	// Another process saved the state in the meantime
+	current := &InternalState{
+		Stargazer:        true,
+		RunCount:         4,
+		MergeCheckCommit: map[string]string{"a": "aaaa", "b": "bbb2"},
+		RepoToCommitIdToPRSet: map[string]map[string]int{
+			"stack1": {"11111111": 0},
+			"stack2": {"22222222": 0},
+			"stack3": {"33333333": 0},
+		},
+	}
+
+	merged := cfg.MergeState(current)
+	assert.Equal(t, &InternalState{
+		Stargazer:        true,
+		RunCount:         5,
+		MergeCheckCommit: map[string]string{"a": "aaa2", "b": "bbb2"},
+		RepoToCommitIdToPRSet: map[string]map[string]int{
+			"stack1": {"11111111": 1},
+			"stack3": {"33333333": 0},
+		},
+		RepoToPRSetNames: map[string]map[string]int{},
+		RepoToCommitIds:  map[string]map[string]bool{},
+		RepoToPRSetRefs:  map[string]map[string]int{},
+	}, merged)
+
+	// The current state isn't changed
+	assert.Len(t, current.RepoToCommitIdToPRSet, 3)
+}
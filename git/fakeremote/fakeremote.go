@@ -0,0 +1,212 @@
+// Package fakeremote is a local bare git repository that stands in for the remote of a fake hosting server
+// (fakegithub, fakegitlab). It implements the git side of the hosting servers: resolving branches, listing the
+// commits of a pull request and merging pull requests.
+package fakeremote
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// Merge methods
+const (
+	MethodMerge  = "MERGE"
+	MethodSquash = "SQUASH"
+	MethodRebase = "REBASE"
+)
+
+// Commit is a commit on one of the remote's branches
+type Commit struct {
+	Oid             string
+	MessageHeadline string
+	MessageBody     string
+}
+
+// Remote is a bare repository whose commits are made by the hosting server's user
+type Remote struct {
+	// Path is the path of the bare repository
+	Path string
+
+	committerName  string
+	committerEmail string
+}
+
+// New creates a bare remote at path with a single empty commit on the default branch.
+// Commits made by the remote (merges) use the committer name and email.
+func New(path string, branch string, committerName string, committerEmail string) (*Remote, error) {
+	r := &Remote{
+		Path:           path,
+		committerName:  committerName,
+		committerEmail: committerEmail,
+	}
+
+	_, err := r.Git(filepath.Dir(path), "init", "--bare", "--initial-branch="+branch, path)
+	if err != nil {
+		return nil, err
+	}
+	tree, err := r.Git(path, "mktree")
+	if err != nil {
+		return nil, err
+	}
+	oid, err := r.Git(path, "commit-tree", tree, "-m", "Initial commit")
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Git(path, "update-ref", "refs/heads/"+branch, oid)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Fork creates a bare clone of the remote at path. Commits made by the fork use the same committer.
+func (r *Remote) Fork(path string) (*Remote, error) {
+	fork := &Remote{
+		Path:           path,
+		committerName:  r.committerName,
+		committerEmail: r.committerEmail,
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return nil, err
+	}
+	_, err = r.Git(filepath.Dir(path), "clone", "--bare", r.Path, path)
+	if err != nil {
+		return nil, err
+	}
+	return fork, nil
+}
+
+// Git runs a git command in dir as the committer.
+func (r *Remote) Git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME="+r.committerName,
+		"GIT_AUTHOR_EMAIL="+r.committerEmail,
+		"GIT_COMMITTER_NAME="+r.committerName,
+		"GIT_COMMITTER_EMAIL="+r.committerEmail,
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Resolve returns the oid of the branch, or false if the branch doesn't exist.
+// Refs outside of refs/heads (like the head of a pull request from a fork) are given by their full name.
+func (r *Remote) Resolve(branch string) (string, bool) {
+	ref := branch
+	if !strings.HasPrefix(ref, "refs/") {
+		ref = "refs/heads/" + branch
+	}
+	oid, err := r.Git(r.Path, "rev-parse", "--verify", "--quiet", ref)
+	if err != nil {
+		return "", false
+	}
+	return oid, true
+}
+
+// IsAncestor returns true if ancestor is reachable from oid
+func (r *Remote) IsAncestor(ancestor string, oid string) bool {
+	_, err := r.Git(r.Path, "merge-base", "--is-ancestor", ancestor, oid)
+	return err == nil
+}
+
+// Commits returns the commits on the head branch that aren't on the base branch, oldest first.
+// There are no commits if either branch doesn't exist.
+func (r *Remote) Commits(baseBranch string, headBranch string) ([]Commit, error) {
+	base, ok := r.Resolve(baseBranch)
+	if !ok {
+		return nil, nil
+	}
+	head, ok := r.Resolve(headBranch)
+	if !ok {
+		return nil, nil
+	}
+
+	out, err := r.Git(r.Path, "log", "--reverse", "--format=%H%x00%s%x00%b%x1e", base+".."+head)
+	if err != nil {
+		return nil, err
+	}
+
+	commits := []Commit{}
+	for _, record := range strings.Split(out, "\x1e") {
+		fields := strings.SplitN(strings.TrimSpace(record), "\x00", 3)
+		if len(fields) != 3 {
+			continue
+		}
+		commits = append(commits, Commit{
+			Oid:             fields[0],
+			MessageHeadline: fields[1],
+			MessageBody:     strings.TrimSpace(fields[2]),
+		})
+	}
+	return commits, nil
+}
+
+// Merge lands the head branch on the base branch using the merge method and returns the new base oid.
+// The message is used for the merge commit or the squashed commit, rebased commits keep their messages.
+// The merge is done in a temporary clone so conflicts leave the remote untouched. A worktree of the remote isn't used as
+// receive-pack fails reading the remote's worktrees while one is being added or removed, and the pull requests of a PR
+// set are closed, pushing to the remote, while the PR set is merged.
+func (r *Remote) Merge(baseBranch string, headBranch string, method string, message string) (string, error) {
+	base, ok := r.Resolve(baseBranch)
+	if !ok {
+		return "", fmt.Errorf("base branch %s doesn't exist", baseBranch)
+	}
+	head, ok := r.Resolve(headBranch)
+	if !ok {
+		return "", fmt.Errorf("head branch %s doesn't exist", headBranch)
+	}
+
+	dir, err := os.MkdirTemp("", "fakeremote-merge")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(dir)
+	worktree := filepath.Join(dir, "clone")
+
+	_, err = r.Git(dir, "clone", "--shared", "--no-checkout", r.Path, worktree)
+	if err != nil {
+		return "", err
+	}
+	_, err = r.Git(worktree, "checkout", "--detach", base)
+	if err != nil {
+		return "", err
+	}
+
+	switch method {
+	case MethodMerge:
+		_, err = r.Git(worktree, "merge", "--no-ff", "-m", message, head)
+	case MethodSquash:
+		_, err = r.Git(worktree, "merge", "--squash", head)
+		if err == nil {
+			_, err = r.Git(worktree, "commit", "-m", message)
+		}
+	default:
+		_, err = r.Git(worktree, "cherry-pick", "--ff", base+".."+head)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	oid, err := r.Git(worktree, "rev-parse", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	_, err = r.Git(r.Path, "fetch", "--no-tags", "--no-write-fetch-head", worktree, "HEAD")
+	if err != nil {
+		return "", err
+	}
+	_, err = r.Git(r.Path, "update-ref", "refs/heads/"+baseBranch, oid, base)
+	if err != nil {
+		return "", err
+	}
+	return oid, nil
+}
@@ -14,12 +14,43 @@ func BranchNameFromCommit(cfg *config.Config, commit Commit) string {
 	return BranchNameFromCommitId(cfg, commit.CommitID)
 }
 
+// BranchNameFromCommitId returns the name of the branch of a commit, made from the branch name template
 func BranchNameFromCommitId(cfg *config.Config, commitId string) string {
-	remoteBranchName := cfg.Repo.GitHubBranch
-	return "spr/" + remoteBranchName + "/" + commitId
+	return strings.NewReplacer(
+		config.BranchNameLogin, cfg.Login(),
+		config.BranchNameTarget, cfg.Repo.GitHubBranch,
+		config.BranchNameCommitId, commitId,
+	).Replace(cfg.Repo.BranchNameTemplateOrDefault())
 }
 
-var BranchNameRegex = regexp.MustCompile(`spr/([a-zA-Z0-9_\-/\.]+)/([a-f0-9]{8})$`)
+// BranchNameRegex returns a regex matching the branch names made from the branch name template (for any target
+// branch). The target branch and commit-id are captured by the "target" and "commitid" subexpressions.
+func BranchNameRegex(cfg *config.Config) *regexp.Regexp {
+	return cfg.BranchNameRegex()
+}
+
+// IsBranchOfTarget returns true if the branch was made from the branch name template for the target branch.
+// Without a {target} placeholder in the template the branches can't be told apart so any template branch matches.
+func IsBranchOfTarget(cfg *config.Config, branchName string) bool {
+	regex := BranchNameRegex(cfg)
+	matches := regex.FindStringSubmatch(branchName)
+	if matches == nil {
+		return false
+	}
+	index := regex.SubexpIndex("target")
+	return index < 0 || matches[index] == cfg.Repo.GitHubBranch
+}
+
+// CommitIdFromBranchName returns the commit-id of a branch made from the branch name template, or an empty string
+// for any other branch
+func CommitIdFromBranchName(cfg *config.Config, branchName string) string {
+	regex := BranchNameRegex(cfg)
+	matches := regex.FindStringSubmatch(branchName)
+	if matches == nil {
+		return ""
+	}
+	return matches[regex.SubexpIndex("commitid")]
+}
 
 // GetLocalCommitStack returns a list of unmerged commits
 //
@@ -27,7 +58,7 @@ var BranchNameRegex = regexp.MustCompile(`spr/([a-zA-Z0-9_\-/\.]+)/([a-f0-9]{8})
 func GetLocalCommitStack(cfg *config.Config, gitcmd GitInterface) []Commit {
 	var commitLog string
 	logCommand := fmt.Sprintf("log --format=medium --no-color %s/%s..HEAD",
-		cfg.Repo.GitHubRemote, cfg.Repo.GitHubBranch)
+		cfg.Repo.UpstreamRemoteName(), cfg.Repo.GitHubBranch)
 	gitcmd.MustGit(logCommand, &commitLog)
 	commits, valid := parseLocalCommitStack(commitLog)
 	if !valid {
@@ -35,7 +66,7 @@ func GetLocalCommitStack(cfg *config.Config, gitcmd GitInterface) []Commit {
 		rewordPath, err := exec.LookPath("spr_reword_helper")
 		check(err)
 		rebaseCommand := fmt.Sprintf("rebase %s/%s -i --autosquash --autostash",
-			cfg.Repo.GitHubRemote, cfg.Repo.GitHubBranch)
+			cfg.Repo.UpstreamRemoteName(), cfg.Repo.GitHubBranch)
 		gitcmd.GitWithEditor(rebaseCommand, nil, rewordPath)
 
 		gitcmd.MustGit(logCommand, &commitLog)
@@ -1,6 +1,11 @@
 package git
 
-import "testing"
+import (
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/stretchr/testify/require"
+)
 
 func TestBranchNameRegex(t *testing.T) {
 	tests := []struct {
@@ -9,15 +14,69 @@ func TestBranchNameRegex(t *testing.T) {
 		commit string
 	}{
 		{input: "spr/b1/deadbeef", branch: "b1", commit: "deadbeef"},
+		{input: "spr/release/1.0/deadbeef", branch: "release/1.0", commit: "deadbeef"},
 	}
 
+	regex := BranchNameRegex(config.EmptyConfig())
 	for _, tc := range tests {
-		matches := BranchNameRegex.FindStringSubmatch(tc.input)
-		if tc.branch != matches[1] {
-			t.Fatalf("expected: '%v', actual: '%v'", tc.branch, matches[1])
-		}
-		if tc.commit != matches[2] {
-			t.Fatalf("expected: '%v', actual: '%v'", tc.commit, matches[2])
-		}
+		matches := regex.FindStringSubmatch(tc.input)
+		require.NotNil(t, matches, tc.input)
+		require.Equal(t, tc.branch, matches[regex.SubexpIndex("target")])
+		require.Equal(t, tc.commit, matches[regex.SubexpIndex("commitid")])
 	}
 }
+
+func TestCommitIdFromBranchName(t *testing.T) {
+	cfg := config.EmptyConfig()
+	require.Equal(t, "", CommitIdFromBranchName(cfg, ""))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "spr/"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "spr/main"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "spr/main/1234444"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "other/main/12344448"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "refs/heads/spr/main/12344448"))
+	require.Equal(t, "12344448", CommitIdFromBranchName(cfg, "spr/main/12344448"))
+}
+
+func TestBranchNameTemplate(t *testing.T) {
+	cfg := config.EmptyConfig()
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.BranchNameTemplate = "users/{login}/{target}/{commit-id}"
+	cfg.User.Login = "octo.cat"
+
+	branchName := BranchNameFromCommitId(cfg, "12344448")
+	require.Equal(t, "users/octo.cat/main/12344448", branchName)
+	require.Equal(t, "12344448", CommitIdFromBranchName(cfg, branchName))
+	// The login is matched literally so the branches of other users aren't matched
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "users/octo-cat/main/12344448"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "users/hubot/main/12344448"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, "spr/main/12344448"))
+}
+
+func TestBranchNameTemplateViewerLogin(t *testing.T) {
+	cfg := config.EmptyConfig()
+	cfg.Repo.GitHubBranch = "main"
+	cfg.Repo.BranchNameTemplate = "users/{login}/{commit-id}"
+	cfg.SetViewerLogin("hubot")
+
+	branchName := BranchNameFromCommitId(cfg, "12344448")
+	require.Equal(t, "users/hubot/12344448", branchName)
+	require.Equal(t, "12344448", CommitIdFromBranchName(cfg, branchName))
+
+	// The configured login overrides the viewer's login
+	cfg.User.Login = "octocat"
+	require.Equal(t, "users/octocat/12344448", BranchNameFromCommitId(cfg, "12344448"))
+	require.Equal(t, "", CommitIdFromBranchName(cfg, branchName))
+}
+
+func TestBranchNameRegexCompiledOnce(t *testing.T) {
+	cfg := config.EmptyConfig()
+	regex := BranchNameRegex(cfg)
+	require.Same(t, regex, BranchNameRegex(cfg))
+
+	// Copies of the config share the regex
+	copied := *cfg
+	require.Same(t, regex, BranchNameRegex(&copied))
+
+	cfg.Repo.BranchNameTemplate = "users/{commit-id}"
+	require.NotSame(t, regex, BranchNameRegex(cfg))
+}
@@ -112,6 +112,16 @@ func (m *Mock) ExpectRemote(remote string) {
 	m.expect("git remote -v", mock.StringOutputter(response))
 }
 
+// ExpectRemotes expects the remotes to be listed, each remote is a name followed by its url
+func (m *Mock) ExpectRemotes(remotes ...[2]string) {
+	response := ""
+	for _, remote := range remotes {
+		response += fmt.Sprintf("%s  %s (fetch)\n", remote[0], remote[1])
+		response += fmt.Sprintf("%s  %s (push)\n", remote[0], remote[1])
+	}
+	m.expect("git remote -v", mock.StringOutputter(response))
+}
+
 func (m *Mock) ExpectFixup(commitHash string) {
 	m.expect("git commit --fixup " + commitHash)
 	m.expect("git rebase -i --autosquash --autostash origin/master")
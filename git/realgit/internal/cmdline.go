@@ -85,7 +85,7 @@ func (c CmdLine) AppendCommitId() error {
 	}
 	rebaseCommand := fmt.Sprintf(
 		"rebase %s/%s -i --autosquash --autostash",
-		c.base.Config.Repo.GitHubRemote,
+		c.base.Config.Repo.UpstreamRemoteName(),
 		c.base.Config.Repo.GitHubBranch,
 	)
 	err = c.GitWithEditor(rebaseCommand, nil, rewordPath)
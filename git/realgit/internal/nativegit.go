@@ -44,7 +44,7 @@ func (c NativeGit) repo() *gogit.Repository {
 }
 
 func (c NativeGit) DeleteRemoteBranch(ctx context.Context, branch string) error {
-	remoteName := c.base.Config.Repo.GitHubRemote
+	remoteName := c.base.Config.Repo.PushRemoteName()
 
 	remote, err := c.repo().Remote(remoteName)
 	if err != nil {
@@ -124,10 +124,10 @@ func (c NativeGit) Push(remoteName string, refspecs []string) error {
 	return nil
 }
 
-// RemoteBranches returns a list of all remote branches
+// RemoteBranches returns a list of all the branches of the remote the spr branches are pushed to
 func (c NativeGit) RemoteBranches() (mapset.Set[string], error) {
 	remoteBranches := mapset.NewSet[string]()
-	remote, err := c.repo().Remote(c.base.Config.Repo.GitHubRemote)
+	remote, err := c.repo().Remote(c.base.Config.Repo.PushRemoteName())
 	if err != nil {
 		return remoteBranches, fmt.Errorf("finding remote branches %w", err)
 	}
@@ -171,9 +171,13 @@ func (c NativeGit) OriginMainRef(ctx context.Context) (string, error) {
 	return c.OriginBranchRef(ctx, branch)
 }
 
-// OriginBranchRef returns the ref for the default remote (often origin) and the given branch
+// OriginBranchRef returns the ref for the default remote (often origin) and the given branch. With a fork the default
+// branch is read from the upstream remote and any other branch from the push remote.
 func (c NativeGit) OriginBranchRef(ctx context.Context, branch string) (string, error) {
-	remote := c.base.Config.Repo.GitHubRemote
+	remote := c.base.Config.Repo.PushRemoteName()
+	if branch == c.base.Config.Repo.GitHubBranch {
+		remote = c.base.Config.Repo.UpstreamRemoteName()
+	}
 
 	originMainRef, err := c.Reference(fmt.Sprintf("refs/remotes/%s/%s", remote, branch), true)
 	if err != nil {
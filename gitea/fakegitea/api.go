@@ -0,0 +1,437 @@
+package fakegitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ejoffe/spr/git/fakeremote"
+)
+
+func (s *Server) serveUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, userJSON(s.user(Login)))
+}
+
+func (s *Server) serveRepository(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, object{
+		"id":             RepoId,
+		"full_name":      Owner + "/" + Name,
+		"default_branch": Branch,
+		"fork":           false,
+		"parent":         nil,
+	})
+}
+
+func (s *Server) serveAssignees(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+	assignees := []object{}
+	for _, user := range s.Users {
+		assignees = append(assignees, userJSON(user))
+	}
+	writeJSON(w, http.StatusOK, assignees)
+}
+
+// serveListPullRequests implements GET /repos/:owner/:repo/pulls with the state filter
+func (s *Server) serveListPullRequests(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+	state := r.URL.Query().Get("state")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prs := []object{}
+	// newest first
+	for _, pr := range slices.Backward(s.pullRequests) {
+		if state != "" && state != "all" && pr.State != state {
+			continue
+		}
+		prs = append(prs, s.pullRequestJSON(pr))
+	}
+	writePage(w, r, prs, s.PageSize)
+}
+
+func (s *Server) serveCreatePullRequest(w http.ResponseWriter, r *http.Request) {
+	var create struct {
+		Head  string `json:"head"`
+		Base  string `json:"base"`
+		Title string `json:"title"`
+		Body  string `json:"body"`
+	}
+	if !checkRepository(w, r) || !decode(w, r, &create) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if create.Title == "" {
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": "[Title]: Required"})
+		return
+	}
+	if create.Head == create.Base {
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": "Invalid PullRequest: There are no changes between the head and the base"})
+		return
+	}
+	for _, branch := range []string{create.Head, create.Base} {
+		if _, ok := s.remote.Resolve(branch); !ok {
+			writeJSON(w, http.StatusNotFound, object{"message": "GetBranch: branch does not exist [name: " + branch + "]"})
+			return
+		}
+	}
+	for _, pr := range s.pullRequests {
+		if pr.State == StateOpen && pr.Head == create.Head && pr.Base == create.Base {
+			writeJSON(w, http.StatusConflict, object{"message": fmt.Sprintf(
+				"pull request already exists for these targets [id: %d, issue_id: %d, head_branch: %s, base_branch: %s]",
+				pr.Id, pr.Id, pr.Head, pr.Base)})
+			return
+		}
+	}
+
+	number := len(s.pullRequests) + 1
+	pr := &PullRequest{
+		Id:        1000 + number,
+		Number:    number,
+		Title:     create.Title,
+		Body:      create.Body,
+		Head:      create.Head,
+		Base:      create.Base,
+		Poster:    Login,
+		State:     StateOpen,
+		Mergeable: true,
+		Reviews:   []Review{{User: Reviewer, State: ReviewApproved}},
+	}
+	s.pullRequests = append(s.pullRequests, pr)
+	writeJSON(w, http.StatusCreated, s.pullRequestJSON(pr))
+}
+
+func (s *Server) serveGetPullRequest(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.pullRequestJSON(pr))
+}
+
+// serveEditPullRequest implements PATCH /repos/:owner/:repo/pulls/:index. A merged pull request can't be reopened.
+func (s *Server) serveEditPullRequest(w http.ResponseWriter, r *http.Request) {
+	var edit struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		Base  *string `json:"base"`
+		State *string `json:"state"`
+	}
+	if !checkRepository(w, r) || !decode(w, r, &edit) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+
+	if edit.Base != nil && *edit.Base != pr.Base {
+		if _, ok := s.remote.Resolve(*edit.Base); !ok {
+			writeJSON(w, http.StatusNotFound, object{"message": "NewBaseBranchNotExist"})
+			return
+		}
+		pr.Base = *edit.Base
+	}
+	if edit.Title != nil {
+		pr.Title = *edit.Title
+	}
+	if edit.Body != nil {
+		pr.Body = *edit.Body
+	}
+	if edit.State != nil && !pr.Merged {
+		switch *edit.State {
+		case StateOpen, StateClosed:
+			pr.State = *edit.State
+		}
+	}
+
+	writeJSON(w, http.StatusCreated, s.pullRequestJSON(pr))
+}
+
+// serveCommits lists the commits of the pull request, newest first
+func (s *Server) serveCommits(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	commits, err := s.remote.Commits(pr.Base, pr.Head)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, object{"message": err.Error()})
+		return
+	}
+
+	items := []object{}
+	for _, c := range slices.Backward(commits) {
+		message := c.MessageHeadline + "\n"
+		if c.MessageBody != "" {
+			message += "\n" + c.MessageBody + "\n"
+		}
+		items = append(items, object{
+			"sha":    c.Oid,
+			"commit": object{"message": message},
+		})
+	}
+	writePage(w, r, items, s.PageSize)
+}
+
+func (s *Server) serveReviews(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	reviews := []object{}
+	for i, review := range pr.Reviews {
+		reviews = append(reviews, object{
+			"id":        i + 1,
+			"user":      userJSON(s.user(review.User)),
+			"state":     review.State,
+			"dismissed": review.Dismissed,
+		})
+	}
+	writePage(w, r, reviews, s.PageSize)
+}
+
+func (s *Server) serveRequestReviewers(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Reviewers []string `json:"reviewers"`
+	}
+	if !checkRepository(w, r) || !decode(w, r, &request) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	for _, reviewer := range request.Reviewers {
+		if !slices.Contains(pr.RequestedReviewers, reviewer) {
+			pr.RequestedReviewers = append(pr.RequestedReviewers, reviewer)
+		}
+	}
+	writeJSON(w, http.StatusCreated, []object{})
+}
+
+// serveMerge merges the pull request straight away, merge_when_checks_succeed is ignored as there is nothing to wait
+// for in the fake.
+func (s *Server) serveMerge(w http.ResponseWriter, r *http.Request) {
+	var merge struct {
+		Do           string `json:"Do"`
+		HeadCommitId string `json:"head_commit_id"`
+	}
+	if !checkRepository(w, r) || !decode(w, r, &merge) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	if pr.Merged {
+		writeJSON(w, http.StatusMethodNotAllowed, object{"message": "The PR is already merged"})
+		return
+	}
+	if pr.State != StateOpen || !pr.Mergeable {
+		writeJSON(w, http.StatusMethodNotAllowed, object{"message": "Please try again later"})
+		return
+	}
+	if merge.HeadCommitId != "" {
+		head, _ := s.remote.Resolve(pr.Head)
+		if head != merge.HeadCommitId {
+			writeJSON(w, http.StatusConflict, object{"message": "head out of date"})
+			return
+		}
+	}
+
+	var method string
+	message := fmt.Sprintf("Merge pull request '%s' (#%d) from %s into %s", pr.Title, pr.Number, pr.Head, pr.Base)
+	switch merge.Do {
+	case "merge", "rebase-merge":
+		method = fakeremote.MethodMerge
+	case "rebase", "fast-forward-only":
+		method = fakeremote.MethodRebase
+	case "squash":
+		method = fakeremote.MethodSquash
+		message = fmt.Sprintf("%s (#%d)", pr.Title, pr.Number)
+	default:
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": "[Do]: Invalid merge style " + merge.Do})
+		return
+	}
+	_, err := s.remote.Merge(pr.Base, pr.Head, method, message)
+	if err != nil {
+		writeJSON(w, http.StatusConflict, object{"message": "Merge conflict: " + err.Error()})
+		return
+	}
+	pr.State = StateClosed
+	pr.Merged = true
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) serveCreateComment(w http.ResponseWriter, r *http.Request) {
+	var comment struct {
+		Body string `json:"body"`
+	}
+	if !checkRepository(w, r) || !decode(w, r, &comment) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.lookup(w, r)
+	if pr == nil {
+		return
+	}
+	pr.Comments = append(pr.Comments, comment.Body)
+	writeJSON(w, http.StatusCreated, object{"id": len(pr.Comments), "body": comment.Body})
+}
+
+// serveCombinedStatus implements GET /repos/:owner/:repo/commits/:ref/status for commit shas
+func (s *Server) serveCombinedStatus(w http.ResponseWriter, r *http.Request) {
+	if !checkRepository(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	ref := r.PathValue("ref")
+	state, ok := s.statuses[ref]
+	if !ok {
+		writeJSON(w, http.StatusOK, object{"sha": ref, "state": "", "statuses": []object{}, "total_count": 0})
+		return
+	}
+	writeJSON(w, http.StatusOK, object{
+		"sha":         ref,
+		"state":       state,
+		"statuses":    []object{{"context": "ci", "status": state}},
+		"total_count": 1,
+	})
+}
+
+// checkRepository writes a 404 if the request isn't for the fake repository
+func checkRepository(w http.ResponseWriter, r *http.Request) bool {
+	if r.PathValue("owner") != Owner || r.PathValue("repo") != Name {
+		writeJSON(w, http.StatusNotFound, object{"message": "The target couldn't be found."})
+		return false
+	}
+	return true
+}
+
+// lookup returns the pull request of the request or writes a 404 if there isn't one
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *PullRequest {
+	number, err := strconv.Atoi(r.PathValue("index"))
+	if err == nil {
+		if pr := s.pullRequestByNumber(number); pr != nil {
+			return pr
+		}
+	}
+	writeJSON(w, http.StatusNotFound, object{"message": "The target couldn't be found."})
+	return nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": "invalid JSON body"})
+		return false
+	}
+	return true
+}
+
+// writePage writes the page of items selected by the page and limit parameters.
+// Like Gitea, the next page is linked in the Link header.
+func writePage(w http.ResponseWriter, r *http.Request, items []object, maxLimit int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = 30
+	}
+	limit = min(limit, maxLimit)
+
+	start := min((page-1)*limit, len(items))
+	end := min(start+limit, len(items))
+	if end < len(items) {
+		next := *r.URL
+		query := next.Query()
+		query.Set("page", strconv.Itoa(page+1))
+		next.RawQuery = query.Encode()
+		w.Header().Set("Link", fmt.Sprintf(`<%s>; rel="next"`, next.String()))
+	}
+	w.Header().Set("X-Total-Count", strconv.Itoa(len(items)))
+	writeJSON(w, http.StatusOK, items[start:end])
+}
+
+func userJSON(user User) object {
+	return object{"id": user.Id, "login": user.Login, "full_name": user.FullName}
+}
+
+func (s *Server) pullRequestJSON(pr *PullRequest) object {
+	headSha, _ := s.remote.Resolve(pr.Head)
+	baseSha, _ := s.remote.Resolve(pr.Base)
+	reviewers := []object{}
+	for _, login := range pr.RequestedReviewers {
+		reviewers = append(reviewers, userJSON(s.user(login)))
+	}
+	return object{
+		"id":                  pr.Id,
+		"number":              pr.Number,
+		"title":               pr.Title,
+		"body":                pr.Body,
+		"state":               pr.State,
+		"draft":               strings.HasPrefix(pr.Title, "WIP:"),
+		"merged":              pr.Merged,
+		"mergeable":           pr.Mergeable,
+		"html_url":            fmt.Sprintf("https://gitea.com/%s/%s/pulls/%d", Owner, Name, pr.Number),
+		"user":                userJSON(s.user(pr.Poster)),
+		"requested_reviewers": reviewers,
+		"head":                object{"ref": pr.Head, "sha": headSha, "repo_id": RepoId, "label": pr.Head},
+		"base":                object{"ref": pr.Base, "sha": baseSha, "repo_id": RepoId, "label": pr.Base},
+	}
+}
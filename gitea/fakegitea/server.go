@@ -0,0 +1,236 @@
+// Package fakegitea is an in-process stand-in for the parts of the Gitea REST API that spr uses, Forgejo serves the
+// same API. It is backed by a local bare git repository that acts as the Gitea remote, so the full update and merge
+// flows can be exercised in `go test` without network access.
+package fakegitea
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git/fakeremote"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Owner is the owner of the fake repository
+	Owner = "spr-owner"
+	// Name is the name of the fake repository
+	Name = "spr-repo"
+	// Login is the login of the authenticated user
+	Login = "spr-user"
+	// Reviewer is the login of the user that approves new pull requests
+	Reviewer = "spr-reviewer"
+	// Branch is the default branch of the fake repository
+	Branch = "main"
+	// RepoId is the id of the fake repository
+	RepoId = 42
+)
+
+// Pull request states, a merged pull request is closed
+const (
+	StateOpen   = "open"
+	StateClosed = "closed"
+)
+
+// Review states
+const (
+	ReviewApproved       = "APPROVED"
+	ReviewRequestChanges = "REQUEST_CHANGES"
+	ReviewComment        = "COMMENT"
+)
+
+// PullRequest is the server side state of a pull request.
+type PullRequest struct {
+	Id     int
+	Number int
+	Title  string
+	Body   string
+	Head   string
+	Base   string
+	Poster string
+	State  string
+	Merged bool
+
+	// Mergeable and Reviews are reported for the pull request.
+	// New pull requests are mergeable and approved by Reviewer so they can be merged straight away.
+	Mergeable bool
+	Reviews   []Review
+
+	RequestedReviewers []string
+	Comments           []string
+}
+
+// Review is a review of a pull request
+type Review struct {
+	User      string
+	State     string
+	Dismissed bool
+}
+
+// User is a user that can be assigned to the fake repository's pull requests
+type User struct {
+	Id       int
+	Login    string
+	FullName string
+}
+
+// Server is a fake Gitea server for a single repository.
+type Server struct {
+	// RemotePath is the bare git repository that acts as the Gitea remote.
+	RemotePath string
+	// PageSize is the maximum number of items returned in a page of any list.
+	PageSize int
+	// Users are the assignees of the repository, the first one is the authenticated user.
+	Users []User
+
+	remote *fakeremote.Remote
+	server *httptest.Server
+
+	lock         sync.Mutex
+	pullRequests []*PullRequest
+	// statuses is the combined commit status by commit sha
+	statuses map[string]string
+}
+
+// New starts a fake Gitea server along with its bare git remote. Both are cleaned up when the test completes.
+func New(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		RemotePath: filepath.Join(t.TempDir(), Name+".git"),
+		PageSize:   50,
+		Users: []User{
+			{Id: 1, Login: Login, FullName: "Spr User"},
+			{Id: 2, Login: Reviewer, FullName: "Spr Reviewer"},
+		},
+		statuses: map[string]string{},
+	}
+	var err error
+	s.remote, err = fakeremote.New(s.RemotePath, Branch, "Gitea", "noreply@gitea.com")
+	require.NoError(t, err)
+
+	s.server = httptest.NewServer(s.handler())
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// URL returns the base url of the server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Configure points the repo config at the fake server and its repository.
+func (s *Server) Configure(cfg *config.Config) {
+	cfg.Repo.HostingProvider = config.HostingProviderGitea
+	cfg.Repo.GitHubHost = "gitea.com"
+	cfg.Repo.GitHubRepoOwner = Owner
+	cfg.Repo.GitHubRepoName = Name
+	cfg.Repo.GitHubApiUrl = s.server.URL + "/api/v1"
+}
+
+// PullRequests returns a copy of every pull request (in any state) ordered by number.
+func (s *Server) PullRequests() []PullRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prs := []PullRequest{}
+	for _, pr := range s.pullRequests {
+		cp := *pr
+		cp.Reviews = slices.Clone(pr.Reviews)
+		cp.RequestedReviewers = slices.Clone(pr.RequestedReviewers)
+		cp.Comments = slices.Clone(pr.Comments)
+		prs = append(prs, cp)
+	}
+	return prs
+}
+
+// ModifyPullRequest calls fn with the pull request so tests can change things like its reviews or mergeability.
+func (s *Server) ModifyPullRequest(number int, fn func(pr *PullRequest)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.pullRequestByNumber(number)
+	if pr == nil {
+		return fmt.Errorf("no pull request #%d", number)
+	}
+	fn(pr)
+	return nil
+}
+
+// SetPullRequestStatus sets the combined commit status (success, pending, failure, error or warning) of the current
+// head commit of the pull request. Pushing a new head commit leaves the pull request without a status.
+func (s *Server) SetPullRequestStatus(number int, state string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.pullRequestByNumber(number)
+	if pr == nil {
+		return fmt.Errorf("no pull request #%d", number)
+	}
+	sha, ok := s.remote.Resolve(pr.Head)
+	if !ok {
+		return fmt.Errorf("pull request #%d has no head branch %s", number, pr.Head)
+	}
+	s.statuses[sha] = state
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/user", s.serveUser)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}", s.serveRepository)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/assignees", s.serveAssignees)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/pulls", s.serveListPullRequests)
+	mux.HandleFunc("POST /api/v1/repos/{owner}/{repo}/pulls", s.serveCreatePullRequest)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/pulls/{index}", s.serveGetPullRequest)
+	mux.HandleFunc("PATCH /api/v1/repos/{owner}/{repo}/pulls/{index}", s.serveEditPullRequest)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/pulls/{index}/commits", s.serveCommits)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/pulls/{index}/reviews", s.serveReviews)
+	mux.HandleFunc("POST /api/v1/repos/{owner}/{repo}/pulls/{index}/requested_reviewers", s.serveRequestReviewers)
+	mux.HandleFunc("POST /api/v1/repos/{owner}/{repo}/pulls/{index}/merge", s.serveMerge)
+	mux.HandleFunc("POST /api/v1/repos/{owner}/{repo}/issues/{index}/comments", s.serveCreateComment)
+	mux.HandleFunc("GET /api/v1/repos/{owner}/{repo}/commits/{ref}/status", s.serveCombinedStatus)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "token ") {
+			writeJSON(w, http.StatusUnauthorized, object{"message": "token is required"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) pullRequestByNumber(number int) *PullRequest {
+	for _, pr := range s.pullRequests {
+		if pr.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (s *Server) user(login string) User {
+	for _, user := range s.Users {
+		if user.Login == login {
+			return user
+		}
+	}
+	return User{Login: login}
+}
+
+type object = map[string]any
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,265 @@
+package fakegitea
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ejoffe/spr/bl/gitapi"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/gitea/giteaclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+// pushCommit adds a commit on top of parent to the remote as the given branch
+func pushCommit(t *testing.T, s *Server, parent string, branch string, message string) {
+	t.Helper()
+
+	base, ok := s.remote.Resolve(parent)
+	require.True(t, ok)
+	tree, err := s.remote.Git(s.RemotePath, "mktree")
+	require.NoError(t, err)
+	oid, err := s.remote.Git(s.RemotePath, "commit-tree", tree, "-p", base, "-m", message)
+	require.NoError(t, err)
+	_, err = s.remote.Git(s.RemotePath, "update-ref", "refs/heads/"+branch, oid)
+	require.NoError(t, err)
+}
+
+func newClient(t *testing.T, s *Server) hosting.Client {
+	t.Setenv("GITEA_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := giteaclient.NewGiteaClient(context.Background(), nil, cfg)
+	require.NoError(t, err)
+	return client
+}
+
+func TestPullRequestsArePaged(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	s.PageSize = 1
+	client := newClient(t, s)
+
+	pushCommit(t, s, Branch, "first", "first commit")
+	pushCommit(t, s, "first", "second", "second commit\n\nsecond body")
+	pushCommit(t, s, "second", "third", "third commit")
+
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "first and second",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, number)
+	_, number, err = client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: "second",
+		HeadRefName: "third",
+		Title:       "third",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, number)
+	// pull requests opened by other users are ignored
+	require.NoError(t, s.ModifyPullRequest(2, func(pr *PullRequest) { pr.Poster = Reviewer }))
+
+	repo, err := client.PullRequestsAndStatus(ctx, Owner, Name)
+	require.NoError(t, err)
+	require.Equal(t, Login, repo.Viewer)
+	require.Equal(t, "42", repo.Id)
+	require.Len(t, repo.PullRequests, 1)
+
+	pr := repo.PullRequests[0]
+	require.Equal(t, "second", pr.HeadRefName)
+	require.Equal(t, Branch, pr.BaseRefName)
+	require.Equal(t, 1, pr.Number)
+	require.Equal(t, "42", pr.BaseRepositoryId)
+	require.Equal(t, Login, pr.Author)
+	require.Equal(t, hosting.MergeableStateMergeable, pr.Mergeable)
+	require.Equal(t, hosting.ReviewDecisionApproved, pr.ReviewDecision)
+	require.Equal(t, hosting.CheckStateNone, pr.CheckState)
+	require.Len(t, pr.Commits, 2)
+	require.Equal(t, "first commit", pr.Commits[0].MessageHeadline)
+	require.Equal(t, "second commit", pr.Commits[1].MessageHeadline)
+	require.Equal(t, "second body", pr.Commits[1].MessageBody)
+
+	require.Equal(t, "WIP: third", s.PullRequests()[1].Title)
+}
+
+func TestPullRequestStatus(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	client := newClient(t, s)
+
+	pushCommit(t, s, Branch, "feature", "feature commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "feature",
+		Title:       "feature",
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		status string
+		state  hosting.CheckState
+	}{
+		{"success", hosting.CheckStateSuccess},
+		{"pending", hosting.CheckStatePending},
+		{"failure", hosting.CheckStateFailure},
+	} {
+		require.NoError(t, s.SetPullRequestStatus(number, tc.status))
+		require.NoError(t, s.ModifyPullRequest(number, func(pr *PullRequest) {
+			pr.Mergeable = false
+			pr.Reviews = append(pr.Reviews, Review{User: "someone", State: ReviewRequestChanges})
+		}))
+
+		repo, err := client.PullRequestsAndStatus(ctx, Owner, Name)
+		require.NoError(t, err)
+		pr := repo.PullRequests[0]
+		require.Equal(t, hosting.MergeableStateConflicting, pr.Mergeable)
+		require.Equal(t, hosting.ReviewDecisionChangesRequested, pr.ReviewDecision)
+		require.Equal(t, tc.state, pr.CheckState, tc.status)
+	}
+}
+
+func TestEditPullRequest(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	client := newClient(t, s)
+
+	pushCommit(t, s, Branch, "first", "first commit")
+	pushCommit(t, s, "first", "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: "first",
+		HeadRefName: "second",
+		Title:       "second",
+	})
+	require.NoError(t, err)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{
+		Title:       ptrutils.Ptr("new title"),
+		Body:        ptrutils.Ptr("new body"),
+		BaseRefName: ptrutils.Ptr(Branch),
+		Draft:       ptrutils.Ptr(true),
+	})
+	require.NoError(t, err)
+	pr := s.PullRequests()[0]
+	require.Equal(t, "WIP: new title", pr.Title)
+	require.Equal(t, "new body", pr.Body)
+	require.Equal(t, Branch, pr.Base)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{State: ptrutils.Ptr(hosting.PullRequestStateClosed)})
+	require.NoError(t, err)
+	require.Equal(t, StateClosed, s.PullRequests()[0].State)
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{State: ptrutils.Ptr(hosting.PullRequestStateOpen)})
+	require.NoError(t, err)
+	require.Equal(t, StateOpen, s.PullRequests()[0].State)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{
+		BaseRefName: ptrutils.Ptr("missing"),
+	})
+	require.ErrorContains(t, err, "NewBaseBranchNotExist")
+}
+
+func TestUpdatePullRequestKeepsDraft(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	client := newClient(t, s)
+
+	pushCommit(t, s, Branch, "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "second",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+
+	commit := git.Commit{CommitID: "00000002", Subject: "second commit"}
+	pr := &hosting.PullRequest{Number: number, Title: s.PullRequests()[0].Title, FromBranch: "second"}
+	err = client.UpdatePullRequest(ctx, nil, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "WIP: second commit", s.PullRequests()[0].Title)
+
+	pr.Title = "second"
+	err = client.UpdatePullRequest(ctx, nil, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "second commit", s.PullRequests()[0].Title)
+}
+
+func TestMergePullRequest(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	client := newClient(t, s)
+
+	pushCommit(t, s, Branch, "feature", "feature commit\n\ncommit-id:00000001")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "feature",
+		Title:       "feature",
+	})
+	require.NoError(t, err)
+	head, _ := s.remote.Resolve("feature")
+	pr := &hosting.PullRequest{Number: number, Commit: git.Commit{CommitHash: head}}
+
+	require.NoError(t, client.AddReviewers(ctx, pr, []string{Reviewer}))
+	require.NoError(t, client.CommentPullRequest(ctx, pr, "a comment"))
+	require.Equal(t, []string{Reviewer}, s.PullRequests()[0].RequestedReviewers)
+	require.Equal(t, []string{"a comment"}, s.PullRequests()[0].Comments)
+
+	// the head commit must match
+	stale := &hosting.PullRequest{Number: number, Commit: git.Commit{CommitHash: "0000000000000000000000000000000000000000"}}
+	err = client.MergePullRequest(ctx, stale, hosting.MergeMethodMerge)
+	require.ErrorContains(t, err, "head out of date")
+
+	require.NoError(t, client.MergePullRequest(ctx, pr, hosting.MergeMethodMerge))
+	merged := s.PullRequests()[0]
+	require.True(t, merged.Merged)
+	require.Equal(t, StateClosed, merged.State)
+	subject, err := s.remote.Git(s.RemotePath, "log", "-1", "--format=%s", Branch)
+	require.NoError(t, err)
+	require.Equal(t, "Merge pull request 'feature' (#1) from feature into main", subject)
+
+	err = client.MergePullRequest(ctx, pr, hosting.MergeMethodMerge)
+	require.ErrorContains(t, err, "already merged")
+}
+
+func TestGitApiUpdatePullRequestKeepsDraft(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITEA_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := giteaclient.NewGiteaClient(ctx, nil, cfg)
+	require.NoError(t, err)
+	gapi := gitapi.New(cfg, nil, client)
+
+	pushCommit(t, s, Branch, "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "second",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+
+	commit := git.Commit{CommitID: "00000002", Subject: "second commit"}
+	pr := &hosting.PullRequest{Number: number, FromBranch: "second"}
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "WIP: second commit", s.PullRequests()[0].Title)
+
+	// A pull request that isn't a work in progress only becomes one when drafts are created
+	require.NoError(t, s.ModifyPullRequest(number, func(pr *PullRequest) { pr.Title = "second" }))
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "second commit", s.PullRequests()[0].Title)
+
+	cfg.User.CreateDraftPRs = true
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "WIP: second commit", s.PullRequests()[0].Title)
+}
@@ -0,0 +1,193 @@
+package giteaclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The subset of the Gitea REST API (v1) resources used by spr, Forgejo serves the same API
+
+type user struct {
+	Id       int    `json:"id"`
+	Login    string `json:"login"`
+	FullName string `json:"full_name"`
+}
+
+type repository struct {
+	Id       int         `json:"id"`
+	FullName string      `json:"full_name"`
+	Fork     bool        `json:"fork"`
+	Parent   *repository `json:"parent"`
+}
+
+type branch struct {
+	Ref    string `json:"ref"`
+	Sha    string `json:"sha"`
+	RepoId int    `json:"repo_id"`
+}
+
+type pullRequest struct {
+	Id        int    `json:"id"`
+	Number    int    `json:"number"`
+	Title     string `json:"title"`
+	Body      string `json:"body"`
+	State     string `json:"state"`
+	Mergeable bool   `json:"mergeable"`
+	Head      branch `json:"head"`
+	Base      branch `json:"base"`
+	User      user   `json:"user"`
+}
+
+type commit struct {
+	Sha    string `json:"sha"`
+	Commit struct {
+		Message string `json:"message"`
+	} `json:"commit"`
+}
+
+type combinedStatus struct {
+	State      string `json:"state"`
+	TotalCount int    `json:"total_count"`
+}
+
+type review struct {
+	Id        int    `json:"id"`
+	User      user   `json:"user"`
+	State     string `json:"state"`
+	Dismissed bool   `json:"dismissed"`
+}
+
+type createPullRequest struct {
+	Head  string `json:"head"`
+	Base  string `json:"base"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+}
+
+type editPullRequest struct {
+	Title *string `json:"title,omitempty"`
+	Body  *string `json:"body,omitempty"`
+	Base  *string `json:"base,omitempty"`
+	State *string `json:"state,omitempty"`
+}
+
+type mergePullRequest struct {
+	Do                     string `json:"Do"`
+	HeadCommitId           string `json:"head_commit_id,omitempty"`
+	MergeWhenChecksSucceed bool   `json:"merge_when_checks_succeed"`
+}
+
+type reviewRequests struct {
+	Reviewers []string `json:"reviewers"`
+}
+
+type comment struct {
+	Body string `json:"body"`
+}
+
+// apiError is returned for responses with a non 2xx status
+type apiError struct {
+	method     string
+	path       string
+	statusCode int
+	message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s %s", e.method, e.path, e.statusCode, http.StatusText(e.statusCode), e.message)
+}
+
+// do sends the request to the api and decodes the JSON response into result (if it isn't nil).
+// It returns whether the Link header has a next page.
+func (c *client) do(ctx context.Context, method string, path string, body any, result any) (bool, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return false, fmt.Errorf("encoding %s %s %w", method, path, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiUrl+path, reqBody)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return false, fmt.Errorf("reading %s %s %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return false, &apiError{
+			method:     method,
+			path:       path,
+			statusCode: resp.StatusCode,
+			message:    errorMessage(data),
+		}
+	}
+
+	if result != nil && len(bytes.TrimSpace(data)) > 0 {
+		err = json.Unmarshal(data, result)
+		if err != nil {
+			return false, fmt.Errorf("decoding %s %s %w", method, path, err)
+		}
+	}
+	return hasNextPage(resp.Header.Get("Link")), nil
+}
+
+// getAll fetches every page of a list resource
+func getAll[T any](ctx context.Context, c *client, path string) ([]T, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	var all []T
+	for page, more := 1, true; more; page++ {
+		var items []T
+		var err error
+		more, err = c.do(ctx, http.MethodGet, fmt.Sprintf("%s%slimit=50&page=%d", path, separator, page), nil, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
+
+// hasNextPage reports whether a Link header has a rel="next" link
+func hasNextPage(link string) bool {
+	for _, part := range strings.Split(link, ",") {
+		if strings.Contains(part, `rel="next"`) {
+			return true
+		}
+	}
+	return false
+}
+
+// errorMessage extracts the message from a Gitea error response
+func errorMessage(data []byte) string {
+	var resp struct {
+		Message string `json:"message"`
+	}
+	if json.Unmarshal(data, &resp) != nil || resp.Message == "" {
+		return strings.TrimSpace(string(data))
+	}
+	return resp.Message
+}
@@ -0,0 +1,570 @@
+// Package giteaclient implements hosting.Client on top of the Gitea REST API so spr can manage stacks of
+// pull requests on Gitea and Forgejo. The combined commit status of the head commit is the check status and the latest
+// reviews decide whether the pull request is approved.
+package giteaclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ejoffe/spr/bl/concurrent"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/rs/zerolog/log"
+)
+
+type authedTransport struct {
+	key     string
+	wrapped http.RoundTripper
+}
+
+func (t *authedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("Authorization", "token "+t.key)
+	return t.wrapped.RoundTrip(req)
+}
+
+const tokenHelpText = `
+No Gitea token found! Create an access token with the "write:repository",
+"write:issue" and "read:user" scopes at https://%s/user/settings/applications
+and set the GITEA_TOKEN environment variable.
+`
+
+// draftPrefix marks a pull request as a work in progress
+const draftPrefix = "WIP: "
+
+func NewGiteaClient(ctx context.Context, git git.GitInterface, config *config.Config) (*client, error) {
+	token := os.Getenv("GITEA_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf(tokenHelpText, config.Repo.GitHubHost)
+	}
+
+	transport := &authedTransport{
+		key:     token,
+		wrapped: githubclient.NewRetryTransport(http.DefaultTransport, config.User),
+	}
+	return &client{
+		config:     config,
+		git:        git,
+		apiUrl:     apiEndpoint(config.Repo),
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
+	}, nil
+}
+
+// Record captures all of the Gitea API traffic to a cassette file at path.
+// The token is redacted from the cassette so it can be attached to bug reports.
+func (c *client) Record(path string) {
+	c.transport.wrapped = githubclient.NewRecordingTransport(c.transport.wrapped, path, c.transport.key)
+}
+
+// apiEndpoint returns the base url of the REST api for the configured host.
+func apiEndpoint(repoConfig *config.RepoConfig) string {
+	if repoConfig.GitHubApiUrl != "" {
+		return strings.TrimSuffix(repoConfig.GitHubApiUrl, "/")
+	}
+	return fmt.Sprintf("https://%s/api/v1", repoConfig.GitHubHost)
+}
+
+type client struct {
+	config     *config.Config
+	git        git.GitInterface
+	apiUrl     string
+	httpClient *http.Client
+	transport  *authedTransport
+}
+
+func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea fetch pull requests\n")
+	}
+
+	repo, err := c.PullRequestsAndStatus(ctx, c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName)
+	if err != nil {
+		return nil, fmt.Errorf("fetching pull requests %w", err)
+	}
+
+	localCommitStack := git.GetLocalCommitStack(c.config, gitcmd)
+	pullRequests, err := hosting.MatchPullRequestStack(c.config, c.config.Repo.GitHubBranch, localCommitStack, repo.PullRequests)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range pullRequests {
+		if pr.Ready(c.config) {
+			pr.MergeStatus.Stacked = true
+		} else {
+			break
+		}
+	}
+
+	localBranch, err := gitcmd.GetLocalBranchShortName()
+	if err != nil {
+		return nil, fmt.Errorf("getting the local branch name %w", err)
+	}
+
+	info := &hosting.Info{
+		UserName:     repo.Viewer,
+		RepositoryID: repo.Id,
+		LocalBranch:  localBranch,
+		PullRequests: pullRequests,
+	}
+
+	log.Debug().Interface("Info", info).Msg("GetInfo")
+	return info, nil
+}
+
+// GetAssignableUsers returns the users that can be assigned to the repository's pull requests. Gitea requests
+// reviewers by login so the login is used as the ID.
+func (c *client) GetAssignableUsers(ctx context.Context) ([]hosting.RepoAssignee, error) {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea get assignees\n")
+	}
+
+	var assignees []user
+	_, err := c.do(ctx, http.MethodGet, c.repoPath()+"/assignees", nil, &assignees)
+	if err != nil {
+		return nil, fmt.Errorf("get assignees failed %w", checkUnauthorized(err))
+	}
+
+	users := []hosting.RepoAssignee{}
+	for _, assignee := range assignees {
+		users = append(users, hosting.RepoAssignee{
+			ID:    assignee.Login,
+			Login: assignee.Login,
+			Name:  assignee.FullName,
+		})
+	}
+	return users, nil
+}
+
+func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
+	info *hosting.Info, commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
+
+	baseRefName := c.config.Repo.GitHubBranch
+	if prevCommit != nil {
+		baseRefName = git.BranchNameFromCommit(c.config, *prevCommit)
+	}
+	headRefName := git.BranchNameFromCommit(c.config, commit)
+
+	log.Debug().Interface("Commit", commit).
+		Str("FromBranch", headRefName).Str("ToBranch", baseRefName).
+		Msg("CreatePullRequest")
+
+	body, err := c.body(gitcmd, commit, info.PullRequests, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, number, err := c.CreatePullRequest2(ctx, c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName,
+		hosting.NewPullRequest{
+			BaseRefName: baseRefName,
+			HeadRefName: headRefName,
+			Title:       commit.Subject,
+			Body:        body,
+			Draft:       c.config.User.CreateDraftPRs,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("creating pull request for commit %s %w", commit.CommitHash, err)
+	}
+
+	pr := &hosting.PullRequest{
+		Id:         id,
+		DatabaseId: id,
+		Number:     number,
+		FromBranch: headRefName,
+		ToBranch:   baseRefName,
+		Commit:     commit,
+		Title:      commit.Subject,
+		MergeStatus: hosting.PullRequestMergeStatus{
+			ChecksPass: hosting.CheckStatusUnknown,
+		},
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea create %d : %s\n", pr.Number, pr.Title)
+	}
+
+	return pr, nil
+}
+
+// CreatePullRequest2 creates the pull request, the head branch of a pull request from a fork is prefixed by the
+// owner of the fork.
+func (c *client) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
+	title := pull.Title
+	if pull.Draft {
+		title = draftPrefix + title
+	}
+	head := pull.HeadRefName
+	if pull.HeadOwner != "" {
+		head = pull.HeadOwner + ":" + head
+	}
+
+	var pr pullRequest
+	_, err := c.do(ctx, http.MethodPost, repoPath(owner, repoName)+"/pulls", createPullRequest{
+		Head:  head,
+		Base:  pull.BaseRefName,
+		Title: title,
+		Body:  pull.Body,
+	}, &pr)
+	if err != nil {
+		return "", 0, checkUnauthorized(err)
+	}
+	return strconv.Itoa(pr.Id), pr.Number, nil
+}
+
+// body returns the body of the pull request for the commit
+func (c *client) body(gitcmd git.GitInterface, commit git.Commit, stack []*hosting.PullRequest, pr *hosting.PullRequest) (string, error) {
+	body := githubclient.FormatBody(commit, stack, c.config.Repo.ShowPrTitlesInStack)
+	if c.config.Repo.PRTemplatePath == "" {
+		return body, nil
+	}
+
+	templatePath := path.Join(gitcmd.RootDir(), c.config.Repo.PRTemplatePath)
+	template, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PR template %w", err)
+	}
+	body, err = githubclient.InsertBodyIntoPRTemplate(body, string(template), c.config.Repo, pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert body into PR template %w", err)
+	}
+	return body, nil
+}
+
+func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest, pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea update %d : %s\n", pr.Number, pr.Title)
+	}
+
+	baseRefName := c.config.Repo.GitHubBranch
+	if prevCommit != nil {
+		baseRefName = git.BranchNameFromCommit(c.config, *prevCommit)
+	}
+
+	log.Debug().Interface("Commit", commit).
+		Str("FromBranch", pr.FromBranch).Str("ToBranch", baseRefName).
+		Interface("PR", pr).Msg("UpdatePullRequest")
+
+	body, err := c.body(gitcmd, commit, pullRequests, pr)
+	if err != nil {
+		return err
+	}
+	// The title is reset to the subject so keep the pull request a draft
+	title := commit.Subject
+	if c.config.User.CreateDraftPRs || strings.HasPrefix(pr.Title, draftPrefix) {
+		title = draftPrefix + title
+	}
+	if c.config.User.PreserveTitleAndBody {
+		title = pr.Title
+		body = pr.Body
+	}
+
+	err = c.editPullRequest(ctx, c.repoPath(), pr.Number, editPullRequest{
+		Title: &title,
+		Body:  &body,
+		Base:  &baseRefName,
+	})
+	if err != nil {
+		return fmt.Errorf("pull request %d update failed %w", pr.Number, err)
+	}
+	return nil
+}
+
+// AddReviewers requests reviews on the pull request, userIDs are the logins returned by GetAssignableUsers
+func (c *client) AddReviewers(ctx context.Context, pr *hosting.PullRequest, userIDs []string) error {
+	log.Debug().Strs("userIDs", userIDs).Msg("AddReviewers")
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea add reviewers %d : %s - %+v\n", pr.Number, pr.Title, userIDs)
+	}
+
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/requested_reviewers", c.repoPath(), pr.Number),
+		reviewRequests{Reviewers: userIDs}, nil)
+	if err != nil {
+		return fmt.Errorf("add reviewers %v to pull request %d failed %w", userIDs, pr.Number, checkUnauthorized(err))
+	}
+	return nil
+}
+
+func (c *client) CommentPullRequest(ctx context.Context, pr *hosting.PullRequest, body string) error {
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/issues/%d/comments", c.repoPath(), pr.Number),
+		comment{Body: body}, nil)
+	if err != nil {
+		return fmt.Errorf("comment on pull request %d failed %w", pr.Number, checkUnauthorized(err))
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea add comment %d : %s\n", pr.Number, pr.Title)
+	}
+	return nil
+}
+
+// MergePullRequest merges the pull request with the merge method. With MergeQueue set the pull request is merged
+// once its checks succeed.
+func (c *client) MergePullRequest(ctx context.Context,
+	pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
+	log.Debug().
+		Interface("PR", pr).
+		Str("mergeMethod", string(mergeMethod)).
+		Msg("MergePullRequest")
+
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/pulls/%d/merge", c.repoPath(), pr.Number),
+		mergePullRequest{
+			Do:                     mergeStyle(mergeMethod),
+			HeadCommitId:           pr.Commit.CommitHash,
+			MergeWhenChecksSucceed: c.config.Repo.MergeQueue,
+		}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to merge %d %w", pr.Number, checkUnauthorized(err))
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea merge %d : %s\n", pr.Number, pr.Title)
+	}
+	return nil
+}
+
+// mergeStyle maps a merge method to the merge style Gitea calls "Do"
+func mergeStyle(mergeMethod hosting.MergeMethod) string {
+	switch mergeMethod {
+	case hosting.MergeMethodSquash:
+		return "squash"
+	case hosting.MergeMethodRebase:
+		return "rebase"
+	default:
+		return "merge"
+	}
+}
+
+// EditPullRequest2 applies the title, body, base branch, state and draft of the edit to the pull request.
+// The head branch of a Gitea pull request can't be changed so it is ignored.
+func (c *client) EditPullRequest2(ctx context.Context, owner string, repoName string, number int, edit hosting.PullRequestEdit) error {
+	resource := repoPath(owner, repoName)
+	title, err := c.draftTitle(ctx, resource, number, edit)
+	if err != nil {
+		return err
+	}
+	patch := editPullRequest{
+		Title: title,
+		Body:  edit.Body,
+		Base:  edit.BaseRefName,
+	}
+	if edit.State != nil {
+		patch.State = ptrutils.Ptr(string(*edit.State))
+	}
+
+	return c.editPullRequest(ctx, resource, number, patch)
+}
+
+// draftTitle returns the title of the edited pull request, nil if it isn't changed. A pull request is a work in
+// progress while its title has the WIP prefix, so it stays one unless edit.Draft is false.
+func (c *client) draftTitle(ctx context.Context, resource string, number int, edit hosting.PullRequestEdit) (*string, error) {
+	if edit.Title == nil && edit.Draft == nil {
+		return nil, nil
+	}
+
+	var current pullRequest
+	if edit.Title == nil || (edit.Draft == nil && !strings.HasPrefix(*edit.Title, draftPrefix)) {
+		_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/pulls/%d", resource, number), nil, &current)
+		if err != nil {
+			return nil, fmt.Errorf("fetching pull request %d %w", number, checkUnauthorized(err))
+		}
+	}
+	title := current.Title
+	if edit.Title != nil {
+		title = *edit.Title
+	}
+	draft := strings.HasPrefix(title, draftPrefix) || strings.HasPrefix(current.Title, draftPrefix)
+	if edit.Draft != nil {
+		draft = *edit.Draft
+	}
+
+	title = strings.TrimPrefix(title, draftPrefix)
+	if draft {
+		title = draftPrefix + title
+	}
+	return &title, nil
+}
+
+func (c *client) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
+	log.Debug().Interface("PR", pr).Msg("ClosePullRequest")
+	err := c.editPullRequest(ctx, c.repoPath(), pr.Number, editPullRequest{State: ptrutils.Ptr("closed")})
+	if err != nil {
+		return fmt.Errorf("unable to close pull request %d %w", pr.Number, err)
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitea close %d : %s\n", pr.Number, pr.Title)
+	}
+
+	return nil
+}
+
+func (c *client) editPullRequest(ctx context.Context, resource string, number int, edit editPullRequest) error {
+	_, err := c.do(ctx, http.MethodPatch, fmt.Sprintf("%s/pulls/%d", resource, number), edit, nil)
+	return checkUnauthorized(err)
+}
+
+// PullRequestsAndStatus fetches the users open pull requests along with their commits, reviews and commit status.
+func (c *client) PullRequestsAndStatus(ctx context.Context, repoOwner string, repoName string) (*hosting.Repository, error) {
+	resource := repoPath(repoOwner, repoName)
+
+	var viewer user
+	_, err := c.do(ctx, http.MethodGet, "/user", nil, &viewer)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the user %w", checkUnauthorized(err))
+	}
+	c.config.SetViewerLogin(viewer.Login)
+	var repo repository
+	_, err = c.do(ctx, http.MethodGet, resource, nil, &repo)
+	if err != nil {
+		return nil, fmt.Errorf("fetching repository %s/%s %w", repoOwner, repoName, checkUnauthorized(err))
+	}
+	pullRequests, err := getAll[pullRequest](ctx, c, resource+"/pulls?state=open")
+	if err != nil {
+		return nil, fmt.Errorf("fetching pull requests %w", checkUnauthorized(err))
+	}
+	// Gitea can't filter the pull requests by author
+	pullRequests = slices.DeleteFunc(pullRequests, func(pr pullRequest) bool {
+		return pr.User.Login != viewer.Login
+	})
+
+	prs, err := concurrent.SliceMap(pullRequests, func(pr pullRequest) (hosting.OpenPullRequest, error) {
+		return c.pullRequest(ctx, resource, pr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &hosting.Repository{
+		Id:           strconv.Itoa(repo.Id),
+		Viewer:       viewer.Login,
+		PullRequests: prs,
+	}
+	if repo.Parent != nil {
+		result.ParentId = strconv.Itoa(repo.Parent.Id)
+	}
+	return result, nil
+}
+
+// pullRequest fetches the commits, reviews and commit status of the pull request and returns it as a hosting pull
+// request
+func (c *client) pullRequest(ctx context.Context, resource string, pr pullRequest) (hosting.OpenPullRequest, error) {
+	prPath := fmt.Sprintf("%s/pulls/%d", resource, pr.Number)
+
+	commits, err := getAll[commit](ctx, c, prPath+"/commits")
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching commits for pull request %d %w", pr.Number, err)
+	}
+	reviews, err := getAll[review](ctx, c, prPath+"/reviews")
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching reviews for pull request %d %w", pr.Number, err)
+	}
+	var status combinedStatus
+	_, err = c.do(ctx, http.MethodGet, fmt.Sprintf("%s/commits/%s/status", resource, pr.Head.Sha), nil, &status)
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching status for pull request %d %w", pr.Number, err)
+	}
+
+	result := hosting.OpenPullRequest{
+		Id:               strconv.Itoa(pr.Id),
+		DatabaseId:       pr.Id,
+		Number:           pr.Number,
+		Title:            pr.Title,
+		Body:             pr.Body,
+		Author:           pr.User.Login,
+		BaseRefName:      pr.Base.Ref,
+		HeadRefName:      pr.Head.Ref,
+		BaseRepositoryId: strconv.Itoa(pr.Base.RepoId),
+		Mergeable:        hosting.MergeableStateMergeable,
+		ReviewDecision:   reviewDecision(reviews),
+		CheckState:       statusState(status),
+	}
+	if !pr.Mergeable {
+		result.Mergeable = hosting.MergeableStateConflicting
+	}
+
+	// The commits are listed newest first, pull request commits are oldest first
+	for _, commit := range slices.Backward(commits) {
+		result.Commits = append(result.Commits, pullRequestCommit(commit, result.CheckState))
+	}
+	return result, nil
+}
+
+func pullRequestCommit(commit commit, state hosting.CheckState) hosting.Commit {
+	headline, body, _ := strings.Cut(commit.Commit.Message, "\n")
+	return hosting.Commit{
+		Oid:             commit.Sha,
+		MessageHeadline: headline,
+		MessageBody:     strings.TrimSpace(body),
+		CheckState:      state,
+	}
+}
+
+// reviewDecision returns approved if the latest review of at least one reviewer approves the pull request and no
+// reviewer's latest review requests changes. Comments and dismissed reviews don't count.
+func reviewDecision(reviews []review) hosting.ReviewDecision {
+	latest := map[string]string{}
+	for _, review := range reviews {
+		if review.Dismissed || (review.State != "APPROVED" && review.State != "REQUEST_CHANGES") {
+			continue
+		}
+		latest[review.User.Login] = review.State
+	}
+
+	approved := false
+	for _, state := range latest {
+		if state == "REQUEST_CHANGES" {
+			return hosting.ReviewDecisionChangesRequested
+		}
+		approved = true
+	}
+	if approved {
+		return hosting.ReviewDecisionApproved
+	}
+	return hosting.ReviewDecisionNone
+}
+
+// statusState maps a combined commit status to a check state, a commit without any statuses has no checks
+func statusState(status combinedStatus) hosting.CheckState {
+	if status.TotalCount == 0 {
+		return hosting.CheckStateNone
+	}
+	switch status.State {
+	case "success", "warning":
+		return hosting.CheckStateSuccess
+	case "failure", "error":
+		return hosting.CheckStateFailure
+	default:
+		return hosting.CheckStatePending
+	}
+}
+
+// repoPath returns the path of the configured repository's resources
+func (c *client) repoPath() string {
+	return repoPath(c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName)
+}
+
+// repoPath returns the path of the repository's resources
+func repoPath(owner string, name string) string {
+	return "/repos/" + url.PathEscape(owner) + "/" + url.PathEscape(name)
+}
+
+// checkUnauthorized adds a hint on how to set up a valid token to 401 Unauthorized errors
+func checkUnauthorized(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.statusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w\n"+
+			" make sure GITEA_TOKEN env variable is set with a valid token\n"+
+			" to create a valid token goto: https://<gitea host>/user/settings/applications", err)
+	}
+	return err
+}
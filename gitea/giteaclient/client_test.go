@@ -0,0 +1,60 @@
+package giteaclient
+
+import (
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiEndpoint(t *testing.T) {
+	require.Equal(t, "https://codeberg.org/api/v1", apiEndpoint(&config.RepoConfig{GitHubHost: "codeberg.org"}))
+	require.Equal(t, "http://localhost:3000/api/v1", apiEndpoint(&config.RepoConfig{
+		GitHubHost:   "git.example.com",
+		GitHubApiUrl: "http://localhost:3000/api/v1/",
+	}))
+}
+
+func TestStatusState(t *testing.T) {
+	require.Equal(t, hosting.CheckStateNone, statusState(combinedStatus{State: "pending"}))
+	require.Equal(t, hosting.CheckStateSuccess, statusState(combinedStatus{State: "success", TotalCount: 1}))
+	require.Equal(t, hosting.CheckStateSuccess, statusState(combinedStatus{State: "warning", TotalCount: 1}))
+	require.Equal(t, hosting.CheckStateFailure, statusState(combinedStatus{State: "failure", TotalCount: 2}))
+	require.Equal(t, hosting.CheckStateFailure, statusState(combinedStatus{State: "error", TotalCount: 1}))
+	require.Equal(t, hosting.CheckStatePending, statusState(combinedStatus{State: "pending", TotalCount: 1}))
+}
+
+func TestReviewDecision(t *testing.T) {
+	r := func(login string, state string) review {
+		return review{User: user{Login: login}, State: state}
+	}
+
+	require.Equal(t, hosting.ReviewDecisionNone, reviewDecision(nil))
+	require.Equal(t, hosting.ReviewDecisionNone, reviewDecision([]review{r("alice", "COMMENT")}))
+	require.Equal(t, hosting.ReviewDecisionApproved, reviewDecision([]review{
+		r("alice", "APPROVED"),
+		r("bob", "COMMENT"),
+	}))
+	require.Equal(t, hosting.ReviewDecisionChangesRequested, reviewDecision([]review{
+		r("alice", "APPROVED"),
+		r("bob", "REQUEST_CHANGES"),
+	}))
+	// only the latest review of each reviewer counts
+	require.Equal(t, hosting.ReviewDecisionApproved, reviewDecision([]review{
+		r("bob", "REQUEST_CHANGES"),
+		r("bob", "APPROVED"),
+	}))
+	dismissed := r("bob", "REQUEST_CHANGES")
+	dismissed.Dismissed = true
+	require.Equal(t, hosting.ReviewDecisionApproved, reviewDecision([]review{
+		r("alice", "APPROVED"),
+		dismissed,
+	}))
+}
+
+func TestHasNextPage(t *testing.T) {
+	require.False(t, hasNextPage(""))
+	require.True(t, hasNextPage(`<https://gitea.com/api/v1/repos/o/r/pulls?page=2>; rel="next",<https://gitea.com/api/v1/repos/o/r/pulls?page=3>; rel="last"`))
+	require.False(t, hasNextPage(`<https://gitea.com/api/v1/repos/o/r/pulls?page=1>; rel="first",<https://gitea.com/api/v1/repos/o/r/pulls?page=2>; rel="prev"`))
+}
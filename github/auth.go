@@ -50,7 +50,7 @@ func FindToken(githubHost string) string {
 		return ""
 	}
 
-	if c, ok := hubCfg["github.com"]; ok {
+	if c, ok := hubCfg[githubHost]; ok {
 		if len(c) == 0 {
 			log.Warn().Msg("no token found in hub config file")
 			return ""
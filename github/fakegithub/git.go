@@ -0,0 +1,35 @@
+package fakegithub
+
+import (
+	"cmp"
+	"fmt"
+
+	"github.com/ejoffe/spr/git/fakeremote"
+)
+
+// pullRequestCommits returns the commits on the head branch that aren't on the base branch, oldest first.
+// A pull request whose branches no longer exist has no commits.
+func (s *Server) pullRequestCommits(pr *PullRequest) ([]fakeremote.Commit, error) {
+	return s.remote.Commits(pr.BaseRefName, s.headRef(pr))
+}
+
+// merge lands the pull request on its base branch using the given merge method and returns the new base oid.
+func (s *Server) merge(pr *PullRequest, mergeMethod string) (string, error) {
+	message := fmt.Sprintf("Merge pull request #%d from %s/%s", pr.Number, cmp.Or(pr.HeadRepositoryOwner, Owner), pr.HeadRefName)
+	if mergeMethod == fakeremote.MethodSquash {
+		message = fmt.Sprintf("%s (#%d)", pr.Title, pr.Number)
+	}
+	return s.remote.Merge(pr.BaseRefName, s.headRef(pr), mergeMethod, message)
+}
+
+// headRef returns the ref of the pull request's head in the repository. Like GitHub, the head branch of a pull request
+// from a fork is fetched into refs/pull/<number>/head, which keeps the last head if the branch is deleted.
+func (s *Server) headRef(pr *PullRequest) string {
+	fork, ok := s.forks[pr.HeadRepositoryOwner]
+	if !ok {
+		return pr.HeadRefName
+	}
+	ref := fmt.Sprintf("refs/pull/%d/head", pr.Number)
+	s.remote.Git(s.RemotePath, "fetch", fork.Path, "+refs/heads/"+pr.HeadRefName+":"+ref)
+	return ref
+}
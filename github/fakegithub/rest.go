@@ -0,0 +1,87 @@
+package fakegithub
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// serveEditPullRequest implements PATCH /repos/{owner}/{repo}/pulls/{number}
+func (s *Server) serveEditPullRequest(w http.ResponseWriter, r *http.Request) {
+	number, err := strconv.Atoi(r.PathValue("number"))
+	if err != nil || s.checkRepository(r.PathValue("owner"), r.PathValue("repo")) != nil {
+		writeJSON(w, http.StatusNotFound, object{"message": "Not Found"})
+		return
+	}
+
+	var edit struct {
+		Title *string `json:"title"`
+		Body  *string `json:"body"`
+		State *string `json:"state"`
+		Base  *string `json:"base"`
+	}
+	err = json.NewDecoder(r.Body).Decode(&edit)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"message": "Problems parsing JSON"})
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.pullRequestByNumber(number)
+	if pr == nil {
+		writeJSON(w, http.StatusNotFound, object{"message": "Not Found"})
+		return
+	}
+	if s.failEdits[number] {
+		delete(s.failEdits, number)
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": "Validation Failed"})
+		return
+	}
+
+	if edit.Base != nil && *edit.Base != pr.BaseRefName {
+		base, ok := s.remote.Resolve(*edit.Base)
+		if !ok {
+			writeJSON(w, http.StatusUnprocessableEntity, object{"message": "Validation Failed", "errors": []object{
+				{"resource": "PullRequest", "field": "base", "code": "invalid"},
+			}})
+			return
+		}
+		head, ok := s.remote.Resolve(s.headRef(pr))
+		if ok && s.remote.IsAncestor(head, base) {
+			writeJSON(w, http.StatusUnprocessableEntity, object{"message": "Validation Failed", "errors": []object{
+				{"resource": "PullRequest", "code": "custom", "message": "There are no new commits between base branch '" + *edit.Base + "' and head branch '" + pr.HeadRefName + "'"},
+			}})
+			return
+		}
+		pr.BaseRefName = *edit.Base
+	}
+	if edit.Title != nil {
+		pr.Title = *edit.Title
+	}
+	if edit.Body != nil {
+		pr.Body = *edit.Body
+	}
+	if edit.State != nil {
+		switch {
+		case strings.EqualFold(*edit.State, "closed") && pr.State == StateOpen:
+			pr.State = StateClosed
+		case strings.EqualFold(*edit.State, "open") && pr.State == StateClosed:
+			pr.State = StateOpen
+		}
+	}
+
+	writeJSON(w, http.StatusOK, object{
+		"id":      pr.DatabaseId,
+		"node_id": pr.Id,
+		"number":  pr.Number,
+		"title":   pr.Title,
+		"body":    pr.Body,
+		"state":   strings.ToLower(pr.State),
+		"merged":  pr.State == StateMerged,
+		"head":    object{"ref": pr.HeadRefName},
+		"base":    object{"ref": pr.BaseRefName},
+	})
+}
@@ -0,0 +1,229 @@
+// Package fakegithub is an in-process stand-in for the parts of the GitHub GraphQL and REST APIs that spr uses.
+// It is backed by a local bare git repository that acts as the GitHub remote, so the full update and merge flows can be
+// exercised in `go test` without network access.
+package fakegithub
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git/fakeremote"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Owner is the owner of the fake repository
+	Owner = "spr-owner"
+	// Name is the name of the fake repository
+	Name = "spr-repo"
+	// Login is the login of the authenticated user
+	Login = "spr-user"
+	// Branch is the default branch of the fake repository
+	Branch = "main"
+)
+
+// Pull request states
+const (
+	StateOpen   = "OPEN"
+	StateClosed = "CLOSED"
+	StateMerged = "MERGED"
+)
+
+// PullRequest is the server side state of a pull request.
+type PullRequest struct {
+	Id          string
+	DatabaseId  int
+	Number      int
+	Title       string
+	Body        string
+	BaseRefName string
+	HeadRefName string
+	Author      string
+	State       string
+
+	// HeadRepositoryOwner is the owner of the fork the head branch is in, it is empty for a branch of the repository.
+	HeadRepositoryOwner string
+
+	// Mergeable, ReviewDecision and CheckState hold the GraphQL enum values reported for the pull request.
+	// New pull requests are mergeable, approved and passing so they can be merged straight away.
+	Mergeable      string
+	ReviewDecision string
+	CheckState     string
+
+	Reviewers []string
+	Comments  []string
+}
+
+// User is an assignable user of the fake repository
+type User struct {
+	Id    string
+	Login string
+	Name  string
+}
+
+// Server is a fake GitHub server for a single repository.
+type Server struct {
+	// RemotePath is the bare git repository that acts as the GitHub remote.
+	RemotePath string
+	// PageSize is the maximum number of nodes returned in a page of any connection.
+	PageSize int
+	// Users are the assignable users of the repository.
+	Users []User
+
+	repositoryId string
+	remote       *fakeremote.Remote
+	server       *httptest.Server
+
+	lock         sync.Mutex
+	pullRequests []*PullRequest
+	// forks are the forks of the repository by owner
+	forks map[string]*fakeremote.Remote
+	// failEdits are the numbers of the pull requests whose next edit fails
+	failEdits map[int]bool
+}
+
+// New starts a fake GitHub server along with its bare git remote. Both are cleaned up when the test completes.
+func New(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		RemotePath:   filepath.Join(t.TempDir(), Name+".git"),
+		PageSize:     100,
+		Users:        []User{{Id: "U_1", Login: Login, Name: "Spr User"}},
+		repositoryId: fmt.Sprintf("R_%s_%s", Owner, Name),
+		forks:        map[string]*fakeremote.Remote{},
+	}
+	var err error
+	s.remote, err = fakeremote.New(s.RemotePath, Branch, "GitHub", "noreply@github.com")
+	require.NoError(t, err)
+
+	s.server = httptest.NewServer(s.handler())
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// URL returns the base url of the server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Configure points the repo config at the fake server and its repository.
+// GitHubHost is left alone so pull request links look like they normally do.
+func (s *Server) Configure(cfg *config.Config) {
+	cfg.Repo.GitHubRepoOwner = Owner
+	cfg.Repo.GitHubRepoName = Name
+	cfg.Repo.GitHubApiUrl = s.server.URL + "/api/v3/"
+	cfg.Repo.GitHubGraphQLUrl = s.server.URL + "/api/graphql"
+}
+
+// Fork creates a fork of the repository owned by owner and returns the path of its bare remote.
+// Pull requests can be opened from the branches of the fork.
+func (s *Server) Fork(t *testing.T, owner string) string {
+	t.Helper()
+
+	fork, err := s.remote.Fork(filepath.Join(t.TempDir(), owner, Name+".git"))
+	require.NoError(t, err)
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.forks[owner] = fork
+	return fork.Path
+}
+
+// PullRequests returns a copy of every pull request (in any state) ordered by number.
+func (s *Server) PullRequests() []PullRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	prs := []PullRequest{}
+	for _, pr := range s.pullRequests {
+		cp := *pr
+		cp.Reviewers = slices.Clone(pr.Reviewers)
+		cp.Comments = slices.Clone(pr.Comments)
+		prs = append(prs, cp)
+	}
+	return prs
+}
+
+// FailNextEdit makes the next edit of the pull request fail with a validation error
+func (s *Server) FailNextEdit(number int) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if s.failEdits == nil {
+		s.failEdits = map[int]bool{}
+	}
+	s.failEdits[number] = true
+}
+
+// ModifyPullRequest calls fn with the pull request so tests can change things like its review or check state.
+func (s *Server) ModifyPullRequest(number int, fn func(pr *PullRequest)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	pr := s.pullRequestByNumber(number)
+	if pr == nil {
+		return fmt.Errorf("no pull request #%d", number)
+	}
+	fn(pr)
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/graphql", s.serveGraphQL)
+	mux.HandleFunc("PATCH /api/v3/repos/{owner}/{repo}/pulls/{number}", s.serveEditPullRequest)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") == "" {
+			writeJSON(w, http.StatusUnauthorized, object{"message": "Bad credentials"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) pullRequestByNumber(number int) *PullRequest {
+	for _, pr := range s.pullRequests {
+		if pr.Number == number {
+			return pr
+		}
+	}
+	return nil
+}
+
+// pullRequestById looks up a pull request by either its node id or its database id.
+func (s *Server) pullRequestById(id string) *PullRequest {
+	for _, pr := range s.pullRequests {
+		if pr.Id == id || fmt.Sprint(pr.DatabaseId) == id {
+			return pr
+		}
+	}
+	return nil
+}
+
+func (s *Server) openPullRequests() []*PullRequest {
+	prs := []*PullRequest{}
+	for _, pr := range s.pullRequests {
+		if pr.State == StateOpen {
+			prs = append(prs, pr)
+		}
+	}
+	return prs
+}
+
+type object = map[string]any
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
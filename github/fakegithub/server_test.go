@@ -0,0 +1,101 @@
+package fakegithub
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+// pushCommit adds a commit on top of parent to the remote as the given branch
+func pushCommit(t *testing.T, s *Server, parent string, branch string, message string) {
+	t.Helper()
+
+	base, ok := s.remote.Resolve(parent)
+	require.True(t, ok)
+	tree, err := s.remote.Git(s.RemotePath, "mktree")
+	require.NoError(t, err)
+	oid, err := s.remote.Git(s.RemotePath, "commit-tree", tree, "-p", base, "-m", message)
+	require.NoError(t, err)
+	_, err = s.remote.Git(s.RemotePath, "update-ref", "refs/heads/"+branch, oid)
+	require.NoError(t, err)
+}
+
+func TestPullRequestsArePaged(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	s.PageSize = 1
+	t.Setenv("GITHUB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := githubclient.NewGitHubClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "first", "first commit")
+	pushCommit(t, s, "first", "second", "second commit")
+	pushCommit(t, s, "second", "third", "third commit")
+
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		RepositoryId: s.repositoryId,
+		BaseRefName:  Branch,
+		HeadRefName:  "second",
+		Title:        "first and second",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, number)
+	_, number, err = client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		RepositoryId: s.repositoryId,
+		BaseRefName:  "second",
+		HeadRefName:  "third",
+		Title:        "third",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, number)
+
+	repo, err := client.PullRequestsAndStatus(ctx, Owner, Name)
+	require.NoError(t, err)
+	require.Equal(t, Login, repo.Viewer)
+	prs := repo.PullRequests
+	require.Len(t, prs, 2)
+	require.Equal(t, "second", prs[0].HeadRefName)
+	require.Len(t, prs[0].Commits, 2)
+	require.Equal(t, "first commit", prs[0].Commits[0].MessageHeadline)
+	require.Equal(t, "second commit", prs[0].Commits[1].MessageHeadline)
+	require.Equal(t, "third", prs[1].HeadRefName)
+	require.Len(t, prs[1].Commits, 1)
+
+	// Only the viewer's pull requests are kept
+	err = s.ModifyPullRequest(1, func(pr *PullRequest) { pr.Author = "someone-else" })
+	require.NoError(t, err)
+	repo, err = client.PullRequestsAndStatus(ctx, Owner, Name)
+	require.NoError(t, err)
+	require.Len(t, repo.PullRequests, 1)
+	require.Equal(t, 2, repo.PullRequests[0].Number)
+}
+
+func TestCreatePullRequestRequiresCommits(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITHUB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := githubclient.NewGitHubClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "feature", "feature commit")
+	_, err = s.remote.Git(s.RemotePath, "update-ref", "refs/heads/empty", "refs/heads/"+Branch)
+	require.NoError(t, err)
+
+	_, _, err = client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		RepositoryId: s.repositoryId,
+		BaseRefName:  "feature",
+		HeadRefName:  "empty",
+	})
+	require.ErrorContains(t, err, "No commits between feature and empty")
+	require.Empty(t, s.PullRequests())
+}
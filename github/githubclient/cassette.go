@@ -0,0 +1,268 @@
+package githubclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// A Cassette is a recording of the requests made to the GitHub APIs along with their responses.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a single request/response exchange
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method  string      `json:"method"`
+	URL     string      `json:"url"`
+	Headers http.Header `json:"headers,omitempty"`
+	Body    string      `json:"body,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Headers    http.Header `json:"headers,omitempty"`
+	Body       string      `json:"body,omitempty"`
+}
+
+const redacted = "REDACTED"
+
+// sensitiveHeaders are dropped from recorded requests and responses
+var sensitiveHeaders = []string{"Authorization", "Cookie", "Set-Cookie", "Proxy-Authorization"}
+
+// authorEmailRegex matches the commit author email sent when merging
+var authorEmailRegex = regexp.MustCompile(`"authorEmail":"[^"]*"`)
+
+// LoadCassette reads a cassette written by a recording transport
+func LoadCassette(path string) (*Cassette, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading cassette %s %w", path, err)
+	}
+
+	cassette := &Cassette{}
+	err = json.Unmarshal(data, cassette)
+	if err != nil {
+		return nil, fmt.Errorf("parsing cassette %s %w", path, err)
+	}
+	return cassette, nil
+}
+
+// Save writes the cassette to path
+func (c *Cassette) Save(path string) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("encoding cassette %w", err)
+	}
+
+	err = os.WriteFile(path, data, 0600)
+	if err != nil {
+		return fmt.Errorf("writing cassette %s %w", path, err)
+	}
+	return nil
+}
+
+type recordingTransport struct {
+	wrapped  http.RoundTripper
+	path     string
+	secrets  []string
+	lock     sync.Mutex
+	cassette Cassette
+}
+
+// NewRecordingTransport returns a transport that records every exchange made through the wrapped transport.
+// The cassette is rewritten after every exchange so it is complete even if spr exits part way through.
+// Credentials, the author email and any of the given secrets are redacted from the cassette.
+func NewRecordingTransport(wrapped http.RoundTripper, path string, secrets ...string) http.RoundTripper {
+	return &recordingTransport{
+		wrapped: wrapped,
+		path:    path,
+		secrets: secrets,
+	}
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := t.wrapped.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	respBody, err := readBody(&resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method:  req.Method,
+			URL:     t.redact(req.URL.String()),
+			Headers: t.redactHeaders(req.Header),
+			Body:    t.redact(reqBody),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Headers:    t.redactHeaders(resp.Header),
+			Body:       t.redact(respBody),
+		},
+	}
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.cassette.Interactions = append(t.cassette.Interactions, interaction)
+	err = t.cassette.Save(t.path)
+	if err != nil {
+		return nil, err
+	}
+
+	return resp, nil
+}
+
+func (t *recordingTransport) redact(s string) string {
+	for _, secret := range t.secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, redacted)
+		}
+	}
+	return redactAuthorEmail(s)
+}
+
+func (t *recordingTransport) redactHeaders(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range sensitiveHeaders {
+		header.Del(name)
+	}
+	for _, values := range header {
+		for i := range values {
+			values[i] = t.redact(values[i])
+		}
+	}
+	return header
+}
+
+func redactAuthorEmail(s string) string {
+	return authorEmailRegex.ReplaceAllString(s, `"authorEmail":"`+redacted+`"`)
+}
+
+// readBody reads the body and replaces it with an unread copy
+func readBody(body *io.ReadCloser) (string, error) {
+	if *body == nil || *body == http.NoBody {
+		return "", nil
+	}
+
+	data, err := io.ReadAll(*body)
+	(*body).Close()
+	if err != nil {
+		return "", fmt.Errorf("reading body %w", err)
+	}
+	*body = io.NopCloser(bytes.NewReader(data))
+	return string(data), nil
+}
+
+type replayTransport struct {
+	lock     sync.Mutex
+	cassette *Cassette
+	used     []bool
+}
+
+// NewReplayTransport returns a transport that answers requests from the cassette instead of the network.
+// A request is matched to the first unused interaction with the same method, path, query and (redacted) body, so
+// concurrent requests can be replayed in any order. Unmatched requests fail.
+func NewReplayTransport(cassette *Cassette) *replayTransport {
+	return &replayTransport{
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}
+}
+
+func (t *replayTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	reqBody, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+	reqBody = redactAuthorEmail(reqBody)
+
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	for i, interaction := range t.cassette.Interactions {
+		if t.used[i] || !requestMatches(interaction.Request, req, reqBody) {
+			continue
+		}
+		t.used[i] = true
+
+		return &http.Response{
+			Status:        fmt.Sprintf("%d %s", interaction.Response.StatusCode, http.StatusText(interaction.Response.StatusCode)),
+			StatusCode:    interaction.Response.StatusCode,
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Header:        interaction.Response.Headers.Clone(),
+			Body:          io.NopCloser(strings.NewReader(interaction.Response.Body)),
+			ContentLength: int64(len(interaction.Response.Body)),
+			Request:       req,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("no recorded interaction for %s %s %s", req.Method, req.URL, reqBody)
+}
+
+func requestMatches(recorded RecordedRequest, req *http.Request, reqBody string) bool {
+	if recorded.Method != req.Method || bodyKey(recorded.Body) != bodyKey(reqBody) {
+		return false
+	}
+
+	recordedURL, err := url.Parse(recorded.URL)
+	if err != nil {
+		return false
+	}
+	return recordedURL.Path == req.URL.Path && recordedURL.RawQuery == req.URL.RawQuery
+}
+
+// bodyKey identifies GraphQL requests by their operation and variables so cassettes keep working when the query text
+// changes. Other bodies are compared as is.
+func bodyKey(body string) string {
+	var graphQLRequest struct {
+		OperationName string          `json:"operationName"`
+		Variables     json.RawMessage `json:"variables"`
+	}
+	err := json.Unmarshal([]byte(body), &graphQLRequest)
+	if err != nil || graphQLRequest.OperationName == "" {
+		return body
+	}
+
+	var variables any
+	json.Unmarshal(graphQLRequest.Variables, &variables)
+	canonical, _ := json.Marshal(variables)
+	return graphQLRequest.OperationName + string(canonical)
+}
+
+// Unused returns the number of recorded interactions that haven't been replayed
+func (t *replayTransport) Unused() int {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	count := 0
+	for _, used := range t.used {
+		if !used {
+			count++
+		}
+	}
+	return count
+}
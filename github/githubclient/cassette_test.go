@@ -0,0 +1,126 @@
+package githubclient
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordingTransportRedactsCredentials(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "bearer secret-token", r.Header.Get("Authorization"))
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(body), "me@example.com")
+
+		w.Header().Set("Set-Cookie", "session=secret")
+		w.Write([]byte(`{"data":{"mergePullRequest":{"pullRequest":{"number":1}}}}`))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "cassette.json")
+	httpClient := &http.Client{
+		Transport: &authedTransport{
+			key:     "secret-token",
+			wrapped: NewRecordingTransport(http.DefaultTransport, path, "secret-token"),
+		},
+	}
+
+	resp, err := httpClient.Post(server.URL+"/graphql", "application/json",
+		strings.NewReader(`{"operationName":"MergePullRequest","variables":{"input":{"authorEmail":"me@example.com"}}}`))
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"data":{"mergePullRequest":{"pullRequest":{"number":1}}}}`, string(body))
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+	require.NotContains(t, string(data), "me@example.com")
+
+	cassette, err := LoadCassette(path)
+	require.NoError(t, err)
+	require.Len(t, cassette.Interactions, 1)
+	interaction := cassette.Interactions[0]
+	require.Equal(t, http.MethodPost, interaction.Request.Method)
+	require.Equal(t, server.URL+"/graphql", interaction.Request.URL)
+	require.Empty(t, interaction.Request.Headers.Get("Authorization"))
+	require.Contains(t, interaction.Request.Body, `"authorEmail":"REDACTED"`)
+	require.Equal(t, http.StatusOK, interaction.Response.StatusCode)
+	require.Empty(t, interaction.Response.Headers.Get("Set-Cookie"))
+	require.Equal(t, string(body), interaction.Response.Body)
+
+	// The redacted recording can be replayed
+	replay := NewReplayTransport(cassette)
+	resp, err = (&http.Client{Transport: replay}).Post("https://github.example.com/graphql", "application/json",
+		strings.NewReader(`{"operationName":"MergePullRequest","variables":{"input":{"authorEmail":"someone@example.com"}}}`))
+	require.NoError(t, err)
+	replayed, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	require.Equal(t, body, replayed)
+	require.Equal(t, 0, replay.Unused())
+}
+
+func TestReplayTransportRejectsUnrecordedRequests(t *testing.T) {
+	replay := NewReplayTransport(&Cassette{
+		Interactions: []Interaction{{
+			Request:  RecordedRequest{Method: http.MethodPost, URL: "https://api.github.com/graphql", Body: `{"operationName":"StarCheck","variables":{"after":""}}`},
+			Response: RecordedResponse{StatusCode: http.StatusOK, Body: `{"data":{}}`},
+		}},
+	})
+	httpClient := &http.Client{Transport: replay}
+
+	_, err := httpClient.Post("https://api.github.com/graphql", "application/json",
+		strings.NewReader(`{"operationName":"StarCheck","variables":{"after":"1"}}`))
+	require.ErrorContains(t, err, "no recorded interaction")
+
+	// Query text and variable order don't matter, only the operation and variable values
+	_, err = httpClient.Post("https://api.github.com/graphql", "application/json",
+		strings.NewReader(`{"query":"query StarCheck {}","variables":{"after":""},"operationName":"StarCheck"}`))
+	require.NoError(t, err)
+
+	// Each interaction is only replayed once
+	_, err = httpClient.Post("https://api.github.com/graphql", "application/json",
+		strings.NewReader(`{"operationName":"StarCheck","variables":{"after":""}}`))
+	require.ErrorContains(t, err, "no recorded interaction")
+}
+
+func TestReplayPagedPullRequests(t *testing.T) {
+	ctx := context.Background()
+	cassette, err := LoadCassette("testdata/pull_requests_paged.json")
+	require.NoError(t, err)
+	replay := NewReplayTransport(cassette)
+
+	cfg := config.EmptyConfig()
+	cfg.Repo.GitHubHost = "github.example.com"
+	c, err := newClient(cfg, nil, replay)
+	require.NoError(t, err)
+
+	repo, err := c.PullRequestsAndStatus(ctx, "spr-owner", "spr-repo")
+	require.NoError(t, err)
+	prs := repo.PullRequests
+	require.Len(t, prs, 2)
+	require.Equal(t, 1, prs[0].Number)
+	require.Len(t, prs[0].Commits, 2)
+	require.Equal(t, "first commit", prs[0].Commits[0].MessageHeadline)
+	require.Equal(t, "second commit", prs[0].Commits[1].MessageHeadline)
+	require.Equal(t, 2, prs[1].Number)
+	require.Len(t, prs[1].Commits, 1)
+
+	err = c.EditPullRequest2(ctx, "spr-owner", "spr-repo", 2, hosting.PullRequestEdit{
+		Title:       ptrutils.Ptr("new title"),
+		BaseRefName: ptrutils.Ptr("main"),
+	})
+	require.NoError(t, err)
+	require.Equal(t, 0, replay.Unused())
+}
@@ -11,10 +11,12 @@ import (
 	"strings"
 
 	"github.com/Khan/genqlient/graphql"
+	"github.com/ejoffe/spr/bl/ptrutils"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
 	"github.com/ejoffe/spr/github"
 	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 	gogithub "github.com/google/go-github/v69/github"
 	"github.com/rs/zerolog/log"
 )
@@ -49,31 +51,81 @@ This configuration file is shared with GitHub's "hub" CLI (https://hub.github.co
 so if you already use that, spr will automatically pick up your token.
 `
 
-func NewGitHubClient(ctx context.Context, git git.GitInterface, config *config.Config) *client {
+func NewGitHubClient(ctx context.Context, git git.GitInterface, config *config.Config) (*client, error) {
 	token := github.FindToken(config.Repo.GitHubHost)
 	if token == "" {
-		fmt.Printf(tokenHelpText, config.Repo.GitHubHost)
-		os.Exit(3)
+		return nil, fmt.Errorf(tokenHelpText, config.Repo.GitHubHost)
 	}
 
-	var gclient graphql.Client
-	if strings.HasSuffix(config.Repo.GitHubHost, "github.com") {
+	transport := &authedTransport{
+		key:     token,
+		wrapped: NewRetryTransport(http.DefaultTransport, config.User),
+	}
+	c, err := newClient(config, git, transport)
+	if err != nil {
+		return nil, err
+	}
+	c.transport = transport
+
+	return c, nil
+}
 
-		httpClient := http.Client{
-			Transport: &authedTransport{
-				key:     token,
-				wrapped: http.DefaultTransport,
-			},
+// newClient creates a client which sends all GraphQL and REST requests through the given transport.
+func newClient(config *config.Config, git git.GitInterface, transport http.RoundTripper) (*client, error) {
+	httpClient := &http.Client{
+		Transport: transport,
+	}
+	gclient := graphql.NewClient(graphQLEndpoint(config.Repo), httpClient)
+
+	goghclient := gogithub.NewClient(httpClient)
+	if apiUrl := restEndpoint(config.Repo); apiUrl != "" {
+		var err error
+		goghclient, err = goghclient.WithEnterpriseURLs(apiUrl, apiUrl)
+		if err != nil {
+			return nil, fmt.Errorf("invalid github api url %q: %w", apiUrl, err)
 		}
-		gclient = graphql.NewClient("https://api.github.com/graphql", &httpClient)
 	}
-	goghclient := gogithub.NewClient(nil).WithAuthToken(github.FindToken(config.Repo.GitHubHost))
+
 	return &client{
 		config:     config,
 		goghclient: goghclient,
 		gclient:    gclient,
 		git:        git,
+	}, nil
+}
+
+// Record captures all of the GitHub API traffic to a cassette file at path.
+// The token is redacted from the cassette so it can be attached to bug reports.
+func (c *client) Record(path string) {
+	c.transport.wrapped = NewRecordingTransport(c.transport.wrapped, path, c.transport.key)
+}
+
+// isGitHubDotCom returns true if the host is the public github.com (as opposed to a GitHub Enterprise Server)
+func isGitHubDotCom(host string) bool {
+	return host == "github.com" || strings.HasSuffix(host, ".github.com")
+}
+
+// graphQLEndpoint returns the url of the GraphQL api for the configured host.
+func graphQLEndpoint(repoConfig *config.RepoConfig) string {
+	if repoConfig.GitHubGraphQLUrl != "" {
+		return repoConfig.GitHubGraphQLUrl
+	}
+	if isGitHubDotCom(repoConfig.GitHubHost) {
+		return "https://api.github.com/graphql"
+	}
+	return fmt.Sprintf("https://%s/api/graphql", repoConfig.GitHubHost)
+}
+
+// restEndpoint returns the base url of the REST api for the configured host.
+// An empty string is returned for github.com as the go-github client already defaults to it.
+func restEndpoint(repoConfig *config.RepoConfig) string {
+	if repoConfig.GitHubApiUrl != "" {
+		return repoConfig.GitHubApiUrl
+	}
+	if isGitHubDotCom(repoConfig.GitHubHost) {
+		return ""
 	}
+	return fmt.Sprintf("https://%s/api/v3/", repoConfig.GitHubHost)
 }
 
 type client struct {
@@ -81,30 +133,27 @@ type client struct {
 	goghclient *gogithub.Client
 	gclient    graphql.Client
 	git        git.GitInterface
+	transport  *authedTransport
 }
 
-func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) *github.GitHubInfo {
+func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
 	if c.config.User.LogGitHubCalls {
 		fmt.Printf("> github fetch pull requests\n")
 	}
 
-	var pullRequestConnection genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection
-	var loginName string
-	var repoID string
-	resp, err := genqlient.PullRequestsWithMergeQueue(
-		ctx, c.gclient,
-		c.config.Repo.GitHubRepoOwner,
-		c.config.Repo.GitHubRepoName,
-	)
-	check(err)
-	pullRequestConnection = resp.Viewer.PullRequests
-	loginName = resp.Viewer.Login
-	repoID = resp.Repository.Id
-
-	targetBranch := c.config.Repo.GitHubBranch
-	localCommitStack := git.GetLocalCommitStack(c.config, gitcmd)
+	resp, err := c.pullRequestsWithMergeQueue(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("fetching pull requests %w", checkUnauthorized(err))
+	}
+	loginName := resp.login
+	c.config.SetViewerLogin(loginName)
+	repoID := resp.repositoryId
 
-	pullRequests := matchPullRequestStack(c.config.Repo, targetBranch, localCommitStack, pullRequestConnection)
+	localCommitStack := git.GetLocalCommitStack(c.config, gitcmd)
+	pullRequests, err := resp.stack(c.config, c.config.Repo.GitHubBranch, localCommitStack)
+	if err != nil {
+		return nil, err
+	}
 	for _, pr := range pullRequests {
 		if pr.Ready(c.config) {
 			pr.MergeStatus.Stacked = true
@@ -115,10 +164,10 @@ func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) *github.G
 
 	localBranch, err := gitcmd.GetLocalBranchShortName()
 	if err != nil {
-		panic(err.Error())
+		return nil, fmt.Errorf("getting the local branch name %w", err)
 	}
 
-	info := &github.GitHubInfo{
+	info := &hosting.Info{
 		UserName:     loginName,
 		RepositoryID: repoID,
 		LocalBranch:  localBranch,
@@ -126,129 +175,17 @@ func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) *github.G
 	}
 
 	log.Debug().Interface("Info", info).Msg("GetInfo")
-	return info
-}
-
-func matchPullRequestStack(
-	repoConfig *config.RepoConfig,
-	targetBranch string,
-	localCommitStack []git.Commit,
-	allPullRequests genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection) []*github.PullRequest {
-
-	if len(localCommitStack) == 0 || allPullRequests.Nodes == nil {
-		return []*github.PullRequest{}
-	}
-
-	// pullRequestMap is a map from commit-id to pull request
-	pullRequestMap := make(map[string]*github.PullRequest)
-	for _, node := range allPullRequests.Nodes {
-		var commits []git.Commit
-		for _, v := range node.Commits.Nodes {
-			for _, line := range strings.Split(v.Commit.MessageBody, "\n") {
-				if strings.HasPrefix(line, "commit-id:") {
-					commits = append(commits, git.Commit{
-						CommitID:   strings.Split(line, ":")[1],
-						CommitHash: v.Commit.Oid,
-						Subject:    v.Commit.MessageHeadline,
-						Body:       v.Commit.MessageBody,
-					})
-				}
-			}
-		}
-
-		pullRequest := &github.PullRequest{
-			DatabaseId: fmt.Sprintf("%d", node.DatabaseId),
-			Id:         node.Id,
-			Number:     node.Number,
-			Title:      node.Title,
-			Body:       node.Body,
-			FromBranch: node.HeadRefName,
-			ToBranch:   node.BaseRefName,
-			Commits:    commits,
-			InQueue:    node.MergeQueueEntry.Id != "",
-		}
-
-		matches := git.BranchNameRegex.FindStringSubmatch(node.HeadRefName)
-		if matches != nil {
-			commit := (node.Commits.Nodes)[len(node.Commits.Nodes)-1].Commit
-			pullRequest.Commit = git.Commit{
-				CommitID:   matches[2],
-				CommitHash: commit.Oid,
-				Subject:    commit.MessageHeadline,
-				Body:       commit.MessageBody,
-			}
-
-			checkStatus := github.CheckStatusPass
-			if commit.StatusCheckRollup.State != "" {
-				switch commit.StatusCheckRollup.State {
-				case "SUCCESS":
-					checkStatus = github.CheckStatusPass
-				case "PENDING":
-					checkStatus = github.CheckStatusPending
-				default:
-					checkStatus = github.CheckStatusFail
-				}
-			}
-
-			pullRequest.MergeStatus = github.PullRequestMergeStatus{
-				ChecksPass:     checkStatus,
-				ReviewApproved: node.ReviewDecision == "APPROVED",
-				NoConflicts:    node.Mergeable == "MERGEABLE",
-			}
-
-			pullRequestMap[pullRequest.Commit.CommitID] = pullRequest
-		}
-	}
-
-	var pullRequests []*github.PullRequest
-
-	// find top pr
-	var currpr *github.PullRequest
-	var found bool
-	for i := len(localCommitStack) - 1; i >= 0; i-- {
-		currpr, found = pullRequestMap[localCommitStack[i].CommitID]
-		if found {
-			break
-		}
-	}
-
-	// The list of commits from the command line actually starts at the
-	//  most recent commit. In order to reverse the list we use a
-	//  custom prepend function instead of append
-	prepend := func(l []*github.PullRequest, pr *github.PullRequest) []*github.PullRequest {
-		l = append(l, &github.PullRequest{})
-		copy(l[1:], l)
-		l[0] = pr
-		return l
-	}
-
-	// build pr stack
-	for currpr != nil {
-		pullRequests = prepend(pullRequests, currpr)
-		if currpr.ToBranch == targetBranch {
-			break
-		}
-
-		matches := git.BranchNameRegex.FindStringSubmatch(currpr.ToBranch)
-		if matches == nil {
-			panic(fmt.Errorf("invalid base branch for pull request:%s", currpr.ToBranch))
-		}
-		nextCommitID := matches[2]
-
-		currpr = pullRequestMap[nextCommitID]
-	}
-
-	return pullRequests
+	return info, nil
 }
 
 // GetAssignableUsers is taken from github.com/cli/cli/api and is the approach used by the official gh
 // client to resolve user IDs to "ID" values for the update PR API calls. See api.RepoAssignableUsers.
-func (c *client) GetAssignableUsers(ctx context.Context) []github.RepoAssignee {
+func (c *client) GetAssignableUsers(ctx context.Context) ([]hosting.RepoAssignee, error) {
 	if c.config.User.LogGitHubCalls {
 		fmt.Printf("> github get assignable users\n")
 	}
 
-	users := []github.RepoAssignee{}
+	users := []hosting.RepoAssignee{}
 	var endCursor string
 	for {
 		resp, err := genqlient.AssignableUsers(
@@ -258,12 +195,11 @@ func (c *client) GetAssignableUsers(ctx context.Context) []github.RepoAssignee {
 			c.config.Repo.GitHubRepoName, endCursor,
 		)
 		if err != nil {
-			log.Fatal().Err(err).Msg("get assignable users failed")
-			return nil
+			return nil, fmt.Errorf("get assignable users failed %w", checkUnauthorized(err))
 		}
 
 		for _, node := range resp.Repository.AssignableUsers.Nodes {
-			user := github.RepoAssignee{
+			user := hosting.RepoAssignee{
 				ID:    node.Id,
 				Login: node.Login,
 			}
@@ -278,11 +214,11 @@ func (c *client) GetAssignableUsers(ctx context.Context) []github.RepoAssignee {
 		endCursor = resp.Repository.AssignableUsers.PageInfo.EndCursor
 	}
 
-	return users
+	return users, nil
 }
 
 func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
-	info *github.GitHubInfo, commit git.Commit, prevCommit *git.Commit) *github.PullRequest {
+	info *hosting.Info, commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
 
 	baseRefName := c.config.Repo.GitHubBranch
 	if prevCommit != nil {
@@ -298,11 +234,11 @@ func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
 	if c.config.Repo.PRTemplatePath != "" {
 		pullRequestTemplate, err := readPRTemplate(gitcmd, c.config.Repo.PRTemplatePath)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to read PR template")
+			return nil, fmt.Errorf("failed to read PR template %w", err)
 		}
 		body, err = InsertBodyIntoPRTemplate(body, pullRequestTemplate, c.config.Repo, nil)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to insert body into PR template")
+			return nil, fmt.Errorf("failed to insert body into PR template %w", err)
 		}
 	}
 	resp, err := genqlient.CreatePullRequest(ctx, c.gclient, genqlient.CreatePullRequestInput{
@@ -313,17 +249,19 @@ func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
 		Body:         body,
 		Draft:        c.config.User.CreateDraftPRs,
 	})
-	check(err)
+	if err != nil {
+		return nil, fmt.Errorf("creating pull request for commit %s %w", commit.CommitHash, checkUnauthorized(err))
+	}
 
-	pr := &github.PullRequest{
+	pr := &hosting.PullRequest{
 		DatabaseId: resp.CreatePullRequest.PullRequest.Id,
 		Number:     resp.CreatePullRequest.PullRequest.Number,
 		FromBranch: headRefName,
 		ToBranch:   baseRefName,
 		Commit:     commit,
 		Title:      commit.Subject,
-		MergeStatus: github.PullRequestMergeStatus{
-			ChecksPass:     github.CheckStatusUnknown,
+		MergeStatus: hosting.PullRequestMergeStatus{
+			ChecksPass:     hosting.CheckStatusUnknown,
 			ReviewApproved: false,
 			NoConflicts:    false,
 			Stacked:        false,
@@ -334,21 +272,41 @@ func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
 		fmt.Printf("> github create %d : %s\n", pr.Number, pr.Title)
 	}
 
-	return pr
+	return pr, nil
 }
 
-func (c *client) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull genqlient.CreatePullRequestInput) (string, int, error) {
-	resp, err := genqlient.CreatePullRequest(ctx, c.gclient, pull)
+// CreatePullRequest2 creates the pull request, a pull request from a fork is opened with the fork as the head
+// repository.
+func (c *client) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
+	headRepositoryId := pull.HeadRepositoryId
+	if pull.HeadOwner != "" {
+		fork, err := genqlient.RepositoryId(ctx, c.gclient, pull.HeadOwner, pull.HeadName)
+		if err != nil {
+			return "", 0, fmt.Errorf("getting the id of %s/%s %w", pull.HeadOwner, pull.HeadName, checkUnauthorized(err))
+		}
+		headRepositoryId = fork.Repository.Id
+	}
+
+	resp, err := genqlient.CreatePullRequest(ctx, c.gclient, genqlient.CreatePullRequestInput{
+		HeadRepositoryId: headRepositoryId,
+		RepositoryId:     pull.RepositoryId,
+		HeadRefName:      pull.HeadRefName,
+		BaseRefName:      pull.BaseRefName,
+		Title:            pull.Title,
+		Body:             pull.Body,
+		Draft:            pull.Draft,
+	})
 	if err != nil {
 		return "", 0, err
 	}
 	return resp.CreatePullRequest.PullRequest.Id, resp.CreatePullRequest.PullRequest.Number, err
 }
 
-func formatStackMarkdown(commit git.Commit, stack []*github.PullRequest, showPrTitlesInStack bool) string {
+func formatStackMarkdown(commit git.Commit, stack []*hosting.PullRequest, showPrTitlesInStack bool) string {
 	var buf bytes.Buffer
 	for i := len(stack) - 1; i >= 0; i-- {
-		isCurrent := stack[i].Commit == commit
+		// The commit of an existing pull request is the one on its remote branch, which has a different hash
+		isCurrent := stack[i].Commit.CommitID == commit.CommitID
 		var suffix string
 		if isCurrent {
 			suffix = " ⬅"
@@ -368,7 +326,7 @@ func formatStackMarkdown(commit git.Commit, stack []*github.PullRequest, showPrT
 	return buf.String()
 }
 
-func FormatBody(commit git.Commit, stack []*github.PullRequest, showPrTitlesInStack bool) string {
+func FormatBody(commit git.Commit, stack []*hosting.PullRequest, showPrTitlesInStack bool) string {
 	if len(stack) <= 1 {
 		return strings.TrimSpace(commit.Body)
 	}
@@ -401,7 +359,7 @@ func readPRTemplate(gitcmd git.GitInterface, templatePath string) (string, error
 //
 // NOTE: on PR update, rather than using the PR template, it will use the existing PR body, which should have
 // the PR template from the initial PR create.
-func InsertBodyIntoPRTemplate(body, prTemplate string, repo *config.RepoConfig, pr *github.PullRequest) (string, error) {
+func InsertBodyIntoPRTemplate(body, prTemplate string, repo *config.RepoConfig, pr *hosting.PullRequest) (string, error) {
 	templateOrExistingPRBody := prTemplate
 	if pr != nil && pr.Body != "" {
 		templateOrExistingPRBody = pr.Body
@@ -451,7 +409,7 @@ func addManualMergeNotice(body string) string {
 		"Do not merge manually using the UI - doing so may have unexpected results.*"
 }
 
-func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*github.PullRequest, pr *github.PullRequest, commit git.Commit, prevCommit *git.Commit) {
+func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest, pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
 
 	if c.config.User.LogGitHubCalls {
 		fmt.Printf("> github update %d : %s\n", pr.Number, pr.Title)
@@ -470,11 +428,11 @@ func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface,
 	if c.config.Repo.PRTemplatePath != "" {
 		pullRequestTemplate, err := readPRTemplate(gitcmd, c.config.Repo.PRTemplatePath)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to read PR template")
+			return fmt.Errorf("failed to read PR template %w", err)
 		}
 		body, err = InsertBodyIntoPRTemplate(body, pullRequestTemplate, c.config.Repo, pr)
 		if err != nil {
-			log.Fatal().Err(err).Msg("failed to insert body into PR template")
+			return fmt.Errorf("failed to insert body into PR template %w", err)
 		}
 	}
 
@@ -494,22 +452,16 @@ func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface,
 	}
 
 	_, err := genqlient.UpdatePullRequest(ctx, c.gclient, input)
-
 	if err != nil {
-		log.Fatal().
-			Str("id", pr.Id).
-			Str("databaseId", pr.DatabaseId).
-			Int("number", pr.Number).
-			Str("title", pr.Title).
-			Err(err).
-			Msg("pull request update failed")
+		return fmt.Errorf("pull request %d update failed %w", pr.Number, checkUnauthorized(err))
 	}
+	return nil
 }
 
 // AddReviewers adds reviewers to the provided pull request using the requestReviews() API call. It
 // takes github user IDs (ID type) as its input. These can be found by first querying the AssignableUsers
 // for the repo, and then mapping login name to ID.
-func (c *client) AddReviewers(ctx context.Context, pr *github.PullRequest, userIDs []string) {
+func (c *client) AddReviewers(ctx context.Context, pr *hosting.PullRequest, userIDs []string) error {
 	log.Debug().Strs("userIDs", userIDs).Msg("AddReviewers")
 	if c.config.User.LogGitHubCalls {
 		fmt.Printf("> github add reviewers %d : %s - %+v\n", pr.Number, pr.Title, userIDs)
@@ -523,39 +475,29 @@ func (c *client) AddReviewers(ctx context.Context, pr *github.PullRequest, userI
 		UserIds:       userIDs,
 	})
 	if err != nil {
-		log.Fatal().
-			Str("id", pr.Id).
-			Str("databaseId", pr.DatabaseId).
-			Int("number", pr.Number).
-			Str("title", pr.Title).
-			Strs("userIDs", userIDs).
-			Err(err).
-			Msg("add reviewers failed")
+		return fmt.Errorf("add reviewers %v to pull request %d failed %w", userIDs, pr.Number, checkUnauthorized(err))
 	}
+	return nil
 }
 
-func (c *client) CommentPullRequest(ctx context.Context, pr *github.PullRequest, comment string) {
+func (c *client) CommentPullRequest(ctx context.Context, pr *hosting.PullRequest, comment string) error {
 	_, err := genqlient.CommentPullRequest(ctx, c.gclient, genqlient.AddCommentInput{
 		SubjectId: pr.Id,
 		Body:      comment,
 	})
 	if err != nil {
-		log.Fatal().
-			Str("id", pr.Id).
-			Str("databaseId", pr.DatabaseId).
-			Int("number", pr.Number).
-			Str("title", pr.Title).
-			Err(err).
-			Msg("pull request update failed")
+		return fmt.Errorf("comment on pull request %d failed %w", pr.Number, checkUnauthorized(err))
 	}
 
 	if c.config.User.LogGitHubCalls {
 		fmt.Printf("> github add comment %d : %s\n", pr.Number, pr.Title)
 	}
+	return nil
 }
 
+// MergePullRequest merges the pull request, the hosting merge methods have the same values as GitHub's
 func (c *client) MergePullRequest(ctx context.Context,
-	pr *github.PullRequest, mergeMethod genqlient.PullRequestMergeMethod) error {
+	pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
 	log.Debug().
 		Interface("PR", pr).
 		Str("mergeMethod", string(mergeMethod)).
@@ -570,14 +512,14 @@ func (c *client) MergePullRequest(ctx context.Context,
 		_, err = genqlient.AutoMergePullRequest(ctx, c.gclient, genqlient.EnablePullRequestAutoMergeInput{
 			AuthorEmail:     email,
 			PullRequestId:   pr.Id,
-			MergeMethod:     mergeMethod,
+			MergeMethod:     genqlient.PullRequestMergeMethod(mergeMethod),
 			ExpectedHeadOid: pr.Commit.CommitHash,
 		})
 	} else {
 		_, err = genqlient.MergePullRequest(ctx, c.gclient, genqlient.MergePullRequestInput{
 			AuthorEmail:     email,
 			PullRequestId:   pr.Id,
-			MergeMethod:     mergeMethod,
+			MergeMethod:     genqlient.PullRequestMergeMethod(mergeMethod),
 			ExpectedHeadOid: pr.Commit.CommitHash,
 		})
 	}
@@ -591,12 +533,26 @@ func (c *client) MergePullRequest(ctx context.Context,
 	return nil
 }
 
-func (c *client) EditPullRequest2(ctx context.Context, owner string, repoName string, number int, pull *gogithub.PullRequest) error {
+func (c *client) EditPullRequest2(ctx context.Context, owner string, repoName string, number int, edit hosting.PullRequestEdit) error {
+	pull := &gogithub.PullRequest{
+		Title: edit.Title,
+		Body:  edit.Body,
+		Draft: edit.Draft,
+	}
+	if edit.HeadRefName != nil {
+		pull.Head = &gogithub.PullRequestBranch{Ref: edit.HeadRefName}
+	}
+	if edit.BaseRefName != nil {
+		pull.Base = &gogithub.PullRequestBranch{Ref: edit.BaseRefName}
+	}
+	if edit.State != nil {
+		pull.State = ptrutils.Ptr(string(*edit.State))
+	}
 	_, _, err := c.goghclient.PullRequests.Edit(ctx, owner, repoName, number, pull)
 	return err
 }
 
-func (c *client) ClosePullRequest(ctx context.Context, pr *github.PullRequest) error {
+func (c *client) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
 	log.Debug().Interface("PR", pr).Msg("ClosePullRequest")
 	_, err := genqlient.ClosePullRequest(ctx, c.gclient, genqlient.ClosePullRequestInput{
 		PullRequestId: pr.Id,
@@ -612,21 +568,232 @@ func (c *client) ClosePullRequest(ctx context.Context, pr *github.PullRequest) e
 	return nil
 }
 
-func (c *client) PullRequestsAndStatus(ctx context.Context, repo_owner string, repo_name string) (*genqlient.PullRequestsAndStatusResponse, error) {
-	return genqlient.PullRequestsAndStatus(ctx, c.gclient, repo_owner, repo_name)
+// PullRequestsAndStatus fetches all of the users open pull requests along with all of their commits.
+// The pages of pull requests and commits are combined into a single repository.
+func (c *client) PullRequestsAndStatus(ctx context.Context, repo_owner string, repo_name string) (*hosting.Repository, error) {
+	repo := &hosting.Repository{}
+	var endCursor string
+	for {
+		page, err := genqlient.PullRequestsAndStatus(ctx, c.gclient, repo_owner, repo_name, endCursor)
+		if err != nil {
+			return nil, err
+		}
+		repo.Id = page.Repository.Id
+		repo.ParentId = page.Repository.Parent.Id
+		repo.Viewer = page.Viewer.Login
+		c.config.SetViewerLogin(repo.Viewer)
+
+		// The open pull requests of the repository are listed, only the viewer's are kept
+		for _, node := range page.Repository.PullRequests.Nodes {
+			if node.Author == nil || node.Author.GetLogin() != repo.Viewer {
+				continue
+			}
+			pr := hostingPullRequest(node.StatusPullRequest)
+			if node.Commits.PageInfo.HasNextPage {
+				if err := c.appendPullRequestCommits(ctx, &pr, node.Commits.PageInfo.EndCursor); err != nil {
+					return nil, err
+				}
+			}
+			repo.PullRequests = append(repo.PullRequests, pr)
+		}
+
+		if !page.Repository.PullRequests.PageInfo.HasNextPage {
+			break
+		}
+		endCursor = page.Repository.PullRequests.PageInfo.EndCursor
+	}
+
+	return repo, nil
+}
+
+// hostingPullRequest converts a pull request node (with its first page of commits) to a hosting pull request.
+// The hosting mergeable and review decision values are the same as GitHub's.
+func hostingPullRequest(node genqlient.StatusPullRequest) hosting.OpenPullRequest {
+	pr := hosting.OpenPullRequest{
+		Id:               node.Id,
+		DatabaseId:       node.DatabaseId,
+		Number:           node.Number,
+		Title:            node.Title,
+		Body:             node.Body,
+		HeadRefName:      node.HeadRefName,
+		BaseRefName:      node.BaseRefName,
+		BaseRepositoryId: node.BaseRepository.Id,
+		Mergeable:        hosting.MergeableState(node.Mergeable),
+		ReviewDecision:   hosting.ReviewDecision(node.ReviewDecision),
+		CheckState:       checkState(node.StatusCheckRollup.State),
+	}
+	if node.Author != nil {
+		pr.Author = node.Author.GetLogin()
+	}
+	for _, commit := range node.Commits.Nodes {
+		pr.Commits = append(pr.Commits, hosting.Commit{
+			Oid:             commit.Commit.Oid,
+			MessageHeadline: commit.Commit.MessageHeadline,
+			MessageBody:     commit.Commit.MessageBody,
+			CheckState:      checkState(commit.Commit.StatusCheckRollup.State),
+		})
+	}
+	return pr
+}
+
+// checkState maps a status rollup state to a check state, an error fails the checks and an expected check is pending
+func checkState(state genqlient.StatusState) hosting.CheckState {
+	switch state {
+	case genqlient.StatusStateError, genqlient.StatusStateFailure:
+		return hosting.CheckStateFailure
+	case genqlient.StatusStateExpected, genqlient.StatusStatePending:
+		return hosting.CheckStatePending
+	case genqlient.StatusStateSuccess:
+		return hosting.CheckStateSuccess
+	default:
+		return hosting.CheckStateNone
+	}
 }
 
-func check(err error) {
+// mergeQueuePullRequests are the users open pull requests along with the viewer's login and the repository id
+type mergeQueuePullRequests struct {
+	login        string
+	repositoryId string
+	pullRequests []hosting.OpenPullRequest
+	// inQueue are the ids of the pull requests in the merge queue
+	inQueue map[string]bool
+}
+
+// stack matches the pull requests with the local commit stack and marks the pull requests in the merge queue
+func (r *mergeQueuePullRequests) stack(cfg *config.Config, targetBranch string, localCommitStack []git.Commit) (
+	[]*hosting.PullRequest, error) {
+	pullRequests, err := hosting.MatchPullRequestStack(cfg, targetBranch, localCommitStack, r.pullRequests)
 	if err != nil {
-		msg := err.Error()
-		if strings.Contains(msg, "401 Unauthorized") {
-			errmsg := "error : 401 Unauthorized\n"
-			errmsg += " make sure GITHUB_TOKEN env variable is set with a valid token\n"
-			errmsg += " to create a valid token goto: https://github.com/settings/tokens\n"
-			fmt.Fprint(os.Stderr, errmsg)
-			os.Exit(-1)
-		} else {
-			panic(err)
+		return nil, err
+	}
+	for _, pr := range pullRequests {
+		pr.InQueue = r.inQueue[pr.Id]
+	}
+	return pullRequests, nil
+}
+
+// pullRequestsWithMergeQueue fetches all of the users open pull requests along with all of their commits.
+// The pages of pull requests and commits are combined.
+func (c *client) pullRequestsWithMergeQueue(ctx context.Context) (*mergeQueuePullRequests, error) {
+	owner := c.config.Repo.GitHubRepoOwner
+	name := c.config.Repo.GitHubRepoName
+
+	resp := &mergeQueuePullRequests{inQueue: map[string]bool{}}
+	var endCursor string
+	for {
+		page, err := genqlient.PullRequestsWithMergeQueue(ctx, c.gclient, owner, name, endCursor)
+		if err != nil {
+			return nil, err
+		}
+		resp.login = page.Viewer.Login
+		resp.repositoryId = page.Repository.Id
+
+		// The open pull requests of the repository are listed, only the viewer's based in the repository are kept
+		for _, node := range page.Repository.PullRequests.Nodes {
+			if !isViewerPullRequest(node.MergeQueuePullRequest, resp.login, resp.repositoryId) {
+				continue
+			}
+			pr := mergeQueueOpenPullRequest(node.MergeQueuePullRequest)
+			if node.Commits.PageInfo.HasNextPage {
+				if err := c.appendPullRequestCommits(ctx, &pr, node.Commits.PageInfo.EndCursor); err != nil {
+					return nil, err
+				}
+			}
+			resp.pullRequests = append(resp.pullRequests, pr)
+			if node.MergeQueueEntry.Id != "" {
+				resp.inQueue[node.Id] = true
+			}
+		}
+
+		if !page.Repository.PullRequests.PageInfo.HasNextPage {
+			break
+		}
+		endCursor = page.Repository.PullRequests.PageInfo.EndCursor
+	}
+
+	return resp, nil
+}
+
+// isViewerPullRequest is true for the pull requests authored by the viewer and based in the repository
+func isViewerPullRequest(node genqlient.MergeQueuePullRequest, login string, repoID string) bool {
+	return node.Author != nil && node.Author.GetLogin() == login && node.Repository.Id == repoID
+}
+
+// mergeQueueOpenPullRequest converts a pull request node (with its first page of commits) to a hosting pull request
+func mergeQueueOpenPullRequest(node genqlient.MergeQueuePullRequest) hosting.OpenPullRequest {
+	pr := hosting.OpenPullRequest{
+		Id:               node.Id,
+		DatabaseId:       node.DatabaseId,
+		Number:           node.Number,
+		Title:            node.Title,
+		Body:             node.Body,
+		HeadRefName:      node.HeadRefName,
+		BaseRefName:      node.BaseRefName,
+		BaseRepositoryId: node.Repository.Id,
+		Mergeable:        hosting.MergeableState(node.Mergeable),
+		ReviewDecision:   hosting.ReviewDecision(node.ReviewDecision),
+	}
+	if node.Author != nil {
+		pr.Author = node.Author.GetLogin()
+	}
+	for _, commit := range node.Commits.Nodes {
+		pr.Commits = append(pr.Commits, hosting.Commit{
+			Oid:             commit.Commit.Oid,
+			MessageHeadline: commit.Commit.MessageHeadline,
+			MessageBody:     commit.Commit.MessageBody,
+			CheckState:      checkState(commit.Commit.StatusCheckRollup.State),
+		})
+	}
+	return pr
+}
+
+// appendPullRequestCommits fetches the commits of a pull request after the endCursor and appends them
+func (c *client) appendPullRequestCommits(ctx context.Context, pr *hosting.OpenPullRequest, endCursor string) error {
+	commits, err := c.pullRequestCommits(ctx, pr.Id, endCursor)
+	if err != nil {
+		return err
+	}
+	for _, commit := range commits {
+		pr.Commits = append(pr.Commits, hosting.Commit{
+			Oid:             commit.Oid,
+			MessageHeadline: commit.MessageHeadline,
+			MessageBody:     commit.MessageBody,
+			CheckState:      checkState(commit.StatusCheckRollup.State),
+		})
+	}
+	return nil
+}
+
+// pullRequestCommits fetches the commits of a pull request starting after the endCursor
+func (c *client) pullRequestCommits(ctx context.Context, pullRequestId string, endCursor string) (
+	[]genqlient.PullRequestCommitsNodePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit, error) {
+	var commits []genqlient.PullRequestCommitsNodePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit
+	for {
+		resp, err := genqlient.PullRequestCommits(ctx, c.gclient, pullRequestId, endCursor)
+		if err != nil {
+			return nil, fmt.Errorf("fetching commits for pull request %s %w", pullRequestId, err)
+		}
+		pr, ok := resp.Node.(*genqlient.PullRequestCommitsNodePullRequest)
+		if !ok {
+			return nil, fmt.Errorf("node %s is not a pull request", pullRequestId)
+		}
+		for _, node := range pr.Commits.Nodes {
+			commits = append(commits, node.Commit)
+		}
+		if !pr.Commits.PageInfo.HasNextPage {
+			break
 		}
+		endCursor = pr.Commits.PageInfo.EndCursor
 	}
+	return commits, nil
+}
+
+// checkUnauthorized adds a hint on how to set up a valid token to 401 Unauthorized errors
+func checkUnauthorized(err error) error {
+	if strings.Contains(err.Error(), "401 Unauthorized") {
+		return fmt.Errorf("%w\n"+
+			" make sure GITHUB_TOKEN env variable is set with a valid token\n"+
+			" to create a valid token goto: https://<github host>/settings/tokens", err)
+	}
+	return err
 }
@@ -1,13 +1,17 @@
 package githubclient
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
 	"strings"
 	"testing"
 
+	"github.com/Khan/genqlient/graphql"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github"
 	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/stretchr/testify/require"
 )
 
@@ -15,8 +19,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 	tests := []struct {
 		name    string
 		commits []git.Commit
-		prs     genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection
-		expect  []*github.PullRequest
+		prs     []genqlient.MergeQueuePullRequest
+		expect  []*hosting.PullRequest
 	}{
 		{
 			name: "ThirdCommitQueue",
@@ -25,28 +29,26 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000002"},
 				{CommitID: "00000003"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:      2,
-						Id:              "20",
-						HeadRefName:     "spr/master/00000002",
-						BaseRefName:     "master",
-						MergeQueueEntry: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestMergeQueueEntry{Id: "020"},
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1", MessageBody: "commit-id:1"},
-								},
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2", MessageBody: "commit-id:2"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:      2,
+					Id:              "20",
+					HeadRefName:     "spr/master/00000002",
+					BaseRefName:     "master",
+					MergeQueueEntry: genqlient.MergeQueuePullRequestMergeQueueEntry{Id: "020"},
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1", MessageBody: "commit-id:1"},
+							},
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2", MessageBody: "commit-id:2"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "2",
 					Id:         "20",
@@ -62,8 +64,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						{CommitID: "1", CommitHash: "1", Body: "commit-id:1"},
 						{CommitID: "2", CommitHash: "2", Body: "commit-id:2"},
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -76,41 +78,39 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000003"},
 				{CommitID: "00000004"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:      2,
-						Id:              "20",
-						HeadRefName:     "spr/master/00000002",
-						BaseRefName:     "master",
-						MergeQueueEntry: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestMergeQueueEntry{Id: "020"},
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1", MessageBody: "commit-id:1"},
-								},
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2", MessageBody: "commit-id:2"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:      2,
+					Id:              "20",
+					HeadRefName:     "spr/master/00000002",
+					BaseRefName:     "master",
+					MergeQueueEntry: genqlient.MergeQueuePullRequestMergeQueueEntry{Id: "020"},
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1", MessageBody: "commit-id:1"},
+							},
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2", MessageBody: "commit-id:2"},
 							},
 						},
 					},
-					{
-						DatabaseId:  3,
-						Id:          "30",
-						HeadRefName: "spr/master/00000003",
-						BaseRefName: "spr/master/00000002",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3", MessageBody: "commit-id:3"},
-								},
+				},
+				{
+					DatabaseId:  3,
+					Id:          "30",
+					HeadRefName: "spr/master/00000003",
+					BaseRefName: "spr/master/00000002",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3", MessageBody: "commit-id:3"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "2",
 					Id:         "20",
@@ -126,8 +126,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						{CommitID: "1", CommitHash: "1", Body: "commit-id:1"},
 						{CommitID: "2", CommitHash: "2", Body: "commit-id:2"},
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -143,8 +143,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 					Commits: []git.Commit{
 						{CommitID: "3", CommitHash: "3", Body: "commit-id:3"},
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -152,14 +152,14 @@ func TestMatchPullRequestStack(t *testing.T) {
 		{
 			name:    "Empty",
 			commits: []git.Commit{},
-			prs:     genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{},
-			expect:  []*github.PullRequest{},
+			prs:     []genqlient.MergeQueuePullRequest(nil),
+			expect:  []*hosting.PullRequest{},
 		},
 		{
 			name:    "FirstCommit",
 			commits: []git.Commit{{CommitID: "00000001"}},
-			prs:     genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{},
-			expect:  []*github.PullRequest{},
+			prs:     []genqlient.MergeQueuePullRequest(nil),
+			expect:  []*hosting.PullRequest{},
 		},
 		{
 			name: "SecondCommit",
@@ -167,24 +167,22 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000001"},
 				{CommitID: "00000002"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "1",
 					Id:         "10",
@@ -194,8 +192,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000001",
 						CommitHash: "1",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -207,37 +205,35 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000002"},
 				{CommitID: "00000003"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
-					{
-						DatabaseId:  2,
-						Id:          "20",
-						HeadRefName: "spr/master/00000002",
-						BaseRefName: "spr/master/00000001",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
-								},
+				},
+				{
+					DatabaseId:  2,
+					Id:          "20",
+					HeadRefName: "spr/master/00000002",
+					BaseRefName: "spr/master/00000001",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "1",
 					Id:         "10",
@@ -247,8 +243,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000001",
 						CommitHash: "1",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -260,8 +256,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000002",
 						CommitHash: "2",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -269,24 +265,22 @@ func TestMatchPullRequestStack(t *testing.T) {
 		{
 			name:    "RemoveOnlyCommit",
 			commits: []git.Commit{},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{},
+			expect: []*hosting.PullRequest{},
 		},
 		{
 			name: "RemoveTopCommit",
@@ -294,50 +288,48 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000001"},
 				{CommitID: "00000002"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
-					{
-						DatabaseId:  3,
-						Id:          "30",
-						HeadRefName: "spr/master/00000003",
-						BaseRefName: "spr/master/00000002",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
-								},
+				},
+				{
+					DatabaseId:  3,
+					Id:          "30",
+					HeadRefName: "spr/master/00000003",
+					BaseRefName: "spr/master/00000002",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
 							},
 						},
 					},
-					{
-						DatabaseId:  2,
-						Id:          "20",
-						HeadRefName: "spr/master/00000002",
-						BaseRefName: "spr/master/00000001",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
-								},
+				},
+				{
+					DatabaseId:  2,
+					Id:          "20",
+					HeadRefName: "spr/master/00000002",
+					BaseRefName: "spr/master/00000001",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "1",
 					Id:         "10",
@@ -347,8 +339,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000001",
 						CommitHash: "1",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -360,8 +352,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000002",
 						CommitHash: "2",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -372,50 +364,48 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000001"},
 				{CommitID: "00000003"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
-					{
-						DatabaseId:  2,
-						Id:          "20",
-						HeadRefName: "spr/master/00000002",
-						BaseRefName: "spr/master/00000001",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
-								},
+				},
+				{
+					DatabaseId:  2,
+					Id:          "20",
+					HeadRefName: "spr/master/00000002",
+					BaseRefName: "spr/master/00000001",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
 							},
 						},
 					},
-					{
-						DatabaseId:  3,
-						Id:          "30",
-						HeadRefName: "spr/master/00000003",
-						BaseRefName: "spr/master/00000002",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3"},
-								},
+				},
+				{
+					DatabaseId:  3,
+					Id:          "30",
+					HeadRefName: "spr/master/00000003",
+					BaseRefName: "spr/master/00000002",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "1",
 					Id:         "10",
@@ -425,8 +415,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000001",
 						CommitHash: "1",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -438,8 +428,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000002",
 						CommitHash: "2",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -451,8 +441,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000003",
 						CommitHash: "3",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -463,50 +453,48 @@ func TestMatchPullRequestStack(t *testing.T) {
 				{CommitID: "00000002"},
 				{CommitID: "00000003"},
 			},
-			prs: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnection{
-				Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequest{
-					{
-						DatabaseId:  1,
-						Id:          "10",
-						HeadRefName: "spr/master/00000001",
-						BaseRefName: "master",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
-								},
+			prs: []genqlient.MergeQueuePullRequest{
+				{
+					DatabaseId:  1,
+					Id:          "10",
+					HeadRefName: "spr/master/00000001",
+					BaseRefName: "master",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "1"},
 							},
 						},
 					},
-					{
-						DatabaseId:  2,
-						Id:          "20",
-						HeadRefName: "spr/master/00000002",
-						BaseRefName: "spr/master/00000001",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
-								},
+				},
+				{
+					DatabaseId:  2,
+					Id:          "20",
+					HeadRefName: "spr/master/00000002",
+					BaseRefName: "spr/master/00000001",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "2"},
 							},
 						},
 					},
-					{
-						DatabaseId:  3,
-						Id:          "30",
-						HeadRefName: "spr/master/00000003",
-						BaseRefName: "spr/master/00000002",
-						Commits: genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnection{
-							Nodes: []genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
-								{
-									genqlient.PullRequestsWithMergeQueueViewerUserPullRequestsPullRequestConnectionNodesPullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3"},
-								},
+				},
+				{
+					DatabaseId:  3,
+					Id:          "30",
+					HeadRefName: "spr/master/00000003",
+					BaseRefName: "spr/master/00000002",
+					Commits: genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnection{
+						Nodes: []genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommit{
+							{
+								genqlient.MergeQueuePullRequestCommitsPullRequestCommitConnectionNodesPullRequestCommitCommit{Oid: "3"},
 							},
 						},
 					},
 				},
 			},
-			expect: []*github.PullRequest{
+			expect: []*hosting.PullRequest{
 				{
 					DatabaseId: "1",
 					Id:         "10",
@@ -516,8 +504,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000001",
 						CommitHash: "1",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 
@@ -530,8 +518,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000002",
 						CommitHash: "2",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 				{
@@ -543,8 +531,8 @@ func TestMatchPullRequestStack(t *testing.T) {
 						CommitID:   "00000003",
 						CommitHash: "3",
 					},
-					MergeStatus: github.PullRequestMergeStatus{
-						ChecksPass: github.CheckStatusPass,
+					MergeStatus: hosting.PullRequestMergeStatus{
+						ChecksPass: hosting.CheckStatusPass,
 					},
 				},
 			},
@@ -552,9 +540,16 @@ func TestMatchPullRequestStack(t *testing.T) {
 	}
 
 	for _, tc := range tests {
-		repoConfig := &config.RepoConfig{}
 		t.Run(tc.name, func(t *testing.T) {
-			actual := matchPullRequestStack(repoConfig, "master", tc.commits, tc.prs)
+			resp := &mergeQueuePullRequests{inQueue: map[string]bool{}}
+			for _, node := range tc.prs {
+				resp.pullRequests = append(resp.pullRequests, mergeQueueOpenPullRequest(node))
+				if node.MergeQueueEntry.Id != "" {
+					resp.inQueue[node.Id] = true
+				}
+			}
+			actual, err := resp.stack(config.EmptyConfig(), "master", tc.commits)
+			require.NoError(t, err)
 			require.Equal(t, tc.expect, actual)
 		})
 	}
@@ -574,18 +569,18 @@ It even includes some **markdown** formatting.`}
 	tests := []struct {
 		description string
 		commit      git.Commit
-		stack       []*github.PullRequest
+		stack       []*hosting.PullRequest
 	}{
 		{
 			description: "",
 			commit:      git.Commit{},
-			stack:       []*github.PullRequest{},
+			stack:       []*hosting.PullRequest{},
 		},
 		{
 			description: `This body describes my nice PR.
 It even includes some **markdown** formatting.`,
 			commit: descriptiveCommit,
-			stack: []*github.PullRequest{
+			stack: []*hosting.PullRequest{
 				{Number: 2, Commit: descriptiveCommit},
 			},
 		},
@@ -602,11 +597,29 @@ It even includes some **markdown** formatting.
 
 ⚠️ *Part of a stack created by [spr](https://github.com/ejoffe/spr). Do not merge manually using the UI - doing so may have unexpected results.*`,
 			commit: descriptiveCommit,
-			stack: []*github.PullRequest{
+			stack: []*hosting.PullRequest{
 				{Number: 1, Commit: simpleCommit},
 				{Number: 2, Commit: descriptiveCommit},
 			},
 		},
+		{
+			description: `This body describes my nice PR.
+It even includes some **markdown** formatting.
+
+---
+
+**Stack**:
+- #2 ⬅
+- #1
+
+
+⚠️ *Part of a stack created by [spr](https://github.com/ejoffe/spr). Do not merge manually using the UI - doing so may have unexpected results.*`,
+			commit: descriptiveCommit,
+			stack: []*hosting.PullRequest{
+				{Number: 1, Commit: simpleCommit},
+				{Number: 2, Commit: git.Commit{CommitID: "def456", CommitHash: "cherrypicked"}},
+			},
+		},
 	}
 
 	for _, tc := range tests {
@@ -631,18 +644,18 @@ It even includes some **markdown** formatting.`}
 	tests := []struct {
 		description string
 		commit      git.Commit
-		stack       []*github.PullRequest
+		stack       []*hosting.PullRequest
 	}{
 		{
 			description: "",
 			commit:      git.Commit{},
-			stack:       []*github.PullRequest{},
+			stack:       []*hosting.PullRequest{},
 		},
 		{
 			description: `This body describes my nice PR.
 It even includes some **markdown** formatting.`,
 			commit: descriptiveCommit,
-			stack: []*github.PullRequest{
+			stack: []*hosting.PullRequest{
 				{Number: 2, Commit: descriptiveCommit},
 			},
 		},
@@ -659,7 +672,7 @@ It even includes some **markdown** formatting.
 
 ⚠️ *Part of a stack created by [spr](https://github.com/ejoffe/spr). Do not merge manually using the UI - doing so may have unexpected results.*`,
 			commit: descriptiveCommit,
-			stack: []*github.PullRequest{
+			stack: []*hosting.PullRequest{
 				{Number: 1, Commit: simpleCommit, Title: "Title A"},
 				{Number: 2, Commit: descriptiveCommit, Title: "Title B"},
 			},
@@ -680,7 +693,7 @@ func TestInsertBodyIntoPRTemplateHappyPath(t *testing.T) {
 		body                string
 		pullRequestTemplate string
 		repo                *config.RepoConfig
-		pr                  *github.PullRequest
+		pr                  *hosting.PullRequest
 		expected            string
 	}{
 		{
@@ -726,7 +739,7 @@ inserted body
 				PRTemplateInsertStart: "## Description",
 				PRTemplateInsertEnd:   "## Checklist",
 			},
-			pr: &github.PullRequest{
+			pr: &hosting.PullRequest{
 				Body: `
 ## Related Issues
 * Issue #1234
@@ -765,7 +778,7 @@ func TestInsertBodyIntoPRTemplateErrors(t *testing.T) {
 		body                string
 		pullRequestTemplate string
 		repo                *config.RepoConfig
-		pr                  *github.PullRequest
+		pr                  *hosting.PullRequest
 		expected            string
 	}{
 		{
@@ -855,3 +868,170 @@ func TestInsertBodyIntoPRTemplateErrors(t *testing.T) {
 		})
 	}
 }
+
+func TestApiEndpoints(t *testing.T) {
+	tests := []struct {
+		name     string
+		repo     *config.RepoConfig
+		graphQL  string
+		restBase string
+	}{
+		{
+			name:     "github.com",
+			repo:     &config.RepoConfig{GitHubHost: "github.com"},
+			graphQL:  "https://api.github.com/graphql",
+			restBase: "",
+		},
+		{
+			name:     "enterprise server",
+			repo:     &config.RepoConfig{GitHubHost: "gh.enterprise.com"},
+			graphQL:  "https://gh.enterprise.com/api/graphql",
+			restBase: "https://gh.enterprise.com/api/v3/",
+		},
+		{
+			name: "explicit overrides",
+			repo: &config.RepoConfig{
+				GitHubHost:       "gh.enterprise.com",
+				GitHubApiUrl:     "https://api.enterprise.com/",
+				GitHubGraphQLUrl: "https://api.enterprise.com/graphql",
+			},
+			graphQL:  "https://api.enterprise.com/graphql",
+			restBase: "https://api.enterprise.com/",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.graphQL, graphQLEndpoint(tc.repo))
+			require.Equal(t, tc.restBase, restEndpoint(tc.repo))
+		})
+	}
+}
+
+// pagedGraphQLClient responds to GraphQL requests with canned json keyed by the operation name and end cursor
+type pagedGraphQLClient struct {
+	responses map[string]string
+}
+
+func (c *pagedGraphQLClient) MakeRequest(ctx context.Context, req *graphql.Request, resp *graphql.Response) error {
+	vars, err := json.Marshal(req.Variables)
+	if err != nil {
+		return err
+	}
+	var input struct {
+		EndCursor string `json:"end_cursor"`
+	}
+	err = json.Unmarshal(vars, &input)
+	if err != nil {
+		return err
+	}
+
+	key := req.OpName + "/" + input.EndCursor
+	data, ok := c.responses[key]
+	if !ok {
+		return fmt.Errorf("unexpected request %s", key)
+	}
+	return json.Unmarshal([]byte(data), resp.Data)
+}
+
+func TestPullRequestsAndStatusPagination(t *testing.T) {
+	gclient := &pagedGraphQLClient{
+		responses: map[string]string{
+			"PullRequestsAndStatus/": `{
+				"viewer": {"login": "me"},
+				"repository": {
+					"id": "R1",
+					"pullRequests": {
+						"nodes": [{
+							"id": "PR1",
+							"number": 1,
+							"headRefName": "spr/main/00000001",
+							"author": {"__typename": "User", "login": "me"},
+							"commits": {
+								"nodes": [{"commit": {"oid": "c1"}}],
+								"pageInfo": {"hasNextPage": true, "endCursor": "C1"}
+							}
+						}],
+						"pageInfo": {"hasNextPage": true, "endCursor": "P1"}
+					}
+				}
+			}`,
+			"PullRequestsAndStatus/P1": `{
+				"viewer": {"login": "me"},
+				"repository": {
+					"id": "R1",
+					"pullRequests": {
+						"nodes": [{
+							"id": "PR2",
+							"number": 2,
+							"headRefName": "spr/main/00000002",
+							"author": {"__typename": "User", "login": "me"},
+							"commits": {
+								"nodes": [{"commit": {"oid": "c3"}}],
+								"pageInfo": {"hasNextPage": false}
+							}
+						}, {
+							"id": "PR3",
+							"number": 3,
+							"headRefName": "spr/main/00000003",
+							"author": {"__typename": "User", "login": "someone"},
+							"commits": {"nodes": [], "pageInfo": {"hasNextPage": false}}
+						}],
+						"pageInfo": {"hasNextPage": false}
+					}
+				}
+			}`,
+			"PullRequestCommits/C1": `{
+				"node": {
+					"__typename": "PullRequest",
+					"commits": {
+						"nodes": [{"commit": {"oid": "c2", "statusCheckRollup": {"state": "SUCCESS"}}}],
+						"pageInfo": {"hasNextPage": false}
+					}
+				}
+			}`,
+		},
+	}
+	c := &client{config: config.EmptyConfig(), gclient: gclient}
+
+	repo, err := c.PullRequestsAndStatus(context.Background(), "owner", "repo")
+	require.NoError(t, err)
+	require.Equal(t, "R1", repo.Id)
+	require.Equal(t, "me", repo.Viewer)
+
+	// The pull requests of other authors are left out
+	prs := repo.PullRequests
+	require.Len(t, prs, 2)
+	require.Equal(t, "PR1", prs[0].Id)
+	require.Equal(t, "PR2", prs[1].Id)
+
+	require.Len(t, prs[0].Commits, 2)
+	require.Equal(t, "c1", prs[0].Commits[0].Oid)
+	require.Equal(t, "c2", prs[0].Commits[1].Oid)
+	require.Equal(t, hosting.CheckStateSuccess, prs[0].Commits[1].CheckState)
+	require.Len(t, prs[1].Commits, 1)
+}
+
+func TestCheckState(t *testing.T) {
+	require.Equal(t, hosting.CheckStateNone, checkState(""))
+	require.Equal(t, hosting.CheckStateSuccess, checkState(genqlient.StatusStateSuccess))
+	require.Equal(t, hosting.CheckStatePending, checkState(genqlient.StatusStatePending))
+	require.Equal(t, hosting.CheckStatePending, checkState(genqlient.StatusStateExpected))
+	require.Equal(t, hosting.CheckStateFailure, checkState(genqlient.StatusStateFailure))
+	require.Equal(t, hosting.CheckStateFailure, checkState(genqlient.StatusStateError))
+}
+
+func TestIsViewerPullRequest(t *testing.T) {
+	node := func(id string, author string, repoID string) genqlient.MergeQueuePullRequest {
+		return genqlient.MergeQueuePullRequest{
+			Id:         id,
+			Author:     &genqlient.MergeQueuePullRequestAuthorUser{Login: author},
+			Repository: genqlient.MergeQueuePullRequestRepository{Id: repoID},
+		}
+	}
+
+	require.True(t, isViewerPullRequest(node("1", "me", "R1"), "me", "R1"))
+	require.False(t, isViewerPullRequest(node("2", "someone", "R1"), "me", "R1"))
+	require.False(t, isViewerPullRequest(node("3", "me", "R2"), "me", "R1"))
+	require.False(t, isViewerPullRequest(genqlient.MergeQueuePullRequest{Id: "4"}, "me", "R1"))
+}
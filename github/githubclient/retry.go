@@ -0,0 +1,230 @@
+package githubclient
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// retryTransport retries requests that fail with transient server errors or are rate limited.
+// Only GETs and GraphQL queries are retried after transport errors and server errors, the server may have already
+// applied a mutation so resending it could apply it twice. Every request is retried when it's rate limited since it
+// wasn't run. Rate limited requests wait for as long as GitHub asks (Retry-After or X-RateLimit-Reset), everything else uses a
+// jittered exponential backoff. A request is given up on once maxRetries or the wait budget is used up, in which case
+// the last response (or error) is returned.
+type retryTransport struct {
+	wrapped    http.RoundTripper
+	maxRetries int
+	budget     time.Duration
+
+	// now, sleep and jitter are replaced in tests
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func() float64
+}
+
+// NewRetryTransport returns a transport which retries the requests made through the wrapped transport as configured by
+// the user config.
+func NewRetryTransport(wrapped http.RoundTripper, userConfig *config.UserConfig) *retryTransport {
+	return &retryTransport{
+		wrapped:    wrapped,
+		maxRetries: userConfig.MaxRetries,
+		budget:     time.Duration(userConfig.RetryBudgetSeconds) * time.Second,
+		now:        time.Now,
+		sleep:      sleepContext,
+		jitter:     rand.Float64,
+	}
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The body is sent once per attempt so keep a copy of it
+	body, err := readBody(&req.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	idempotent := isIdempotent(req, body)
+	waited := time.Duration(0)
+	for attempt := 0; ; attempt++ {
+		attemptReq := req.Clone(req.Context())
+		if body != "" {
+			attemptReq.Body = io.NopCloser(strings.NewReader(body))
+		}
+
+		resp, err := t.wrapped.RoundTrip(attemptReq)
+		retry, wait, reason := t.shouldRetry(req, idempotent, resp, err, attempt)
+		if !retry || attempt >= t.maxRetries || waited+wait > t.budget {
+			return resp, err
+		}
+
+		log.Debug().
+			Str("url", req.URL.String()).
+			Str("reason", reason).
+			Dur("wait", wait).
+			Int("attempt", attempt+1).
+			Msg("retrying github request")
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		err = t.sleep(req.Context(), wait)
+		if err != nil {
+			return nil, err
+		}
+		waited += wait
+	}
+}
+
+// shouldRetry decides if the outcome of an attempt is worth retrying and how long to wait before doing so.
+// Transport and server errors are only retried for idempotent requests.
+func (t *retryTransport) shouldRetry(req *http.Request, idempotent bool, resp *http.Response, err error, attempt int) (bool, time.Duration, string) {
+	if err != nil {
+		if !idempotent || req.Context().Err() != nil {
+			return false, 0, ""
+		}
+		return true, t.backoff(attempt), err.Error()
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return true, t.rateLimitWait(resp, attempt), resp.Status
+	case resp.StatusCode == http.StatusForbidden && isRateLimited(resp):
+		return true, t.rateLimitWait(resp, attempt), "rate limited"
+	case idempotent && (resp.StatusCode == http.StatusBadGateway ||
+		resp.StatusCode == http.StatusServiceUnavailable ||
+		resp.StatusCode == http.StatusGatewayTimeout):
+		return true, t.retryAfter(resp, attempt), resp.Status
+	case resp.StatusCode == http.StatusOK && isGraphQLRateLimited(req, resp):
+		return true, t.rateLimitWait(resp, attempt), "graphql RATE_LIMITED"
+	}
+	return false, 0, ""
+}
+
+// mutationRegex matches a GraphQL mutation operation
+var mutationRegex = regexp.MustCompile(`(?m)^\s*mutation\b`)
+
+// isIdempotent returns true if the request can safely be sent again, which is the case for GETs and GraphQL queries
+func isIdempotent(req *http.Request, body string) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead:
+		return true
+	case http.MethodPost:
+		if !strings.HasSuffix(req.URL.Path, "graphql") {
+			return false
+		}
+		var graphQLRequest struct {
+			Query string `json:"query"`
+		}
+		err := json.Unmarshal([]byte(body), &graphQLRequest)
+		return err == nil && graphQLRequest.Query != "" && !mutationRegex.MatchString(graphQLRequest.Query)
+	default:
+		return false
+	}
+}
+
+// isRateLimited returns true if a 403 response is due to the primary or secondary rate limit (as opposed to a lack of
+// permissions)
+func isRateLimited(resp *http.Response) bool {
+	if resp.Header.Get("Retry-After") != "" || resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		return true
+	}
+
+	body, err := readBody(&resp.Body)
+	if err != nil {
+		return false
+	}
+	return strings.Contains(strings.ToLower(body), "rate limit")
+}
+
+// isGraphQLRateLimited returns true if the GraphQL response has a RATE_LIMITED error
+func isGraphQLRateLimited(req *http.Request, resp *http.Response) bool {
+	if !strings.HasSuffix(req.URL.Path, "graphql") {
+		return false
+	}
+
+	body, err := readBody(&resp.Body)
+	if err != nil {
+		return false
+	}
+
+	var graphQLResponse struct {
+		Errors []struct {
+			Type string `json:"type"`
+		} `json:"errors"`
+	}
+	err = json.Unmarshal([]byte(body), &graphQLResponse)
+	if err != nil {
+		return false
+	}
+	for _, e := range graphQLResponse.Errors {
+		if e.Type == "RATE_LIMITED" {
+			return true
+		}
+	}
+	return false
+}
+
+// rateLimitWait is how long GitHub asked us to wait, falling back to the exponential backoff
+func (t *retryTransport) rateLimitWait(resp *http.Response, attempt int) time.Duration {
+	if resp.Header.Get("Retry-After") == "" && resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64)
+		if err == nil {
+			return max(time.Unix(reset, 0).Sub(t.now()), 0) + time.Second
+		}
+	}
+	return t.retryAfter(resp, attempt)
+}
+
+// retryAfter returns the Retry-After wait, falling back to the exponential backoff
+func (t *retryTransport) retryAfter(resp *http.Response, attempt int) time.Duration {
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return t.backoff(attempt)
+	}
+
+	seconds, err := strconv.Atoi(retryAfter)
+	if err == nil {
+		return time.Duration(seconds) * time.Second
+	}
+	date, err := http.ParseTime(retryAfter)
+	if err == nil {
+		return max(date.Sub(t.now()), 0)
+	}
+	return t.backoff(attempt)
+}
+
+// backoff returns an exponential delay for the attempt with jitter so concurrent requests don't retry in lock step
+func (t *retryTransport) backoff(attempt int) time.Duration {
+	delay := retryMaxDelay
+	if attempt < 16 {
+		delay = min(retryBaseDelay<<attempt, retryMaxDelay)
+	}
+	return delay/2 + time.Duration(t.jitter()*float64(delay/2))
+}
@@ -0,0 +1,253 @@
+package githubclient
+
+import (
+	"cmp"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/stretchr/testify/require"
+)
+
+// cannedResponse is a response returned by the retry test server
+type cannedResponse struct {
+	status  int
+	headers map[string]string
+	body    string
+}
+
+// retryTestServer returns the canned responses in order (repeating the last one) and captures the request bodies
+func retryTestServer(t *testing.T, responses ...cannedResponse) (*httptest.Server, *[]string) {
+	lock := sync.Mutex{}
+	bodies := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lock.Lock()
+		defer lock.Unlock()
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+
+		response := responses[min(len(bodies), len(responses))-1]
+		for k, v := range response.headers {
+			w.Header().Set(k, v)
+		}
+		w.WriteHeader(response.status)
+		w.Write([]byte(response.body))
+	}))
+	t.Cleanup(server.Close)
+	return server, &bodies
+}
+
+// testRetryTransport returns a retry transport which records the waits instead of sleeping
+func testRetryTransport(maxRetries int, budgetSeconds int) (*retryTransport, *[]time.Duration) {
+	waits := []time.Duration{}
+	transport := NewRetryTransport(http.DefaultTransport, &config.UserConfig{
+		MaxRetries:         maxRetries,
+		RetryBudgetSeconds: budgetSeconds,
+	})
+	transport.now = func() time.Time { return time.Unix(1000, 0) }
+	transport.jitter = func() float64 { return 1 }
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	return transport, &waits
+}
+
+func TestRetryTransport(t *testing.T) {
+	ok := cannedResponse{status: http.StatusOK, body: `{"data":{}}`}
+	query := `{"query":"query PullRequests { viewer { login } }"}`
+	mutation := `{"query":"\nmutation MergePullRequest { mergePullRequest { clientMutationId } }"}`
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		body       string
+		responses  []cannedResponse
+		maxRetries int
+		budget     int
+		status     int
+		waits      []time.Duration
+	}{
+		{
+			name:       "success isn't retried",
+			responses:  []cannedResponse{ok},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{},
+		},
+		{
+			name: "bad gateway uses exponential backoff",
+			responses: []cannedResponse{
+				{status: http.StatusBadGateway},
+				{status: http.StatusBadGateway},
+				{status: http.StatusServiceUnavailable},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{time.Second, 2 * time.Second, 4 * time.Second},
+		},
+		{
+			name: "retry after header is honored",
+			responses: []cannedResponse{
+				{status: http.StatusForbidden, headers: map[string]string{"Retry-After": "7"}, body: `{"message":"You have exceeded a secondary rate limit"}`},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{7 * time.Second},
+		},
+		{
+			name: "rate limit reset header is honored",
+			responses: []cannedResponse{
+				{status: http.StatusForbidden, headers: map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": "1010"}},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{11 * time.Second},
+		},
+		{
+			name: "graphql rate limited errors are retried",
+			responses: []cannedResponse{
+				{status: http.StatusOK, body: `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{time.Second},
+		},
+		{
+			name:       "graphql mutations aren't retried on server errors",
+			body:       mutation,
+			responses:  []cannedResponse{{status: http.StatusBadGateway}, ok},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusBadGateway,
+			waits:      []time.Duration{},
+		},
+		{
+			name: "graphql mutations are retried when rate limited",
+			body: mutation,
+			responses: []cannedResponse{
+				{status: http.StatusOK, body: `{"errors":[{"type":"RATE_LIMITED","message":"API rate limit exceeded"}]}`},
+				{status: http.StatusForbidden, headers: map[string]string{"Retry-After": "7"}},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{time.Second, 7 * time.Second},
+		},
+		{
+			name:       "rest gets are retried on server errors",
+			method:     http.MethodGet,
+			path:       "/repos/owner/repo/pulls",
+			responses:  []cannedResponse{{status: http.StatusGatewayTimeout}, ok},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{time.Second},
+		},
+		{
+			name:       "rest posts aren't retried on server errors",
+			path:       "/repos/owner/repo/pulls",
+			body:       `{"title":"title"}`,
+			responses:  []cannedResponse{{status: http.StatusServiceUnavailable}, ok},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusServiceUnavailable,
+			waits:      []time.Duration{},
+		},
+		{
+			name:       "rest posts are retried when rate limited",
+			path:       "/repos/owner/repo/pulls",
+			body:       `{"title":"title"}`,
+			responses:  []cannedResponse{{status: http.StatusTooManyRequests}, ok},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusOK,
+			waits:      []time.Duration{time.Second},
+		},
+		{
+			name: "forbidden without rate limiting isn't retried",
+			responses: []cannedResponse{
+				{status: http.StatusForbidden, body: `{"message":"Resource not accessible by integration"}`},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusForbidden,
+			waits:      []time.Duration{},
+		},
+		{
+			name:       "gives up after max retries",
+			responses:  []cannedResponse{{status: http.StatusBadGateway}},
+			maxRetries: 2,
+			budget:     120,
+			status:     http.StatusBadGateway,
+			waits:      []time.Duration{time.Second, 2 * time.Second},
+		},
+		{
+			name: "gives up when the wait would exceed the budget",
+			responses: []cannedResponse{
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "60"}},
+				{status: http.StatusTooManyRequests, headers: map[string]string{"Retry-After": "61"}},
+				ok,
+			},
+			maxRetries: 5,
+			budget:     120,
+			status:     http.StatusTooManyRequests,
+			waits:      []time.Duration{60 * time.Second},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			method := cmp.Or(tc.method, http.MethodPost)
+			path := cmp.Or(tc.path, "/graphql")
+			body := tc.body
+			if tc.body == "" && tc.method == "" {
+				body = query
+			}
+			server, bodies := retryTestServer(t, tc.responses...)
+			transport, waits := testRetryTransport(tc.maxRetries, tc.budget)
+
+			req, err := http.NewRequest(method, server.URL+path, strings.NewReader(body))
+			require.NoError(t, err)
+			resp, err := (&http.Client{Transport: transport}).Do(req)
+			require.NoError(t, err)
+			require.Equal(t, tc.status, resp.StatusCode, strconv.Itoa(resp.StatusCode))
+			require.Equal(t, tc.waits, *waits)
+
+			// The body is resent with every attempt
+			require.Len(t, *bodies, len(tc.waits)+1)
+			for _, sent := range *bodies {
+				require.Equal(t, body, sent)
+			}
+		})
+	}
+}
+
+func TestRetryTransportBackoffIsCapped(t *testing.T) {
+	transport, _ := testRetryTransport(0, 0)
+	require.Equal(t, retryMaxDelay, transport.backoff(5))
+	require.Equal(t, retryMaxDelay, transport.backoff(100))
+
+	transport.jitter = func() float64 { return 0 }
+	require.Equal(t, 4*time.Second, transport.backoff(3))
+}
@@ -7,7 +7,6 @@ import (
 	"os"
 	"strings"
 
-	"github.com/ejoffe/rake"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/config/config_parser"
 	"github.com/ejoffe/spr/github/githubclient/genqlient"
@@ -20,7 +19,7 @@ const (
 	promptCycle  = 25
 )
 
-func (c *client) MaybeStar(ctx context.Context, cfg *config.Config) {
+func (c *client) MaybeStar(ctx context.Context, cfg *config.Config) error {
 	if !cfg.State.Stargazer && cfg.State.RunCount%promptCycle == 0 {
 		starred, err := c.isStar(ctx)
 		if err != nil {
@@ -31,8 +30,10 @@ func (c *client) MaybeStar(ctx context.Context, cfg *config.Config) {
 			line = strings.TrimSpace(line)
 			if line != "n" {
 				cfg.State.Stargazer = true
-				rake.LoadSources(cfg.State,
-					rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+				err = config_parser.WriteState(cfg)
+				if err != nil {
+					return err
+				}
 				fmt.Println("Thank You! Happy Coding!")
 			}
 		}
@@ -40,8 +41,10 @@ func (c *client) MaybeStar(ctx context.Context, cfg *config.Config) {
 		if starred {
 			log.Debug().Bool("stargazer", true).Msg("MaybeStar")
 			cfg.State.Stargazer = true
-			rake.LoadSources(cfg.State,
-				rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+			err = config_parser.WriteState(cfg)
+			if err != nil {
+				return err
+			}
 		} else {
 			log.Debug().Bool("stargazer", false).Msg("MaybeStar")
 			fmt.Print("enjoying git spr? add a GitHub star? [Y/n]:")
@@ -50,14 +53,20 @@ func (c *client) MaybeStar(ctx context.Context, cfg *config.Config) {
 			line = strings.TrimSpace(line)
 			if line != "n" {
 				log.Debug().Msg("MaybeStar : adding star")
-				c.addStar(ctx)
+				err := c.addStar(ctx)
+				if err != nil {
+					return err
+				}
 				cfg.State.Stargazer = true
-				rake.LoadSources(cfg.State,
-					rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+				err = config_parser.WriteState(cfg)
+				if err != nil {
+					return err
+				}
 				fmt.Println("Thank You! Happy Coding!")
 			}
 		}
 	}
+	return nil
 }
 
 func (c *client) isStar(ctx context.Context) (bool, error) {
@@ -91,12 +100,17 @@ func (c *client) isStar(ctx context.Context) (bool, error) {
 	}
 }
 
-func (c *client) addStar(ctx context.Context) {
+func (c *client) addStar(ctx context.Context) error {
 	resp, err := genqlient.StarGetRepo(ctx, c.gclient, sprRepoOwner, sprRepoName)
-	check(err)
+	if err != nil {
+		return fmt.Errorf("getting the spr repository %w", err)
+	}
 
-	genqlient.StarAdd(ctx, c.gclient, genqlient.AddStarInput{
+	_, err = genqlient.StarAdd(ctx, c.gclient, genqlient.AddStarInput{
 		StarrableId: resp.Repository.Id,
 	})
-	check(err)
+	if err != nil {
+		return fmt.Errorf("starring the spr repository %w", err)
+	}
+	return nil
 }
@@ -5,10 +5,8 @@ import (
 	"fmt"
 
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github"
-	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/ejoffe/spr/mock"
-	gogithub "github.com/google/go-github/v69/github"
 )
 
 const (
@@ -24,35 +22,35 @@ func NewMockClient(expectations *mock.Expectations) *MockClient {
 }
 
 type MockClient struct {
-	Info         *github.GitHubInfo
+	Info         *hosting.Info
 	expectations *mock.Expectations
 	Synchronized bool // When true code is executed without goroutines. Allows test to be deterministic
 }
 
-func (c *MockClient) GetInfo(ctx context.Context, gitcmd git.GitInterface) *github.GitHubInfo {
+func (c *MockClient) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
 	fmt.Printf("HUB: GetInfo\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op: mock.GetInfoOP,
 	})
-	return c.Info
+	return c.Info, nil
 }
 
-func (c *MockClient) GetAssignableUsers(ctx context.Context) []github.RepoAssignee {
+func (c *MockClient) GetAssignableUsers(ctx context.Context) ([]hosting.RepoAssignee, error) {
 	fmt.Printf("HUB: GetAssignableUsers\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op: mock.GetAssignableUsersOP,
 	})
-	return []github.RepoAssignee{
+	return []hosting.RepoAssignee{
 		{
 			ID:    NobodyUserID,
 			Login: NobodyLogin,
 			Name:  "No Body",
 		},
-	}
+	}, nil
 }
 
-func (c *MockClient) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface, info *github.GitHubInfo,
-	commit git.Commit, prevCommit *git.Commit) *github.PullRequest {
+func (c *MockClient) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface, info *hosting.Info,
+	commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
 	fmt.Printf("HUB: CreatePullRequest\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:     mock.CreatePullRequestOP,
@@ -62,22 +60,22 @@ func (c *MockClient) CreatePullRequest(ctx context.Context, gitcmd git.GitInterf
 
 	// TODO - don't hardcode ID and Number
 	// TODO - set FromBranch and ToBranch correctly
-	return &github.PullRequest{
+	return &hosting.PullRequest{
 		Id:         "001",
 		DatabaseId: "001",
 		Number:     1,
 		Commit:     commit,
 		Title:      commit.Subject,
-		MergeStatus: github.PullRequestMergeStatus{
-			ChecksPass:     github.CheckStatusPass,
+		MergeStatus: hosting.PullRequestMergeStatus{
+			ChecksPass:     hosting.CheckStatusPass,
 			ReviewApproved: true,
 			NoConflicts:    true,
 			Stacked:        true,
 		},
-	}
+	}, nil
 }
 
-func (c *MockClient) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull genqlient.CreatePullRequestInput) (string, int, error) {
+func (c *MockClient) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
 	fmt.Printf("HUB: CreatePullRequest2\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op: mock.CreatePullRequestOP,
@@ -88,31 +86,34 @@ func (c *MockClient) CreatePullRequest2(ctx context.Context, owner string, repoN
 	return "1", 1, nil
 }
 
-func (c *MockClient) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*github.PullRequest, pr *github.PullRequest, commit git.Commit, prevCommit *git.Commit) {
+func (c *MockClient) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest, pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
 	fmt.Printf("HUB: UpdatePullRequest\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:     mock.UpdatePullRequestOP,
 		Commit: commit,
 		Prev:   prevCommit,
 	})
+	return nil
 }
 
-func (c *MockClient) AddReviewers(ctx context.Context, pr *github.PullRequest, userIDs []string) {
+func (c *MockClient) AddReviewers(ctx context.Context, pr *hosting.PullRequest, userIDs []string) error {
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:      mock.AddReviewersOP,
 		UserIDs: userIDs,
 	})
+	return nil
 }
 
-func (c *MockClient) CommentPullRequest(ctx context.Context, pr *github.PullRequest, comment string) {
+func (c *MockClient) CommentPullRequest(ctx context.Context, pr *hosting.PullRequest, comment string) error {
 	fmt.Printf("HUB: CommentPullRequest\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:     mock.CommentPullRequestOP,
 		Commit: pr.Commit,
 	})
+	return nil
 }
 
-func (c *MockClient) EditPullRequest2(ctx context.Context, owner string, repo string, number int, pull *gogithub.PullRequest) error {
+func (c *MockClient) EditPullRequest2(ctx context.Context, owner string, repo string, number int, edit hosting.PullRequestEdit) error {
 	fmt.Printf("HUB: EditPullRequest2\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op: mock.EditPullRequestOP,
@@ -121,7 +122,7 @@ func (c *MockClient) EditPullRequest2(ctx context.Context, owner string, repo st
 }
 
 func (c *MockClient) MergePullRequest(ctx context.Context,
-	pr *github.PullRequest, mergeMethod genqlient.PullRequestMergeMethod) error {
+	pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
 	fmt.Printf("HUB: MergePullRequest, method=%q\n", mergeMethod)
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:          mock.MergePullRequestOP,
@@ -131,7 +132,7 @@ func (c *MockClient) MergePullRequest(ctx context.Context,
 	return nil
 }
 
-func (c *MockClient) ClosePullRequest(ctx context.Context, pr *github.PullRequest) error {
+func (c *MockClient) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
 	fmt.Printf("HUB: ClosePullRequest\n")
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op:     mock.ClosePullRequestOP,
@@ -141,7 +142,7 @@ func (c *MockClient) ClosePullRequest(ctx context.Context, pr *github.PullReques
 	return nil
 }
 
-func (c *MockClient) PullRequestsAndStatus(ctx_ context.Context, repo_owner string, repo_name string) (*genqlient.PullRequestsAndStatusResponse, error) {
+func (c *MockClient) PullRequestsAndStatus(ctx_ context.Context, repo_owner string, repo_name string) (*hosting.Repository, error) {
 	c.expectations.GithubApi(mock.GithubExpectation{
 		Op: mock.ClosePullRequestAndStatusOP,
 	})
@@ -190,7 +191,7 @@ func (c *MockClient) ExpectCommentPullRequest(commit git.Commit) {
 	})
 }
 
-func (c *MockClient) ExpectMergePullRequest(commit git.Commit, mergeMethod genqlient.PullRequestMergeMethod) {
+func (c *MockClient) ExpectMergePullRequest(commit git.Commit, mergeMethod hosting.MergeMethod) {
 	c.expectations.ExpectGitHub(mock.GithubExpectation{
 		Op:          mock.MergePullRequestOP,
 		Commit:      commit,
@@ -0,0 +1,399 @@
+package fakegitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+
+	"github.com/ejoffe/spr/git/fakeremote"
+)
+
+func (s *Server) serveUser(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, userJSON(s.user(UserId)))
+}
+
+func (s *Server) serveProject(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+	writeJSON(w, http.StatusOK, object{
+		"id":                  ProjectId,
+		"path_with_namespace": Owner + "/" + Name,
+		"default_branch":      Branch,
+		"forked_from_project": nil,
+	})
+}
+
+func (s *Server) serveMembers(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+	members := []object{}
+	for _, user := range s.Users {
+		members = append(members, userJSON(user))
+	}
+	writePage(w, r, members, s.PageSize)
+}
+
+// serveListMergeRequests implements GET /projects/:id/merge_requests with the state and author_id filters
+func (s *Server) serveListMergeRequests(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+	state := r.URL.Query().Get("state")
+	authorId := r.URL.Query().Get("author_id")
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mrs := []object{}
+	// GitLab lists the newest merge requests first
+	for _, mr := range slices.Backward(s.mergeRequests) {
+		if state != "" && state != "all" && mr.State != state {
+			continue
+		}
+		if authorId != "" && authorId != strconv.Itoa(mr.AuthorId) {
+			continue
+		}
+		mrs = append(mrs, s.mergeRequestJSON(mr))
+	}
+	writePage(w, r, mrs, s.PageSize)
+}
+
+func (s *Server) serveCreateMergeRequest(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+	var create struct {
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		Title        string `json:"title"`
+		Description  string `json:"description"`
+	}
+	if !decode(w, r, &create) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if create.Title == "" {
+		writeJSON(w, http.StatusBadRequest, object{"message": "title is missing"})
+		return
+	}
+	if _, ok := s.remote.Resolve(create.SourceBranch); !ok {
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": []string{"Source branch does not exist"}})
+		return
+	}
+	if _, ok := s.remote.Resolve(create.TargetBranch); !ok {
+		writeJSON(w, http.StatusUnprocessableEntity, object{"message": []string{"Target branch does not exist"}})
+		return
+	}
+	for _, mr := range s.mergeRequests {
+		if mr.State == StateOpened && mr.SourceBranch == create.SourceBranch {
+			writeJSON(w, http.StatusConflict, object{"message": []string{
+				fmt.Sprintf("Another open merge request already exists for this source branch: !%d", mr.Iid),
+			}})
+			return
+		}
+	}
+
+	iid := len(s.mergeRequests) + 1
+	mr := &MergeRequest{
+		Id:           1000 + iid,
+		Iid:          iid,
+		Title:        create.Title,
+		Description:  create.Description,
+		SourceBranch: create.SourceBranch,
+		TargetBranch: create.TargetBranch,
+		AuthorId:     UserId,
+		State:        StateOpened,
+		Approved:     true,
+	}
+	s.mergeRequests = append(s.mergeRequests, mr)
+	writeJSON(w, http.StatusCreated, s.mergeRequestJSON(mr))
+}
+
+func (s *Server) serveGetMergeRequest(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, s.mergeRequestJSON(mr))
+}
+
+// serveUpdateMergeRequest implements PUT /projects/:id/merge_requests/:iid. Closing a merged merge request is a no-op.
+func (s *Server) serveUpdateMergeRequest(w http.ResponseWriter, r *http.Request) {
+	var update struct {
+		Title        *string `json:"title"`
+		Description  *string `json:"description"`
+		TargetBranch *string `json:"target_branch"`
+		StateEvent   *string `json:"state_event"`
+		ReviewerIds  []int   `json:"reviewer_ids"`
+	}
+	if !checkProject(w, r) || !decode(w, r, &update) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+
+	if update.TargetBranch != nil {
+		if _, ok := s.remote.Resolve(*update.TargetBranch); !ok {
+			writeJSON(w, http.StatusUnprocessableEntity, object{"message": []string{"Target branch does not exist"}})
+			return
+		}
+		mr.TargetBranch = *update.TargetBranch
+	}
+	if update.Title != nil {
+		mr.Title = *update.Title
+	}
+	if update.Description != nil {
+		mr.Description = *update.Description
+	}
+	if update.ReviewerIds != nil {
+		mr.ReviewerIds = update.ReviewerIds
+	}
+	if update.StateEvent != nil {
+		switch {
+		case *update.StateEvent == "close" && mr.State == StateOpened:
+			mr.State = StateClosed
+		case *update.StateEvent == "reopen" && mr.State == StateClosed:
+			mr.State = StateOpened
+		}
+	}
+
+	writeJSON(w, http.StatusOK, s.mergeRequestJSON(mr))
+}
+
+// serveCommits lists the commits of the merge request, newest first
+func (s *Server) serveCommits(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	commits, err := s.remote.Commits(mr.TargetBranch, mr.SourceBranch)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, object{"message": err.Error()})
+		return
+	}
+
+	items := []object{}
+	for _, c := range slices.Backward(commits) {
+		message := c.MessageHeadline
+		if c.MessageBody != "" {
+			message += "\n\n" + c.MessageBody
+		}
+		items = append(items, object{
+			"id":       c.Oid,
+			"short_id": c.Oid[:8],
+			"title":    c.MessageHeadline,
+			"message":  message,
+		})
+	}
+	writePage(w, r, items, s.PageSize)
+}
+
+func (s *Server) serveApprovals(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	writeJSON(w, http.StatusOK, object{"iid": mr.Iid, "approved": mr.Approved})
+}
+
+func (s *Server) servePipelines(w http.ResponseWriter, r *http.Request) {
+	if !checkProject(w, r) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	pipelines := []object{}
+	if mr.PipelineStatus != "" {
+		pipelines = append(pipelines, object{"id": mr.Id, "status": mr.PipelineStatus})
+	}
+	writeJSON(w, http.StatusOK, pipelines)
+}
+
+// serveMerge merges the merge request straight away, merge_when_pipeline_succeeds is ignored as there is nothing to
+// wait for in the fake.
+func (s *Server) serveMerge(w http.ResponseWriter, r *http.Request) {
+	var merge struct {
+		Sha    string `json:"sha"`
+		Squash bool   `json:"squash"`
+	}
+	if !checkProject(w, r) || !decode(w, r, &merge) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	if mr.State != StateOpened || !mr.Approved {
+		writeJSON(w, http.StatusMethodNotAllowed, object{"message": "405 Method Not Allowed"})
+		return
+	}
+	if mr.HasConflicts {
+		writeJSON(w, http.StatusNotAcceptable, object{"message": "Branch cannot be merged"})
+		return
+	}
+	if merge.Sha != "" {
+		head, _ := s.remote.Resolve(mr.SourceBranch)
+		if head != merge.Sha {
+			writeJSON(w, http.StatusConflict, object{"message": "SHA does not match HEAD of source branch: " + head})
+			return
+		}
+	}
+
+	method := s.MergeMethod
+	message := fmt.Sprintf("Merge branch '%s' into '%s'\n\n%s\n\nSee merge request %s/%s!%d",
+		mr.SourceBranch, mr.TargetBranch, mr.Title, Owner, Name, mr.Iid)
+	if merge.Squash {
+		method = fakeremote.MethodSquash
+		message = mr.Title
+	}
+	_, err := s.remote.Merge(mr.TargetBranch, mr.SourceBranch, method, message)
+	if err != nil {
+		writeJSON(w, http.StatusNotAcceptable, object{"message": "Branch cannot be merged: " + err.Error()})
+		return
+	}
+	mr.State = StateMerged
+	writeJSON(w, http.StatusOK, s.mergeRequestJSON(mr))
+}
+
+func (s *Server) serveCreateNote(w http.ResponseWriter, r *http.Request) {
+	var note struct {
+		Body string `json:"body"`
+	}
+	if !checkProject(w, r) || !decode(w, r, &note) {
+		return
+	}
+
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.lookup(w, r)
+	if mr == nil {
+		return
+	}
+	mr.Notes = append(mr.Notes, note.Body)
+	writeJSON(w, http.StatusCreated, object{"id": len(mr.Notes), "body": note.Body})
+}
+
+// checkProject writes a 404 if the request isn't for the fake project, which can be referred to by id or path
+func checkProject(w http.ResponseWriter, r *http.Request) bool {
+	project := r.PathValue("project")
+	if project != strconv.Itoa(ProjectId) && project != Owner+"/"+Name {
+		writeJSON(w, http.StatusNotFound, object{"message": "404 Project Not Found"})
+		return false
+	}
+	return true
+}
+
+// lookup returns the merge request of the request or writes a 404 if there isn't one
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) *MergeRequest {
+	iid, err := strconv.Atoi(r.PathValue("iid"))
+	if err == nil {
+		if mr := s.mergeRequestByIid(iid); mr != nil {
+			return mr
+		}
+	}
+	writeJSON(w, http.StatusNotFound, object{"message": "404 Not found"})
+	return nil
+}
+
+func decode(w http.ResponseWriter, r *http.Request, v any) bool {
+	err := json.NewDecoder(r.Body).Decode(v)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, object{"error": "invalid JSON body"})
+		return false
+	}
+	return true
+}
+
+// writePage writes the page of items selected by the page and per_page parameters.
+// Like GitLab, the next page is given in the X-Next-Page header.
+func writePage(w http.ResponseWriter, r *http.Request, items []object, maxPerPage int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	perPage, err := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if err != nil || perPage < 1 {
+		perPage = 20
+	}
+	perPage = min(perPage, maxPerPage)
+
+	start := min((page-1)*perPage, len(items))
+	end := min(start+perPage, len(items))
+	if end < len(items) {
+		w.Header().Set("X-Next-Page", strconv.Itoa(page+1))
+	}
+	writeJSON(w, http.StatusOK, items[start:end])
+}
+
+func userJSON(user User) object {
+	return object{"id": user.Id, "username": user.Username, "name": user.Name}
+}
+
+func (s *Server) mergeRequestJSON(mr *MergeRequest) object {
+	sha, _ := s.remote.Resolve(mr.SourceBranch)
+	reviewers := []object{}
+	for _, id := range mr.ReviewerIds {
+		reviewers = append(reviewers, userJSON(s.user(id)))
+	}
+	return object{
+		"id":                mr.Id,
+		"iid":               mr.Iid,
+		"project_id":        ProjectId,
+		"title":             mr.Title,
+		"description":       mr.Description,
+		"state":             mr.State,
+		"source_branch":     mr.SourceBranch,
+		"target_branch":     mr.TargetBranch,
+		"source_project_id": ProjectId,
+		"target_project_id": ProjectId,
+		"author":            userJSON(s.user(mr.AuthorId)),
+		"reviewers":         reviewers,
+		"has_conflicts":     mr.HasConflicts,
+		"sha":               sha,
+	}
+}
@@ -0,0 +1,200 @@
+// Package fakegitlab is an in-process stand-in for the parts of the GitLab REST API that spr uses.
+// It is backed by a local bare git repository that acts as the GitLab remote, so the full update and merge flows can be
+// exercised in `go test` without network access.
+package fakegitlab
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git/fakeremote"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	// Owner is the namespace of the fake project
+	Owner = "spr-owner"
+	// Name is the name of the fake project
+	Name = "spr-repo"
+	// Login is the username of the authenticated user
+	Login = "spr-user"
+	// Branch is the default branch of the fake project
+	Branch = "main"
+	// ProjectId is the id of the fake project
+	ProjectId = 42
+	// UserId is the id of the authenticated user
+	UserId = 1
+)
+
+// Merge request states
+const (
+	StateOpened = "opened"
+	StateClosed = "closed"
+	StateMerged = "merged"
+)
+
+// MergeRequest is the server side state of a merge request.
+type MergeRequest struct {
+	Id           int
+	Iid          int
+	Title        string
+	Description  string
+	SourceBranch string
+	TargetBranch string
+	AuthorId     int
+	State        string
+
+	// HasConflicts, Approved and PipelineStatus are reported for the merge request.
+	// New merge requests are approved and don't have a pipeline so they can be merged straight away.
+	HasConflicts   bool
+	Approved       bool
+	PipelineStatus string
+
+	ReviewerIds []int
+	Notes       []string
+}
+
+// User is a member of the fake project
+type User struct {
+	Id       int
+	Username string
+	Name     string
+}
+
+// Server is a fake GitLab server for a single project.
+type Server struct {
+	// RemotePath is the bare git repository that acts as the GitLab remote.
+	RemotePath string
+	// PageSize is the maximum number of items returned in a page of any list.
+	PageSize int
+	// Users are the members of the project.
+	Users []User
+	// MergeMethod is how the project merges merge requests that aren't squashed, fakeremote.MethodMerge creates a
+	// merge commit and fakeremote.MethodRebase fast forwards.
+	MergeMethod string
+
+	remote *fakeremote.Remote
+	server *httptest.Server
+
+	lock          sync.Mutex
+	mergeRequests []*MergeRequest
+}
+
+// New starts a fake GitLab server along with its bare git remote. Both are cleaned up when the test completes.
+func New(t *testing.T) *Server {
+	t.Helper()
+
+	s := &Server{
+		RemotePath:  filepath.Join(t.TempDir(), Name+".git"),
+		PageSize:    100,
+		Users:       []User{{Id: UserId, Username: Login, Name: "Spr User"}},
+		MergeMethod: fakeremote.MethodMerge,
+	}
+	var err error
+	s.remote, err = fakeremote.New(s.RemotePath, Branch, "GitLab", "noreply@gitlab.com")
+	require.NoError(t, err)
+
+	s.server = httptest.NewServer(s.handler())
+	t.Cleanup(s.server.Close)
+
+	return s
+}
+
+// URL returns the base url of the server
+func (s *Server) URL() string {
+	return s.server.URL
+}
+
+// Configure points the repo config at the fake server and its project.
+func (s *Server) Configure(cfg *config.Config) {
+	cfg.Repo.HostingProvider = config.HostingProviderGitLab
+	cfg.Repo.GitHubHost = "gitlab.com"
+	cfg.Repo.GitHubRepoOwner = Owner
+	cfg.Repo.GitHubRepoName = Name
+	cfg.Repo.GitHubApiUrl = s.server.URL + "/api/v4"
+}
+
+// MergeRequests returns a copy of every merge request (in any state) ordered by iid.
+func (s *Server) MergeRequests() []MergeRequest {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mrs := []MergeRequest{}
+	for _, mr := range s.mergeRequests {
+		cp := *mr
+		cp.ReviewerIds = slices.Clone(mr.ReviewerIds)
+		cp.Notes = slices.Clone(mr.Notes)
+		mrs = append(mrs, cp)
+	}
+	return mrs
+}
+
+// ModifyMergeRequest calls fn with the merge request so tests can change things like its approval or pipeline.
+func (s *Server) ModifyMergeRequest(iid int, fn func(mr *MergeRequest)) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	mr := s.mergeRequestByIid(iid)
+	if mr == nil {
+		return fmt.Errorf("no merge request !%d", iid)
+	}
+	fn(mr)
+	return nil
+}
+
+func (s *Server) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v4/user", s.serveUser)
+	mux.HandleFunc("GET /api/v4/projects/{project}", s.serveProject)
+	mux.HandleFunc("GET /api/v4/projects/{project}/members/all", s.serveMembers)
+	mux.HandleFunc("GET /api/v4/projects/{project}/merge_requests", s.serveListMergeRequests)
+	mux.HandleFunc("POST /api/v4/projects/{project}/merge_requests", s.serveCreateMergeRequest)
+	mux.HandleFunc("GET /api/v4/projects/{project}/merge_requests/{iid}", s.serveGetMergeRequest)
+	mux.HandleFunc("PUT /api/v4/projects/{project}/merge_requests/{iid}", s.serveUpdateMergeRequest)
+	mux.HandleFunc("GET /api/v4/projects/{project}/merge_requests/{iid}/commits", s.serveCommits)
+	mux.HandleFunc("GET /api/v4/projects/{project}/merge_requests/{iid}/approvals", s.serveApprovals)
+	mux.HandleFunc("GET /api/v4/projects/{project}/merge_requests/{iid}/pipelines", s.servePipelines)
+	mux.HandleFunc("PUT /api/v4/projects/{project}/merge_requests/{iid}/merge", s.serveMerge)
+	mux.HandleFunc("POST /api/v4/projects/{project}/merge_requests/{iid}/notes", s.serveCreateNote)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") == "" {
+			writeJSON(w, http.StatusUnauthorized, object{"message": "401 Unauthorized"})
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) mergeRequestByIid(iid int) *MergeRequest {
+	for _, mr := range s.mergeRequests {
+		if mr.Iid == iid {
+			return mr
+		}
+	}
+	return nil
+}
+
+func (s *Server) user(id int) User {
+	for _, user := range s.Users {
+		if user.Id == id {
+			return user
+		}
+	}
+	return User{Id: id}
+}
+
+type object = map[string]any
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,246 @@
+package fakegitlab
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ejoffe/spr/bl/gitapi"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/gitlab/gitlabclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+// pushCommit adds a commit on top of parent to the remote as the given branch
+func pushCommit(t *testing.T, s *Server, parent string, branch string, message string) {
+	t.Helper()
+
+	base, ok := s.remote.Resolve(parent)
+	require.True(t, ok)
+	tree, err := s.remote.Git(s.RemotePath, "mktree")
+	require.NoError(t, err)
+	oid, err := s.remote.Git(s.RemotePath, "commit-tree", tree, "-p", base, "-m", message)
+	require.NoError(t, err)
+	_, err = s.remote.Git(s.RemotePath, "update-ref", "refs/heads/"+branch, oid)
+	require.NoError(t, err)
+}
+
+func TestMergeRequestsArePaged(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	s.PageSize = 1
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := gitlabclient.NewGitLabClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "first", "first commit")
+	pushCommit(t, s, "first", "second", "second commit\n\nsecond body")
+	pushCommit(t, s, "second", "third", "third commit")
+
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "first and second",
+	})
+	require.NoError(t, err)
+	require.Equal(t, 1, number)
+	_, number, err = client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: "second",
+		HeadRefName: "third",
+		Title:       "third",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+	require.Equal(t, 2, number)
+
+	repo, err := client.PullRequestsAndStatus(ctx, Owner, Name)
+	require.NoError(t, err)
+	require.Equal(t, Login, repo.Viewer)
+	require.Equal(t, "42", repo.Id)
+	prs := repo.PullRequests
+	require.Len(t, prs, 2)
+
+	// GitLab lists the newest merge requests first
+	require.Equal(t, "third", prs[0].HeadRefName)
+	require.Equal(t, "Draft: third", prs[0].Title)
+	require.Len(t, prs[0].Commits, 1)
+
+	require.Equal(t, "second", prs[1].HeadRefName)
+	require.Equal(t, Branch, prs[1].BaseRefName)
+	require.Equal(t, 1, prs[1].Number)
+	require.Equal(t, "42", prs[1].BaseRepositoryId)
+	require.Equal(t, Login, prs[1].Author)
+	require.Equal(t, hosting.MergeableStateMergeable, prs[1].Mergeable)
+	require.Equal(t, hosting.ReviewDecisionApproved, prs[1].ReviewDecision)
+	require.Equal(t, hosting.CheckStateNone, prs[1].CheckState)
+	require.Len(t, prs[1].Commits, 2)
+	require.Equal(t, "first commit", prs[1].Commits[0].MessageHeadline)
+	require.Equal(t, "second commit", prs[1].Commits[1].MessageHeadline)
+	require.Equal(t, "second body", prs[1].Commits[1].MessageBody)
+}
+
+func TestMergeRequestStatus(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := gitlabclient.NewGitLabClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "feature", "feature commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "feature",
+		Title:       "feature",
+	})
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		pipelineStatus string
+		state          hosting.CheckState
+	}{
+		{"success", hosting.CheckStateSuccess},
+		{"running", hosting.CheckStatePending},
+		{"failed", hosting.CheckStateFailure},
+	} {
+		require.NoError(t, s.ModifyMergeRequest(number, func(mr *MergeRequest) {
+			mr.HasConflicts = true
+			mr.Approved = false
+			mr.PipelineStatus = tc.pipelineStatus
+		}))
+
+		repo, err := client.PullRequestsAndStatus(ctx, Owner, Name)
+		require.NoError(t, err)
+		pr := repo.PullRequests[0]
+		require.Equal(t, hosting.MergeableStateConflicting, pr.Mergeable)
+		require.Equal(t, hosting.ReviewDecisionNone, pr.ReviewDecision)
+		require.Equal(t, tc.state, pr.CheckState, tc.pipelineStatus)
+	}
+}
+
+func TestEditMergeRequest(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := gitlabclient.NewGitLabClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "first", "first commit")
+	pushCommit(t, s, "first", "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: "first",
+		HeadRefName: "second",
+		Title:       "second",
+	})
+	require.NoError(t, err)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{
+		Title:       ptrutils.Ptr("new title"),
+		Body:        ptrutils.Ptr("new body"),
+		BaseRefName: ptrutils.Ptr(Branch),
+		Draft:       ptrutils.Ptr(true),
+	})
+	require.NoError(t, err)
+	mr := s.MergeRequests()[0]
+	require.Equal(t, "Draft: new title", mr.Title)
+	require.Equal(t, "new body", mr.Description)
+	require.Equal(t, Branch, mr.TargetBranch)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{State: ptrutils.Ptr(hosting.PullRequestStateClosed)})
+	require.NoError(t, err)
+	require.Equal(t, StateClosed, s.MergeRequests()[0].State)
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{State: ptrutils.Ptr(hosting.PullRequestStateOpen)})
+	require.NoError(t, err)
+	require.Equal(t, StateOpened, s.MergeRequests()[0].State)
+
+	err = client.EditPullRequest2(ctx, Owner, Name, number, hosting.PullRequestEdit{
+		BaseRefName: ptrutils.Ptr("missing"),
+	})
+	require.ErrorContains(t, err, "Target branch does not exist")
+}
+
+func TestUpdateMergeRequestKeepsDraft(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := gitlabclient.NewGitLabClient(ctx, nil, cfg)
+	require.NoError(t, err)
+
+	pushCommit(t, s, Branch, "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "second",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+
+	commit := git.Commit{CommitID: "00000002", Subject: "second commit"}
+	pr := &hosting.PullRequest{Number: number, Title: s.MergeRequests()[0].Title, FromBranch: "second"}
+	err = client.UpdatePullRequest(ctx, nil, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Draft: second commit", s.MergeRequests()[0].Title)
+
+	// Drafts are kept when they are created as drafts
+	cfg.User.CreateDraftPRs = true
+	pr.Title = "second"
+	err = client.UpdatePullRequest(ctx, nil, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Draft: second commit", s.MergeRequests()[0].Title)
+
+	cfg.User.CreateDraftPRs = false
+	err = client.UpdatePullRequest(ctx, nil, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "second commit", s.MergeRequests()[0].Title)
+}
+
+func TestGitApiUpdatePullRequestKeepsDraft(t *testing.T) {
+	ctx := context.Background()
+	s := New(t)
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+
+	cfg := config.DefaultConfig()
+	s.Configure(cfg)
+	client, err := gitlabclient.NewGitLabClient(ctx, nil, cfg)
+	require.NoError(t, err)
+	gapi := gitapi.New(cfg, nil, client)
+
+	pushCommit(t, s, Branch, "second", "second commit")
+	_, number, err := client.CreatePullRequest2(ctx, Owner, Name, hosting.NewPullRequest{
+		BaseRefName: Branch,
+		HeadRefName: "second",
+		Title:       "second",
+		Draft:       true,
+	})
+	require.NoError(t, err)
+
+	commit := git.Commit{CommitID: "00000002", Subject: "second commit"}
+	pr := &hosting.PullRequest{Number: number, FromBranch: "second"}
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Draft: second commit", s.MergeRequests()[0].Title)
+
+	// A merge request that isn't a draft only becomes one when drafts are created
+	require.NoError(t, s.ModifyMergeRequest(number, func(mr *MergeRequest) { mr.Title = "second" }))
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "second commit", s.MergeRequests()[0].Title)
+
+	cfg.User.CreateDraftPRs = true
+	err = gapi.UpdatePullRequest(ctx, []*hosting.PullRequest{pr}, pr, commit, nil)
+	require.NoError(t, err)
+	require.Equal(t, "Draft: second commit", s.MergeRequests()[0].Title)
+}
@@ -0,0 +1,186 @@
+package gitlabclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// The subset of the GitLab REST API (v4) resources used by spr
+
+type user struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+	Name     string `json:"name"`
+}
+
+type project struct {
+	Id                int      `json:"id"`
+	PathWithNamespace string   `json:"path_with_namespace"`
+	ForkedFromProject *project `json:"forked_from_project"`
+}
+
+type mergeRequest struct {
+	Id              int    `json:"id"`
+	Iid             int    `json:"iid"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	State           string `json:"state"`
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	SourceProjectId int    `json:"source_project_id"`
+	TargetProjectId int    `json:"target_project_id"`
+	Author          user   `json:"author"`
+	HasConflicts    bool   `json:"has_conflicts"`
+	Sha             string `json:"sha"`
+}
+
+type commit struct {
+	Id      string `json:"id"`
+	Title   string `json:"title"`
+	Message string `json:"message"`
+}
+
+type approvals struct {
+	Approved bool `json:"approved"`
+}
+
+type pipeline struct {
+	Id     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+type createMergeRequest struct {
+	SourceBranch    string `json:"source_branch"`
+	TargetBranch    string `json:"target_branch"`
+	TargetProjectId int    `json:"target_project_id,omitempty"`
+	Title           string `json:"title"`
+	Description     string `json:"description,omitempty"`
+}
+
+type updateMergeRequest struct {
+	Title        *string `json:"title,omitempty"`
+	Description  *string `json:"description,omitempty"`
+	TargetBranch *string `json:"target_branch,omitempty"`
+	StateEvent   *string `json:"state_event,omitempty"`
+	ReviewerIds  []int   `json:"reviewer_ids,omitempty"`
+}
+
+type acceptMergeRequest struct {
+	Sha                       string `json:"sha,omitempty"`
+	Squash                    bool   `json:"squash"`
+	MergeWhenPipelineSucceeds bool   `json:"merge_when_pipeline_succeeds"`
+}
+
+type note struct {
+	Body string `json:"body"`
+}
+
+// apiError is returned for responses with a non 2xx status
+type apiError struct {
+	method     string
+	path       string
+	statusCode int
+	message    string
+}
+
+func (e *apiError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s %s", e.method, e.path, e.statusCode, http.StatusText(e.statusCode), e.message)
+}
+
+// do sends the request to the api and decodes the JSON response into result (if it isn't nil).
+// It returns the next page from the X-Next-Page header which is empty on the last page.
+func (c *client) do(ctx context.Context, method string, path string, body any, result any) (string, error) {
+	var reqBody io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return "", fmt.Errorf("encoding %s %s %w", method, path, err)
+		}
+		reqBody = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.apiUrl+path, reqBody)
+	if err != nil {
+		return "", err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("reading %s %s %w", method, path, err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", &apiError{
+			method:     method,
+			path:       path,
+			statusCode: resp.StatusCode,
+			message:    errorMessage(data),
+		}
+	}
+
+	if result != nil {
+		err = json.Unmarshal(data, result)
+		if err != nil {
+			return "", fmt.Errorf("decoding %s %s %w", method, path, err)
+		}
+	}
+	return resp.Header.Get("X-Next-Page"), nil
+}
+
+// getAll fetches every page of a list resource
+func getAll[T any](ctx context.Context, c *client, path string) ([]T, error) {
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+
+	var all []T
+	page := "1"
+	for page != "" {
+		var items []T
+		var err error
+		page, err = c.do(ctx, http.MethodGet, path+separator+"per_page=100&page="+page, nil, &items)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, items...)
+	}
+	return all, nil
+}
+
+// errorMessage extracts the message from a GitLab error response, which is either a string or an object of field
+// errors
+func errorMessage(data []byte) string {
+	var resp struct {
+		Message any    `json:"message"`
+		Error   string `json:"error"`
+	}
+	if json.Unmarshal(data, &resp) != nil {
+		return strings.TrimSpace(string(data))
+	}
+	if resp.Error != "" {
+		return resp.Error
+	}
+	switch message := resp.Message.(type) {
+	case string:
+		return message
+	case nil:
+		return ""
+	default:
+		encoded, _ := json.Marshal(message)
+		return string(encoded)
+	}
+}
@@ -0,0 +1,551 @@
+// Package gitlabclient implements hosting.Client on top of the GitLab REST API so spr can manage stacks of
+// merge requests. Merge requests are reported in the same shape as GitHub pull requests: the iid is the pull request
+// number, the pipeline status is the check status and an approved merge request has an approved review.
+package gitlabclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ejoffe/spr/bl/concurrent"
+	"github.com/ejoffe/spr/bl/ptrutils"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/rs/zerolog/log"
+)
+
+type authedTransport struct {
+	key     string
+	wrapped http.RoundTripper
+}
+
+func (t *authedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req.Header.Set("PRIVATE-TOKEN", t.key)
+	return t.wrapped.RoundTrip(req)
+}
+
+const tokenHelpText = `
+No GitLab token found! Create a personal access token with the "api" scope
+at https://%s/-/user_settings/personal_access_tokens and set the GITLAB_TOKEN
+environment variable.
+`
+
+// draftPrefix marks a merge request as a draft
+const draftPrefix = "Draft: "
+
+func NewGitLabClient(ctx context.Context, git git.GitInterface, config *config.Config) (*client, error) {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf(tokenHelpText, config.Repo.GitHubHost)
+	}
+
+	transport := &authedTransport{
+		key:     token,
+		wrapped: githubclient.NewRetryTransport(http.DefaultTransport, config.User),
+	}
+	return &client{
+		config:     config,
+		git:        git,
+		apiUrl:     apiEndpoint(config.Repo),
+		httpClient: &http.Client{Transport: transport},
+		transport:  transport,
+	}, nil
+}
+
+// Record captures all of the GitLab API traffic to a cassette file at path.
+// The token is redacted from the cassette so it can be attached to bug reports.
+func (c *client) Record(path string) {
+	c.transport.wrapped = githubclient.NewRecordingTransport(c.transport.wrapped, path, c.transport.key)
+}
+
+// apiEndpoint returns the base url of the REST api for the configured host.
+func apiEndpoint(repoConfig *config.RepoConfig) string {
+	if repoConfig.GitHubApiUrl != "" {
+		return strings.TrimSuffix(repoConfig.GitHubApiUrl, "/")
+	}
+	return fmt.Sprintf("https://%s/api/v4", repoConfig.GitHubHost)
+}
+
+type client struct {
+	config     *config.Config
+	git        git.GitInterface
+	apiUrl     string
+	httpClient *http.Client
+	transport  *authedTransport
+}
+
+func (c *client) GetInfo(ctx context.Context, gitcmd git.GitInterface) (*hosting.Info, error) {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab fetch merge requests\n")
+	}
+
+	repo, err := c.PullRequestsAndStatus(ctx, c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName)
+	if err != nil {
+		return nil, fmt.Errorf("fetching merge requests %w", err)
+	}
+
+	localCommitStack := git.GetLocalCommitStack(c.config, gitcmd)
+	pullRequests, err := hosting.MatchPullRequestStack(c.config, c.config.Repo.GitHubBranch, localCommitStack, repo.PullRequests)
+	if err != nil {
+		return nil, err
+	}
+	for _, pr := range pullRequests {
+		if pr.Ready(c.config) {
+			pr.MergeStatus.Stacked = true
+		} else {
+			break
+		}
+	}
+
+	localBranch, err := gitcmd.GetLocalBranchShortName()
+	if err != nil {
+		return nil, fmt.Errorf("getting the local branch name %w", err)
+	}
+
+	info := &hosting.Info{
+		UserName:     repo.Viewer,
+		RepositoryID: repo.Id,
+		LocalBranch:  localBranch,
+		PullRequests: pullRequests,
+	}
+
+	log.Debug().Interface("Info", info).Msg("GetInfo")
+	return info, nil
+}
+
+func (c *client) GetAssignableUsers(ctx context.Context) ([]hosting.RepoAssignee, error) {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab get project members\n")
+	}
+
+	members, err := getAll[user](ctx, c, c.projectPath()+"/members/all")
+	if err != nil {
+		return nil, fmt.Errorf("get project members failed %w", checkUnauthorized(err))
+	}
+
+	users := []hosting.RepoAssignee{}
+	for _, member := range members {
+		users = append(users, hosting.RepoAssignee{
+			ID:    strconv.Itoa(member.Id),
+			Login: member.Username,
+			Name:  member.Name,
+		})
+	}
+	return users, nil
+}
+
+func (c *client) CreatePullRequest(ctx context.Context, gitcmd git.GitInterface,
+	info *hosting.Info, commit git.Commit, prevCommit *git.Commit) (*hosting.PullRequest, error) {
+
+	baseRefName := c.config.Repo.GitHubBranch
+	if prevCommit != nil {
+		baseRefName = git.BranchNameFromCommit(c.config, *prevCommit)
+	}
+	headRefName := git.BranchNameFromCommit(c.config, commit)
+
+	log.Debug().Interface("Commit", commit).
+		Str("FromBranch", headRefName).Str("ToBranch", baseRefName).
+		Msg("CreatePullRequest")
+
+	body, err := c.body(gitcmd, commit, info.PullRequests, nil)
+	if err != nil {
+		return nil, err
+	}
+	id, number, err := c.CreatePullRequest2(ctx, c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName,
+		hosting.NewPullRequest{
+			BaseRefName: baseRefName,
+			HeadRefName: headRefName,
+			Title:       commit.Subject,
+			Body:        body,
+			Draft:       c.config.User.CreateDraftPRs,
+		})
+	if err != nil {
+		return nil, fmt.Errorf("creating merge request for commit %s %w", commit.CommitHash, err)
+	}
+
+	pr := &hosting.PullRequest{
+		Id:         id,
+		DatabaseId: id,
+		Number:     number,
+		FromBranch: headRefName,
+		ToBranch:   baseRefName,
+		Commit:     commit,
+		Title:      commit.Subject,
+		MergeStatus: hosting.PullRequestMergeStatus{
+			ChecksPass: hosting.CheckStatusUnknown,
+		},
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab create %d : %s\n", pr.Number, pr.Title)
+	}
+
+	return pr, nil
+}
+
+// CreatePullRequest2 creates the merge request. A merge request from a fork is created on the fork and targets the
+// project, which is given by its id.
+func (c *client) CreatePullRequest2(ctx context.Context, owner string, repoName string, pull hosting.NewPullRequest) (string, int, error) {
+	title := pull.Title
+	if pull.Draft {
+		title = draftPrefix + title
+	}
+
+	create := createMergeRequest{
+		SourceBranch: pull.HeadRefName,
+		TargetBranch: pull.BaseRefName,
+		Title:        title,
+		Description:  pull.Body,
+	}
+	resource := projectPath(owner, repoName)
+	if pull.HeadOwner != "" {
+		projectId, err := strconv.Atoi(pull.RepositoryId)
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid project id %q %w", pull.RepositoryId, err)
+		}
+		create.TargetProjectId = projectId
+		resource = projectPath(pull.HeadOwner, pull.HeadName)
+	}
+
+	var mr mergeRequest
+	_, err := c.do(ctx, http.MethodPost, resource+"/merge_requests", create, &mr)
+	if err != nil {
+		return "", 0, checkUnauthorized(err)
+	}
+	return strconv.Itoa(mr.Id), mr.Iid, nil
+}
+
+// body returns the description of the merge request for the commit
+func (c *client) body(gitcmd git.GitInterface, commit git.Commit, stack []*hosting.PullRequest, pr *hosting.PullRequest) (string, error) {
+	body := githubclient.FormatBody(commit, stack, c.config.Repo.ShowPrTitlesInStack)
+	if c.config.Repo.PRTemplatePath == "" {
+		return body, nil
+	}
+
+	templatePath := path.Join(gitcmd.RootDir(), c.config.Repo.PRTemplatePath)
+	template, err := os.ReadFile(templatePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read PR template %w", err)
+	}
+	body, err = githubclient.InsertBodyIntoPRTemplate(body, string(template), c.config.Repo, pr)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert body into PR template %w", err)
+	}
+	return body, nil
+}
+
+func (c *client) UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*hosting.PullRequest, pr *hosting.PullRequest, commit git.Commit, prevCommit *git.Commit) error {
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab update %d : %s\n", pr.Number, pr.Title)
+	}
+
+	baseRefName := c.config.Repo.GitHubBranch
+	if prevCommit != nil {
+		baseRefName = git.BranchNameFromCommit(c.config, *prevCommit)
+	}
+
+	log.Debug().Interface("Commit", commit).
+		Str("FromBranch", pr.FromBranch).Str("ToBranch", baseRefName).
+		Interface("PR", pr).Msg("UpdatePullRequest")
+
+	body, err := c.body(gitcmd, commit, pullRequests, pr)
+	if err != nil {
+		return err
+	}
+	// The title is reset to the subject so keep the merge request a draft
+	title := commit.Subject
+	if c.config.User.CreateDraftPRs || strings.HasPrefix(pr.Title, draftPrefix) {
+		title = draftPrefix + title
+	}
+	if c.config.User.PreserveTitleAndBody {
+		title = pr.Title
+		body = pr.Body
+	}
+
+	err = c.updateMergeRequest(ctx, c.projectPath(), pr.Number, updateMergeRequest{
+		Title:        &title,
+		Description:  &body,
+		TargetBranch: &baseRefName,
+	})
+	if err != nil {
+		return fmt.Errorf("merge request %d update failed %w", pr.Number, err)
+	}
+	return nil
+}
+
+// AddReviewers sets the reviewers of the merge request, userIDs are the ids returned by GetAssignableUsers
+func (c *client) AddReviewers(ctx context.Context, pr *hosting.PullRequest, userIDs []string) error {
+	log.Debug().Strs("userIDs", userIDs).Msg("AddReviewers")
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab add reviewers %d : %s - %+v\n", pr.Number, pr.Title, userIDs)
+	}
+
+	reviewerIds := []int{}
+	for _, userID := range userIDs {
+		id, err := strconv.Atoi(userID)
+		if err != nil {
+			return fmt.Errorf("invalid reviewer id %q %w", userID, err)
+		}
+		reviewerIds = append(reviewerIds, id)
+	}
+
+	err := c.updateMergeRequest(ctx, c.projectPath(), pr.Number, updateMergeRequest{ReviewerIds: reviewerIds})
+	if err != nil {
+		return fmt.Errorf("add reviewers %v to merge request %d failed %w", userIDs, pr.Number, err)
+	}
+	return nil
+}
+
+func (c *client) CommentPullRequest(ctx context.Context, pr *hosting.PullRequest, comment string) error {
+	_, err := c.do(ctx, http.MethodPost, fmt.Sprintf("%s/merge_requests/%d/notes", c.projectPath(), pr.Number),
+		note{Body: comment}, nil)
+	if err != nil {
+		return fmt.Errorf("comment on merge request %d failed %w", pr.Number, checkUnauthorized(err))
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab add comment %d : %s\n", pr.Number, pr.Title)
+	}
+	return nil
+}
+
+// MergePullRequest merges the merge request. GitLab merges with the method configured for the project, a squash
+// merge method squashes the commits first. With MergeQueue set the merge request is merged once its pipeline
+// succeeds.
+func (c *client) MergePullRequest(ctx context.Context,
+	pr *hosting.PullRequest, mergeMethod hosting.MergeMethod) error {
+	log.Debug().
+		Interface("PR", pr).
+		Str("mergeMethod", string(mergeMethod)).
+		Msg("MergePullRequest")
+
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d/merge", c.projectPath(), pr.Number),
+		acceptMergeRequest{
+			Sha:                       pr.Commit.CommitHash,
+			Squash:                    mergeMethod == hosting.MergeMethodSquash,
+			MergeWhenPipelineSucceeds: c.config.Repo.MergeQueue,
+		}, nil)
+	if err != nil {
+		return fmt.Errorf("unable to merge %d %w", pr.Number, checkUnauthorized(err))
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab merge %d : %s\n", pr.Number, pr.Title)
+	}
+	return nil
+}
+
+// EditPullRequest2 applies the title, body, base branch, state and draft of the edit to the merge request.
+// The head branch of a merge request can't be changed so it is ignored.
+func (c *client) EditPullRequest2(ctx context.Context, owner string, repoName string, number int, edit hosting.PullRequestEdit) error {
+	resource := projectPath(owner, repoName)
+	title, err := c.draftTitle(ctx, resource, number, edit)
+	if err != nil {
+		return err
+	}
+	update := updateMergeRequest{
+		Title:        title,
+		Description:  edit.Body,
+		TargetBranch: edit.BaseRefName,
+	}
+	if edit.State != nil {
+		switch *edit.State {
+		case hosting.PullRequestStateClosed:
+			update.StateEvent = ptrutils.Ptr("close")
+		case hosting.PullRequestStateOpen:
+			update.StateEvent = ptrutils.Ptr("reopen")
+		}
+	}
+
+	return c.updateMergeRequest(ctx, resource, number, update)
+}
+
+// draftTitle returns the title of the edited merge request, nil if it isn't changed. A merge request is a draft while
+// its title has the draft prefix, so a draft stays a draft unless edit.Draft is false.
+func (c *client) draftTitle(ctx context.Context, resource string, number int, edit hosting.PullRequestEdit) (*string, error) {
+	if edit.Title == nil && edit.Draft == nil {
+		return nil, nil
+	}
+
+	var current mergeRequest
+	if edit.Title == nil || (edit.Draft == nil && !strings.HasPrefix(*edit.Title, draftPrefix)) {
+		_, err := c.do(ctx, http.MethodGet, fmt.Sprintf("%s/merge_requests/%d", resource, number), nil, &current)
+		if err != nil {
+			return nil, fmt.Errorf("fetching merge request %d %w", number, checkUnauthorized(err))
+		}
+	}
+	title := current.Title
+	if edit.Title != nil {
+		title = *edit.Title
+	}
+	draft := strings.HasPrefix(title, draftPrefix) || strings.HasPrefix(current.Title, draftPrefix)
+	if edit.Draft != nil {
+		draft = *edit.Draft
+	}
+
+	title = strings.TrimPrefix(title, draftPrefix)
+	if draft {
+		title = draftPrefix + title
+	}
+	return &title, nil
+}
+
+func (c *client) ClosePullRequest(ctx context.Context, pr *hosting.PullRequest) error {
+	log.Debug().Interface("PR", pr).Msg("ClosePullRequest")
+	err := c.updateMergeRequest(ctx, c.projectPath(), pr.Number, updateMergeRequest{StateEvent: ptrutils.Ptr("close")})
+	if err != nil {
+		return fmt.Errorf("unable to close merge request %d %w", pr.Number, err)
+	}
+
+	if c.config.User.LogGitHubCalls {
+		fmt.Printf("> gitlab close %d : %s\n", pr.Number, pr.Title)
+	}
+
+	return nil
+}
+
+func (c *client) updateMergeRequest(ctx context.Context, resource string, number int, update updateMergeRequest) error {
+	_, err := c.do(ctx, http.MethodPut, fmt.Sprintf("%s/merge_requests/%d", resource, number), update, nil)
+	return checkUnauthorized(err)
+}
+
+// PullRequestsAndStatus fetches the users open merge requests along with their commits, approval and pipeline status.
+func (c *client) PullRequestsAndStatus(ctx context.Context, repoOwner string, repoName string) (*hosting.Repository, error) {
+	resource := projectPath(repoOwner, repoName)
+
+	var viewer user
+	_, err := c.do(ctx, http.MethodGet, "/user", nil, &viewer)
+	if err != nil {
+		return nil, fmt.Errorf("fetching the user %w", checkUnauthorized(err))
+	}
+	c.config.SetViewerLogin(viewer.Username)
+	var proj project
+	_, err = c.do(ctx, http.MethodGet, resource, nil, &proj)
+	if err != nil {
+		return nil, fmt.Errorf("fetching project %s/%s %w", repoOwner, repoName, checkUnauthorized(err))
+	}
+	mergeRequests, err := getAll[mergeRequest](ctx, c,
+		fmt.Sprintf("%s/merge_requests?state=opened&author_id=%d", resource, viewer.Id))
+	if err != nil {
+		return nil, fmt.Errorf("fetching merge requests %w", checkUnauthorized(err))
+	}
+
+	pullRequests, err := concurrent.SliceMap(mergeRequests, func(mr mergeRequest) (hosting.OpenPullRequest, error) {
+		return c.pullRequest(ctx, resource, mr)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repo := &hosting.Repository{
+		Id:           strconv.Itoa(proj.Id),
+		Viewer:       viewer.Username,
+		PullRequests: pullRequests,
+	}
+	if proj.ForkedFromProject != nil {
+		repo.ParentId = strconv.Itoa(proj.ForkedFromProject.Id)
+	}
+	return repo, nil
+}
+
+// pullRequest fetches the commits, approval and pipeline status of the merge request and returns it as a pull request
+func (c *client) pullRequest(ctx context.Context, resource string, mr mergeRequest) (hosting.OpenPullRequest, error) {
+	mrPath := fmt.Sprintf("%s/merge_requests/%d", resource, mr.Iid)
+
+	commits, err := getAll[commit](ctx, c, mrPath+"/commits")
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching commits for merge request %d %w", mr.Iid, err)
+	}
+	var approval approvals
+	_, err = c.do(ctx, http.MethodGet, mrPath+"/approvals", nil, &approval)
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching approvals for merge request %d %w", mr.Iid, err)
+	}
+	var pipelines []pipeline
+	_, err = c.do(ctx, http.MethodGet, mrPath+"/pipelines", nil, &pipelines)
+	if err != nil {
+		return hosting.OpenPullRequest{}, fmt.Errorf("fetching pipelines for merge request %d %w", mr.Iid, err)
+	}
+
+	pr := hosting.OpenPullRequest{
+		Id:               strconv.Itoa(mr.Id),
+		DatabaseId:       mr.Id,
+		Number:           mr.Iid,
+		Title:            mr.Title,
+		Body:             mr.Description,
+		Author:           mr.Author.Username,
+		BaseRefName:      mr.TargetBranch,
+		HeadRefName:      mr.SourceBranch,
+		BaseRepositoryId: strconv.Itoa(mr.TargetProjectId),
+		Mergeable:        hosting.MergeableStateMergeable,
+	}
+	if mr.HasConflicts {
+		pr.Mergeable = hosting.MergeableStateConflicting
+	}
+	if approval.Approved {
+		pr.ReviewDecision = hosting.ReviewDecisionApproved
+	}
+	// The pipelines are listed newest first
+	if len(pipelines) > 0 {
+		pr.CheckState = pipelineState(pipelines[0].Status)
+	}
+
+	// The commits are listed newest first, pull request commits are oldest first
+	for _, commit := range slices.Backward(commits) {
+		pr.Commits = append(pr.Commits, pullRequestCommit(commit, pr.CheckState))
+	}
+	return pr, nil
+}
+
+func pullRequestCommit(commit commit, state hosting.CheckState) hosting.Commit {
+	_, body, _ := strings.Cut(commit.Message, "\n")
+	return hosting.Commit{
+		Oid:             commit.Id,
+		MessageHeadline: commit.Title,
+		MessageBody:     strings.TrimSpace(body),
+		CheckState:      state,
+	}
+}
+
+// pipelineState maps the status of a pipeline to a check state
+func pipelineState(status string) hosting.CheckState {
+	switch status {
+	case "success", "skipped":
+		return hosting.CheckStateSuccess
+	case "failed", "canceled":
+		return hosting.CheckStateFailure
+	default:
+		return hosting.CheckStatePending
+	}
+}
+
+// projectPath returns the path of the configured project's resources
+func (c *client) projectPath() string {
+	return projectPath(c.config.Repo.GitHubRepoOwner, c.config.Repo.GitHubRepoName)
+}
+
+// projectPath returns the path of the project's resources
+func projectPath(owner string, name string) string {
+	return "/projects/" + url.PathEscape(owner+"/"+name)
+}
+
+// checkUnauthorized adds a hint on how to set up a valid token to 401 Unauthorized errors
+func checkUnauthorized(err error) error {
+	var apiErr *apiError
+	if errors.As(err, &apiErr) && apiErr.statusCode == http.StatusUnauthorized {
+		return fmt.Errorf("%w\n"+
+			" make sure GITLAB_TOKEN env variable is set with a valid token\n"+
+			" to create a valid token goto: https://<gitlab host>/-/user_settings/personal_access_tokens", err)
+	}
+	return err
+}
@@ -0,0 +1,39 @@
+package gitlabclient
+
+import (
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/stretchr/testify/require"
+)
+
+func TestApiEndpoint(t *testing.T) {
+	require.Equal(t, "https://gitlab.com/api/v4", apiEndpoint(&config.RepoConfig{GitHubHost: "gitlab.com"}))
+	require.Equal(t, "https://gitlab.example.com/api/v4", apiEndpoint(&config.RepoConfig{GitHubHost: "gitlab.example.com"}))
+	require.Equal(t, "http://localhost:8080/api/v4", apiEndpoint(&config.RepoConfig{
+		GitHubHost:   "gitlab.example.com",
+		GitHubApiUrl: "http://localhost:8080/api/v4/",
+	}))
+}
+
+func TestPipelineState(t *testing.T) {
+	require.Equal(t, hosting.CheckStateSuccess, pipelineState("success"))
+	require.Equal(t, hosting.CheckStateSuccess, pipelineState("skipped"))
+	require.Equal(t, hosting.CheckStateFailure, pipelineState("failed"))
+	require.Equal(t, hosting.CheckStateFailure, pipelineState("canceled"))
+	require.Equal(t, hosting.CheckStatePending, pipelineState("running"))
+	require.Equal(t, hosting.CheckStatePending, pipelineState("manual"))
+}
+
+func TestPullRequestCommit(t *testing.T) {
+	c := pullRequestCommit(commit{
+		Id:      "hash00000001",
+		Title:   "subject 00000001",
+		Message: "subject 00000001\n\ncommit-id:00000001\n",
+	}, hosting.CheckStatePending)
+	require.Equal(t, "hash00000001", c.Oid)
+	require.Equal(t, "subject 00000001", c.MessageHeadline)
+	require.Equal(t, "commit-id:00000001", c.MessageBody)
+	require.Equal(t, hosting.CheckStatePending, c.CheckState)
+}
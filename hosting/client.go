@@ -0,0 +1,56 @@
+package hosting
+
+import (
+	"context"
+
+	"github.com/ejoffe/spr/git"
+)
+
+// Client is the client of the service hosting the repository, each hosting service has an adapter implementing it.
+// It adds the operations on the pull requests of the local stack to the operations by number of Interface.
+type Client interface {
+	Interface
+
+	// GetInfo returns the list of pull requests which match the local stack of commits
+	GetInfo(ctx context.Context, gitcmd git.GitInterface) (*Info, error)
+
+	// GetAssignableUsers returns a list of valid users that can review the pull request
+	GetAssignableUsers(ctx context.Context) ([]RepoAssignee, error)
+
+	// CreatePullRequest creates a pull request
+	CreatePullRequest(ctx context.Context, gitcmd git.GitInterface, info *Info, commit git.Commit, prevCommit *git.Commit) (*PullRequest, error)
+
+	// UpdatePullRequest updates a pull request with current commit
+	UpdatePullRequest(ctx context.Context, gitcmd git.GitInterface, pullRequests []*PullRequest, pr *PullRequest, commit git.Commit, prevCommit *git.Commit) error
+
+	// AddReviewers adds a reviewer to the given pull request
+	AddReviewers(ctx context.Context, pr *PullRequest, userIDs []string) error
+
+	// CommentPullRequest add a comment to the given pull request
+	CommentPullRequest(ctx context.Context, pr *PullRequest, comment string) error
+
+	// MergePullRequest merged the given pull request
+	MergePullRequest(ctx context.Context, pr *PullRequest, mergeMethod MergeMethod) error
+
+	// ClosePullRequest closes the given pull request
+	ClosePullRequest(ctx context.Context, pr *PullRequest) error
+}
+
+// Info is the user, repository and pull requests of the local stack returned by GetInfo
+type Info struct {
+	UserName     string
+	RepositoryID string
+	LocalBranch  string
+	PullRequests []*PullRequest
+}
+
+// RepoAssignee is a user that can review pull requests
+type RepoAssignee struct {
+	ID    string
+	Login string
+	Name  string
+}
+
+func (i *Info) Key() string {
+	return i.RepositoryID + "_" + i.LocalBranch
+}
@@ -0,0 +1,130 @@
+// Package hosting has the provider neutral types spr uses to talk to the service hosting the repository. GitHub,
+// GitLab and Gitea each have a client that adapts their API to these types, so the pull request set logic and the
+// test fakes don't depend on the shape of any one API.
+package hosting
+
+import (
+	"context"
+)
+
+// Interface is the part of a hosting client that reads and mutates pull requests by number.
+type Interface interface {
+	// PullRequestsAndStatus returns the repository's open pull requests along with their commits and status
+	PullRequestsAndStatus(ctx context.Context, owner string, name string) (*Repository, error)
+
+	// CreatePullRequest2 creates a pull request and returns its id and number
+	CreatePullRequest2(ctx context.Context, owner string, name string, pull NewPullRequest) (string, int, error)
+
+	// EditPullRequest2 applies the fields that are set in the edit to the pull request
+	EditPullRequest2(ctx context.Context, owner string, name string, number int, edit PullRequestEdit) error
+}
+
+// Repository is the repository as seen by the authenticated user
+type Repository struct {
+	Id string
+	// ParentId is the id of the repository this one was forked from, it is empty if it isn't a fork.
+	ParentId string
+	// Viewer is the login of the authenticated user
+	Viewer       string
+	PullRequests []OpenPullRequest
+}
+
+// OpenPullRequest is an open pull request along with its status
+type OpenPullRequest struct {
+	Id               string
+	DatabaseId       int
+	Number           int
+	Title            string
+	Body             string
+	Author           string
+	HeadRefName      string
+	BaseRefName      string
+	BaseRepositoryId string
+
+	Mergeable      MergeableState
+	ReviewDecision ReviewDecision
+	CheckState     CheckState
+
+	// Commits are ordered oldest first
+	Commits []Commit
+}
+
+// Commit is a commit of a pull request
+type Commit struct {
+	Oid             string
+	MessageHeadline string
+	MessageBody     string
+	CheckState      CheckState
+}
+
+// CheckState is the combined state of the checks (or pipeline) run on a commit
+type CheckState string
+
+const (
+	// CheckStateNone is the state of a commit without any checks
+	CheckStateNone    CheckState = ""
+	CheckStatePending CheckState = "PENDING"
+	CheckStateSuccess CheckState = "SUCCESS"
+	CheckStateFailure CheckState = "FAILURE"
+)
+
+// ReviewDecision is the review state of a pull request
+type ReviewDecision string
+
+const (
+	ReviewDecisionNone             ReviewDecision = ""
+	ReviewDecisionApproved         ReviewDecision = "APPROVED"
+	ReviewDecisionChangesRequested ReviewDecision = "CHANGES_REQUESTED"
+	ReviewDecisionReviewRequired   ReviewDecision = "REVIEW_REQUIRED"
+)
+
+// MergeableState is whether a pull request can be merged without conflicts
+type MergeableState string
+
+const (
+	MergeableStateMergeable   MergeableState = "MERGEABLE"
+	MergeableStateConflicting MergeableState = "CONFLICTING"
+	MergeableStateUnknown     MergeableState = "UNKNOWN"
+)
+
+// MergeMethod is how a pull request is merged, the values match the (upper cased) mergeMethod config
+type MergeMethod string
+
+const (
+	MergeMethodMerge  MergeMethod = "MERGE"
+	MergeMethodSquash MergeMethod = "SQUASH"
+	MergeMethodRebase MergeMethod = "REBASE"
+)
+
+// PullRequestState is the state a pull request can be set to
+type PullRequestState string
+
+const (
+	PullRequestStateOpen   PullRequestState = "open"
+	PullRequestStateClosed PullRequestState = "closed"
+)
+
+// NewPullRequest has the fields of a pull request to create
+type NewPullRequest struct {
+	// HeadRepositoryId and RepositoryId are the ids of the head and base repositories, they are only used by GitHub
+	HeadRepositoryId string
+	RepositoryId     string
+	// HeadOwner and HeadName are the repository the head branch is in when it is a fork of the base repository
+	HeadOwner   string
+	HeadName    string
+	HeadRefName string
+	BaseRefName string
+	Title       string
+	Body        string
+	Draft       bool
+}
+
+// PullRequestEdit has the fields of a pull request to change, nil fields are left unchanged
+type PullRequestEdit struct {
+	Title       *string
+	Body        *string
+	HeadRefName *string
+	BaseRefName *string
+	Draft       *bool
+	State       *PullRequestState
+}
@@ -1,4 +1,4 @@
-package github
+package hosting
 
 import (
 	"fmt"
@@ -172,8 +172,7 @@ func (pr *PullRequest) Stringer(config *config.Config) string {
 	padding := func(s string) string { return s }
 	padding = padNumber(5)
 
-	prInfo := fmt.Sprintf("https://%s/%s/%s/pull/%s",
-		config.Repo.GitHubHost, config.Repo.GitHubRepoOwner, config.Repo.GitHubRepoName, padding(fmt.Sprintf("%d", pr.Number)))
+	prInfo := config.Repo.PullRequestURLPrefix() + padding(fmt.Sprintf("%d", pr.Number))
 
 	var mq string
 	if len(pr.Commits) > 1 {
@@ -0,0 +1,98 @@
+package hosting
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+)
+
+// MatchPullRequestStack returns the pull requests of the local commit stack, starting with the one based on the
+// target branch. It is used by the hosting clients that build GetInfo on top of PullRequestsAndStatus.
+func MatchPullRequestStack(cfg *config.Config, targetBranch string, localCommitStack []git.Commit,
+	openPullRequests []OpenPullRequest) ([]*PullRequest, error) {
+	if len(localCommitStack) == 0 || len(openPullRequests) == 0 {
+		return []*PullRequest{}, nil
+	}
+
+	// pullRequestMap is a map from commit-id to pull request
+	pullRequestMap := make(map[string]*PullRequest)
+	for _, node := range openPullRequests {
+		commitId := git.CommitIdFromBranchName(cfg, node.HeadRefName)
+		if commitId == "" || len(node.Commits) == 0 {
+			continue
+		}
+
+		var commits []git.Commit
+		for _, v := range node.Commits {
+			for _, line := range strings.Split(v.MessageBody, "\n") {
+				if strings.HasPrefix(line, "commit-id:") {
+					commits = append(commits, git.Commit{
+						CommitID:   strings.Split(line, ":")[1],
+						CommitHash: v.Oid,
+						Subject:    v.MessageHeadline,
+						Body:       v.MessageBody,
+					})
+				}
+			}
+		}
+
+		head := node.Commits[len(node.Commits)-1]
+		checkStatus := CheckStatusPass
+		switch head.CheckState {
+		case CheckStatePending:
+			checkStatus = CheckStatusPending
+		case CheckStateFailure:
+			checkStatus = CheckStatusFail
+		}
+
+		pullRequestMap[commitId] = &PullRequest{
+			DatabaseId: strconv.Itoa(node.DatabaseId),
+			Id:         node.Id,
+			Number:     node.Number,
+			Title:      node.Title,
+			Body:       node.Body,
+			FromBranch: node.HeadRefName,
+			ToBranch:   node.BaseRefName,
+			Commits:    commits,
+			Commit: git.Commit{
+				CommitID:   commitId,
+				CommitHash: head.Oid,
+				Subject:    head.MessageHeadline,
+				Body:       head.MessageBody,
+			},
+			MergeStatus: PullRequestMergeStatus{
+				ChecksPass:     checkStatus,
+				ReviewApproved: node.ReviewDecision == ReviewDecisionApproved,
+				NoConflicts:    node.Mergeable == MergeableStateMergeable,
+			},
+		}
+	}
+
+	// find the top pull request
+	var currpr *PullRequest
+	for i := len(localCommitStack) - 1; i >= 0 && currpr == nil; i-- {
+		currpr = pullRequestMap[localCommitStack[i].CommitID]
+	}
+
+	// build the stack from the top down
+	var pullRequests []*PullRequest
+	for currpr != nil {
+		pullRequests = append(pullRequests, currpr)
+		if currpr.ToBranch == targetBranch {
+			break
+		}
+
+		commitId := git.CommitIdFromBranchName(cfg, currpr.ToBranch)
+		if commitId == "" {
+			return nil, fmt.Errorf("invalid target branch for pull request:%s", currpr.ToBranch)
+		}
+		currpr = pullRequestMap[commitId]
+	}
+	slices.Reverse(pullRequests)
+
+	return pullRequests, nil
+}
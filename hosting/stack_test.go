@@ -0,0 +1,69 @@
+package hosting
+
+import (
+	"testing"
+
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchPullRequestStack(t *testing.T) {
+	node := func(number int, commitID string, base string, state CheckState) OpenPullRequest {
+		return OpenPullRequest{
+			Id:             "100" + commitID,
+			DatabaseId:     100 + number,
+			Number:         number,
+			Title:          "title " + commitID,
+			BaseRefName:    base,
+			HeadRefName:    "spr/main/" + commitID,
+			Mergeable:      MergeableStateMergeable,
+			ReviewDecision: ReviewDecisionApproved,
+			Commits: []Commit{{
+				Oid:             "hash" + commitID,
+				MessageHeadline: "subject " + commitID,
+				MessageBody:     "commit-id:" + commitID,
+				CheckState:      state,
+			}},
+		}
+	}
+
+	localCommitStack := []git.Commit{
+		{CommitID: "00000001", CommitHash: "local1"},
+		{CommitID: "00000002", CommitHash: "local2"},
+	}
+	nodes := []OpenPullRequest{
+		node(2, "00000002", "spr/main/00000001", CheckStatePending),
+		node(1, "00000001", "main", CheckStateSuccess),
+		// pull requests that aren't part of the stack are ignored
+		node(3, "00000003", "main", CheckStateSuccess),
+	}
+
+	pullRequests, err := MatchPullRequestStack(config.EmptyConfig(), "main", localCommitStack, nodes)
+	require.NoError(t, err)
+	require.Len(t, pullRequests, 2)
+
+	require.Equal(t, 1, pullRequests[0].Number)
+	require.Equal(t, "101", pullRequests[0].DatabaseId)
+	require.Equal(t, "main", pullRequests[0].ToBranch)
+	require.Equal(t, git.Commit{
+		CommitID:   "00000001",
+		CommitHash: "hash00000001",
+		Subject:    "subject 00000001",
+		Body:       "commit-id:00000001",
+	}, pullRequests[0].Commit)
+	require.Equal(t, PullRequestMergeStatus{
+		ChecksPass:     CheckStatusPass,
+		ReviewApproved: true,
+		NoConflicts:    true,
+	}, pullRequests[0].MergeStatus)
+
+	require.Equal(t, 2, pullRequests[1].Number)
+	require.Equal(t, "spr/main/00000001", pullRequests[1].ToBranch)
+	require.Equal(t, CheckStatusPending, pullRequests[1].MergeStatus.ChecksPass)
+
+	_, err = MatchPullRequestStack(config.EmptyConfig(), "main", localCommitStack, []OpenPullRequest{
+		node(2, "00000002", "feature", CheckStateSuccess),
+	})
+	require.ErrorContains(t, err, "invalid target branch for pull request:feature")
+}
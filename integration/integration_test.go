@@ -17,8 +17,8 @@ import (
 	"github.com/ejoffe/spr/config/config_parser"
 	"github.com/ejoffe/spr/git"
 	"github.com/ejoffe/spr/git/realgit"
-	"github.com/ejoffe/spr/github"
 	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/ejoffe/spr/output/mockoutput"
 	"github.com/ejoffe/spr/spr"
 	ngit "github.com/go-git/go-git/v5"
@@ -40,7 +40,7 @@ var prefix string = ""
 // resoruces contains various resources for unit testing
 type resources struct {
 	cfg       *config.Config
-	github    github.GitHubInterface
+	github    hosting.Client
 	gitshell  git.GitInterface
 	stackedpr *spr.Stackediff
 	printer   *mockoutput.CapturedOutput
@@ -89,7 +89,8 @@ func initialize(t *testing.T, cfgfn func(*config.Config)) *resources {
 	gitcmd = realgit.NewGitCmd(cfg)
 
 	ctx := context.Background()
-	client := githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+	client, err := githubclient.NewGitHubClient(ctx, gitcmd, cfg)
+	require.NoError(t, err)
 	stackedpr := spr.NewStackedPR(cfg, client, gitcmd)
 
 	// Direct the output to a mock Printer so we can test against the output
@@ -218,7 +219,7 @@ func TestBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 
 	t.Run("Starts in expected state", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
@@ -240,12 +241,12 @@ func TestBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
 		resources.printer.ExpectRegExp("1.*No Pull Request Created")
 		resources.printer.ExpectRegExp("0.*No Pull Request Created")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
 	t.Run("Can create PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s0.*github.com")
@@ -256,8 +257,8 @@ func TestBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 
 	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.MergePRSet(ctx, "s0")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 }
@@ -280,7 +281,7 @@ func TestInABranchBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 
 	t.Run("Starts in expected state", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
@@ -298,7 +299,7 @@ func TestInABranchBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
 		resources.printer.ExpectRegExp("1.*No Pull Request Created")
@@ -307,7 +308,7 @@ func TestInABranchBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 	})
 
 	t.Run("Can create PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s0.*github.com")
@@ -318,8 +319,8 @@ func TestInABranchBasicCommitUpdateMergeWithNoSubsetPRSets(t *testing.T) {
 
 	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.MergePRSet(ctx, "s0")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
@@ -339,7 +340,7 @@ func TestBasicCommitUpdateMergeWithMultiplePRSets(t *testing.T) {
 
 	t.Run("Starts in expected state", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
@@ -360,7 +361,7 @@ func TestBasicCommitUpdateMergeWithMultiplePRSets(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("3.*No Pull Request Created")
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
@@ -370,12 +371,12 @@ func TestBasicCommitUpdateMergeWithMultiplePRSets(t *testing.T) {
 	})
 
 	t.Run("Can create PR sets with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-1")
-		resources.stackedpr.UpdatePRSets(ctx, "2")
-		resources.stackedpr.UpdatePRSets(ctx, "3")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "2"))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "3"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("3.*s2.*github.com")
 		resources.printer.ExpectRegExp("2.*s1.*github.com")
@@ -385,24 +386,24 @@ func TestBasicCommitUpdateMergeWithMultiplePRSets(t *testing.T) {
 	})
 
 	t.Run("Can merge PR sets with spr merge", func(t *testing.T) {
-		resources.stackedpr.MergePRSet(ctx, "s2")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s2"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s1.*github.com")
 		resources.printer.ExpectRegExp("1.*s0.*github.com")
 		resources.printer.ExpectRegExp("0.*s0.*github.com")
 		resources.printer.ExpectationsMet()
 
-		resources.stackedpr.MergePRSet(ctx, "s1")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s1"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("1.*s0.*github.com")
 		resources.printer.ExpectRegExp("0.*s0.*github.com")
 		resources.printer.ExpectationsMet()
 
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.MergePRSet(ctx, "s0")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 }
@@ -416,7 +417,7 @@ func TestBasicCommitUpdateWithMergeConflictsWithSelectedCommits(t *testing.T) {
 
 	t.Run("Starts in expected state", func(t *testing.T) {
 		resources.printer.ExpectString("no local commits\n")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectationsMet()
 	})
 
@@ -437,7 +438,7 @@ func TestBasicCommitUpdateWithMergeConflictsWithSelectedCommits(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("3.*No Pull Request Created")
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
@@ -447,10 +448,8 @@ func TestBasicCommitUpdateWithMergeConflictsWithSelectedCommits(t *testing.T) {
 	})
 
 	t.Run("Try to create PRs but get merge conflict due to skipping a dependent commit", func(t *testing.T) {
-		require.Panicsf(t, func() {
-			os.Setenv("SPR_DEBUG", "1") // Hack to force a panic instead of os.Exit(1)
-			resources.stackedpr.UpdatePRSets(ctx, "1-3")
-		}, "Expected a panic when a commit is includes that can't be cherry picked onto the existing commits")
+		err := resources.stackedpr.UpdatePRSets(ctx, "1-3")
+		require.Errorf(t, err, "Expected an error when a commit is includes that can't be cherry picked onto the existing commits")
 	})
 }
 
@@ -462,7 +461,7 @@ func TestBasicCommitUpdateReOrderCommitsReUpdateMerge(t *testing.T) {
 	name := prefix + t.Name()
 
 	t.Run("Starts in expected state", func(t *testing.T) {
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
 	})
@@ -481,7 +480,7 @@ func TestBasicCommitUpdateReOrderCommitsReUpdateMerge(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
 		resources.printer.ExpectRegExp("1.*No Pull Request Created")
@@ -490,10 +489,10 @@ func TestBasicCommitUpdateReOrderCommitsReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can create PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s0.*github.com")
 		resources.printer.ExpectRegExp("1.*s0.*github.com")
@@ -521,10 +520,10 @@ func TestBasicCommitUpdateReOrderCommitsReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can update PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s1.*github.com")
 		resources.printer.ExpectRegExp("1.*s1.*github.com")
@@ -533,8 +532,8 @@ func TestBasicCommitUpdateReOrderCommitsReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
-		resources.stackedpr.MergePRSet(ctx, "s1")
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s1"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
 	})
@@ -548,7 +547,7 @@ func TestBasicCommitUpdateRemoveCommitReUpdateMerge(t *testing.T) {
 	name := prefix + t.Name()
 
 	t.Run("Starts in expected state", func(t *testing.T) {
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
 	})
@@ -567,7 +566,7 @@ func TestBasicCommitUpdateRemoveCommitReUpdateMerge(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
 		resources.printer.ExpectRegExp("1.*No Pull Request Created")
@@ -576,10 +575,10 @@ func TestBasicCommitUpdateRemoveCommitReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can create PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s0.*github.com")
 		resources.printer.ExpectRegExp("1.*s0.*github.com")
@@ -607,10 +606,10 @@ func TestBasicCommitUpdateRemoveCommitReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can update PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "s0")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "s0"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("1.*s1.*github.com")
 		resources.printer.ExpectRegExp("0.*s1.*github.com")
@@ -618,10 +617,10 @@ func TestBasicCommitUpdateRemoveCommitReUpdateMerge(t *testing.T) {
 	})
 
 	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
-		resources.stackedpr.MergePRSet(ctx, "s1")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s1"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
@@ -637,7 +636,7 @@ func TestBasicCommitUpdateMergeWithMergeCheck(t *testing.T) {
 	name := prefix + t.Name()
 
 	t.Run("Starts in expected state", func(t *testing.T) {
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
 	})
@@ -656,7 +655,7 @@ func TestBasicCommitUpdateMergeWithMergeCheck(t *testing.T) {
 			},
 		})
 
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*No Pull Request Created")
 		resources.printer.ExpectRegExp("1.*No Pull Request Created")
@@ -665,10 +664,10 @@ func TestBasicCommitUpdateMergeWithMergeCheck(t *testing.T) {
 	})
 
 	t.Run("Can create PRs with spr update", func(t *testing.T) {
-		resources.stackedpr.UpdatePRSets(ctx, "0-2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectString(spr.Header(resources.cfg))
 		resources.printer.ExpectRegExp("2.*s0.*github.com")
 		resources.printer.ExpectRegExp("1.*s0.*github.com")
@@ -677,21 +676,19 @@ func TestBasicCommitUpdateMergeWithMergeCheck(t *testing.T) {
 	})
 
 	t.Run("Can't merge without spr check first", func(t *testing.T) {
-		require.Panicsf(t, func() {
-			os.Setenv("SPR_DEBUG", "1") // Hack to force a panic instead of os.Exit(1)
-			resources.stackedpr.MergePRSet(ctx, "s0")
-		}, "Expected a panic when a spr check is needed but hasn't been executed")
+		err := resources.stackedpr.MergePRSet(ctx, "s0")
+		require.Errorf(t, err, "Expected an error when a spr check is needed but hasn't been executed")
 	})
 
 	t.Run("Run merge check", func(t *testing.T) {
-		resources.stackedpr.RunMergeCheck(ctx)
+		require.NoError(t, resources.stackedpr.RunMergeCheck(ctx))
 	})
 
 	t.Run("Can merge after spr check", func(t *testing.T) {
-		resources.stackedpr.MergePRSet(ctx, "s0")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
 
 		resources.printer.Purge()
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
@@ -705,16 +702,14 @@ func TestMergeWithInvalidPRSetFails(t *testing.T) {
 	defer resources.validate()
 
 	t.Run("Starts in expected state", func(t *testing.T) {
-		resources.stackedpr.StatusCommitsAndPRSets(ctx)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
 		resources.printer.ExpectRegExp(".*no local commits.*")
 		resources.printer.ExpectationsMet()
 	})
 
 	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
-		require.Panicsf(t, func() {
-			os.Setenv("SPR_DEBUG", "1") // Hack to force a panic instead of os.Exit(1)
-			resources.stackedpr.MergePRSet(ctx, "s0")
-		}, "Expected a panic when a spr merge with an invalid PR set")
+		err := resources.stackedpr.MergePRSet(ctx, "s0")
+		require.Errorf(t, err, "Expected an error when a spr merge with an invalid PR set")
 		resources.printer.ExpectationsMet()
 	})
 }
@@ -0,0 +1,1100 @@
+package integration
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ejoffe/spr/bl/journal"
+	"github.com/ejoffe/spr/bl/lockfile"
+	"github.com/ejoffe/spr/bl/prsetplan"
+	"github.com/ejoffe/spr/config"
+	"github.com/ejoffe/spr/git"
+	"github.com/ejoffe/spr/git/realgit"
+	"github.com/ejoffe/spr/gitea/fakegitea"
+	"github.com/ejoffe/spr/gitea/giteaclient"
+	"github.com/ejoffe/spr/github/fakegithub"
+	"github.com/ejoffe/spr/github/githubclient"
+	"github.com/ejoffe/spr/gitlab/fakegitlab"
+	"github.com/ejoffe/spr/gitlab/gitlabclient"
+	"github.com/ejoffe/spr/hosting"
+	"github.com/ejoffe/spr/output/mockoutput"
+	"github.com/ejoffe/spr/spr"
+	"github.com/stretchr/testify/require"
+)
+
+// The offline tests run the same flows as the integration tests but against a fake GitHub server and a local bare
+// remote so they can run as part of `go test ./...`.
+
+// TestMain builds the spr_reword_helper (which spr update uses to add commit-ids) and puts it on the PATH.
+func TestMain(m *testing.M) {
+	binDir, err := os.MkdirTemp("", "spr-integration-bin")
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	cmd := exec.Command("go", "build", "-o", filepath.Join(binDir, "spr_reword_helper"), "github.com/ejoffe/spr/cmd/reword")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		fmt.Printf("building spr_reword_helper %s: %s\n", err, out)
+		os.RemoveAll(binDir)
+		os.Exit(1)
+	}
+	os.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	code := m.Run()
+	os.RemoveAll(binDir)
+	os.Exit(code)
+}
+
+// offlineResources contains the resources for an offline test
+type offlineResources struct {
+	cfg       *config.Config
+	fake      *fakegithub.Server
+	gitlab    *fakegitlab.Server
+	gitea     *fakegitea.Server
+	gitshell  git.GitInterface
+	stackedpr *spr.Stackediff
+	printer   *mockoutput.CapturedOutput
+}
+
+// offlineInitialize starts a fake GitHub server and changes into a fresh clone of its remote.
+func offlineInitialize(t *testing.T, cfgfn func(*config.Config)) *offlineResources {
+	t.Helper()
+
+	fake := fakegithub.New(t)
+	t.Setenv("GITHUB_TOKEN", "fake-token")
+	offlineClone(t, fake.RemotePath, fakegithub.Name)
+
+	cfg := config.DefaultConfig()
+	fake.Configure(cfg)
+	cfg.State.Stargazer = true
+	cfgfn(cfg)
+
+	gitcmd := realgit.NewGitCmd(cfg)
+	client, err := githubclient.NewGitHubClient(context.Background(), gitcmd, cfg)
+	require.NoError(t, err)
+
+	resources := newOfflineResources(cfg, gitcmd, client)
+	resources.fake = fake
+	return resources
+}
+
+// offlineGitLabInitialize starts a fake GitLab server and changes into a fresh clone of its remote.
+func offlineGitLabInitialize(t *testing.T, cfgfn func(*config.Config)) *offlineResources {
+	t.Helper()
+
+	fake := fakegitlab.New(t)
+	t.Setenv("GITLAB_TOKEN", "fake-token")
+	offlineClone(t, fake.RemotePath, fakegitlab.Name)
+
+	cfg := config.DefaultConfig()
+	fake.Configure(cfg)
+	cfgfn(cfg)
+
+	gitcmd := realgit.NewGitCmd(cfg)
+	client, err := gitlabclient.NewGitLabClient(context.Background(), gitcmd, cfg)
+	require.NoError(t, err)
+
+	resources := newOfflineResources(cfg, gitcmd, client)
+	resources.gitlab = fake
+	return resources
+}
+
+// offlineGiteaInitialize starts a fake Gitea server and changes into a fresh clone of its remote.
+func offlineGiteaInitialize(t *testing.T, cfgfn func(*config.Config)) *offlineResources {
+	t.Helper()
+
+	fake := fakegitea.New(t)
+	t.Setenv("GITEA_TOKEN", "fake-token")
+	offlineClone(t, fake.RemotePath, fakegitea.Name)
+
+	cfg := config.DefaultConfig()
+	fake.Configure(cfg)
+	cfgfn(cfg)
+
+	gitcmd := realgit.NewGitCmd(cfg)
+	client, err := giteaclient.NewGiteaClient(context.Background(), gitcmd, cfg)
+	require.NoError(t, err)
+
+	resources := newOfflineResources(cfg, gitcmd, client)
+	resources.gitea = fake
+	return resources
+}
+
+// offlineClone isolates git from the user's config and changes into a fresh clone of the remote
+func offlineClone(t *testing.T, remotePath string, name string) {
+	t.Helper()
+
+	home := t.TempDir()
+	// Background gc packing the refs while they are read makes the remote branches go missing now and then
+	gitconfig := "[user]\n\tname = Testy McTestFace\n\temail = testy.mctestface@example.com\n" +
+		"[gc]\n\tauto = 0\n[maintenance]\n\tauto = false\n"
+	err := os.WriteFile(filepath.Join(home, ".gitconfig"), []byte(gitconfig), 0644)
+	require.NoError(t, err)
+	t.Setenv("HOME", home)
+	t.Setenv("GIT_CONFIG_NOSYSTEM", "1")
+	// GIT_EDITOR would take precedence over the spr_reword_helper (git commands drop empty env vars)
+	t.Setenv("GIT_EDITOR", "")
+
+	clone := filepath.Join(t.TempDir(), name)
+	out, err := exec.Command("git", "clone", remotePath, clone).CombinedOutput()
+	require.NoError(t, err, string(out))
+	t.Chdir(clone)
+}
+
+func newOfflineResources(cfg *config.Config, gitcmd git.GitInterface, client hosting.Client) *offlineResources {
+	stackedpr := spr.NewStackedPR(cfg, client, gitcmd)
+
+	// Direct the output to a mock Printer so we can test against the output
+	capout := mockoutput.MockPrinter()
+	stackedpr.Printer = capout
+
+	return &offlineResources{
+		cfg:       cfg,
+		gitshell:  gitcmd,
+		stackedpr: stackedpr,
+		printer:   capout,
+	}
+}
+
+// commitFiles creates a commit for each name which adds a file of the same name
+func (r *offlineResources) commitFiles(t *testing.T, names ...string) {
+	t.Helper()
+
+	for _, name := range names {
+		err := os.WriteFile(filepath.Join(r.gitshell.RootDir(), name), []byte(name+"\n"), 0644)
+		require.NoError(t, err)
+		require.NoError(t, r.gitshell.Git("add "+name, nil))
+		require.NoError(t, r.gitshell.Git("commit -m "+name, nil))
+	}
+}
+
+// openPullRequests returns the open pull requests on the fake server
+func (r *offlineResources) openPullRequests() []fakegithub.PullRequest {
+	prs := []fakegithub.PullRequest{}
+	for _, pr := range r.fake.PullRequests() {
+		if pr.State == fakegithub.StateOpen {
+			prs = append(prs, pr)
+		}
+	}
+	return prs
+}
+
+// requireNoSprBranches checks that all of the spr branches have been deleted from the remote
+func (r *offlineResources) requireNoSprBranches(t *testing.T) {
+	t.Helper()
+
+	branches, err := r.gitshell.RemoteBranches()
+	require.NoError(t, err)
+	for branch := range branches.Iter() {
+		branch = strings.TrimPrefix(branch, "refs/heads/")
+		require.Empty(t, git.CommitIdFromBranchName(r.cfg, branch), "%s should have been deleted", branch)
+	}
+}
+
+func TestOfflineUpdateMergeWithNoSubsetPRSets(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	t.Run("Starts in expected state", func(t *testing.T) {
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+	})
+
+	t.Run("New commits are shown with spr status", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2")
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*No Pull Request Created")
+		resources.printer.ExpectRegExp("1.*No Pull Request Created")
+		resources.printer.ExpectRegExp("0.*No Pull Request Created")
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+	})
+
+	t.Run("Can create PRs with spr update", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s0.*github.com/spr-owner/spr-repo/pull/3")
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		resources.printer.ExpectationsMet()
+
+		// The PRs are stacked on top of each other
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 3)
+		require.Equal(t, "main", prs[0].BaseRefName)
+		require.Equal(t, prs[0].HeadRefName, prs[1].BaseRefName)
+		require.Equal(t, prs[1].HeadRefName, prs[2].BaseRefName)
+		require.Equal(t, []string{"file0", "file1", "file2"}, []string{prs[0].Title, prs[1].Title, prs[2].Title})
+
+		// Each PR links to the others in the set
+		for _, pr := range prs {
+			require.Regexp(t, `- #3.*\n- #2.*\n- #1`, pr.Body)
+			require.Contains(t, pr.Body, fmt.Sprintf("#%d ⬅", pr.Number))
+		}
+	})
+
+	t.Run("Can merge PRs with spr merge", func(t *testing.T) {
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, resources.openPullRequests())
+		resources.requireNoSprBranches(t)
+		for _, name := range []string{"file0", "file1", "file2"} {
+			require.FileExists(t, filepath.Join(resources.gitshell.RootDir(), name))
+		}
+	})
+}
+
+func TestOfflineUpdateMergeWithMultiplePRSets(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	t.Run("Can create PR sets with spr update", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2", "file3")
+
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "2"))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "3"))
+
+		resources.printer.Purge()
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("3.*s2.*github.com")
+		resources.printer.ExpectRegExp("2.*s1.*github.com")
+		resources.printer.ExpectRegExp("1.*s0.*github.com")
+		resources.printer.ExpectRegExp("0.*s0.*github.com")
+		resources.printer.ExpectationsMet()
+		require.Len(t, resources.openPullRequests(), 4)
+	})
+
+	t.Run("Can merge PR sets with spr merge", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s2"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s1.*github.com")
+		resources.printer.ExpectRegExp("1.*s0.*github.com")
+		resources.printer.ExpectRegExp("0.*s0.*github.com")
+		resources.printer.ExpectationsMet()
+
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s1"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("1.*s0.*github.com")
+		resources.printer.ExpectRegExp("0.*s0.*github.com")
+		resources.printer.ExpectationsMet()
+
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, resources.openPullRequests())
+		resources.requireNoSprBranches(t)
+	})
+}
+
+func TestOfflineUpdateWithMergeConflictsWithSelectedCommits(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	resources.commitFiles(t, "file0", "file1")
+	// Modify file0 again so the last commit depends on the first
+	err := os.WriteFile(filepath.Join(resources.gitshell.RootDir(), "file0"), []byte("more content\n"), 0644)
+	require.NoError(t, err)
+	require.NoError(t, resources.gitshell.Git("commit -a -m file0-again", nil))
+
+	err = resources.stackedpr.UpdatePRSets(ctx, "1-2")
+	require.ErrorContains(t, err, "an earlier commit is required", "Expected an error when a commit is included that can't be cherry picked onto the existing commits")
+	require.Empty(t, resources.openPullRequests())
+}
+
+func TestOfflineUpdateDryRun(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	t.Run("Dry run of new PRs makes no changes", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2")
+		var before string
+		require.NoError(t, resources.gitshell.Git("rev-parse HEAD", &before))
+
+		require.NoError(t, resources.stackedpr.PlanPRSets(ctx, "0-2"))
+		resources.printer.ExpectString("dry run: spr update would\n")
+		resources.printer.ExpectRegExp(`^add commit-id:[a-f0-9]{8} to [a-f0-9]{8} : file0\n$`)
+		resources.printer.ExpectRegExp(`^add commit-id:[a-f0-9]{8} to [a-f0-9]{8} : file1\n$`)
+		resources.printer.ExpectRegExp(`^add commit-id:[a-f0-9]{8} to [a-f0-9]{8} : file2\n$`)
+		resources.printer.ExpectRegExp(`^push spr/main/[a-f0-9]{8} \([a-f0-9]{8} : file0\)\n$`)
+		resources.printer.ExpectRegExp(`^push spr/main/[a-f0-9]{8} \([a-f0-9]{8} : file1\)\n$`)
+		resources.printer.ExpectRegExp(`^push spr/main/[a-f0-9]{8} \([a-f0-9]{8} : file2\)\n$`)
+		resources.printer.ExpectRegExp(`^create pull request #1 spr/main/[a-f0-9]{8} -> main : file0\n(?s:.*)- #3\n    - #2\n    - #1 ⬅`)
+		resources.printer.ExpectRegExp(`^create pull request #2 spr/main/[a-f0-9]{8} -> spr/main/[a-f0-9]{8} : file1\n`)
+		resources.printer.ExpectRegExp(`^create pull request #3 spr/main/[a-f0-9]{8} -> spr/main/[a-f0-9]{8} : file2\n`)
+		resources.printer.ExpectationsMet()
+
+		var after string
+		require.NoError(t, resources.gitshell.Git("rev-parse HEAD", &after))
+		require.Equal(t, before, after)
+		require.Empty(t, resources.fake.PullRequests())
+		resources.requireNoSprBranches(t)
+		require.Empty(t, resources.cfg.PRSets())
+	})
+
+	t.Run("Dry run of shrinking a PR set makes no changes", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+		resources.printer.Purge()
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 3)
+
+		require.NoError(t, resources.stackedpr.PlanPRSets(ctx, "s0:0"))
+		resources.printer.ExpectString("dry run: spr update would\n")
+		resources.printer.ExpectRegExp(`^push ` + prs[0].HeadRefName + ` \([a-f0-9]{8} : file0\)\n$`)
+		resources.printer.ExpectRegExp(`^rewrite body of pull request #1\n    commit-id:[a-f0-9]{8}\n$`)
+		resources.printer.ExpectString("close pull request #2 : file1\n")
+		resources.printer.ExpectString("close pull request #3 : file2\n")
+		deleted := []string{prs[1].HeadRefName, prs[2].HeadRefName}
+		slices.Sort(deleted)
+		resources.printer.ExpectString("delete branch " + deleted[0] + "\n")
+		resources.printer.ExpectString("delete branch " + deleted[1] + "\n")
+		resources.printer.ExpectationsMet()
+
+		require.Equal(t, prs, resources.openPullRequests())
+		require.Len(t, resources.cfg.PRSets(), 3)
+	})
+
+	t.Run("Local branches named like the pull request branches are left alone", func(t *testing.T) {
+		prs := resources.openPullRequests()
+		branch := prs[0].HeadRefName
+		require.NoError(t, resources.gitshell.Git("branch "+branch+" HEAD", nil))
+		var before string
+		require.NoError(t, resources.gitshell.Git("rev-parse "+branch, &before))
+
+		require.NoError(t, resources.stackedpr.PlanPRSets(ctx, "s0:0"))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "s0:0"))
+		resources.printer.Purge()
+
+		var after string
+		require.NoError(t, resources.gitshell.Git("rev-parse "+branch, &after))
+		require.Equal(t, before, after)
+
+		// The pull request's branch is the cherry pick, not the local branch
+		var remote string
+		require.NoError(t, resources.gitshell.Git("ls-remote origin refs/heads/"+branch, &remote))
+		var subject string
+		require.NoError(t, resources.gitshell.Git("log -1 --format=%s "+strings.Fields(remote)[0], &subject))
+		require.Equal(t, "file0", strings.TrimSpace(subject))
+		require.Len(t, resources.openPullRequests(), 1)
+	})
+}
+
+func TestOfflineUndo(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	remoteBranches := func() map[string]string {
+		var out string
+		require.NoError(t, resources.gitshell.Git("ls-remote origin", &out))
+		branches := map[string]string{}
+		for _, line := range strings.Split(out, "\n") {
+			hash, ref, _ := strings.Cut(line, "\t")
+			branches[ref] = hash
+		}
+		return branches
+	}
+	head := func() string {
+		var out string
+		require.NoError(t, resources.gitshell.Git("rev-parse HEAD", &out))
+		return out
+	}
+
+	resources.commitFiles(t, "file0", "file1", "file2")
+	headBeforeUpdate := head()
+
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+	prsAfterUpdate := resources.openPullRequests()
+	require.Len(t, prsAfterUpdate, 3)
+	branchesAfterUpdate := remoteBranches()
+	headAfterUpdate := head()
+	stateAfterUpdate := maps.Clone(resources.cfg.PRSets())
+
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "s0:0"))
+	require.Len(t, resources.openPullRequests(), 1)
+	resources.printer.Purge()
+
+	t.Run("Undo reopens the closed PRs and restores their branches", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.Undo(ctx))
+		resources.printer.Purge()
+
+		require.Equal(t, prsAfterUpdate, resources.openPullRequests())
+		require.Equal(t, branchesAfterUpdate, remoteBranches())
+		require.Equal(t, headAfterUpdate, head())
+		require.Equal(t, stateAfterUpdate, resources.cfg.PRSets())
+	})
+
+	t.Run("Undo closes the created PRs and resets the local branch", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.Undo(ctx))
+		resources.printer.Purge()
+
+		require.Empty(t, resources.openPullRequests())
+		resources.requireNoSprBranches(t)
+		require.Equal(t, headBeforeUpdate, head())
+		require.Empty(t, resources.cfg.PRSets())
+	})
+
+	t.Run("Nothing left to undo", func(t *testing.T) {
+		resources.printer.ExpectString("nothing to undo\n")
+		require.NoError(t, resources.stackedpr.Undo(ctx))
+		resources.printer.ExpectationsMet()
+	})
+}
+
+func TestOfflineUpdateRollsBackOnFailure(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	remoteBranches := func() map[string]string {
+		var out string
+		require.NoError(t, resources.gitshell.Git("ls-remote origin", &out))
+		branches := map[string]string{}
+		for _, line := range strings.Split(out, "\n") {
+			hash, ref, _ := strings.Cut(line, "\t")
+			branches[ref] = hash
+		}
+		return branches
+	}
+
+	resources.commitFiles(t, "file0", "file1", "file2")
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+	prsBefore := resources.openPullRequests()
+	require.Len(t, prsBefore, 2)
+	branchesBefore := remoteBranches()
+	stateBefore := maps.Clone(resources.cfg.PRSets())
+	resources.printer.Purge()
+
+	t.Run("A failed edit rolls back the update", func(t *testing.T) {
+		resources.fake.FailNextEdit(2)
+		err := resources.stackedpr.UpdatePRSets(ctx, "s0:0-2")
+		require.ErrorContains(t, err, "Validation Failed")
+		resources.printer.Purge()
+
+		require.Equal(t, prsBefore, resources.openPullRequests())
+		require.Equal(t, branchesBefore, remoteBranches())
+		require.Equal(t, stateBefore, resources.cfg.PRSets())
+	})
+
+	t.Run("The rolled back update isn't journaled", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.Undo(ctx))
+		resources.printer.Purge()
+
+		require.Empty(t, resources.openPullRequests())
+		resources.requireNoSprBranches(t)
+	})
+}
+
+func TestOfflineGitLabUpdateMerge(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineGitLabInitialize(t, func(c *config.Config) {})
+
+	openMergeRequests := func() []fakegitlab.MergeRequest {
+		mrs := []fakegitlab.MergeRequest{}
+		for _, mr := range resources.gitlab.MergeRequests() {
+			if mr.State == fakegitlab.StateOpened {
+				mrs = append(mrs, mr)
+			}
+		}
+		return mrs
+	}
+
+	t.Run("Can create merge requests with spr update", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s0.*gitlab.com/spr-owner/spr-repo/-/merge_requests/3")
+		resources.printer.ExpectRegExp("1.*s0.*gitlab.com/spr-owner/spr-repo/-/merge_requests/2")
+		resources.printer.ExpectRegExp("0.*s0.*gitlab.com/spr-owner/spr-repo/-/merge_requests/1")
+		resources.printer.ExpectationsMet()
+
+		// The merge requests are stacked on top of each other
+		mrs := openMergeRequests()
+		require.Len(t, mrs, 3)
+		require.Equal(t, "main", mrs[0].TargetBranch)
+		require.Equal(t, mrs[0].SourceBranch, mrs[1].TargetBranch)
+		require.Equal(t, mrs[1].SourceBranch, mrs[2].TargetBranch)
+		require.Equal(t, []string{"file0", "file1", "file2"}, []string{mrs[0].Title, mrs[1].Title, mrs[2].Title})
+		for _, mr := range mrs {
+			require.Contains(t, mr.Description, fmt.Sprintf("#%d ⬅", mr.Iid))
+		}
+	})
+
+	t.Run("Can merge merge requests with spr merge", func(t *testing.T) {
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, openMergeRequests())
+		resources.requireNoSprBranches(t)
+		for _, name := range []string{"file0", "file1", "file2"} {
+			require.FileExists(t, filepath.Join(resources.gitshell.RootDir(), name))
+		}
+	})
+}
+
+func TestOfflineGiteaUpdateMerge(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineGiteaInitialize(t, func(c *config.Config) {})
+
+	openPullRequests := func() []fakegitea.PullRequest {
+		prs := []fakegitea.PullRequest{}
+		for _, pr := range resources.gitea.PullRequests() {
+			if pr.State == fakegitea.StateOpen {
+				prs = append(prs, pr)
+			}
+		}
+		return prs
+	}
+
+	t.Run("Can create pull requests with spr update", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s0.*gitea.com/spr-owner/spr-repo/pulls/3")
+		resources.printer.ExpectRegExp("1.*s0.*gitea.com/spr-owner/spr-repo/pulls/2")
+		resources.printer.ExpectRegExp("0.*s0.*gitea.com/spr-owner/spr-repo/pulls/1")
+		resources.printer.ExpectationsMet()
+
+		// The pull requests are stacked on top of each other
+		prs := openPullRequests()
+		require.Len(t, prs, 3)
+		require.Equal(t, "main", prs[0].Base)
+		require.Equal(t, prs[0].Head, prs[1].Base)
+		require.Equal(t, prs[1].Head, prs[2].Base)
+		require.Equal(t, []string{"file0", "file1", "file2"}, []string{prs[0].Title, prs[1].Title, prs[2].Title})
+		for _, pr := range prs {
+			require.Contains(t, pr.Body, fmt.Sprintf("#%d ⬅", pr.Number))
+		}
+	})
+
+	t.Run("Can merge pull requests with spr merge", func(t *testing.T) {
+		for _, pr := range openPullRequests() {
+			require.NoError(t, resources.gitea.SetPullRequestStatus(pr.Number, "success"))
+		}
+
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, openPullRequests())
+		resources.requireNoSprBranches(t)
+		for _, name := range []string{"file0", "file1", "file2"} {
+			require.FileExists(t, filepath.Join(resources.gitshell.RootDir(), name))
+		}
+	})
+}
+
+func TestOfflineForkUpdateMerge(t *testing.T) {
+	ctx := context.Background()
+	const forkOwner = "spr-contributor"
+	resources := offlineInitialize(t, func(c *config.Config) {
+		c.Repo.PushRemote = "fork"
+		c.Repo.PushRepoOwner = forkOwner
+		c.Repo.PushRepoName = fakegithub.Name
+	})
+	forkPath := resources.fake.Fork(t, forkOwner)
+	require.NoError(t, resources.gitshell.Git("remote add fork "+forkPath, nil))
+
+	// upstreamSprBranches returns the spr branches that were pushed to the upstream repository
+	upstreamSprBranches := func() []string {
+		var out string
+		require.NoError(t, resources.gitshell.Git("ls-remote --heads origin", &out))
+		branches := []string{}
+		for line := range strings.Lines(out) {
+			if strings.Contains(line, "refs/heads/spr/") {
+				branches = append(branches, line)
+			}
+		}
+		return branches
+	}
+
+	t.Run("Can create pull requests on upstream from the fork with spr update", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1", "file2")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-2"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s0.*github.com/spr-owner/spr-repo/pull/3")
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		resources.printer.ExpectationsMet()
+
+		// A branch of the fork can't be the base of an upstream pull request so every pull request targets main
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 3)
+		for _, pr := range prs {
+			require.Equal(t, "main", pr.BaseRefName)
+			require.Equal(t, forkOwner, pr.HeadRepositoryOwner)
+		}
+		require.Equal(t, []string{"file0", "file1", "file2"}, []string{prs[0].Title, prs[1].Title, prs[2].Title})
+		require.Empty(t, upstreamSprBranches())
+	})
+
+	t.Run("Can merge pull requests from the fork with spr merge", func(t *testing.T) {
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, resources.openPullRequests())
+		// RemoteBranches lists the branches of the push remote
+		resources.requireNoSprBranches(t)
+		for _, name := range []string{"file0", "file1", "file2"} {
+			require.FileExists(t, filepath.Join(resources.gitshell.RootDir(), name))
+		}
+	})
+}
+
+func TestOfflineUpdateMergeOnSlashedTargetBranch(t *testing.T) {
+	ctx := context.Background()
+	const target = "release/2026.10"
+	resources := offlineInitialize(t, func(c *config.Config) {
+		c.Repo.GitHubBranch = target
+	})
+	require.NoError(t, resources.gitshell.Git("push origin main:refs/heads/"+target, nil))
+	require.NoError(t, resources.gitshell.Git("fetch origin", nil))
+	require.NoError(t, resources.gitshell.Git("checkout -b feature origin/"+target, nil))
+
+	t.Run("Can create PRs on the target branch with spr update", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		resources.printer.ExpectationsMet()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 2)
+		require.Equal(t, target, prs[0].BaseRefName)
+		require.Regexp(t, "^spr/release/2026.10/[a-f0-9]{8}$", prs[0].HeadRefName)
+		require.Equal(t, prs[0].HeadRefName, prs[1].BaseRefName)
+	})
+
+	t.Run("Can merge PRs into the target branch with spr merge", func(t *testing.T) {
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Empty(t, resources.openPullRequests())
+		resources.requireNoSprBranches(t)
+		var out string
+		require.NoError(t, resources.gitshell.Git("ls-tree --name-only origin/"+target, &out))
+		require.Contains(t, out, "file0")
+		require.Contains(t, out, "file1")
+	})
+}
+
+func TestOfflineStacksOnMultipleTargetBranches(t *testing.T) {
+	ctx := context.Background()
+	const release = "release/1.0"
+	resources := offlineInitialize(t, func(c *config.Config) {
+		c.Repo.LocalBranch = "main"
+	})
+	require.NoError(t, resources.gitshell.Git("push origin main:refs/heads/"+release, nil))
+	require.NoError(t, resources.gitshell.Git("fetch origin", nil))
+
+	// checkout switches to the local branch and the target branch it tracks, like the config parser does
+	checkout := func(t *testing.T, localBranch string, target string) {
+		require.NoError(t, resources.gitshell.Git("checkout "+localBranch, nil))
+		resources.cfg.Repo.LocalBranch = localBranch
+		resources.cfg.Repo.GitHubBranch = target
+	}
+
+	t.Run("Can create a stack on main", func(t *testing.T) {
+		resources.commitFiles(t, "file0", "file1")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		resources.printer.ExpectationsMet()
+	})
+
+	t.Run("Can create a backport stack without touching the stack on main", func(t *testing.T) {
+		require.NoError(t, resources.gitshell.Git("branch backport origin/"+release, nil))
+		checkout(t, "backport", release)
+		resources.commitFiles(t, "backport0")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0"))
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/3")
+		resources.printer.ExpectationsMet()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 3)
+		require.Equal(t, "main", prs[0].BaseRefName)
+		require.Equal(t, prs[0].HeadRefName, prs[1].BaseRefName)
+		require.Equal(t, release, prs[2].BaseRefName)
+	})
+
+	t.Run("The stack on main keeps its PR set", func(t *testing.T) {
+		checkout(t, "main", "main")
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+
+	t.Run("Can merge the backport stack", func(t *testing.T) {
+		checkout(t, "backport", release)
+
+		resources.printer.ExpectString("no local commits\n")
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 2)
+		require.Equal(t, "main", prs[0].BaseRefName)
+	})
+}
+
+func TestOfflineStacksOnMultipleLocalBranches(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {
+		c.Repo.LocalBranch = "main"
+	})
+
+	// checkout switches to the local branch, both branches are stacked on main
+	checkout := func(t *testing.T, localBranch string) {
+		require.NoError(t, resources.gitshell.Git("checkout "+localBranch, nil))
+		resources.cfg.Repo.LocalBranch = localBranch
+	}
+
+	t.Run("Can create a stack on each local branch", func(t *testing.T) {
+		require.NoError(t, resources.gitshell.Git("branch other origin/main", nil))
+		resources.commitFiles(t, "file0", "file1")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+		resources.printer.Purge()
+
+		checkout(t, "other")
+		resources.commitFiles(t, "other0")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0"))
+		resources.printer.Purge()
+
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+
+	t.Run("Updating a stack leaves the pull requests of the other local branch open", func(t *testing.T) {
+		checkout(t, "main")
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+		resources.printer.Purge()
+
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+
+	t.Run("Dropping a commit closes its pull request only", func(t *testing.T) {
+		checkout(t, "other")
+		require.NoError(t, resources.gitshell.Git("reset --hard origin/main", nil))
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, ""))
+		resources.printer.Purge()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 2)
+		require.Equal(t, "main", prs[0].BaseRefName)
+		require.Equal(t, prs[0].HeadRefName, prs[1].BaseRefName)
+	})
+}
+
+func TestOfflinePRSetsAreStoredOnTheRemote(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	// prSetRefs returns the PR set refs on the remote
+	prSetRefs := func() []string {
+		var out string
+		require.NoError(t, resources.gitshell.Git("ls-remote origin refs/spr/prsets/*", &out))
+		refs := []string{}
+		for _, line := range strings.Split(out, "\n") {
+			if _, ref, found := strings.Cut(line, "\t"); found {
+				refs = append(refs, ref)
+			}
+		}
+		return refs
+	}
+
+	resources.commitFiles(t, "file0", "file1", "file2")
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "2"))
+	resources.printer.Purge()
+
+	t.Run("Each commit in a PR set has a ref on the remote", func(t *testing.T) {
+		refs := prSetRefs()
+		require.Len(t, refs, 3)
+		require.Regexp(t, `^refs/spr/prsets/[^/]+/s0/[a-f0-9]{8}$`, refs[0])
+		require.Regexp(t, `^refs/spr/prsets/[^/]+/s0/[a-f0-9]{8}$`, refs[1])
+		require.Regexp(t, `^refs/spr/prsets/[^/]+/s1/[a-f0-9]{8}$`, refs[2])
+	})
+
+	t.Run("The PR sets are restored from the remote without the state file", func(t *testing.T) {
+		resources.cfg.SetPRSets(nil)
+		resources.cfg.SetPRSetRefs(nil)
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp("2.*s1.*github.com/spr-owner/spr-repo/pull/3")
+		resources.printer.ExpectRegExp("1.*s0.*github.com/spr-owner/spr-repo/pull/2")
+		resources.printer.ExpectRegExp("0.*s0.*github.com/spr-owner/spr-repo/pull/1")
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+		require.Len(t, resources.cfg.PRSets(), 3)
+	})
+
+	t.Run("Removing a commit from a PR set removes its ref", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "s0:0"))
+		resources.printer.Purge()
+
+		refs := prSetRefs()
+		require.Len(t, refs, 2)
+		require.Regexp(t, `^refs/spr/prsets/[^/]+/s0/`, refs[0])
+		require.Regexp(t, `^refs/spr/prsets/[^/]+/s1/`, refs[1])
+	})
+
+	t.Run("Merging PR sets removes their refs", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s0"))
+		require.Len(t, prSetRefs(), 1)
+
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "s1:0-1"))
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "s1"))
+		resources.printer.Purge()
+		require.Empty(t, prSetRefs())
+	})
+}
+
+func TestOfflineConcurrentUpdatesAreRefused(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+	resources.commitFiles(t, "file0", "file1")
+
+	t.Run("An update is refused while another spr holds the lock", func(t *testing.T) {
+		lock, err := journal.Lock(resources.gitshell)
+		require.NoError(t, err)
+
+		err = resources.stackedpr.UpdatePRSets(ctx, "0-1")
+		require.ErrorIs(t, err, lockfile.ErrLocked)
+		require.ErrorContains(t, err, "another spr is running")
+		require.Empty(t, resources.fake.PullRequests())
+
+		err = resources.stackedpr.MergePRSet(ctx, "s0")
+		require.ErrorIs(t, err, lockfile.ErrLocked)
+
+		require.NoError(t, lock.Release())
+	})
+
+	t.Run("The update runs once the lock is released", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "0-1"))
+		resources.printer.Purge()
+		require.Len(t, resources.openPullRequests(), 2)
+	})
+}
+
+func TestOfflineNamedPRSets(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+
+	resources.commitFiles(t, "file0", "file1", "file2")
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "auth-refactor:0-1"))
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "docs:2"))
+	resources.printer.Purge()
+
+	t.Run("The status shows the names of the PR sets", func(t *testing.T) {
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`2.*s1\(docs\).*github.com/spr-owner/spr-repo/pull/3`)
+		resources.printer.ExpectRegExp(`1.*s0\(auth-refactor\).*github.com/spr-owner/spr-repo/pull/2`)
+		resources.printer.ExpectRegExp(`0.*s0\(auth-refactor\).*github.com/spr-owner/spr-repo/pull/1`)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+		require.Equal(t, map[string]int{"auth-refactor": 0, "docs": 1}, resources.cfg.PRSetNames())
+	})
+
+	t.Run("The status can be filtered by name", func(t *testing.T) {
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`2.*s1\(docs\)`)
+		require.NoError(t, resources.stackedpr.StatusSelectedCommits(ctx, "docs"))
+		resources.printer.ExpectationsMet()
+	})
+
+	t.Run("A named PR set can be updated and merged by name", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "auth-refactor:0"))
+		require.NoError(t, resources.stackedpr.MergePRSet(ctx, "auth-refactor"))
+		resources.printer.Purge()
+
+		require.Len(t, resources.openPullRequests(), 1)
+		require.Equal(t, map[string]int{"docs": 1}, resources.cfg.PRSetNames())
+	})
+}
+
+func TestOfflineInteractiveUpdate(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+	resources.commitFiles(t, "file0", "file1", "file2")
+
+	t.Run("Commits are added to the PR sets in the edited plan", func(t *testing.T) {
+		t.Setenv("EDITOR", "sed -i s/^--/auth/")
+		require.NoError(t, resources.stackedpr.EditPRSets(ctx))
+		resources.printer.Purge()
+
+		require.Len(t, resources.openPullRequests(), 3)
+		require.Equal(t, map[string]int{"auth": 0}, resources.cfg.PRSetNames())
+	})
+
+	t.Run("A commit moved to a new PR set keeps its pull request", func(t *testing.T) {
+		t.Setenv("EDITOR", "sed -i '1s/^[^ ]*/docs/'")
+		require.NoError(t, resources.stackedpr.EditPRSets(ctx))
+		resources.printer.Purge()
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`2.*s1\(docs\).*github.com/spr-owner/spr-repo/pull/3`)
+		resources.printer.ExpectRegExp(`1.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/2`)
+		resources.printer.ExpectRegExp(`0.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/1`)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+
+	t.Run("An empty plan aborts the update", func(t *testing.T) {
+		t.Setenv("EDITOR", "truncate -s 0")
+		resources.printer.ExpectString("the PR set plan is empty, nothing to update\n")
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`2.*s1\(docs\)`)
+		resources.printer.ExpectRegExp(`1.*s0\(auth\)`)
+		resources.printer.ExpectRegExp(`0.*s0\(auth\)`)
+		require.NoError(t, resources.stackedpr.EditPRSets(ctx))
+		resources.printer.ExpectationsMet()
+	})
+
+	t.Run("An invalid plan is reported", func(t *testing.T) {
+		t.Setenv("EDITOR", "sed -i '1s/^[^ ]*/top/'")
+		err := resources.stackedpr.EditPRSets(ctx)
+		require.ErrorIs(t, err, prsetplan.ErrInvalidPlan)
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+
+	t.Run("The plan isn't applied if the commits changed while it was edited", func(t *testing.T) {
+		// The editor checks the lock isn't held and commits while the plan is edited
+		t.Setenv("EDITOR", `sh -c 'test ! -e .git/spr/lock && sed -i "1s/^[^ ]*/--/" "$0" && `+
+			`git commit -q --allow-empty -m extra'`)
+		err := resources.stackedpr.EditPRSets(ctx)
+		require.ErrorContains(t, err, "the commits or PR sets changed while the plan was edited")
+		resources.printer.Purge()
+		require.Len(t, resources.openPullRequests(), 3)
+	})
+}
+
+func TestOfflineSplitPRSet(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+	resources.commitFiles(t, "file0", "file1", "file2", "file3", "file4")
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "auth:0-4"))
+	resources.printer.Purge()
+
+	t.Run("Invalid selectors are refused", func(t *testing.T) {
+		require.ErrorContains(t, resources.stackedpr.SplitPRSet(ctx, "s1", []string{"0"}), "invalid index s1")
+		require.ErrorContains(t, resources.stackedpr.SplitPRSet(ctx, "auth", []string{"s0:1"}),
+			"the split selector s0:1 can't have a destination PR set")
+		require.ErrorContains(t, resources.stackedpr.SplitPRSet(ctx, "auth", []string{"1-2", "2-3"}),
+			"the split selector 2-3 selects commits that another selector selected")
+		require.Len(t, resources.openPullRequests(), 5)
+	})
+
+	t.Run("The split commits keep their pull requests", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.SplitPRSet(ctx, "auth", []string{"2", "3-4"}))
+		resources.printer.Purge()
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`4.*s2.*github.com/spr-owner/spr-repo/pull/5`)
+		resources.printer.ExpectRegExp(`3.*s2.*github.com/spr-owner/spr-repo/pull/4`)
+		resources.printer.ExpectRegExp(`2.*s1.*github.com/spr-owner/spr-repo/pull/3`)
+		resources.printer.ExpectRegExp(`1.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/2`)
+		resources.printer.ExpectRegExp(`0.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/1`)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 5)
+		require.Equal(t, "main", prs[0].BaseRefName)
+		require.Equal(t, prs[0].HeadRefName, prs[1].BaseRefName)
+		require.Equal(t, "main", prs[2].BaseRefName)
+		require.Equal(t, "main", prs[3].BaseRefName)
+		require.Equal(t, prs[3].HeadRefName, prs[4].BaseRefName)
+	})
+
+	t.Run("Splitting every commit keeps the first part in the PR set", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.SplitPRSet(ctx, "s2", []string{"3", "4"}))
+		resources.printer.Purge()
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 5)
+		require.Equal(t, "main", prs[3].BaseRefName)
+		require.Equal(t, "main", prs[4].BaseRefName)
+		require.Equal(t, map[string]int{"auth": 0}, resources.cfg.PRSetNames())
+	})
+}
+
+func TestOfflineCombinePRSets(t *testing.T) {
+	ctx := context.Background()
+	resources := offlineInitialize(t, func(c *config.Config) {})
+	resources.commitFiles(t, "file0", "file1", "file2", "file3")
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "auth:0-1"))
+	require.NoError(t, resources.stackedpr.UpdatePRSets(ctx, "docs:2-3"))
+	resources.printer.Purge()
+
+	t.Run("Invalid PR sets are refused", func(t *testing.T) {
+		require.ErrorContains(t, resources.stackedpr.CombinePRSets(ctx, []string{"auth", "s2"}), "invalid index s2")
+		require.ErrorContains(t, resources.stackedpr.CombinePRSets(ctx, []string{"auth", "s0"}),
+			"PR set s0 is given more than once")
+		require.Len(t, resources.openPullRequests(), 4)
+	})
+
+	t.Run("The combined PR set keeps the pull requests", func(t *testing.T) {
+		require.NoError(t, resources.stackedpr.CombinePRSets(ctx, []string{"auth", "docs"}))
+		resources.printer.Purge()
+
+		resources.printer.ExpectString(spr.Header(resources.cfg))
+		resources.printer.ExpectRegExp(`3.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/4`)
+		resources.printer.ExpectRegExp(`2.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/3`)
+		resources.printer.ExpectRegExp(`1.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/2`)
+		resources.printer.ExpectRegExp(`0.*s0\(auth\).*github.com/spr-owner/spr-repo/pull/1`)
+		require.NoError(t, resources.stackedpr.StatusCommitsAndPRSets(ctx))
+		resources.printer.ExpectationsMet()
+		require.Equal(t, map[string]int{"auth": 0}, resources.cfg.PRSetNames())
+
+		prs := resources.openPullRequests()
+		require.Len(t, prs, 4)
+		require.Equal(t, "main", prs[0].BaseRefName)
+		for i, pr := range prs {
+			if i > 0 {
+				require.Equal(t, prs[i-1].HeadRefName, pr.BaseRefName)
+			}
+			require.Regexp(t, `- #4.*\n- #3.*\n- #2.*\n- #1`, pr.Body)
+			require.Contains(t, pr.Body, fmt.Sprintf("#%d ⬅", pr.Number))
+		}
+	})
+}
@@ -9,7 +9,7 @@ import (
 	"testing"
 
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github/githubclient/genqlient"
+	"github.com/ejoffe/spr/hosting"
 )
 
 type Outputter interface {
@@ -81,7 +81,7 @@ type GithubExpectation struct {
 	Op          operation
 	Commit      git.Commit
 	Prev        *git.Commit
-	MergeMethod genqlient.PullRequestMergeMethod
+	MergeMethod hosting.MergeMethod
 	UserIDs     []string
 }
 
@@ -3,6 +3,6 @@ package spr
 import "context"
 
 type SPRInterface interface {
-	StatusPullRequests(ctx context.Context)
-	UpdatePullRequests(ctx context.Context)
+	StatusPullRequests(ctx context.Context) error
+	UpdatePullRequests(ctx context.Context) error
 }
@@ -2,10 +2,12 @@ package spr
 
 import (
 	"bufio"
+	"cmp"
 	"context"
 	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"os"
 	"os/exec"
 	"os/signal"
@@ -15,21 +17,24 @@ import (
 	"sync"
 	"syscall"
 
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/ejoffe/profiletimer"
-	"github.com/ejoffe/rake"
 	"github.com/ejoffe/spr/bl"
 	"github.com/ejoffe/spr/bl/concurrent"
+	"github.com/ejoffe/spr/bl/dryrun"
 	"github.com/ejoffe/spr/bl/gitapi"
+	"github.com/ejoffe/spr/bl/journal"
+	"github.com/ejoffe/spr/bl/prsetplan"
 	"github.com/ejoffe/spr/bl/selector"
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/config/config_parser"
 	"github.com/ejoffe/spr/git"
-	"github.com/ejoffe/spr/github"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/ejoffe/spr/output"
 )
 
 // NewStackedPR constructs and returns a new stackediff instance.
-func NewStackedPR(config *config.Config, github github.GitHubInterface, gitcmd git.GitInterface) *Stackediff {
+func NewStackedPR(config *config.Config, github hosting.Client, gitcmd git.GitInterface) *Stackediff {
 
 	return &Stackediff{
 		config:       config,
@@ -44,7 +49,7 @@ func NewStackedPR(config *config.Config, github github.GitHubInterface, gitcmd g
 
 type Stackediff struct {
 	config       *config.Config
-	github       github.GitHubInterface
+	github       hosting.Client
 	gitcmd       git.GitInterface
 	profiletimer profiletimer.Timer
 
@@ -56,11 +61,11 @@ type Stackediff struct {
 // AmendCommit enables one to easily amend a commit in the middle of a stack
 //
 //	of commits. A list of commits is printed and one can be chosen to be amended.
-func (sd *Stackediff) AmendCommit(ctx context.Context) {
+func (sd *Stackediff) AmendCommit(ctx context.Context) error {
 	localCommits := git.GetLocalCommitStack(sd.config, sd.gitcmd)
 	if len(localCommits) == 0 {
 		sd.Printer.Printf("No commits to amend\n")
-		return
+		return nil
 	}
 
 	for i := len(localCommits) - 1; i >= 0; i-- {
@@ -80,19 +85,25 @@ func (sd *Stackediff) AmendCommit(ctx context.Context) {
 	commitIndex, err := strconv.Atoi(line)
 	if err != nil || commitIndex < 1 || commitIndex > len(localCommits) {
 		sd.Printer.Printf("InvalidInput\n")
-		return
+		return nil
 	}
 	commitIndex = commitIndex - 1
-	check(err)
-	sd.gitcmd.MustGit("commit --fixup "+localCommits[commitIndex].CommitHash, nil)
+	err = sd.gitcmd.Git("commit --fixup "+localCommits[commitIndex].CommitHash, nil)
+	if err != nil {
+		return fmt.Errorf("creating fixup commit %w", err)
+	}
 
 	rebaseCmd := fmt.Sprintf("rebase -i --autosquash --autostash %s/%s",
-		sd.config.Repo.GitHubRemote, sd.config.Repo.GitHubBranch)
-	sd.gitcmd.MustGit(rebaseCmd, nil)
+		sd.config.Repo.UpstreamRemoteName(), sd.config.Repo.GitHubBranch)
+	err = sd.gitcmd.Git(rebaseCmd, nil)
+	if err != nil {
+		return fmt.Errorf("rebasing fixup commit %w", err)
+	}
+	return nil
 }
 
 func (sd *Stackediff) addReviewers(ctx context.Context,
-	pr *github.PullRequest, reviewers []string, assignable []github.RepoAssignee) {
+	pr *hosting.PullRequest, reviewers []string, assignable []hosting.RepoAssignee) error {
 	userIDs := make([]string, 0, len(reviewers))
 	for _, r := range reviewers {
 		found := false
@@ -104,13 +115,13 @@ func (sd *Stackediff) addReviewers(ctx context.Context,
 			}
 		}
 		if !found {
-			check(fmt.Errorf("unable to add reviewer, user %q not found", r))
+			return fmt.Errorf("unable to add reviewer, user %q not found", r)
 		}
 	}
-	sd.github.AddReviewers(ctx, pr, userIDs)
+	return sd.github.AddReviewers(ctx, pr, userIDs)
 }
 
-func alignLocalCommits(commits []git.Commit, prs []*github.PullRequest) []git.Commit {
+func alignLocalCommits(commits []git.Commit, prs []*hosting.PullRequest) []git.Commit {
 	var remoteCommits = map[string]bool{}
 	for _, pr := range prs {
 		for _, c := range pr.Commits {
@@ -138,26 +149,35 @@ func alignLocalCommits(commits []git.Commit, prs []*github.PullRequest) []git.Co
 //	 pull request if a commit has been amended.
 //	In the case where commits are reordered, the corresponding pull requests
 //	 will also be reordered to match the commit stack order.
-func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string, count *uint) {
+func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string, count *uint) error {
 	sd.profiletimer.Step("UpdatePullRequests::Start")
-	githubInfo := sd.fetchAndGetGitHubInfo(ctx)
+	githubInfo, err := sd.fetchAndGetGitHubInfo(ctx)
+	if err != nil {
+		return err
+	}
 	if githubInfo == nil {
-		return
+		return nil
 	}
 	sd.profiletimer.Step("UpdatePullRequests::FetchAndGetGitHubInfo")
 	localCommits := alignLocalCommits(git.GetLocalCommitStack(sd.config, sd.gitcmd), githubInfo.PullRequests)
 	sd.profiletimer.Step("UpdatePullRequests::GetLocalCommitStack")
 
 	// close prs for deleted commits
-	var validPullRequests []*github.PullRequest
+	var validPullRequests []*hosting.PullRequest
 	localCommitMap := map[string]*git.Commit{}
 	for _, commit := range localCommits {
 		localCommitMap[commit.CommitID] = &commit
 	}
 	for _, pr := range githubInfo.PullRequests {
 		if _, found := localCommitMap[pr.Commit.CommitID]; !found {
-			sd.github.CommentPullRequest(ctx, pr, "Closing pull request: commit has gone away")
-			sd.github.ClosePullRequest(ctx, pr)
+			err = sd.github.CommentPullRequest(ctx, pr, "Closing pull request: commit has gone away")
+			if err != nil {
+				return err
+			}
+			err = sd.github.ClosePullRequest(ctx, pr)
+			if err != nil {
+				return err
+			}
 		} else {
 			validPullRequests = append(validPullRequests, pr)
 		}
@@ -167,6 +187,7 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 	if commitsReordered(localCommits, githubInfo.PullRequests) {
 		wg := new(sync.WaitGroup)
 		wg.Add(len(githubInfo.PullRequests))
+		errs := make([]error, len(githubInfo.PullRequests))
 
 		// if commits have been reordered :
 		//   first - rebase all pull requests to target branch
@@ -174,7 +195,7 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 		for i := range githubInfo.PullRequests {
 			fn := func(i int) {
 				pr := githubInfo.PullRequests[i]
-				sd.github.UpdatePullRequest(ctx, sd.gitcmd, githubInfo.PullRequests, pr, pr.Commit, nil)
+				errs[i] = sd.github.UpdatePullRequest(ctx, sd.gitcmd, githubInfo.PullRequests, pr, pr.Commit, nil)
 				wg.Done()
 			}
 			if sd.synchronized {
@@ -185,22 +206,27 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 		}
 
 		wg.Wait()
+		err = errors.Join(errs...)
+		if err != nil {
+			return err
+		}
 		sd.profiletimer.Step("UpdatePullRequests::ReparentPullRequestsToMaster")
 	}
 
-	if !sd.syncCommitStackToGitHub(ctx, localCommits, githubInfo) {
-		return
+	err = sd.syncCommitStackToGitHub(ctx, localCommits, githubInfo)
+	if err != nil {
+		return err
 	}
 	sd.profiletimer.Step("UpdatePullRequests::SyncCommitStackToGithub")
 
 	type prUpdate struct {
-		pr         *github.PullRequest
+		pr         *hosting.PullRequest
 		commit     git.Commit
 		prevCommit *git.Commit
 	}
 
 	updateQueue := make([]prUpdate, 0)
-	var assignable []github.RepoAssignee
+	var assignable []hosting.RepoAssignee
 
 	// iterate through local_commits and update pull_requests
 	var prevCommit *git.Commit
@@ -224,14 +250,23 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 		if !prFound {
 			// if pull request is not found for this commit_id it means the commit
 			//  is new and we need to create a new pull request
-			pr := sd.github.CreatePullRequest(ctx, sd.gitcmd, githubInfo, c, prevCommit)
+			pr, err := sd.github.CreatePullRequest(ctx, sd.gitcmd, githubInfo, c, prevCommit)
+			if err != nil {
+				return err
+			}
 			githubInfo.PullRequests = append(githubInfo.PullRequests, pr)
 			updateQueue = append(updateQueue, prUpdate{pr, c, prevCommit})
 			if len(reviewers) != 0 {
 				if assignable == nil {
-					assignable = sd.github.GetAssignableUsers(ctx)
+					assignable, err = sd.github.GetAssignableUsers(ctx)
+					if err != nil {
+						return err
+					}
+				}
+				err = sd.addReviewers(ctx, pr, reviewers, assignable)
+				if err != nil {
+					return err
 				}
-				sd.addReviewers(ctx, pr, reviewers, assignable)
 			}
 			prevCommit = &localCommits[commitIndex]
 		}
@@ -244,13 +279,14 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 
 	wg := new(sync.WaitGroup)
 	wg.Add(len(updateQueue))
+	errs := make([]error, len(updateQueue))
 
 	// Sort the PR stack by the local commit order, in case some commits were reordered
 	sortedPullRequests := sortPullRequestsByLocalCommitOrder(githubInfo.PullRequests, localCommits)
 	for i := range updateQueue {
 		fn := func(i int) {
 			pr := updateQueue[i]
-			sd.github.UpdatePullRequest(ctx, sd.gitcmd, sortedPullRequests, pr.pr, pr.commit, pr.prevCommit)
+			errs[i] = sd.github.UpdatePullRequest(ctx, sd.gitcmd, sortedPullRequests, pr.pr, pr.commit, pr.prevCommit)
 			wg.Done()
 		}
 		if sd.synchronized {
@@ -261,28 +297,41 @@ func (sd *Stackediff) UpdatePullRequests(ctx context.Context, reviewers []string
 	}
 
 	wg.Wait()
+	err = errors.Join(errs...)
+	if err != nil {
+		return err
+	}
 
 	sd.profiletimer.Step("UpdatePullRequests::commitUpdateQueue")
 
-	sd.StatusPullRequests(ctx)
+	return sd.StatusPullRequests(ctx)
 }
 
 // MergePRSet merges the given PR set
 // In order to merge a PRSet without conflicts we find the newest PR and update the PR to merge into main/master.
 // The newest PR branch has all of the commits of the others so this will land all commits into main/master.
 // We then close the other PRs.
-func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
+func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) error {
+	return sd.journaled("merge "+setIndex, func(sd *Stackediff) error {
+		return sd.mergePRSet(ctx, setIndex)
+	})
+}
+
+// mergePRSet makes the changes for MergePRSet
+func (sd *Stackediff) mergePRSet(ctx context.Context, setIndex string) error {
 	sd.profiletimer.Step("MergePRSet::Start")
 	gitapi := gitapi.New(sd.config, sd.gitcmd, sd.github)
 
-	index, ok := selector.AsPRSet(setIndex)
+	index, ok := selector.AsPRSetOrName(setIndex, sd.config.PRSetNames())
 	if !ok {
-		check(fmt.Errorf("unable to parse PR set index %s", setIndex))
+		return fmt.Errorf("unable to parse PR set index or name %s", setIndex)
 	}
 	sd.profiletimer.Step("MergePRSet::AsPRSet")
 
 	state, err := bl.NewReadState(ctx, sd.config, sd.gitcmd, sd.github)
-	check(err)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("MergePRSet::NewReadState")
 
 	// MergeCheck
@@ -291,16 +340,19 @@ func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
 		commits := state.CommitsByPRSet(index)
 		if len(commits) > 0 {
 			sd.profiletimer.Step("MergePRSet::GetInfo")
-			githubInfo := sd.github.GetInfo(ctx, sd.gitcmd)
+			githubInfo, err := sd.github.GetInfo(ctx, sd.gitcmd)
+			if err != nil {
+				return err
+			}
 			sd.profiletimer.Step("MergePRSet::GotInfo")
 			// Get the newest commit
 			lastCommit := state.CommitsByPRSet(index)[0]
 			checkedCommit, found := sd.config.State.MergeCheckCommit[githubInfo.Key()]
 
 			if !found {
-				check(errors.New("need to run merge check 'spr check' before merging"))
+				return errors.New("need to run merge check 'spr check' before merging")
 			} else if checkedCommit != "SKIP" && lastCommit.CommitHash != checkedCommit {
-				check(errors.New("need to run merge check 'spr check' before merging"))
+				return errors.New("need to run merge check 'spr check' before merging")
 			}
 			sd.profiletimer.Step("MergePRSet::MergeChecked")
 		}
@@ -308,7 +360,7 @@ func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
 
 	commits := state.CommitsByPRSet(index)
 	if len(commits) == 0 {
-		check(fmt.Errorf("invalid index %s", setIndex))
+		return fmt.Errorf("invalid index %s", setIndex)
 	}
 	// We want the oldest PR first so we preserve the PR links when updating it to merge to main/master
 	slices.Reverse(commits)
@@ -327,7 +379,7 @@ func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
 				return struct{}{}, fmt.Errorf("unable to merge oldest PR in PR set %w", err)
 			}
 
-			err = sd.gitcmd.Fetch(sd.config.Repo.GitHubRemote, true)
+			err = sd.gitcmd.Fetch(sd.config.Repo.UpstreamRemoteName(), true)
 			if err != nil {
 				return struct{}{}, fmt.Errorf("unable to fetch merge changes %w", err)
 			}
@@ -340,12 +392,28 @@ func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
 		}
 		return struct{}{}, err
 	})
-	check(err)
+	if err != nil {
+		return err
+	}
 
-	err = sd.gitcmd.Rebase(ctx, sd.config.Repo.GitHubRemote, sd.config.Repo.GitHubBranch)
-	check(err)
+	// The merged commits are no longer in a PR set, and the PR set no longer has a name
+	for _, ci := range commits {
+		ci.PRIndex = nil
+	}
+	maps.DeleteFunc(state.PRSetNames, func(_ string, prIndex int) bool { return prIndex == index })
+	sd.config.SetPRSetNames(state.PRSetNames)
+	err = state.PushPRSetRefs(sd.config, sd.gitcmd)
+	if err != nil {
+		return err
+	}
+
+	err = sd.gitcmd.Rebase(ctx, sd.config.Repo.UpstreamRemoteName(), sd.config.Repo.GitHubBranch)
+	if err != nil {
+		return err
+	}
 
 	sd.profiletimer.Step("MergePRSet::NewReadState")
+	return nil
 }
 
 // UpdatePRSets updatest the PR Sets given the selection.
@@ -353,33 +421,279 @@ func (sd *Stackediff) MergePRSet(ctx context.Context, setIndex string) {
 //   - If there are more than one PR in a PR set an index is included in the PR message showing the other PRs in the PR set
 //     with an arrow pointing to where you are.
 //   - If a new PR set overlaps with an existing one. The overlapped commits are pulled into the new PR set.
-func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
+//   - If the update fails the branches, PRs and PR set state it changed are rolled back.
+func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) error {
+	err := sd.transaction(ctx, "update "+sel, func(sd *Stackediff) error {
+		return sd.updatePRSets(ctx, selected(sel))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Display status
+	return sd.StatusCommitsAndPRSets(ctx)
+}
+
+// EditPRSets updates the PR sets with a plan edited in $EDITOR, like git rebase -i. The plan lists each commit with its
+// PR set, which can be changed to move the commit to another PR set, a new one or out of its PR set.
+func (sd *Stackediff) EditPRSets(ctx context.Context) error {
+	// The plan is edited without holding the lock, so the lock isn't held for as long as the editor is open
+	apply, err := sd.editPRSets(ctx)
+	if err != nil {
+		return err
+	}
+	if apply != nil {
+		err = sd.transaction(ctx, "update -i", func(sd *Stackediff) error {
+			return sd.updatePRSets(ctx, apply)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Display status
+	return sd.StatusCommitsAndPRSets(ctx)
+}
+
+// SplitPRSet splits the PR set into a new PR set for each of the selectors, which select commits in the PR set.
+// Commits that aren't selected stay in the PR set, or if all of them are selected the commits of the first selector
+// do. The commits keep their pull requests, which are retargeted to their new position.
+func (sd *Stackediff) SplitPRSet(ctx context.Context, setIndex string, sels []string) error {
+	err := sd.transaction(ctx, "split "+setIndex+" "+strings.Join(sels, " "), func(sd *Stackediff) error {
+		return sd.updatePRSets(ctx, split(setIndex, sels))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Display status
+	return sd.StatusCommitsAndPRSets(ctx)
+}
+
+// split returns the function that splits the PR set into the commits chosen by each selector
+func split(setIndex string, sels []string) func(state *bl.State) error {
+	return func(state *bl.State) error {
+		prIndex, ok := selector.AsPRSetOrName(setIndex, state.PRSetNames)
+		if !ok {
+			return fmt.Errorf("unable to parse PR set index or name %s", setIndex)
+		}
+		inPRSet := mapset.NewSet[int]()
+		for _, commit := range state.CommitsByPRSet(prIndex) {
+			inPRSet.Add(commit.Index)
+		}
+		if inPRSet.Cardinality() == 0 {
+			return fmt.Errorf("invalid index %s", setIndex)
+		}
+
+		parts := []mapset.Set[int]{}
+		selected := mapset.NewSet[int]()
+		for _, sel := range sels {
+			indices, err := selector.Evaluate(state.LocalCommits, state.PRSetNames, sel)
+			if err != nil {
+				return err
+			}
+			switch {
+			case indices.DestinationPRIndex != nil || indices.DestinationName != "":
+				return fmt.Errorf("the split selector %s can't have a destination PR set", sel)
+			case indices.CommitIndexes.Cardinality() == 0:
+				return fmt.Errorf("the split selector %s doesn't select any commits", sel)
+			case !indices.CommitIndexes.IsSubset(inPRSet):
+				return fmt.Errorf("the split selector %s selects commits that aren't in %s", sel, setIndex)
+			case selected.ContainsAny(indices.CommitIndexes.ToSlice()...):
+				return fmt.Errorf("the split selector %s selects commits that another selector selected", sel)
+			}
+			parts = append(parts, indices.CommitIndexes)
+			selected = selected.Union(indices.CommitIndexes)
+		}
+
+		state.SplitPRSet(prIndex, parts)
+		return nil
+	}
+}
+
+// CombinePRSets combines the PR sets into the first one. The commits keep their pull requests, which are restacked and
+// have their bodies rewritten to the combined stack order.
+func (sd *Stackediff) CombinePRSets(ctx context.Context, setIndexes []string) error {
+	err := sd.transaction(ctx, "combine "+strings.Join(setIndexes, " "), func(sd *Stackediff) error {
+		return sd.updatePRSets(ctx, combine(setIndexes))
+	})
+	if err != nil {
+		return err
+	}
+
+	// Display status
+	return sd.StatusCommitsAndPRSets(ctx)
+}
+
+// combine returns the function that moves the commits of the PR sets into the first PR set
+func combine(setIndexes []string) func(state *bl.State) error {
+	return func(state *bl.State) error {
+		prIndexes := []int{}
+		commits := mapset.NewSet[int]()
+		for _, setIndex := range setIndexes {
+			prIndex, ok := selector.AsPRSetOrName(setIndex, state.PRSetNames)
+			if !ok {
+				return fmt.Errorf("unable to parse PR set index or name %s", setIndex)
+			}
+			if slices.Contains(prIndexes, prIndex) {
+				return fmt.Errorf("PR set %s is given more than once", setIndex)
+			}
+			prSetCommits := state.CommitsByPRSet(prIndex)
+			if len(prSetCommits) == 0 {
+				return fmt.Errorf("invalid index %s", setIndex)
+			}
+			for _, commit := range prSetCommits {
+				commits.Add(commit.Index)
+			}
+			prIndexes = append(prIndexes, prIndex)
+		}
+
+		state.ApplyIndices(&bl.Indices{DestinationPRIndex: &prIndexes[0], CommitIndexes: commits})
+		return nil
+	}
+}
+
+// selected returns the function that applies the commits chosen by the selector to their PR set
+func selected(sel string) func(state *bl.State) error {
+	return func(state *bl.State) error {
+		indices, err := selector.Evaluate(state.LocalCommits, state.PRSetNames, sel)
+		if err != nil {
+			return err
+		}
+
+		// Update the commits PRIndex and tracked orphaned and mutated PR sets.
+		// Sets the indices.DestinationPRIndex if a new destination PRIndex is created
+		state.ApplyIndices(&indices)
+		return nil
+	}
+}
+
+// editPRSets opens the plan of the PR sets in the editor and returns the function that applies the edited plan to the
+// state, it is nil if the plan was emptied to abort the update. The plan is only applied if the PR sets are still the
+// ones it was made from when the state is read again under the lock.
+func (sd *Stackediff) editPRSets(ctx context.Context) (func(state *bl.State) error, error) {
+	// The commits need their commit-id to be listed in the plan
+	err := sd.locked(sd.gitcmd.AppendCommitId)
+	if err != nil {
+		return nil, err
+	}
+
+	// The state is read with a copy of the config, it is only saved once it is read again to be updated
+	cfg := *sd.config
+	cfg.State = sd.config.State.Clone()
+	state, err := bl.NewReadState(ctx, &cfg, sd.gitcmd, sd.github)
+	if err != nil {
+		return nil, err
+	}
+	if len(state.LocalCommits) == 0 {
+		return func(state *bl.State) error { return nil }, nil
+	}
+	formatted := prsetplan.Format(state)
+
+	file, err := os.CreateTemp("", "spr-prsets-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("creating the PR set plan %w", err)
+	}
+	defer os.Remove(file.Name())
+	_, err = file.WriteString(formatted)
+	err = errors.Join(err, file.Close())
+	if err != nil {
+		return nil, fmt.Errorf("writing the PR set plan %w", err)
+	}
+
+	// Like git the editor is run by the shell so it can have arguments
+	editor := cmp.Or(os.Getenv("EDITOR"), "vi")
+	cmd := exec.Command("sh", "-c", editor+` "$@"`, editor, file.Name())
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err = cmd.Run()
+	if err != nil {
+		return nil, fmt.Errorf("running the editor %s %w", editor, err)
+	}
+
+	edited, err := os.ReadFile(file.Name())
+	if err != nil {
+		return nil, fmt.Errorf("reading the PR set plan %w", err)
+	}
+	plan, err := prsetplan.Parse(state, string(edited))
+	if err != nil {
+		return nil, err
+	}
+	if plan == nil {
+		sd.Printer.Printf("the PR set plan is empty, nothing to update\n")
+		return nil, nil
+	}
+
+	return func(state *bl.State) error {
+		if prsetplan.Format(state) != formatted {
+			return fmt.Errorf("the commits or PR sets changed while the plan was edited, run spr update -i again")
+		}
+		prsetplan.Apply(state, plan)
+		return nil
+	}, nil
+}
+
+// PlanPRSets prints the changes UpdatePRSets would make given the selection without making them.
+// The update is run against recording git and github implementations and a copy of the state so nothing is pushed,
+// edited or saved.
+func (sd *Stackediff) PlanPRSets(ctx context.Context, sel string) error {
+	plan := dryrun.NewPlan()
+
+	cfg := *sd.config
+	cfg.State = sd.config.State.Clone()
+
+	dryRun := *sd
+	dryRun.config = &cfg
+	dryRun.gitcmd = dryrun.NewGit(sd.gitcmd, plan)
+	dryRun.github = dryrun.NewGitHub(sd.github, plan)
+	// The plan is computed under the lock so it doesn't see another spr process's update half done
+	err := sd.locked(func() error {
+		return dryRun.updatePRSets(ctx, selected(sel))
+	})
+	if err != nil {
+		return err
+	}
+
+	if plan.Empty() {
+		sd.Printer.Printf("dry run: nothing to update\n")
+		return nil
+	}
+	sd.Printer.Printf("dry run: spr update would\n")
+	plan.Print(sd.Printer)
+	return nil
+}
+
+// updatePRSets makes the changes for UpdatePRSets, apply changes the PR sets of the commits in the state
+func (sd *Stackediff) updatePRSets(ctx context.Context, apply func(state *bl.State) error) error {
 	sd.profiletimer.Step("UpdatePRSets::Start")
 	gitapi := gitapi.New(sd.config, sd.gitcmd, sd.github)
 
 	// Add the commit-id to any commits that don't have it yet.
-	sd.gitcmd.AppendCommitId()
+	err := sd.gitcmd.AppendCommitId()
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("UpdatePRSets::AppndCommitId")
 
 	// Fetch/Prune from github remote
 	awaitFetch := concurrent.Async2Ret1(
-		sd.gitcmd.Fetch,
-		sd.config.Repo.GitHubRemote,
+		sd.fetch,
+		sd.config.Repo.UpstreamRemoteName(),
 		true,
 	)
 
 	state, err := bl.NewReadState(ctx, sd.config, sd.gitcmd, sd.github)
-	check(err)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("UpdatePRSets::NewReadState")
 
-	// Compute the indices that will be included in the updated PR
-	indices, err := selector.Evaluate(state.LocalCommits, sel)
-	check(err)
-	sd.profiletimer.Step("UpdatePRSets::Evaluate")
-
-	// Update the commits PRIndex and tracked orphaned and mutated PR sets.
-	// Sets the indices.DestinationPRIndex if a new destination PRIndex is created
-	state.ApplyIndices(&indices)
+	// Compute the commits that will be included in the updated PRs and apply them to the PR sets
+	err = apply(state)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("UpdatePRSets::ApplyIndices")
 
 	// Handle reordered commits.
@@ -398,17 +712,20 @@ func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
 		_, err = concurrent.SliceMapWithIndex(commits, func(cindex int, ci *bl.LocalCommit) (struct{}, error) {
 			// Don't need to rework if no PR exists
 			if ci.PullRequest == nil {
-				return struct{}{}, err
+				return struct{}{}, nil
 			}
 
 			err := gitapi.UpdatePullRequestToMain(ctx, pullRequests, ci.PullRequest, ci.Commit)
 			return struct{}{}, err
 		})
+		if err != nil {
+			return err
+		}
 	}
 	sd.profiletimer.Step("UpdatePRSets::HandleRedorderdCommits")
 
 	// Delete orphaned PRs (along with the associated branches)
-	_, err = concurrent.SliceMap(state.OrphanedPRs.ToSlice(), func(pr *github.PullRequest) (struct{}, error) {
+	_, err = concurrent.SliceMap(state.OrphanedPRs.ToSlice(), func(pr *hosting.PullRequest) (struct{}, error) {
 		if pr == nil {
 			return struct{}{}, nil
 		}
@@ -416,17 +733,20 @@ func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
 		err := gitapi.DeletePullRequest(ctx, pr)
 		return struct{}{}, err
 	})
-	check(err)
+	if err != nil {
+		return err
+	}
 	state.OrphanedPRs.Clear()
 	sd.profiletimer.Step("UpdatePRSets::DeleteOrphanedPRs")
 
 	// Wait for the fetch/prune to complete
 	err = awaitFetch.Await()
-	check(err)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("UpdatePRSets::Fetch")
 
 	// Update all branches of the mutated PR sets
-	createdBranches := []string{}
 	for prSet := range state.MutatedPRSets.Iter() {
 		commits := state.CommitsByPRSet(prSet)
 		// Destination branch starts with the "main" branch.
@@ -439,13 +759,8 @@ func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
 
 			err := gitapi.CreateRemoteBranchWithCherryPick(ctx, branchName, destBranchName, commits[c].CommitHash)
 			if err != nil {
-				// Try to clean up any previously created branches.
-				for _, createdBranch := range createdBranches {
-					sd.gitcmd.DeleteRemoteBranch(ctx, createdBranch)
-				}
+				return err
 			}
-			check(err)
-			createdBranches = append(createdBranches, branchName)
 
 			destBranchName = branchName
 		}
@@ -472,7 +787,9 @@ func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
 			}
 
 			pr, err := gitapi.CreatePullRequest(ctx, state.ParentRepositoryId, state.RepositoryId, ci.Commit, parentBaseCommit)
-			check(err)
+			if err != nil {
+				return err
+			}
 			ci.PullRequest = pr
 		}
 
@@ -487,45 +804,77 @@ func (sd *Stackediff) UpdatePRSets(ctx context.Context, sel string) {
 			err := gitapi.UpdatePullRequest(ctx, pullRequests, ci.PullRequest, ci.Commit, parentBaseCommit)
 			return struct{}{}, err
 		})
+		if err != nil {
+			return err
+		}
 	}
 	sd.profiletimer.Step("UpdatePRSets::Update/CreatePRSets")
 
 	// Update persistent PR set state
 	state.UpdatePRSetState(sd.config)
+	err = state.PushPRSetRefs(sd.config, sd.gitcmd)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("UpdatePRSets::UpdatePRSetState")
-
-	// Display status
-	sd.StatusCommitsAndPRSets(ctx)
+	return nil
 }
 
 // StatusCommitsAndPRSets outputs the status of all commits and PR sets.
 // If a PR set is stored in state but no PR exists (like it was manually deleted from the github UI) then it will be
 // removed from state.
-func (sd *Stackediff) StatusCommitsAndPRSets(ctx context.Context) {
+func (sd *Stackediff) StatusCommitsAndPRSets(ctx context.Context) error {
+	return sd.StatusSelectedCommits(ctx, "")
+}
+
+// StatusSelectedCommits outputs the status of the commits chosen by the selector, like s1 or a PR set name, an empty
+// selector shows all of the commits.
+func (sd *Stackediff) StatusSelectedCommits(ctx context.Context, sel string) error {
 	sd.profiletimer.Step("StatusCommitsAndPRSets::Start")
 	state, err := bl.NewReadState(ctx, sd.config, sd.gitcmd, sd.github)
-	check(err)
+	if err != nil {
+		return err
+	}
 	sd.profiletimer.Step("StatusCommitsAndPRSets::NewReadState")
 
 	if state.Head() == nil {
 		sd.Printer.Printf("no local commits\n")
-		return
+		return nil
+	}
+
+	selected := func(*bl.LocalCommit) bool { return true }
+	if sel != "" {
+		indices, err := selector.Evaluate(state.LocalCommits, state.PRSetNames, sel)
+		if err != nil {
+			return err
+		}
+		if indices.DestinationPRIndex != nil || indices.DestinationName != "" {
+			return fmt.Errorf("the status selector %s can't have a destination PR set", sel)
+		}
+		selected = func(commit *bl.LocalCommit) bool { return indices.CommitIndexes.Contains(commit.Index) }
 	}
+
 	sd.Printer.Printf(Header(sd.config))
 	sd.profiletimer.Step("StatusCommitsAndPRSets::PrintDetails")
 	for this := range state.LocalCommitsIter() {
-		sd.Printer.Printf("%s\n", this.PRSetString(sd.config))
+		if selected(this) {
+			sd.Printer.Printf("%s\n", this.PRSetString(sd.config))
+		}
 	}
 	sd.profiletimer.Step("StatusCommitsAndPRSets::OutputStatus")
+	return nil
 }
 
 // StatusPullRequests fetches all the users pull requests from github and
 //
 //	prints out the status of each. It does not make any updates locally or
 //	remotely on github.
-func (sd *Stackediff) StatusPullRequests(ctx context.Context) {
+func (sd *Stackediff) StatusPullRequests(ctx context.Context) error {
 	sd.profiletimer.Step("StatusPullRequests::Start")
-	githubInfo := sd.github.GetInfo(ctx, sd.gitcmd)
+	githubInfo, err := sd.github.GetInfo(ctx, sd.gitcmd)
+	if err != nil {
+		return err
+	}
 
 	if len(githubInfo.PullRequests) == 0 {
 		sd.Printer.Printf("pull request stack is empty\n")
@@ -537,42 +886,144 @@ func (sd *Stackediff) StatusPullRequests(ctx context.Context) {
 		}
 	}
 	sd.profiletimer.Step("StatusPullRequests::End")
+	return nil
 }
 
 // SyncStack synchronizes your local stack with remote's
-func (sd *Stackediff) SyncStack(ctx context.Context) {
+func (sd *Stackediff) SyncStack(ctx context.Context) error {
+	return sd.journaled("sync", func(sd *Stackediff) error {
+		return sd.syncStack(ctx)
+	})
+}
+
+// syncStack makes the changes for SyncStack
+func (sd *Stackediff) syncStack(ctx context.Context) error {
 	sd.profiletimer.Step("SyncStack::Start")
 	defer sd.profiletimer.Step("SyncStack::End")
 
-	githubInfo := sd.github.GetInfo(ctx, sd.gitcmd)
+	githubInfo, err := sd.github.GetInfo(ctx, sd.gitcmd)
+	if err != nil {
+		return err
+	}
 
 	if len(githubInfo.PullRequests) == 0 {
 		sd.Printer.Printf("pull request stack is empty\n")
-		return
+		return nil
 	}
 
 	lastPR := githubInfo.PullRequests[len(githubInfo.PullRequests)-1]
 	syncCommand := fmt.Sprintf("cherry-pick ..%s", lastPR.Commit.CommitHash)
-	err := sd.gitcmd.Git(syncCommand, nil)
-	check(err)
+	return sd.gitcmd.Git(syncCommand, nil)
 }
 
-func (sd *Stackediff) RunMergeCheck(ctx context.Context) {
+// Undo reverts the changes made by the most recent update, merge or sync.
+// Remote branches are restored, pull requests are reopened or closed, the local branch is reset and the PR set state
+// is restored. Each undo reverts the command before the last one undone.
+func (sd *Stackediff) Undo(ctx context.Context) error {
+	return sd.locked(func() error {
+		return sd.undo(ctx)
+	})
+}
+
+// undo makes the changes for Undo
+func (sd *Stackediff) undo(ctx context.Context) error {
+	sd.profiletimer.Step("Undo::Start")
+	entry, err := journal.Last(sd.gitcmd)
+	if err != nil {
+		return err
+	}
+	if entry == nil {
+		sd.Printer.Printf("nothing to undo\n")
+		return nil
+	}
+
+	sd.Printer.Printf("undo spr %s\n", entry.Command)
+	err = entry.Undo(ctx, sd.config, sd.gitcmd, sd.github, sd.Printer)
+	if err != nil {
+		return err
+	}
+	sd.profiletimer.Step("Undo::Undo")
+
+	return journal.RemoveLast(sd.gitcmd)
+}
+
+// journaled runs fn against git and github implementations which record the previous state of everything fn changes
+// to the undo journal. The journal entry is saved even if fn fails so partial changes can be undone.
+func (sd *Stackediff) journaled(command string, fn func(sd *Stackediff) error) error {
+	return sd.locked(func() error {
+		entry, err := sd.recorded(command, fn)
+		if entry == nil {
+			return err
+		}
+		return errors.Join(err, journal.Record(sd.gitcmd, entry))
+	})
+}
+
+// transaction runs fn like journaled but if fn fails the remote changes it made are rolled back and the PR set state
+// is restored, so GitHub and the state are left as they were. The journal entry is only saved if the rollback fails.
+func (sd *Stackediff) transaction(ctx context.Context, command string, fn func(sd *Stackediff) error) error {
+	return sd.locked(func() error {
+		entry, err := sd.recorded(command, fn)
+		if entry == nil {
+			return err
+		}
+		if err == nil {
+			return journal.Record(sd.gitcmd, entry)
+		}
+
+		sd.Printer.Printf("spr %s failed, rolling back\n", command)
+		rollbackErr := entry.Rollback(ctx, sd.config, sd.gitcmd, sd.github, sd.Printer)
+		if rollbackErr != nil {
+			return errors.Join(err,
+				fmt.Errorf("rolling back, run spr undo to finish the rollback %w", rollbackErr),
+				journal.Record(sd.gitcmd, entry))
+		}
+		return err
+	})
+}
+
+// locked runs fn holding the repository's lock, so another spr process can't change the remote branches, pull requests
+// or journal at the same time
+func (sd *Stackediff) locked(fn func() error) error {
+	lock, err := journal.Lock(sd.gitcmd)
+	if err != nil {
+		return err
+	}
+	return errors.Join(fn(), lock.Release())
+}
+
+// recorded runs fn and returns the journal entry of the changes it made, the entry is nil if it couldn't be started
+func (sd *Stackediff) recorded(command string, fn func(sd *Stackediff) error) (*journal.Entry, error) {
+	entry, err := journal.Begin(sd.config, sd.gitcmd, command)
+	if err != nil {
+		return nil, err
+	}
+
+	recording := *sd
+	recording.gitcmd = journal.NewGit(sd.gitcmd, entry)
+	recording.github = journal.NewGitHub(sd.github, entry)
+	return entry, fn(&recording)
+}
+
+func (sd *Stackediff) RunMergeCheck(ctx context.Context) error {
 	sd.profiletimer.Step("RunMergeCheck::Start")
 	defer sd.profiletimer.Step("RunMergeCheck::End")
 
 	if sd.config.Repo.MergeCheck == "" {
 		fmt.Println("use MergeCheck to configure a pre merge check command to run")
-		return
+		return nil
 	}
 
 	localCommits := git.GetLocalCommitStack(sd.config, sd.gitcmd)
 	if len(localCommits) == 0 {
 		sd.Printer.Printf("no local commits - nothing to check\n")
-		return
+		return nil
 	}
 
-	githubInfo := sd.github.GetInfo(ctx, sd.gitcmd)
+	githubInfo, err := sd.github.GetInfo(ctx, sd.gitcmd)
+	if err != nil {
+		return err
+	}
 
 	sigch := make(chan os.Signal, 1)
 	signal.Notify(sigch, os.Interrupt, syscall.SIGTERM)
@@ -589,14 +1040,16 @@ func (sd *Stackediff) RunMergeCheck(ctx context.Context) {
 	cmd.Stdin = os.Stdin
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
-	err := cmd.Start()
-	check(err)
+	err = cmd.Start()
+	if err != nil {
+		return fmt.Errorf("starting merge check %w", err)
+	}
 
 	go func() {
 		_, ok := <-sigch
 		if ok {
-			err := cmd.Process.Signal(syscall.SIGKILL)
-			check(err)
+			// If the signal can't be delivered the process has already exited
+			cmd.Process.Signal(syscall.SIGKILL)
 		}
 	}()
 
@@ -604,17 +1057,22 @@ func (sd *Stackediff) RunMergeCheck(ctx context.Context) {
 
 	if err != nil {
 		sd.config.State.MergeCheckCommit[githubInfo.Key()] = ""
-		rake.LoadSources(sd.config.State,
-			rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+		writeErr := config_parser.WriteState(sd.config)
+		if writeErr != nil {
+			return writeErr
+		}
 		sd.Printer.Printf("MergeCheck FAILED: %s\n", err)
-		return
+		return nil
 	}
 
 	lastCommit := localCommits[len(localCommits)-1]
 	sd.config.State.MergeCheckCommit[githubInfo.Key()] = lastCommit.CommitHash
-	rake.LoadSources(sd.config.State,
-		rake.YamlFileWriter(config_parser.InternalConfigFilePath()))
+	err = config_parser.WriteState(sd.config)
+	if err != nil {
+		return err
+	}
 	sd.Printer.Printf("MergeCheck PASSED\n")
+	return nil
 }
 
 // ProfilingEnable enables stopwatch profiling
@@ -623,12 +1081,11 @@ func (sd *Stackediff) ProfilingEnable() {
 }
 
 // ProfilingSummary prints profiling info to stdout
-func (sd *Stackediff) ProfilingSummary() {
-	err := sd.profiletimer.ShowResults()
-	check(err)
+func (sd *Stackediff) ProfilingSummary() error {
+	return sd.profiletimer.ShowResults()
 }
 
-func commitsReordered(localCommits []git.Commit, pullRequests []*github.PullRequest) bool {
+func commitsReordered(localCommits []git.Commit, pullRequests []*hosting.PullRequest) bool {
 	for i := 0; i < len(pullRequests); i++ {
 		if localCommits[i].CommitID != pullRequests[i].Commit.CommitID {
 			return true
@@ -637,13 +1094,13 @@ func commitsReordered(localCommits []git.Commit, pullRequests []*github.PullRequ
 	return false
 }
 
-func sortPullRequestsByLocalCommitOrder(pullRequests []*github.PullRequest, localCommits []git.Commit) []*github.PullRequest {
-	pullRequestMap := map[string]*github.PullRequest{}
+func sortPullRequestsByLocalCommitOrder(pullRequests []*hosting.PullRequest, localCommits []git.Commit) []*hosting.PullRequest {
+	pullRequestMap := map[string]*hosting.PullRequest{}
 	for _, pullRequest := range pullRequests {
 		pullRequestMap[pullRequest.Commit.CommitID] = pullRequest
 	}
 
-	var sortedPullRequests []*github.PullRequest
+	var sortedPullRequests []*hosting.PullRequest
 	for _, commit := range localCommits {
 		if !commit.WIP && pullRequestMap[commit.CommitID] != nil {
 			sortedPullRequests = append(sortedPullRequests, pullRequestMap[commit.CommitID])
@@ -652,30 +1109,45 @@ func sortPullRequestsByLocalCommitOrder(pullRequests []*github.PullRequest, loca
 	return sortedPullRequests
 }
 
-func (sd *Stackediff) fetchAndGetGitHubInfo(ctx context.Context) *github.GitHubInfo {
+// fetch fetches the remote, along with the push remote when the spr branches are pushed to a fork
+func (sd *Stackediff) fetch(remoteName string, prune bool) error {
+	err := sd.gitcmd.Fetch(remoteName, prune)
+	if err != nil || !sd.config.Repo.ForkWorkflow() {
+		return err
+	}
+	return sd.gitcmd.Fetch(sd.config.Repo.PushRemoteName(), prune)
+}
+
+func (sd *Stackediff) fetchAndGetGitHubInfo(ctx context.Context) (*hosting.Info, error) {
+	fetchCommand := "fetch"
 	if sd.config.Repo.ForceFetchTags {
-		sd.gitcmd.MustGit("fetch --tags --force", nil)
-	} else {
-		sd.gitcmd.MustGit("fetch", nil)
+		fetchCommand = "fetch --tags --force"
+	}
+	err := sd.gitcmd.Git(fetchCommand, nil)
+	if err != nil {
+		return nil, fmt.Errorf("fetching %w", err)
 	}
 	rebaseCommand := fmt.Sprintf("rebase %s/%s --autostash",
-		sd.config.Repo.GitHubRemote, sd.config.Repo.GitHubBranch)
-	err := sd.gitcmd.Git(rebaseCommand, nil)
+		sd.config.Repo.UpstreamRemoteName(), sd.config.Repo.GitHubBranch)
+	err = sd.gitcmd.Git(rebaseCommand, nil)
 	if err != nil {
-		return nil
+		return nil, fmt.Errorf("rebasing on %s/%s %w", sd.config.Repo.UpstreamRemoteName(), sd.config.Repo.GitHubBranch, err)
 	}
-	info := sd.github.GetInfo(ctx, sd.gitcmd)
-	if git.BranchNameRegex.FindString(info.LocalBranch) != "" {
+	info, err := sd.github.GetInfo(ctx, sd.gitcmd)
+	if err != nil {
+		return nil, err
+	}
+	if git.CommitIdFromBranchName(sd.config, info.LocalBranch) != "" {
 		sd.Printer.Printf("error: don't run spr in a remote pr branch\n").
 			Printf(" this could lead to weird duplicate pull requests getting created\n").
 			Printf(" in general there is no need to checkout remote branches used for prs\n").
 			Printf(" instead use local branches and run spr update to sync your commit stack\n").
 			Printf("  with your pull requests on github\n").
 			Printf("branch name: %s\n", info.LocalBranch)
-		return nil
+		return nil, nil
 	}
 
-	return info
+	return info, nil
 }
 
 // syncCommitStackToGitHub gets all the local commits in the given branch
@@ -683,19 +1155,27 @@ func (sd *Stackediff) fetchAndGetGitHubInfo(ctx context.Context) *github.GitHubI
 //	which are new (on top of remote branch) and creates a corresponding
 //	branch on github for each commit.
 func (sd *Stackediff) syncCommitStackToGitHub(ctx context.Context,
-	commits []git.Commit, info *github.GitHubInfo) bool {
+	commits []git.Commit, info *hosting.Info) (err error) {
 
 	var output string
-	sd.gitcmd.MustGit("status --porcelain --untracked-files=no", &output)
+	err = sd.gitcmd.Git("status --porcelain --untracked-files=no", &output)
+	if err != nil {
+		return fmt.Errorf("getting the status %w", err)
+	}
 	if output != "" {
-		err := sd.gitcmd.Git("stash", nil)
+		err = sd.gitcmd.Git("stash", nil)
 		if err != nil {
-			return false
+			return fmt.Errorf("stashing changes %w", err)
 		}
-		defer sd.gitcmd.MustGit("stash pop", nil)
+		defer func() {
+			popErr := sd.gitcmd.Git("stash pop", nil)
+			if err == nil && popErr != nil {
+				err = fmt.Errorf("restoring stashed changes %w", popErr)
+			}
+		}()
 	}
 
-	commitUpdated := func(c git.Commit, info *github.GitHubInfo) bool {
+	commitUpdated := func(c git.Commit, info *hosting.Info) bool {
 		for _, pr := range info.PullRequests {
 			if pr.Commit.CommitID == c.CommitID {
 				return pr.Commit.CommitHash != c.CommitHash
@@ -724,27 +1204,23 @@ func (sd *Stackediff) syncCommitStackToGitHub(ctx context.Context,
 	if len(updatedCommits) > 0 {
 		if sd.config.Repo.BranchPushIndividually {
 			for _, refName := range refNames {
-				pushCommand := fmt.Sprintf("push --force %s %s", sd.config.Repo.GitHubRemote, refName)
-				sd.gitcmd.MustGit(pushCommand, nil)
+				pushCommand := fmt.Sprintf("push --force %s %s", sd.config.Repo.PushRemoteName(), refName)
+				err = sd.gitcmd.Git(pushCommand, nil)
+				if err != nil {
+					return fmt.Errorf("pushing %s %w", refName, err)
+				}
 			}
 		} else {
-			pushCommand := fmt.Sprintf("push --force --atomic %s ", sd.config.Repo.GitHubRemote)
+			pushCommand := fmt.Sprintf("push --force --atomic %s ", sd.config.Repo.PushRemoteName())
 			pushCommand += strings.Join(refNames, " ")
-			sd.gitcmd.MustGit(pushCommand, nil)
+			err = sd.gitcmd.Git(pushCommand, nil)
+			if err != nil {
+				return fmt.Errorf("pushing branches %w", err)
+			}
 		}
 	}
 	sd.profiletimer.Step("SyncCommitStack::PushBranches")
-	return true
-}
-
-func check(err error) {
-	if err != nil {
-		if os.Getenv("SPR_DEBUG") == "1" {
-			panic(err)
-		}
-		fmt.Printf("error: %s\n", err)
-		os.Exit(1)
-	}
+	return nil
 }
 
 func Header(config *config.Config) string {
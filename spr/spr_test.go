@@ -9,10 +9,11 @@ import (
 	"github.com/ejoffe/spr/config"
 	"github.com/ejoffe/spr/git"
 	"github.com/ejoffe/spr/git/mockgit"
-	"github.com/ejoffe/spr/github"
 	"github.com/ejoffe/spr/github/mockclient"
+	"github.com/ejoffe/spr/hosting"
 	"github.com/ejoffe/spr/mock"
 	"github.com/ejoffe/spr/output/mockoutput"
+	"github.com/stretchr/testify/require"
 )
 
 func makeTestObjects(t *testing.T, synchronized bool) (
@@ -27,7 +28,7 @@ func makeTestObjects(t *testing.T, synchronized bool) (
 	expectations := mock.New(t, synchronized)
 	gitmock = mockgit.NewMockGit(expectations)
 	githubmock = mockclient.NewMockClient(expectations)
-	githubmock.Info = &github.GitHubInfo{
+	githubmock.Info = &hosting.Info{
 		UserName:     "TestSPR",
 		RepositoryID: "RepoID",
 		LocalBranch:  "master",
@@ -76,7 +77,7 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 
 		// 'git spr status' :: StatusPullRequest
 		githubmock.ExpectGetInfo()
-		s.StatusPullRequests(ctx)
+		require.NoError(t, s.StatusPullRequests(ctx))
 		capout.ExpectString("pull request stack is empty\n")
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
@@ -92,11 +93,11 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 		githubmock.ExpectAddReviewers([]string{mockclient.NobodyUserID})
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
-		pr := github.PullRequest{
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
+		pr := hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -120,13 +121,13 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectUpdatePullRequest(c2, &c1)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n")
 		capout.ExpectString(Header(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -134,10 +135,10 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 			Title: "test commit 2",
 		}
 		capout.ExpectString(pr.Stringer(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -169,15 +170,15 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c3, &c2)
 		githubmock.ExpectUpdatePullRequest(c4, &c3)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString(Header(s.config))
 		for i := 4; i > 0; i-- {
-			pr = github.PullRequest{
+			pr = hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -202,7 +203,7 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c4, &c3)
 		githubmock.ExpectGetInfo()
 
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString("warning: not updating reviewers for PR #1\n").
@@ -213,10 +214,10 @@ func testSPRBasicFlowFourCommitsQueue(t *testing.T, sync bool) {
 				commits = []git.Commit{{}, {}}
 			}
 
-			pr = github.PullRequest{
+			pr = hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -265,7 +266,7 @@ func testSPRBasicFlowFourCommits(t *testing.T, sync bool) {
 
 		// 'git spr status' :: StatusPullRequest
 		githubmock.ExpectGetInfo()
-		s.StatusPullRequests(ctx)
+		require.NoError(t, s.StatusPullRequests(ctx))
 		capout.ExpectString("pull request stack is empty\n")
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
@@ -281,12 +282,12 @@ func testSPRBasicFlowFourCommits(t *testing.T, sync bool) {
 		githubmock.ExpectAddReviewers([]string{mockclient.NobodyUserID})
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString(Header(s.config))
-		pr := github.PullRequest{
+		pr := hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -309,13 +310,13 @@ func testSPRBasicFlowFourCommits(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectUpdatePullRequest(c2, &c1)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n")
 		capout.ExpectString(Header(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -323,10 +324,10 @@ func testSPRBasicFlowFourCommits(t *testing.T, sync bool) {
 			Title: "test commit 2",
 		}
 		capout.ExpectString(pr.Stringer(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -358,15 +359,15 @@ func testSPRBasicFlowFourCommits(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c3, &c2)
 		githubmock.ExpectUpdatePullRequest(c4, &c3)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString("warning: not updating reviewers for PR #1\n").
 			ExpectString(Header(s.config))
 		for i := 4; i > 0; i-- {
-			pr = github.PullRequest{
+			pr = hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -412,12 +413,12 @@ func testSPRBasicFlowDeleteBranch(t *testing.T, sync bool) {
 		githubmock.ExpectAddReviewers([]string{mockclient.NobodyUserID})
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString(Header(s.config))
-		pr := github.PullRequest{
+		pr := hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -440,13 +441,13 @@ func testSPRBasicFlowDeleteBranch(t *testing.T, sync bool) {
 		githubmock.ExpectUpdatePullRequest(c1, nil)
 		githubmock.ExpectUpdatePullRequest(c2, &c1)
 		githubmock.ExpectGetInfo()
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		capout.ExpectString("warning: not updating reviewers for PR #1\n")
 		capout.ExpectString(Header(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -454,10 +455,10 @@ func testSPRBasicFlowDeleteBranch(t *testing.T, sync bool) {
 			Title: "test commit 2",
 		}
 		capout.ExpectString(pr.Stringer(s.config))
-		pr = github.PullRequest{
+		pr = hosting.PullRequest{
 			Number: 1,
-			MergeStatus: github.PullRequestMergeStatus{
-				ChecksPass:     github.CheckStatusPass,
+			MergeStatus: hosting.PullRequestMergeStatus{
+				ChecksPass:     hosting.CheckStatusPass,
 				ReviewApproved: true,
 				NoConflicts:    true,
 				Stacked:        true,
@@ -524,10 +525,10 @@ func testSPRMergeCount(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for i := 4; i > 0; i-- {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -536,7 +537,7 @@ func testSPRMergeCount(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, []string{mockclient.NobodyLogin}, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -566,7 +567,7 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 
 		// 'git spr state' :: StatusPullRequest
 		githubmock.ExpectGetInfo()
-		s.StatusPullRequests(ctx)
+		require.NoError(t, s.StatusPullRequests(ctx))
 		capout.ExpectString("pull request stack is empty\n")
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
@@ -584,10 +585,10 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -596,7 +597,7 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -613,10 +614,10 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -625,7 +626,7 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -643,10 +644,10 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -655,7 +656,7 @@ func testSPRAmendCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -700,7 +701,7 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 
 		// 'git spr status' :: StatusPullRequest
 		githubmock.ExpectGetInfo()
-		s.StatusPullRequests(ctx)
+		require.NoError(t, s.StatusPullRequests(ctx))
 		capout.ExpectString("pull request stack is empty\n")
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
@@ -722,10 +723,10 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{4, 3, 2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -734,7 +735,7 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -760,10 +761,10 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{4, 3, 2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -772,7 +773,7 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -800,10 +801,10 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{5, 4, 3, 2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -812,7 +813,7 @@ func testSPRReorderCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -854,7 +855,7 @@ func testSPRDeleteCommit(t *testing.T, sync bool) {
 
 		// 'git spr status' :: StatusPullRequest
 		githubmock.ExpectGetInfo()
-		s.StatusPullRequests(ctx)
+		require.NoError(t, s.StatusPullRequests(ctx))
 		capout.ExpectString("pull request stack is empty\n")
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
@@ -876,10 +877,10 @@ func testSPRDeleteCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{4, 3, 2, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -889,7 +890,7 @@ func testSPRDeleteCommit(t *testing.T, sync bool) {
 			capout.ExpectString(pr.Stringer(s.config))
 		}
 
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -911,10 +912,10 @@ func testSPRDeleteCommit(t *testing.T, sync bool) {
 		githubmock.ExpectGetInfo()
 		capout.ExpectString(Header(s.config))
 		for _, i := range []int{4, 1} {
-			pr := github.PullRequest{
+			pr := hosting.PullRequest{
 				Number: 1,
-				MergeStatus: github.PullRequestMergeStatus{
-					ChecksPass:     github.CheckStatusPass,
+				MergeStatus: hosting.PullRequestMergeStatus{
+					ChecksPass:     hosting.CheckStatusPass,
 					ReviewApproved: true,
 					NoConflicts:    true,
 					Stacked:        true,
@@ -923,7 +924,7 @@ func testSPRDeleteCommit(t *testing.T, sync bool) {
 			}
 			capout.ExpectString(pr.Stringer(s.config))
 		}
-		s.UpdatePullRequests(ctx, nil, nil)
+		require.NoError(t, s.UpdatePullRequests(ctx, nil, nil))
 		gitmock.ExpectationsMet()
 		githubmock.ExpectationsMet()
 		capout.ExpectationsMet()
@@ -943,7 +944,7 @@ func testAmendNoCommits(t *testing.T, sync bool) {
 		ctx := context.Background()
 
 		gitmock.ExpectLogAndRespond([]*git.Commit{})
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString("No commits to amend\n")
 		capout.ExpectationsMet()
 	})
@@ -967,7 +968,7 @@ func testAmendOneCommit(t *testing.T, sync bool) {
 		gitmock.ExpectLogAndRespond([]*git.Commit{&c1})
 		gitmock.ExpectFixup(c1.CommitHash)
 		input.WriteString("1")
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString(" 1 : 00000001 : test commit 1\n").
 			ExpectString("Commit to amend (1): ")
 		capout.ExpectationsMet()
@@ -997,7 +998,7 @@ func testAmendTwoCommits(t *testing.T, sync bool) {
 		gitmock.ExpectLogAndRespond([]*git.Commit{&c1, &c2})
 		gitmock.ExpectFixup(c2.CommitHash)
 		input.WriteString("1")
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString(" 2 : 00000001 : test commit 1\n").
 			ExpectString(" 1 : 00000002 : test commit 2\n").
 			ExpectString("Commit to amend (1-2): ")
@@ -1023,7 +1024,7 @@ func testAmendInvalidInput(t *testing.T, sync bool) {
 
 		gitmock.ExpectLogAndRespond([]*git.Commit{&c1})
 		input.WriteString("a")
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString(" 1 : 00000001 : test commit 1\n").
 			ExpectString("Commit to amend (1): ").
 			ExpectString("InvalidInput\n")
@@ -1032,7 +1033,7 @@ func testAmendInvalidInput(t *testing.T, sync bool) {
 
 		gitmock.ExpectLogAndRespond([]*git.Commit{&c1})
 		input.WriteString("0")
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString(" 1 : 00000001 : test commit 1\n").
 			ExpectString("Commit to amend (1): ").
 			ExpectString("InvalidInput\n")
@@ -1041,7 +1042,7 @@ func testAmendInvalidInput(t *testing.T, sync bool) {
 
 		gitmock.ExpectLogAndRespond([]*git.Commit{&c1})
 		input.WriteString("2")
-		s.AmendCommit(ctx)
+		require.NoError(t, s.AmendCommit(ctx))
 		capout.ExpectString(" 1 : 00000001 : test commit 1\n").
 			ExpectString("Commit to amend (1): ").
 			ExpectString("InvalidInput\n")